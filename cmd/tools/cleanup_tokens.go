@@ -5,7 +5,11 @@ import (
 	"log"
 	"time"
 
+	emaillinkpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink/postgres"
 	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	oauthpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2/postgres"
+	oauthserverpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver/postgres"
+	webauthnpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn/postgres"
 	"github.com/NBDor/Go-Auth-Service/internal/database"
 )
 
@@ -34,4 +38,76 @@ func main() {
 	}
 
 	log.Printf("Successfully removed %d expired tokens", count)
+
+	// Cleanup expired refresh tokens
+	refreshStore := postgres.NewRefreshTokenStore(db)
+	count, err = refreshStore.CleanupExpiredTokens(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup refresh tokens: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired refresh tokens", count)
+
+	// Cleanup expired sessions
+	sessionStore := postgres.NewSessionStore(db)
+	count, err = sessionStore.CleanupExpiredSessions(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup sessions: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired sessions", count)
+
+	// Cleanup expired one-time tokens
+	oneTimeStore := postgres.NewOneTimeTokenStore(db)
+	count, err = oneTimeStore.CleanupExpiredTokens(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup one-time tokens: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired one-time tokens", count)
+
+	// Cleanup expired login attempt counters
+	attemptStore := postgres.NewLoginAttemptStore(db)
+	count, err = attemptStore.CleanupExpiredAttempts(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup login attempts: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired login attempt records", count)
+
+	// Cleanup expired WebAuthn challenges
+	challengeStore := webauthnpg.NewChallengeStore(db)
+	count, err = challengeStore.CleanupExpiredChallenges(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup WebAuthn challenges: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired WebAuthn challenges", count)
+
+	// Cleanup expired OpenID Connect login states
+	stateStore := oauthpg.NewStateStore(db)
+	count, err = stateStore.CleanupExpiredStates(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup OIDC login states: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired OIDC login states", count)
+
+	// Cleanup expired login links
+	linkStore := emaillinkpg.NewLinkStore(db)
+	count, err = linkStore.CleanupExpiredLinks(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup login links: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired login links", count)
+
+	// Cleanup expired OAuth authorization codes
+	codeStore := oauthserverpg.NewCodeStore(db)
+	count, err = codeStore.CleanupExpiredCodes(ctx)
+	if err != nil {
+		log.Fatalf("Failed to cleanup authorization codes: %v", err)
+	}
+
+	log.Printf("Successfully removed %d expired authorization codes", count)
 }
@@ -0,0 +1,49 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrKeyNotFound = errors.New("API key not found")
+	ErrKeyExists   = errors.New("API key already exists")
+)
+
+// APIKey is a key a user created for a machine client. The key itself is only
+// shown when it's created; the store keeps a hash of it.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string   // Label chosen by the user, e.g. "Nightly export"
+	Prefix     string   // Public start of the key, e.g. "ak_3f9c2a1b7d4e", used to look it up and shown in listings
+	KeyHash    string   // Hex SHA-256 of the whole key
+	Scopes     []string // What the key may be used for; checked by the services accepting it
+	CreatedAt  time.Time
+	ExpiresAt  time.Time // Zero for keys that don't expire
+	LastUsedAt time.Time // Zero until the key is first used
+}
+
+// reports whether the key has expired at the given time
+func (k *APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeyStore persists API keys
+type KeyStore interface {
+	// returns ErrKeyExists if a key with the same ID or prefix exists
+	Create(ctx context.Context, key *APIKey) error
+	
+	// returns ErrKeyNotFound if no key has the prefix
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	
+	// returns the user's keys, oldest first
+	ListByUser(ctx context.Context, userID string) ([]*APIKey, error)
+	
+	// records a use of the key
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+	
+	// removes one of the user's keys; returns ErrKeyNotFound if the user has no such key
+	Delete(ctx context.Context, userID string, id string) error
+}
@@ -0,0 +1,146 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidName   = errors.New("API key name must be 1 to 100 characters")
+	ErrInvalidScope  = errors.New("invalid API key scope")
+	ErrInvalidExpiry = errors.New("invalid API key expiry")
+	ErrTooManyKeys   = errors.New("too many API keys")
+)
+
+const (
+	lookupIDSize = 6  // Random bytes in the public lookup ID
+	secretSize   = 32 // Random bytes in the secret part of a key
+)
+
+// creates an API key for a user and returns it with its stored form. The key is
+// only available now; the store keeps its hash. A zero expiresAt means the longest
+// lifetime allowed, which is no expiry unless MaxLifetime is set.
+// Directory users only log in through the directory and get local.ErrDirectoryManaged.
+func (p *Provider) CreateKey(ctx context.Context, userID string, name string, scopes []string, expiresAt time.Time) (string, *APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", nil, ErrInvalidName
+	}
+	
+	scopes, err := p.checkScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	
+	now := time.Now()
+	if expiresAt.IsZero() && p.config.MaxLifetime > 0 {
+		expiresAt = now.Add(p.config.MaxLifetime)
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return "", nil, fmt.Errorf("%w: must be in the future", ErrInvalidExpiry)
+	}
+	if p.config.MaxLifetime > 0 && expiresAt.After(now.Add(p.config.MaxLifetime)) {
+		return "", nil, fmt.Errorf("%w: keys can be valid for at most %s", ErrInvalidExpiry, p.config.MaxLifetime)
+	}
+	
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if local.DirectoryManaged(user) {
+		return "", nil, local.ErrDirectoryManaged
+	}
+	
+	existing, err := p.keyStore.ListByUser(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if p.config.MaxKeysPerUser > 0 && len(existing) >= p.config.MaxKeysPerUser {
+		return "", nil, fmt.Errorf("%w: a user can have at most %d", ErrTooManyKeys, p.config.MaxKeysPerUser)
+	}
+	
+	// A lookup ID collision is unlikely, but would otherwise fail the request
+	for attempt := 1; ; attempt++ {
+		token, prefix, err := p.generateKey()
+		if err != nil {
+			return "", nil, err
+		}
+	
+		key := &APIKey{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			Name:      name,
+			Prefix:    prefix,
+			KeyHash:   hashKey(token),
+			Scopes:    scopes,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		}
+		err = p.keyStore.Create(ctx, key)
+		if errors.Is(err, ErrKeyExists) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return token, key, nil
+	}
+}
+
+// returns the user's API keys, oldest first
+func (p *Provider) ListKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	return p.keyStore.ListByUser(ctx, userID)
+}
+
+// deletes one of the user's API keys; returns ErrKeyNotFound if the user has no such key
+func (p *Provider) DeleteKey(ctx context.Context, userID string, id string) error {
+	return p.keyStore.Delete(ctx, userID, id)
+}
+
+// checks requested scopes against the allowed ones and removes duplicates
+func (p *Provider) checkScopes(scopes []string) ([]string, error) {
+	checked := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope == "" || strings.IndexFunc(scope, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if len(p.config.AllowedScopes) > 0 && !contains(p.config.AllowedScopes, scope) {
+			return nil, fmt.Errorf("%w: %q is not allowed", ErrInvalidScope, scope)
+		}
+		if !contains(checked, scope) {
+			checked = append(checked, scope)
+		}
+	}
+	return checked, nil
+}
+
+// generates a new key and returns it with its public prefix. Keys look like
+// <KeyPrefix><lookup ID in hex>_<secret in base64url>.
+func (p *Provider) generateKey() (string, string, error) {
+	random := make([]byte, lookupIDSize+secretSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	
+	prefix := p.config.KeyPrefix + hex.EncodeToString(random[:lookupIDSize])
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(random[lookupIDSize:]), prefix, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package apikey
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryKeyStore implements KeyStore with in-memory storage
+type MemoryKeyStore struct {
+	keys     map[string]*APIKey // Indexed by ID
+	prefixes map[string]string  // Prefix to ID
+	mu       sync.RWMutex
+}
+
+// NewMemoryKeyStore creates a new in-memory API key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys:     make(map[string]*APIKey),
+		prefixes: make(map[string]string),
+	}
+}
+
+// Create stores a new key
+func (s *MemoryKeyStore) Create(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.keys[key.ID]; exists {
+		return ErrKeyExists
+	}
+	if _, exists := s.prefixes[key.Prefix]; exists {
+		return ErrKeyExists
+	}
+	
+	s.keys[key.ID] = cloneKey(key)
+	s.prefixes[key.Prefix] = key.ID
+	return nil
+}
+
+// GetByPrefix retrieves a key by its public prefix
+func (s *MemoryKeyStore) GetByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	id, exists := s.prefixes[prefix]
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+	
+	return cloneKey(s.keys[id]), nil
+}
+
+// ListByUser returns the user's keys, oldest first
+func (s *MemoryKeyStore) ListByUser(ctx context.Context, userID string) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	keys := make([]*APIKey, 0)
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			keys = append(keys, cloneKey(key))
+		}
+	}
+	
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Touch records a use of the key
+func (s *MemoryKeyStore) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	key, exists := s.keys[id]
+	if !exists {
+		return ErrKeyNotFound
+	}
+	
+	key.LastUsedAt = usedAt
+	return nil
+}
+
+// Delete removes one of the user's keys
+func (s *MemoryKeyStore) Delete(ctx context.Context, userID string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	key, exists := s.keys[id]
+	if !exists || key.UserID != userID {
+		return ErrKeyNotFound
+	}
+	
+	delete(s.prefixes, key.Prefix)
+	delete(s.keys, id)
+	return nil
+}
+
+// cloneKey returns a copy so callers can't modify stored keys
+func cloneKey(key *APIKey) *APIKey {
+	keyCopy := *key
+	keyCopy.Scopes = append([]string(nil), key.Scopes...)
+	return &keyCopy
+}
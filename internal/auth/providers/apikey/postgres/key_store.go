@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// KeyStore implements apikey.KeyStore with PostgreSQL
+type KeyStore struct {
+	db *sqlx.DB
+}
+
+// keyRow represents a row in the api_keys table
+type keyRow struct {
+	ID         string         `db:"id"`
+	UserID     string         `db:"user_id"`
+	Name       string         `db:"name"`
+	Prefix     string         `db:"prefix"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	CreatedAt  time.Time      `db:"created_at"`
+	ExpiresAt  sql.NullTime   `db:"expires_at"`
+	LastUsedAt sql.NullTime   `db:"last_used_at"`
+}
+
+// NewKeyStore creates a new PostgreSQL-backed API key store
+func NewKeyStore(db *sqlx.DB) *KeyStore {
+	return &KeyStore{
+		db: db,
+	}
+}
+
+// Create stores a new key
+func (s *KeyStore) Create(ctx context.Context, key *apikey.APIKey) error {
+	scopes := pq.StringArray(key.Scopes)
+	if scopes == nil {
+		scopes = pq.StringArray{}
+	}
+	
+	expiresAt := sql.NullTime{Time: key.ExpiresAt, Valid: !key.ExpiresAt.IsZero()}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, scopes, key.CreatedAt, expiresAt)
+	
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return apikey.ErrKeyExists
+	}
+	return err
+}
+
+// GetByPrefix retrieves a key by its public prefix
+func (s *KeyStore) GetByPrefix(ctx context.Context, prefix string) (*apikey.APIKey, error) {
+	var row keyRow
+	err := s.db.GetContext(ctx, &row, "SELECT * FROM api_keys WHERE prefix = $1", prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apikey.ErrKeyNotFound
+		}
+		return nil, err
+	}
+	
+	return row.toKey(), nil
+}
+
+// ListByUser returns the user's keys, oldest first
+func (s *KeyStore) ListByUser(ctx context.Context, userID string) ([]*apikey.APIKey, error) {
+	var rows []keyRow
+	err := s.db.SelectContext(ctx, &rows,
+		"SELECT * FROM api_keys WHERE user_id = $1 ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	keys := make([]*apikey.APIKey, 0, len(rows))
+	for i := range rows {
+		keys = append(keys, rows[i].toKey())
+	}
+	return keys, nil
+}
+
+// Touch records a use of the key
+func (s *KeyStore) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", usedAt, id)
+	if err != nil {
+		return err
+	}
+	
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return apikey.ErrKeyNotFound
+	}
+	return nil
+}
+
+// Delete removes one of the user's keys
+func (s *KeyStore) Delete(ctx context.Context, userID string, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM api_keys WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return apikey.ErrKeyNotFound
+	}
+	return nil
+}
+
+// toKey converts a database row to an API key
+func (row *keyRow) toKey() *apikey.APIKey {
+	key := &apikey.APIKey{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		Name:      row.Name,
+		Prefix:    row.Prefix,
+		KeyHash:   row.KeyHash,
+		Scopes:    row.Scopes,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		key.ExpiresAt = row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		key.LastUsedAt = row.LastUsedAt.Time
+	}
+	return key
+}
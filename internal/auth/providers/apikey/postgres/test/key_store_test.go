@@ -0,0 +1,107 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	localpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDatabase connects to the test database and creates a user to own keys
+func setupDatabase(t *testing.T) (*sqlx.DB, *local.StoredUser) {
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	userStore := localpg.NewSQLUserStore(db)
+	suffix := time.Now().Format("20060102150405.000000")
+	user := &local.StoredUser{
+		Username:     "apikey-" + suffix,
+		Email:        "apikey-" + suffix + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	t.Cleanup(func() { userStore.Delete(context.Background(), user.ID) })
+	
+	return db, user
+}
+
+func TestPostgresKeyStore(t *testing.T) {
+	db, user := setupDatabase(t)
+	store := postgres.NewKeyStore(db)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	suffix := now.Format("150405.000000")
+	
+	// 1. Store and read back keys, with and without an expiry
+	key := &apikey.APIKey{
+		ID:        "key-1-" + user.ID[:8],
+		UserID:    user.ID,
+		Name:      "Nightly export",
+		Prefix:    "ak_1-" + suffix,
+		KeyHash:   "hash-1",
+		Scopes:    []string{"read", "export"},
+		CreatedAt: now,
+		ExpiresAt: now.Add(24 * time.Hour),
+	}
+	require.NoError(t, store.Create(ctx, key))
+	assert.ErrorIs(t, store.Create(ctx, key), apikey.ErrKeyExists)
+	
+	require.NoError(t, store.Create(ctx, &apikey.APIKey{
+		ID:        "key-2-" + user.ID[:8],
+		UserID:    user.ID,
+		Name:      "Backups",
+		Prefix:    "ak_2-" + suffix,
+		KeyHash:   "hash-2",
+		CreatedAt: now.Add(time.Second),
+	}))
+	
+	stored, err := store.GetByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, stored.ID)
+	assert.Equal(t, "hash-1", stored.KeyHash)
+	assert.Equal(t, []string{"read", "export"}, stored.Scopes)
+	assert.WithinDuration(t, key.ExpiresAt, stored.ExpiresAt, time.Second)
+	assert.True(t, stored.LastUsedAt.IsZero())
+	
+	_, err = store.GetByPrefix(ctx, "ak_unknown")
+	assert.ErrorIs(t, err, apikey.ErrKeyNotFound)
+	
+	// 2. Record a use
+	require.NoError(t, store.Touch(ctx, key.ID, now))
+	assert.ErrorIs(t, store.Touch(ctx, "unknown", now), apikey.ErrKeyNotFound)
+	
+	keys, err := store.ListByUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "Nightly export", keys[0].Name)
+	assert.WithinDuration(t, now, keys[0].LastUsedAt, time.Second)
+	assert.Equal(t, "Backups", keys[1].Name)
+	assert.Empty(t, keys[1].Scopes)
+	assert.True(t, keys[1].ExpiresAt.IsZero())
+	
+	// 3. Only the owner can delete a key
+	assert.ErrorIs(t, store.Delete(ctx, "someone-else", key.ID), apikey.ErrKeyNotFound)
+	require.NoError(t, store.Delete(ctx, user.ID, key.ID))
+	assert.ErrorIs(t, store.Delete(ctx, user.ID, key.ID), apikey.ErrKeyNotFound)
+}
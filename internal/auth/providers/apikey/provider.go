@@ -0,0 +1,225 @@
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+)
+
+type Config struct {
+	KeyPrefix string // Start of every key, so keys can be told apart from JWTs and found by secret scanners
+	
+	AllowedScopes []string // Scopes keys may have; any scope if empty
+	
+	MaxKeysPerUser int // Keys a user may have at once, expired ones included
+	
+	MaxLifetime time.Duration // Longest lifetime a key may have; 0 allows keys that never expire
+	
+	LastUsedInterval time.Duration // Uses of a key closer together than this aren't recorded, to save writes
+}
+
+// returns the configuration with keys that may never expire
+func DefaultConfig() Config {
+	return Config{
+		KeyPrefix:        "ak_",
+		MaxKeysPerUser:   25,
+		LastUsedInterval: time.Minute,
+	}
+}
+
+// authenticates machine clients with API keys created by the users of the local provider.
+// A key acts as the user who created it, limited to its scopes. The provider doesn't enforce
+// scopes itself: callers serving a key's requests must check the "scopes" in the user's metadata.
+// Other tokens, like the local provider's JWTs, are passed on to the tokens provider, so
+// ValidateToken accepts both.
+type Provider struct {
+	config          Config
+	userStore       local.UserStore
+	tokens          auth.Provider
+	keyStore        KeyStore
+	revocationStore local.TokenRevocationStore
+}
+
+// Option configures optional provider dependencies
+type Option func(*Provider)
+
+// stores API keys in the given store instead of in memory
+func WithKeyStore(store KeyStore) Option {
+	return func(p *Provider) {
+		p.keyStore = store
+	}
+}
+
+// checks keys against the users' "logout everywhere" watermarks in the given store, usually the
+// local provider's, instead of an empty one in memory. Keys created before a user logged out
+// everywhere or reset their password then stop working, like the user's tokens.
+func WithRevocationStore(store local.TokenRevocationStore) Option {
+	return func(p *Provider) {
+		p.revocationStore = store
+	}
+}
+
+// creates a new API key provider for the users in userStore. Tokens that aren't API keys
+// are passed on to tokens, usually the local provider.
+func NewProvider(config Config, userStore local.UserStore, tokens auth.Provider, options ...Option) *Provider {
+	p := &Provider{
+		config:          config,
+		userStore:       userStore,
+		tokens:          tokens,
+		keyStore:        NewMemoryKeyStore(),
+		revocationStore: local.NewMemoryTokenStore(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// returns the provider identifier
+func (p *Provider) Name() string {
+	return "apikey"
+}
+
+// authenticates an API key given as creds.Token
+func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	if creds.Type != "apikey" || !p.IsKey(creds.Token) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return p.validateKey(ctx, creds.Token)
+}
+
+// validates an API key, or passes any other token on to the tokens provider.
+// Users authenticated with a key have the key's ID and scopes in their metadata;
+// it's up to the caller to check the scopes.
+func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
+	if p.IsKey(token) {
+		return p.validateKey(ctx, token)
+	}
+	return p.tokens.ValidateToken(ctx, token)
+}
+
+// refreshes a token with the tokens provider. API keys can't be refreshed.
+func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
+	if p.IsKey(token) {
+		return "", auth.ErrInvalidCredentials
+	}
+	return p.tokens.RefreshToken(ctx, token)
+}
+
+// deletes an API key, or passes any other token on to the tokens provider
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	if !p.IsKey(token) {
+		return p.tokens.RevokeToken(ctx, token)
+	}
+	
+	key, err := p.lookupKey(ctx, token)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return p.keyStore.Delete(ctx, key.UserID, key.ID)
+}
+
+// reports whether a token looks like an API key rather than a JWT
+func (p *Provider) IsKey(token string) bool {
+	return strings.HasPrefix(token, p.config.KeyPrefix)
+}
+
+// checks an API key and returns the user it belongs to
+func (p *Provider) validateKey(ctx context.Context, token string) (*auth.User, error) {
+	key, err := p.lookupKey(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, fmt.Errorf("%w: API key expired", auth.ErrInvalidCredentials)
+	}
+	
+	// Whoever had the user's password or tokens may have created the key
+	revokedBefore, err := p.revocationStore.RevokedBefore(ctx, key.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !revokedBefore.IsZero() && !key.CreatedAt.After(revokedBefore) {
+		return nil, fmt.Errorf("%w: API key revoked", auth.ErrInvalidCredentials)
+	}
+	
+	user, err := p.userStore.GetByID(ctx, key.UserID)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	// Keys created before the account moved to a directory don't get around it
+	if local.DirectoryManaged(user) {
+		return nil, fmt.Errorf("%w: the account belongs to a directory", auth.ErrInvalidCredentials)
+	}
+	
+	if now.Sub(key.LastUsedAt) >= p.config.LastUsedInterval {
+		if err := p.keyStore.Touch(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+	}
+	
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Roles:    user.Roles,
+		Metadata: map[string]interface{}{
+			"provider":   p.Name(),
+			"api_key_id": key.ID,
+			"scopes":     key.Scopes,
+		},
+	}, nil
+}
+
+// finds the stored key matching an API key. Unknown keys are invalid credentials.
+func (p *Provider) lookupKey(ctx context.Context, token string) (*APIKey, error) {
+	prefix, ok := p.keyPrefix(token)
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	key, err := p.keyStore.GetByPrefix(ctx, prefix)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	if subtle.ConstantTimeCompare([]byte(hashKey(token)), []byte(key.KeyHash)) != 1 {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return key, nil
+}
+
+// returns the public prefix of an API key: the configured prefix and the lookup ID before the secret
+func (p *Provider) keyPrefix(token string) (string, bool) {
+	lookup, secret, found := strings.Cut(strings.TrimPrefix(token, p.config.KeyPrefix), "_")
+	if !found || len(lookup) != 2*lookupIDSize || secret == "" {
+		return "", false
+	}
+	return p.config.KeyPrefix + lookup, true
+}
+
+// hashes an API key for storage. Keys are random enough that a fast hash is safe.
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
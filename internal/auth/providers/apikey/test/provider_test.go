@@ -0,0 +1,219 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupProvider creates an API key provider for a local provider with one user
+func setupProvider(t *testing.T, config apikey.Config) (*apikey.Provider, *local.ProviderWithRevocation, *local.StoredUser) {
+	userStore := local.NewMemoryUserStore()
+	user := &local.StoredUser{
+		Username: "batch",
+		Email:    "batch@example.com",
+		Roles:    []string{"user"},
+		Metadata: map[string]interface{}{},
+	}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	tokenStore := local.NewMemoryTokenStore()
+	localProvider := local.NewProviderWithRevocation(localConfig, userStore, tokenStore)
+	return apikey.NewProvider(config, userStore, localProvider, apikey.WithRevocationStore(tokenStore)), localProvider, user
+}
+
+func TestAPIKeys(t *testing.T) {
+	provider, _, user := setupProvider(t, apikey.DefaultConfig())
+	ctx := context.Background()
+	assert.Equal(t, "apikey", provider.Name())
+	
+	// 1. A new key is shown once and only its hash is stored
+	key, stored, err := provider.CreateKey(ctx, user.ID, " Nightly export ", []string{"read", "export", "read"}, time.Time{})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, stored.Prefix+"_"))
+	assert.True(t, provider.IsKey(key))
+	assert.Equal(t, "Nightly export", stored.Name)
+	assert.Equal(t, []string{"read", "export"}, stored.Scopes)
+	assert.True(t, stored.ExpiresAt.IsZero())
+	assert.NotContains(t, stored.KeyHash, key[len(stored.Prefix)+1:])
+	
+	// 2. The key authenticates as its user, with its scopes
+	validated, err := provider.ValidateToken(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	assert.Equal(t, []string{"user"}, validated.Roles)
+	assert.Equal(t, stored.ID, validated.Metadata["api_key_id"])
+	assert.Equal(t, []string{"read", "export"}, validated.Metadata["scopes"])
+	
+	authenticated, err := provider.Authenticate(ctx, auth.Credentials{Type: "apikey", Token: key})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+	
+	// 3. Uses are recorded
+	keys, err := provider.ListKeys(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.WithinDuration(t, time.Now(), keys[0].LastUsedAt, time.Minute)
+	
+	// 4. A changed secret or an unknown prefix doesn't authenticate
+	_, err = provider.ValidateToken(ctx, key[:len(key)-1]+"x")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.ValidateToken(ctx, "ak_000000000000_secret")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.ValidateToken(ctx, "ak_malformed")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 5. Only the owner can delete a key, and deleted keys stop working
+	assert.ErrorIs(t, provider.DeleteKey(ctx, "someone-else", stored.ID), apikey.ErrKeyNotFound)
+	require.NoError(t, provider.DeleteKey(ctx, user.ID, stored.ID))
+	_, err = provider.ValidateToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAPIKeysAcceptJWTs(t *testing.T) {
+	provider, localProvider, user := setupProvider(t, apikey.DefaultConfig())
+	ctx := context.Background()
+	
+	// 1. Tokens that aren't keys are validated by the local provider
+	pair, err := localProvider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	validated, err := provider.ValidateToken(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	assert.Nil(t, validated.Metadata["api_key_id"])
+	
+	// 2. Revoking a key deletes it, and revoking a JWT revokes it with the local provider
+	key, _, err := provider.CreateKey(ctx, user.ID, "CI", nil, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, provider.RevokeToken(ctx, key))
+	_, err = provider.ValidateToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	require.NoError(t, provider.RevokeToken(ctx, pair.AccessToken))
+	_, err = provider.ValidateToken(ctx, pair.AccessToken)
+	assert.Error(t, err)
+	
+	// 3. Keys can't be refreshed
+	_, err = provider.RefreshToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAPIKeyLimits(t *testing.T) {
+	config := apikey.DefaultConfig()
+	config.AllowedScopes = []string{"read", "write"}
+	config.MaxKeysPerUser = 2
+	config.MaxLifetime = 24 * time.Hour
+	provider, _, user := setupProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Names and scopes are checked
+	_, _, err := provider.CreateKey(ctx, user.ID, " ", nil, time.Time{})
+	assert.ErrorIs(t, err, apikey.ErrInvalidName)
+	_, _, err = provider.CreateKey(ctx, user.ID, "Admin", []string{"admin"}, time.Time{})
+	assert.ErrorIs(t, err, apikey.ErrInvalidScope)
+	_, _, err = provider.CreateKey(ctx, user.ID, "Spaces", []string{"read write"}, time.Time{})
+	assert.ErrorIs(t, err, apikey.ErrInvalidScope)
+	
+	// 2. Keys expire within the maximum lifetime, which is also the default
+	_, _, err = provider.CreateKey(ctx, user.ID, "Forever", nil, time.Now().Add(48*time.Hour))
+	assert.ErrorIs(t, err, apikey.ErrInvalidExpiry)
+	_, _, err = provider.CreateKey(ctx, user.ID, "Past", nil, time.Now().Add(-time.Minute))
+	assert.ErrorIs(t, err, apikey.ErrInvalidExpiry)
+	
+	_, stored, err := provider.CreateKey(ctx, user.ID, "Default", []string{"read"}, time.Time{})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), stored.ExpiresAt, time.Minute)
+	
+	_, _, err = provider.CreateKey(ctx, user.ID, "Hourly", []string{"write"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	
+	// 3. Users can only have so many keys
+	_, _, err = provider.CreateKey(ctx, user.ID, "Third", nil, time.Time{})
+	assert.ErrorIs(t, err, apikey.ErrTooManyKeys)
+	
+	// 4. Keys of unknown users can't be created
+	_, _, err = provider.CreateKey(ctx, "unknown", "Key", nil, time.Time{})
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+}
+
+func TestExpiredAPIKey(t *testing.T) {
+	store := apikey.NewMemoryKeyStore()
+	userStore := local.NewMemoryUserStore()
+	user := &local.StoredUser{Username: "batch", Email: "batch@example.com"}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	provider := apikey.NewProvider(apikey.DefaultConfig(), userStore, nil, apikey.WithKeyStore(store))
+	ctx := context.Background()
+	
+	key, stored, err := provider.CreateKey(ctx, user.ID, "Short-lived", nil, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = provider.ValidateToken(ctx, key)
+	require.NoError(t, err)
+	
+	// Move the expiry into the past, as if an hour went by
+	require.NoError(t, store.Delete(ctx, user.ID, stored.ID))
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+	require.NoError(t, store.Create(ctx, stored))
+	
+	_, err = provider.ValidateToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// Keys of deleted users stop working too
+	key, _, err = provider.CreateKey(ctx, user.ID, "Long-lived", nil, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, userStore.Delete(ctx, user.ID))
+	_, err = provider.ValidateToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAPIKeysRevokedWithTokens(t *testing.T) {
+	provider, localProvider, user := setupProvider(t, apikey.DefaultConfig())
+	ctx := context.Background()
+	
+	key, _, err := provider.CreateKey(ctx, user.ID, "Nightly export", nil, time.Time{})
+	require.NoError(t, err)
+	_, err = provider.ValidateToken(ctx, key)
+	require.NoError(t, err)
+	
+	// Logging out everywhere disables the keys created before, like the user's tokens
+	require.NoError(t, localProvider.RevokeAllTokens(ctx, user.ID))
+	_, err = provider.ValidateToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// Keys created afterwards work
+	key, _, err = provider.CreateKey(ctx, user.ID, "Nightly export", nil, time.Time{})
+	require.NoError(t, err)
+	_, err = provider.ValidateToken(ctx, key)
+	assert.NoError(t, err)
+}
+
+func TestAPIKeysOfDirectoryUsers(t *testing.T) {
+	provider, localProvider, user := setupProvider(t, apikey.DefaultConfig())
+	ctx := context.Background()
+	
+	key, _, err := provider.CreateKey(ctx, user.ID, "Nightly export", nil, time.Time{})
+	require.NoError(t, err)
+	
+	// The account moves to a directory, which users log in through only
+	_, err = localProvider.UpdateUser(ctx, user.ID, local.UserUpdate{
+		Metadata: map[string]interface{}{local.MetadataDirectoryDN: "uid=batch,ou=people,dc=example,dc=com"},
+	})
+	require.NoError(t, err)
+	
+	// 1. Keys created before stop working
+	_, err = provider.ValidateToken(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 2. No new keys can be created
+	_, _, err = provider.CreateKey(ctx, user.ID, "Nightly export", nil, time.Time{})
+	assert.ErrorIs(t, err, local.ErrDirectoryManaged)
+}
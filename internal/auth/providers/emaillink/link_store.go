@@ -0,0 +1,40 @@
+package emaillink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+)
+
+var (
+	ErrInvalidLink  = fmt.Errorf("%w: invalid or expired login link", auth.ErrInvalidCredentials)
+	ErrWrongBrowser = fmt.Errorf("%w: login link was requested in another browser", auth.ErrInvalidCredentials)
+	ErrLinkExists   = errors.New("login link already exists")
+)
+
+// Link is an emailed login link. The store only keeps hashes of the token in
+// the link and of the nonce in the cookie of the browser that asked for it.
+type Link struct {
+	TokenHash string
+	NonceHash string
+	UserID    string
+	Email     string // Address the link was sent to; the link stops working if the user changes it
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// LinkStore persists login links until they're used
+type LinkStore interface {
+	// returns ErrLinkExists if a link with the same token hash exists
+	Create(ctx context.Context, link *Link) error
+	
+	// deletes and returns a link, so it can only be used once;
+	// returns ErrInvalidLink if there's no such link or it has expired
+	Consume(ctx context.Context, tokenHash string) (*Link, error)
+	
+	// removes expired links
+	CleanupExpiredLinks(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,115 @@
+package emaillink
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+)
+
+// Limit on looking up an address and sending it a link after the request has been answered
+const backgroundEmailTimeout = time.Minute
+
+// emails a login link to the user with this email address and returns the nonce the
+// browser that asked for it must present with the link, e.g. in a cookie.
+// Every address gets a nonce. The address is looked up and the link sent in the background,
+// and unknown addresses, directory users and delivery failures are only logged, so neither
+// the outcome nor the time it takes reveals which addresses have accounts.
+func (p *Provider) SendLink(ctx context.Context, email string) (string, error) {
+	nonce, nonceHash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	
+	email = strings.TrimSpace(email)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundEmailTimeout)
+		defer cancel()
+		
+		if err := p.sendLink(ctx, email, nonceHash); err != nil {
+			log.Printf("Login link request error: %v", err)
+		}
+	}()
+	return nonce, nil
+}
+
+// emails a login link bound to the nonce to the user with this email address, if there is one
+func (p *Provider) sendLink(ctx context.Context, email string, nonceHash string) error {
+	user, err := p.userStore.GetByEmail(ctx, email)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	// Directory users log in through the directory, so it can lock them out
+	if local.DirectoryManaged(user) {
+		log.Printf("Not sending login link to user %s, who logs in through a directory", user.ID)
+		return nil
+	}
+	
+	if p.mailer == nil {
+		log.Printf("No mailer configured; not sending login link to user %s", user.ID)
+		return nil
+	}
+	
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	err = p.linkStore.Create(ctx, &Link{
+		TokenHash: tokenHash,
+		NonceHash: nonceHash,
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.config.LinkExpiration),
+	})
+	if err != nil {
+		return err
+	}
+	
+	link := strings.TrimSuffix(p.config.PublicURL, "/") + "/auth/magic-link/callback?token=" + url.QueryEscape(token)
+	err = p.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Hi %s,\n\nTo log in, open this link in the browser you asked for it from:\n\n%s\n\n"+
+			"The link expires in %s and works once. If you didn't ask for it, you can ignore this email.\n",
+			user.Username, link, p.config.LinkExpiration),
+	})
+	if err != nil {
+		log.Printf("Failed to send login link to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// creates a random token and the hash it is stored under. Tokens are random
+// enough that a fast hash is safe.
+func generateToken() (token string, tokenHash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// hashes a token for storage
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
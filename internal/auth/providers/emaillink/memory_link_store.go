@@ -0,0 +1,67 @@
+package emaillink
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLinkStore implements LinkStore with in-memory storage
+type MemoryLinkStore struct {
+	links map[string]*Link // Indexed by token hash
+	mu    sync.Mutex
+}
+
+// NewMemoryLinkStore creates a new in-memory login link store
+func NewMemoryLinkStore() *MemoryLinkStore {
+	return &MemoryLinkStore{
+		links: make(map[string]*Link),
+	}
+}
+
+// Create stores a new link
+func (s *MemoryLinkStore) Create(ctx context.Context, link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.links[link.TokenHash]; exists {
+		return ErrLinkExists
+	}
+	
+	linkCopy := *link
+	s.links[link.TokenHash] = &linkCopy
+	return nil
+}
+
+// Consume deletes and returns a link
+func (s *MemoryLinkStore) Consume(ctx context.Context, tokenHash string) (*Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	link, exists := s.links[tokenHash]
+	if !exists {
+		return nil, ErrInvalidLink
+	}
+	delete(s.links, tokenHash)
+	
+	if time.Now().After(link.ExpiresAt) {
+		return nil, ErrInvalidLink
+	}
+	return link, nil
+}
+
+// CleanupExpiredLinks removes expired links
+func (s *MemoryLinkStore) CleanupExpiredLinks(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	for hash, link := range s.links {
+		if now.After(link.ExpiresAt) {
+			delete(s.links, hash)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// LinkStore implements emaillink.LinkStore with PostgreSQL
+type LinkStore struct {
+	db *sqlx.DB
+}
+
+// linkRow represents a row in the magic_links table
+type linkRow struct {
+	TokenHash string    `db:"token_hash"`
+	NonceHash string    `db:"nonce_hash"`
+	UserID    string    `db:"user_id"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// NewLinkStore creates a new PostgreSQL-backed login link store
+func NewLinkStore(db *sqlx.DB) *LinkStore {
+	return &LinkStore{
+		db: db,
+	}
+}
+
+// Create stores a new link
+func (s *LinkStore) Create(ctx context.Context, link *emaillink.Link) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO magic_links (token_hash, nonce_hash, user_id, email, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		link.TokenHash, link.NonceHash, link.UserID, link.Email, link.CreatedAt, link.ExpiresAt)
+	
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return emaillink.ErrLinkExists
+	}
+	return err
+}
+
+// Consume deletes and returns a link in a single statement, so it can only be used once
+func (s *LinkStore) Consume(ctx context.Context, tokenHash string) (*emaillink.Link, error) {
+	var row linkRow
+	err := s.db.GetContext(ctx, &row, `
+		DELETE FROM magic_links WHERE token_hash = $1
+		RETURNING token_hash, nonce_hash, user_id, email, created_at, expires_at`,
+		tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, emaillink.ErrInvalidLink
+		}
+		return nil, err
+	}
+	
+	if time.Now().After(row.ExpiresAt) {
+		return nil, emaillink.ErrInvalidLink
+	}
+	
+	return &emaillink.Link{
+		TokenHash: row.TokenHash,
+		NonceHash: row.NonceHash,
+		UserID:    row.UserID,
+		Email:     row.Email,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}, nil
+}
+
+// CleanupExpiredLinks removes expired links
+func (s *LinkStore) CleanupExpiredLinks(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM magic_links WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
@@ -0,0 +1,104 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	localpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDatabase connects to the test database and creates a user to send links to
+func setupDatabase(t *testing.T) (*sqlx.DB, *local.StoredUser) {
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	userStore := localpg.NewSQLUserStore(db)
+	suffix := time.Now().Format("20060102150405.000000")
+	user := &local.StoredUser{
+		Username:     "emaillink-" + suffix,
+		Email:        "emaillink-" + suffix + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	t.Cleanup(func() { userStore.Delete(context.Background(), user.ID) })
+	
+	return db, user
+}
+
+func TestPostgresLinkStore(t *testing.T) {
+	db, user := setupDatabase(t)
+	store := postgres.NewLinkStore(db)
+	ctx := context.Background()
+	now := time.Now()
+	suffix := now.Format("150405.000000")
+	
+	// 1. Store a link and use it once
+	link := &emaillink.Link{
+		TokenHash: "token-" + suffix,
+		NonceHash: "nonce-" + suffix,
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(15 * time.Minute),
+	}
+	require.NoError(t, store.Create(ctx, link))
+	assert.ErrorIs(t, store.Create(ctx, link), emaillink.ErrLinkExists)
+	
+	consumed, err := store.Consume(ctx, link.TokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, link.NonceHash, consumed.NonceHash)
+	assert.Equal(t, user.ID, consumed.UserID)
+	assert.Equal(t, user.Email, consumed.Email)
+	
+	_, err = store.Consume(ctx, link.TokenHash)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+	
+	// 2. Expired links can't be used and are cleaned up
+	require.NoError(t, store.Create(ctx, &emaillink.Link{
+		TokenHash: "expired-" + suffix,
+		NonceHash: "nonce-" + suffix,
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now.Add(-time.Hour),
+		ExpiresAt: now.Add(-time.Minute),
+	}))
+	require.NoError(t, store.Create(ctx, &emaillink.Link{
+		TokenHash: "stale-" + suffix,
+		NonceHash: "nonce-" + suffix,
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now.Add(-time.Hour),
+		ExpiresAt: now.Add(-time.Minute),
+	}))
+	
+	_, err = store.Consume(ctx, "expired-"+suffix)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+	
+	count, err := store.CleanupExpiredLinks(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(1))
+	
+	_, err = store.Consume(ctx, "stale-"+suffix)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+}
@@ -0,0 +1,140 @@
+package emaillink
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+)
+
+type Config struct {
+	PublicURL string // Externally reachable base URL of the service, used to build the links
+	
+	LinkExpiration time.Duration // Time users have to open the link
+}
+
+// returns the configuration for a service running on localhost
+func DefaultConfig() Config {
+	return Config{
+		PublicURL:      "http://localhost:8080",
+		LinkExpiration: 15 * time.Minute,
+	}
+}
+
+// logs the users of the local provider in with single-use links sent to their email address.
+// A link only works in the browser that asked for it, which proves it with the nonce SendLink returned.
+// Tokens are issued and checked by the local provider.
+type Provider struct {
+	config    Config
+	userStore local.UserStore
+	tokens    auth.Provider
+	mailer    mail.Mailer
+	linkStore LinkStore
+}
+
+// Option configures optional provider dependencies
+type Option func(*Provider)
+
+// stores login links in the given store instead of in memory
+func WithLinkStore(store LinkStore) Option {
+	return func(p *Provider) {
+		p.linkStore = store
+	}
+}
+
+// sends the links with the given mailer; without one no links are sent
+func WithMailer(mailer mail.Mailer) Option {
+	return func(p *Provider) {
+		p.mailer = mailer
+	}
+}
+
+// creates a new email link provider for the users in userStore. Token operations are passed on to tokens,
+// usually the local provider, which also issues the tokens after a login.
+func NewProvider(config Config, userStore local.UserStore, tokens auth.Provider, options ...Option) *Provider {
+	p := &Provider{
+		config:    config,
+		userStore: userStore,
+		tokens:    tokens,
+		linkStore: NewMemoryLinkStore(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// returns the provider identifier
+func (p *Provider) Name() string {
+	return "emaillink"
+}
+
+// logs in with the token from a login link, given as creds.Token, and the nonce
+// SendLink returned to the browser that asked for it, given as creds.Params["nonce"].
+// Links are single use, even if the nonce doesn't match. Since the link proves the
+// user controls the address, it's marked verified.
+func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	nonce, _ := creds.Params["nonce"].(string)
+	if creds.Type != "emaillink" || creds.Token == "" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	link, err := p.linkStore.Consume(ctx, hashToken(creds.Token))
+	if err != nil {
+		return nil, err
+	}
+	
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(hashToken(nonce)), []byte(link.NonceHash)) != 1 {
+		return nil, ErrWrongBrowser
+	}
+	
+	user, err := p.userStore.GetByID(ctx, link.UserID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrInvalidLink
+		}
+		return nil, err
+	}
+	if user.Email != link.Email || local.DirectoryManaged(user) {
+		return nil, ErrInvalidLink
+	}
+	
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		if err := p.userStore.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Roles:    user.Roles,
+		Metadata: map[string]interface{}{"provider": p.Name()},
+	}, nil
+}
+
+// validates a token with the provider that issued it
+func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
+	return p.tokens.ValidateToken(ctx, token)
+}
+
+// refreshes a token with the provider that issued it
+func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
+	return p.tokens.RefreshToken(ctx, token)
+}
+
+// revokes a token with the provider that issued it
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	return p.tokens.RevokeToken(ctx, token)
+}
+
+// returns how long login links work after they're sent
+func (p *Provider) LinkExpiration() time.Duration {
+	return p.config.LinkExpiration
+}
@@ -0,0 +1,180 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupProvider creates an email link provider for a local provider with one unverified user
+func setupProvider(t *testing.T, config emaillink.Config) (*emaillink.Provider, local.UserStore, *mail.MemoryMailer, *local.StoredUser) {
+	userStore := local.NewMemoryUserStore()
+	user := &local.StoredUser{
+		Username: "linker",
+		Email:    "linker@example.com",
+		Roles:    []string{"user"},
+	}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	localProvider := local.NewProviderWithRevocation(localConfig, userStore, local.NewMemoryTokenStore())
+	
+	mailer := mail.NewMemoryMailer()
+	provider := emaillink.NewProvider(config, userStore, localProvider, emaillink.WithMailer(mailer))
+	return provider, userStore, mailer, user
+}
+
+// waitForEmails waits for the links sent in the background until there are count of them
+func waitForEmails(t *testing.T, mailer *mail.MemoryMailer, count int) {
+	require.Eventually(t, func() bool { return len(mailer.Messages()) >= count }, time.Second, 5*time.Millisecond,
+		"expected %d emails", count)
+}
+
+// linkToken returns the token from the last link emailed to the address
+func linkToken(t *testing.T, mailer *mail.MemoryMailer, to string) string {
+	message, ok := mailer.Last(to)
+	require.True(t, ok)
+	
+	match := regexp.MustCompile(`/auth/magic-link/callback\?token=(\S+)`).FindStringSubmatch(message.Body)
+	require.NotNil(t, match)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+// login authenticates with a link token and browser nonce
+func login(provider *emaillink.Provider, token string, nonce string) (*auth.User, error) {
+	return provider.Authenticate(context.Background(), auth.Credentials{
+		Type:   "emaillink",
+		Token:  token,
+		Params: map[string]interface{}{"nonce": nonce},
+	})
+}
+
+func TestLoginLink(t *testing.T) {
+	config := emaillink.DefaultConfig()
+	config.PublicURL = "https://auth.example.com/"
+	provider, userStore, mailer, user := setupProvider(t, config)
+	ctx := context.Background()
+	assert.Equal(t, "emaillink", provider.Name())
+	
+	// 1. The link goes to the user's address
+	nonce, err := provider.SendLink(ctx, " linker@example.com ")
+	require.NoError(t, err)
+	assert.NotEmpty(t, nonce)
+	
+	waitForEmails(t, mailer, 1)
+	message, ok := mailer.Last("linker@example.com")
+	require.True(t, ok)
+	assert.Contains(t, message.Body, "https://auth.example.com/auth/magic-link/callback?token=")
+	token := linkToken(t, mailer, "linker@example.com")
+	
+	// 2. The link logs in the browser that asked for it, and verifies the address
+	authenticated, err := login(provider, token, nonce)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+	assert.Equal(t, "linker", authenticated.Username)
+	assert.Equal(t, "emaillink", authenticated.Metadata["provider"])
+	
+	stored, err := userStore.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.EmailVerified)
+	
+	// 3. It only works once
+	_, err = login(provider, token, nonce)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestLoginLinkOtherBrowser(t *testing.T) {
+	provider, _, mailer, _ := setupProvider(t, emaillink.DefaultConfig())
+	ctx := context.Background()
+	
+	// 1. Another browser's nonce doesn't work, and uses up the link
+	_, err := provider.SendLink(ctx, "linker@example.com")
+	require.NoError(t, err)
+	waitForEmails(t, mailer, 1)
+	token := linkToken(t, mailer, "linker@example.com")
+	
+	otherNonce, err := provider.SendLink(ctx, "nobody@example.com")
+	require.NoError(t, err)
+	
+	_, err = login(provider, token, otherNonce)
+	assert.ErrorIs(t, err, emaillink.ErrWrongBrowser)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	_, err = login(provider, token, otherNonce)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+	
+	// 2. Neither does a missing nonce
+	nonce, err := provider.SendLink(ctx, "linker@example.com")
+	require.NoError(t, err)
+	waitForEmails(t, mailer, 2)
+	token = linkToken(t, mailer, "linker@example.com")
+	
+	_, err = login(provider, token, "")
+	assert.ErrorIs(t, err, emaillink.ErrWrongBrowser)
+	_, err = login(provider, token, nonce)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+	
+	// 3. Unknown addresses get a nonce but no email
+	assert.Never(t, func() bool { return len(mailer.Messages()) > 2 }, 100*time.Millisecond, 5*time.Millisecond)
+	assert.NotEmpty(t, otherNonce)
+}
+
+func TestExpiredLoginLink(t *testing.T) {
+	config := emaillink.DefaultConfig()
+	config.LinkExpiration = time.Millisecond
+	provider, userStore, mailer, user := setupProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Links stop working once they expire
+	nonce, err := provider.SendLink(ctx, "linker@example.com")
+	require.NoError(t, err)
+	waitForEmails(t, mailer, 1)
+	token := linkToken(t, mailer, "linker@example.com")
+	
+	time.Sleep(5 * time.Millisecond)
+	_, err = login(provider, token, nonce)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+	
+	// 2. And when the user changes their address
+	provider, userStore, mailer, user = setupProvider(t, emaillink.DefaultConfig())
+	nonce, err = provider.SendLink(ctx, "linker@example.com")
+	require.NoError(t, err)
+	waitForEmails(t, mailer, 1)
+	token = linkToken(t, mailer, "linker@example.com")
+	
+	user.Email = "moved@example.com"
+	require.NoError(t, userStore.Update(ctx, user))
+	_, err = login(provider, token, nonce)
+	assert.ErrorIs(t, err, emaillink.ErrInvalidLink)
+}
+
+func TestMemoryLinkStoreCleanup(t *testing.T) {
+	store := emaillink.NewMemoryLinkStore()
+	ctx := context.Background()
+	now := time.Now()
+	
+	require.NoError(t, store.Create(ctx, &emaillink.Link{TokenHash: "expired", ExpiresAt: now.Add(-time.Minute)}))
+	require.NoError(t, store.Create(ctx, &emaillink.Link{TokenHash: "valid", ExpiresAt: now.Add(time.Minute)}))
+	assert.ErrorIs(t, store.Create(ctx, &emaillink.Link{TokenHash: "valid"}), emaillink.ErrLinkExists)
+	
+	count, err := store.CleanupExpiredLinks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	
+	link, err := store.Consume(ctx, "valid")
+	require.NoError(t, err)
+	assert.Equal(t, "valid", link.TokenHash)
+}
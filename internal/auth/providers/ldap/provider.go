@@ -0,0 +1,201 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/ldap"
+)
+
+var (
+	ErrUnavailable     = errors.New("directory server unavailable")
+	ErrNotProvisioned  = errors.New("directory user has no local account")
+	ErrAccountConflict = errors.New("a local account that doesn't belong to the directory user has the same username")
+)
+
+type Config struct {
+	Name string // Provider identifier in the registry and the created_by metadata of shadow users
+	
+	URL string // ldap://host:389 or ldaps://host:636
+	
+	InsecureSkipVerify bool // Accepts any certificate from an ldaps:// server; for testing only
+	
+	BindDN string // Service account that searches for users; empty for anonymous searches
+	
+	BindPassword string
+	
+	BaseDN string // Where to search for users, e.g. ou=people,dc=example,dc=com
+	
+	UserFilter string // Filter finding the user; {username} is replaced with the escaped username
+	
+	UsernameAttribute string // Attribute holding the username of shadow users
+	
+	EmailAttribute string
+	
+	GroupAttribute string // Attribute of user entries listing the DNs of their groups, e.g. memberOf; empty to skip
+	
+	GroupBaseDN string // Optional: also search for groups below this DN
+	
+	GroupFilter string // Filter finding the user's groups; {dn} and {username} are replaced with escaped values
+	
+	GroupRoles map[string]string // Roles of members of these groups, keyed by lowercase group name (the first RDN value) or DN. Without it, group names become roles.
+	
+	DefaultRoles []string // Roles every directory user gets
+	
+	ProvisionUsers bool // Creates a shadow user in the local user store on first login; without it, only users with a shadow user can log in
+	
+	Timeout time.Duration // Limit on each login's conversation with the server
+}
+
+// returns the configuration for an OpenLDAP-style directory; URL and BaseDN still need to be set
+func DefaultConfig() Config {
+	return Config{
+		Name:              "ldap",
+		UserFilter:        "(uid={username})",
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+		GroupAttribute:    "memberOf",
+		GroupFilter:       "(member={dn})",
+		DefaultRoles:      []string{"user"},
+		ProvisionUsers:    true,
+		Timeout:           10 * time.Second,
+	}
+}
+
+// authenticates users with a bind to an LDAP directory such as OpenLDAP or Active Directory.
+// Directory users log in to shadow users in the local user store, whose roles follow their
+// group membership. Tokens are issued and checked by the tokens provider.
+type Provider struct {
+	config    Config
+	userStore local.UserStore
+	tokens    auth.Provider
+	tlsConfig *tls.Config
+}
+
+// creates a new LDAP provider with shadow users in userStore. Token operations are passed on to tokens,
+// usually the local provider, which also issues the tokens after a login.
+func NewProvider(config Config, userStore local.UserStore, tokens auth.Provider) *Provider {
+	return &Provider{
+		config:    config,
+		userStore: userStore,
+		tokens:    tokens,
+		tlsConfig: &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+	}
+}
+
+// returns the provider identifier
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// checks a username and password against the directory and returns the user's shadow user.
+// The user is looked up with the service account, then the password is checked by binding as them.
+// An unreachable server is reported as ErrUnavailable.
+func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	if creds.Type != "password" || strings.TrimSpace(creds.Username) == "" || creds.Password == "" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	
+	entry, err := p.findUser(ctx, conn, creds.Username)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Groups are read with the service account, which may see more than the user
+	groups, err := p.groups(ctx, conn, entry, creds.Username)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := conn.Bind(ctx, entry.DN, creds.Password); err != nil {
+		if errors.Is(err, protocol.ErrInvalidCredentials) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	
+	user, err := p.shadowUser(ctx, entry, creds.Username, p.roles(groups))
+	if err != nil {
+		return nil, err
+	}
+	
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Roles:    user.Roles,
+		Metadata: map[string]interface{}{
+			"provider": p.config.Name,
+			"dn":       entry.DN,
+			"groups":   groups,
+		},
+	}, nil
+}
+
+// validates a token with the provider that issued it
+func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
+	return p.tokens.ValidateToken(ctx, token)
+}
+
+// refreshes a token with the provider that issued it
+func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
+	return p.tokens.RefreshToken(ctx, token)
+}
+
+// revokes a token with the provider that issued it
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	return p.tokens.RevokeToken(ctx, token)
+}
+
+// connects to the server and binds as the service account, if there is one
+func (p *Provider) connect(ctx context.Context) (*protocol.Conn, error) {
+	conn, err := protocol.DialURL(ctx, p.config.URL, p.tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	
+	if p.config.BindDN != "" {
+		if err := conn.Bind(ctx, p.config.BindDN, p.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("binding as the service account: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// finds the entry of the user logging in. Unknown and ambiguous usernames are invalid credentials.
+func (p *Provider) findUser(ctx context.Context, conn *protocol.Conn, username string) (*protocol.Entry, error) {
+	filter := strings.ReplaceAll(p.config.UserFilter, "{username}", protocol.EscapeFilter(username))
+	entries, err := conn.Search(ctx, &protocol.SearchRequest{
+		BaseDN:     p.config.BaseDN,
+		Scope:      protocol.ScopeWholeSubtree,
+		Filter:     filter,
+		Attributes: []string{p.config.UsernameAttribute, p.config.EmailAttribute, p.config.GroupAttribute},
+		SizeLimit:  2,
+	})
+	if len(entries) > 1 {
+		return nil, fmt.Errorf("%w: %d directory entries match %q", auth.ErrInvalidCredentials, len(entries), filter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("searching for the user: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return entries[0], nil
+}
@@ -0,0 +1,244 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/ldap"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/ldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupDirectory starts a directory with a service account, two users and two groups
+func setupDirectory(t *testing.T) *protocol.TestServer {
+	server := protocol.NewTestServer()
+	t.Cleanup(server.Close)
+	
+	server.AddEntry("cn=service,dc=example,dc=com", map[string][]string{
+		"cn":           {"service"},
+		"userPassword": {"service-secret"},
+	})
+	server.AddEntry("uid=alice,ou=people,dc=example,dc=com", map[string][]string{
+		"objectClass":  {"person"},
+		"uid":          {"alice"},
+		"mail":         {"alice@example.com"},
+		"memberOf":     {"cn=Admins,ou=groups,dc=example,dc=com"},
+		"userPassword": {"alice-secret"},
+	})
+	server.AddEntry("uid=bob,ou=people,dc=example,dc=com", map[string][]string{
+		"objectClass":  {"person"},
+		"uid":          {"bob"},
+		"mail":         {"bob@example.com"},
+		"userPassword": {"bob-secret"},
+	})
+	server.AddEntry("cn=staff,ou=groups,dc=example,dc=com", map[string][]string{
+		"cn":     {"staff"},
+		"member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"},
+	})
+	return server
+}
+
+// newProvider creates an LDAP provider for the test directory with shadow users in userStore
+func newProvider(server *protocol.TestServer, userStore local.UserStore, configure func(*ldap.Config)) *ldap.Provider {
+	config := ldap.DefaultConfig()
+	config.URL = server.URL
+	config.BindDN = "cn=service,dc=example,dc=com"
+	config.BindPassword = "service-secret"
+	config.BaseDN = "ou=people,dc=example,dc=com"
+	config.GroupBaseDN = "ou=groups,dc=example,dc=com"
+	if configure != nil {
+		configure(&config)
+	}
+	
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	return ldap.NewProvider(config, userStore, local.NewProvider(localConfig, local.NewMemoryUserStore()))
+}
+
+func passwordCredentials(username string, password string) auth.Credentials {
+	return auth.Credentials{Type: "password", Provider: "ldap", Username: username, Password: password}
+}
+
+func TestLDAPLogin(t *testing.T) {
+	server := setupDirectory(t)
+	userStore := local.NewMemoryUserStore()
+	provider := newProvider(server, userStore, func(config *ldap.Config) {
+		config.GroupRoles = map[string]string{"admins": "admin", "cn=staff,ou=groups,dc=example,dc=com": "staff"}
+	})
+	ctx := context.Background()
+	assert.Equal(t, "ldap", provider.Name())
+	
+	// 1. The first login creates a shadow user with roles from both kinds of group membership
+	user, err := provider.Authenticate(ctx, passwordCredentials("alice", "alice-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, []string{"user", "admin", "staff"}, user.Roles)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", user.Metadata["dn"])
+	
+	stored, err := userStore.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, stored.ID)
+	assert.True(t, stored.EmailVerified)
+	assert.Empty(t, stored.PasswordHash)
+	assert.Equal(t, "ldap", stored.Metadata["created_by"])
+	
+	// 2. Later logins reuse the shadow user and bring its roles up to date
+	stored.Roles = []string{"user"}
+	require.NoError(t, userStore.Update(ctx, stored))
+	
+	again, err := provider.Authenticate(ctx, passwordCredentials("alice", "alice-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+	stored, err = userStore.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "admin", "staff"}, stored.Roles)
+	
+	// 3. Users only get the roles of their own groups
+	bob, err := provider.Authenticate(ctx, passwordCredentials("bob", "bob-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "staff"}, bob.Roles)
+	
+	// 4. Wrong passwords, unknown users and filter injection are invalid credentials
+	_, err = provider.Authenticate(ctx, passwordCredentials("alice", "wrong"))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	_, err = provider.Authenticate(ctx, passwordCredentials("carol", "carol-secret"))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	_, err = provider.Authenticate(ctx, passwordCredentials("*", "alice-secret"))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 5. An empty password never reaches the server, where it would be an anonymous bind
+	_, err = provider.Authenticate(ctx, passwordCredentials("alice", ""))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestLDAPGroupNamesAsRoles(t *testing.T) {
+	server := setupDirectory(t)
+	provider := newProvider(server, nil, nil)
+	
+	// Without a group mapping, group names are roles, and without a user store nothing is stored
+	user, err := provider.Authenticate(context.Background(), passwordCredentials("alice", "alice-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", user.ID)
+	assert.Equal(t, []string{"user", "admins", "staff"}, user.Roles)
+	assert.Equal(t, []string{"cn=Admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+		user.Metadata["groups"])
+}
+
+func TestLDAPShadowUsers(t *testing.T) {
+	server := setupDirectory(t)
+	userStore := local.NewMemoryUserStore()
+	ctx := context.Background()
+	
+	// 1. Without provisioning, users need an existing shadow user
+	provider := newProvider(server, userStore, func(config *ldap.Config) {
+		config.ProvisionUsers = false
+	})
+	_, err := provider.Authenticate(ctx, passwordCredentials("bob", "bob-secret"))
+	assert.ErrorIs(t, err, ldap.ErrNotProvisioned)
+	
+	// 2. A local account with the same username can't be taken over from the directory
+	require.NoError(t, userStore.Create(ctx, &local.StoredUser{
+		Username: "alice",
+		Email:    "alice@local.example.com",
+		Roles:    []string{"user"},
+		Metadata: map[string]interface{}{"created_by": "system"},
+	}))
+	provider = newProvider(server, userStore, nil)
+	_, err = provider.Authenticate(ctx, passwordCredentials("alice", "alice-secret"))
+	assert.ErrorIs(t, err, ldap.ErrAccountConflict)
+	
+	// 3. Neither can one with the same email address
+	require.NoError(t, userStore.Create(ctx, &local.StoredUser{
+		Username: "robert",
+		Email:    "bob@example.com",
+		Roles:    []string{"user"},
+	}))
+	_, err = provider.Authenticate(ctx, passwordCredentials("bob", "bob-secret"))
+	assert.ErrorIs(t, err, ldap.ErrAccountConflict)
+}
+
+func TestLDAPShadowUsersOnlyLogInThroughDirectory(t *testing.T) {
+	server := setupDirectory(t)
+	userStore := local.NewMemoryUserStore()
+	ctx := context.Background()
+	
+	_, err := newProvider(server, userStore, nil).Authenticate(ctx, passwordCredentials("alice", "alice-secret"))
+	require.NoError(t, err)
+	stored, err := userStore.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.True(t, local.DirectoryManaged(stored))
+	
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	mailer := mail.NewMemoryMailer()
+	localProvider := local.NewProvider(localConfig, userStore, local.WithMailer(mailer))
+	
+	// 1. Shadow users get no password reset email, so they can't get a local password
+	require.NoError(t, localProvider.RequestPasswordReset(ctx, "alice@example.com"))
+	assert.Never(t, func() bool { return len(mailer.Messages()) > 0 }, 100*time.Millisecond, 5*time.Millisecond)
+	
+	// 2. Nor a login link
+	linkProvider := emaillink.NewProvider(emaillink.DefaultConfig(), userStore, localProvider, emaillink.WithMailer(mailer))
+	_, err = linkProvider.SendLink(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.Never(t, func() bool { return len(mailer.Messages()) > 0 }, 100*time.Millisecond, 5*time.Millisecond)
+	
+	// 3. A local password, e.g. one set before the account moved to the directory, doesn't log them in
+	hash, err := bcrypt.GenerateFromPassword([]byte("local-secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	stored.PasswordHash = string(hash)
+	require.NoError(t, userStore.Update(ctx, stored))
+	
+	_, err = localProvider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "alice", Password: "local-secret"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestLDAPAccountCheck(t *testing.T) {
+	server := setupDirectory(t)
+	userStore := local.NewMemoryUserStore()
+	provider := newProvider(server, userStore, nil)
+	ctx := context.Background()
+	
+	_, err := provider.Authenticate(ctx, passwordCredentials("alice", "alice-secret"))
+	require.NoError(t, err)
+	alice, err := userStore.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	
+	// 1. Users still in the directory pass, and other users aren't this directory's business
+	assert.NoError(t, provider.CheckAccount(ctx, alice))
+	assert.NoError(t, provider.CheckAccount(ctx, &local.StoredUser{Username: "bob", Metadata: map[string]interface{}{"created_by": "registration"}}))
+	
+	// 2. A user who was replaced by another entry with the same username is disabled
+	moved := *alice
+	moved.Metadata = map[string]interface{}{"created_by": "ldap", local.MetadataDirectoryDN: "uid=alice,ou=former,dc=example,dc=com"}
+	assert.ErrorIs(t, provider.CheckAccount(ctx, &moved), local.ErrAccountDisabled)
+	
+	// 3. So is one deleted from the directory
+	server.RemoveEntry("uid=alice,ou=people,dc=example,dc=com")
+	assert.ErrorIs(t, provider.CheckAccount(ctx, alice), local.ErrAccountDisabled)
+	
+	// 4. An unreachable directory doesn't count as disabling anyone
+	server.Close()
+	err = provider.CheckAccount(ctx, alice)
+	assert.ErrorIs(t, err, ldap.ErrUnavailable)
+	assert.NotErrorIs(t, err, local.ErrAccountDisabled)
+}
+
+func TestLDAPUnavailable(t *testing.T) {
+	server := setupDirectory(t)
+	provider := newProvider(server, local.NewMemoryUserStore(), nil)
+	server.Close()
+	
+	_, err := provider.Authenticate(context.Background(), passwordCredentials("alice", "alice-secret"))
+	assert.ErrorIs(t, err, ldap.ErrUnavailable)
+}
@@ -0,0 +1,212 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/ldap"
+)
+
+// returns the DNs of the groups a user belongs to, from the group attribute of
+// their entry and from the group search, without duplicates
+func (p *Provider) groups(ctx context.Context, conn *protocol.Conn, entry *protocol.Entry, username string) ([]string, error) {
+	var groups []string
+	seen := make(map[string]bool)
+	add := func(dn string) {
+		key := strings.ToLower(dn)
+		if dn != "" && !seen[key] {
+			seen[key] = true
+			groups = append(groups, dn)
+		}
+	}
+	
+	if p.config.GroupAttribute != "" {
+		for _, dn := range entry.Values(p.config.GroupAttribute) {
+			add(dn)
+		}
+	}
+	
+	if p.config.GroupBaseDN != "" {
+		filter := strings.NewReplacer(
+			"{dn}", protocol.EscapeFilter(entry.DN),
+			"{username}", protocol.EscapeFilter(username),
+		).Replace(p.config.GroupFilter)
+		entries, err := conn.Search(ctx, &protocol.SearchRequest{
+			BaseDN:     p.config.GroupBaseDN,
+			Scope:      protocol.ScopeWholeSubtree,
+			Filter:     filter,
+			Attributes: []string{"cn"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("searching for groups: %w", err)
+		}
+		for _, group := range entries {
+			add(group.DN)
+		}
+	}
+	
+	return groups, nil
+}
+
+// maps group DNs to roles. Groups are looked up in GroupRoles by lowercase name, then by DN;
+// without GroupRoles, each group's lowercase name is a role. Every user gets the default roles.
+func (p *Provider) roles(groups []string) []string {
+	roles := make([]string, 0, len(p.config.DefaultRoles)+len(groups))
+	seen := make(map[string]bool)
+	add := func(role string) {
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	
+	for _, role := range p.config.DefaultRoles {
+		add(role)
+	}
+	for _, dn := range groups {
+		name := strings.ToLower(groupName(dn))
+		if len(p.config.GroupRoles) == 0 {
+			add(name)
+			continue
+		}
+		if role, ok := p.config.GroupRoles[name]; ok {
+			add(role)
+		} else if role, ok := p.config.GroupRoles[strings.ToLower(dn)]; ok {
+			add(role)
+		}
+	}
+	
+	return roles
+}
+
+// returns the value of the first RDN of a group DN, e.g. "admins" for "cn=admins,ou=groups,dc=example,dc=com"
+func groupName(dn string) string {
+	rdn, _, _ := strings.Cut(dn, ",")
+	_, value, found := strings.Cut(rdn, "=")
+	if !found {
+		return strings.TrimSpace(dn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// returns the shadow user of a directory user, creating it on first login when ProvisionUsers is set
+// and updating its email and roles from the directory otherwise. Without a user store, the user is
+// returned without being stored, identified by their DN.
+func (p *Provider) shadowUser(ctx context.Context, entry *protocol.Entry, username string, roles []string) (*local.StoredUser, error) {
+	if name := strings.TrimSpace(entry.Value(p.config.UsernameAttribute)); name != "" {
+		username = name
+	}
+	email := entry.Value(p.config.EmailAttribute)
+	
+	if p.userStore == nil {
+		return &local.StoredUser{ID: entry.DN, Username: username, Email: email, Roles: roles}, nil
+	}
+	
+	user, err := p.userStore.GetByUsername(ctx, username)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return p.provisionUser(ctx, entry, username, email, roles)
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	// Logging in to a local account would let whoever controls the directory take it over
+	if createdBy, _ := user.Metadata["created_by"].(string); createdBy != p.config.Name {
+		return nil, ErrAccountConflict
+	}
+	
+	if user.Email == email && equalRoles(user.Roles, roles) && user.Metadata[local.MetadataDirectoryDN] == entry.DN {
+		return user, nil
+	}
+	if email != "" {
+		user.Email = email
+	}
+	user.Roles = roles
+	user.Metadata[local.MetadataDirectoryDN] = entry.DN
+	if err := p.userStore.Update(ctx, user); err != nil {
+		if errors.Is(err, local.ErrEmailTaken) {
+			return nil, fmt.Errorf("%w: %v", ErrAccountConflict, err)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// checks that one of this directory's shadow users is still in the directory. Users who were
+// deleted there, or whom UserFilter no longer finds, e.g. because it leaves out disabled
+// accounts, get local.ErrAccountDisabled. Implements local.AccountCheck, so refreshing
+// tokens ends their sessions.
+func (p *Provider) CheckAccount(ctx context.Context, user *local.StoredUser) error {
+	if createdBy, _ := user.Metadata["created_by"].(string); createdBy != p.config.Name {
+		return nil
+	}
+	
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	
+	entry, err := p.findUser(ctx, conn, user.Username)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return local.ErrAccountDisabled
+	}
+	if err != nil {
+		return err
+	}
+	
+	// Another entry with the same username isn't the user who logged in
+	if entry.DN != user.Metadata[local.MetadataDirectoryDN] {
+		return local.ErrAccountDisabled
+	}
+	return nil
+}
+
+// creates the shadow user of a directory user logging in for the first time
+func (p *Provider) provisionUser(ctx context.Context, entry *protocol.Entry, username string, email string, roles []string) (*local.StoredUser, error) {
+	if !p.config.ProvisionUsers {
+		return nil, ErrNotProvisioned
+	}
+	if email == "" {
+		return nil, fmt.Errorf("directory entry %q has no %s attribute", entry.DN, p.config.EmailAttribute)
+	}
+	
+	// The directory vouches for the address, and shadow users can only log in through it
+	user := &local.StoredUser{
+		Username:      username,
+		Email:         email,
+		EmailVerified: true,
+		Roles:         roles,
+		Metadata: map[string]interface{}{
+			"created_by":              p.config.Name,
+			local.MetadataDirectoryDN: entry.DN,
+		},
+	}
+	if err := p.userStore.Create(ctx, user); err != nil {
+		if errors.Is(err, local.ErrUsernameTaken) || errors.Is(err, local.ErrEmailTaken) {
+			return nil, fmt.Errorf("%w: %v", ErrAccountConflict, err)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// reports whether two role lists have the same roles in the same order
+func equalRoles(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+)
+
+var ErrEmailNotVerified = errors.New("email address not verified")
+
+// confirms the email address a verification token was sent to.
+// Tokens are single use and stop working if the user changes their address in the meantime.
+func (p *Provider) VerifyEmail(ctx context.Context, token string) (*StoredUser, error) {
+	stored, err := p.oneTimeStore.Consume(ctx, hashOpaqueToken(token), PurposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	
+	user, err := p.userStore.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrInvalidOneTimeToken
+		}
+		return nil, err
+	}
+	if user.Email != stored.Email {
+		return nil, ErrInvalidOneTimeToken
+	}
+	
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		if err := p.userStore.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	
+	return user, nil
+}
+
+// sends a new verification link to the user with this email address.
+// Unknown and already verified addresses are silently ignored, so the
+// response doesn't reveal which addresses have accounts.
+func (p *Provider) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := p.userStore.GetByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	if user.EmailVerified {
+		return nil
+	}
+	
+	return p.sendVerificationEmail(ctx, user)
+}
+
+// issues a verification token for the user's current address and emails it.
+// Links sent earlier stop working. Does nothing when no mailer is configured.
+func (p *Provider) sendVerificationEmail(ctx context.Context, user *StoredUser) error {
+	if p.mailer == nil {
+		return nil
+	}
+	
+	token, err := p.issueOneTimeToken(ctx, user, PurposeVerifyEmail, p.config.EmailVerificationExpiration)
+	if err != nil {
+		return err
+	}
+	
+	link := strings.TrimSuffix(p.config.PublicURL, "/") + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return p.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't create an account, you can ignore this email.\n",
+			user.Username, link, p.config.EmailVerificationExpiration),
+	})
+}
+
+// creates a one-time token for the user's current email address and returns it.
+// Tokens issued earlier for the same purpose are deleted, so only the newest link works.
+func (p *Provider) issueOneTimeToken(ctx context.Context, user *StoredUser, purpose string, lifetime time.Duration) (string, error) {
+	if err := p.oneTimeStore.DeleteByUser(ctx, user.ID, purpose); err != nil {
+		return "", err
+	}
+	
+	token, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	
+	now := time.Now()
+	err = p.oneTimeStore.Create(ctx, &OneTimeToken{
+		TokenHash: tokenHash,
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	return token, nil
+}
@@ -0,0 +1,266 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+)
+
+const PurposeLinkIdentity = "link_identity"
+
+var ErrLastLoginMethod = errors.New("the user has no other way to log in")
+
+// ExternalIdentity is a user as an external identity provider knows them
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Username      string // Preferred username; made unique when an account is created
+	Email         string
+	EmailVerified bool     // Whether the identity provider vouches for Email
+	Roles         []string // Roles the identity provider gives the user; only Config.IdentityRoles are kept
+}
+
+// logs in the user linked to an external identity. Unknown identities are linked to
+// the user with the same email address if Config.LinkVerifiedEmail allows it, and
+// get a new account otherwise, unless registration is disabled.
+// Accounts created this way have no password. Directory users only log in through
+// the directory, so they get ErrDirectoryManaged.
+func (p *Provider) LoginWithIdentity(ctx context.Context, external ExternalIdentity) (*auth.User, error) {
+	if external.Provider == "" || external.Subject == "" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	now := time.Now()
+	user, err := p.linkedUser(ctx, external, now)
+	if errors.Is(err, ErrIdentityNotFound) {
+		user, err = p.userForNewIdentity(ctx, external)
+		if err != nil {
+			return nil, err
+		}
+		err = p.identityStore.Create(ctx, &Identity{
+			Provider:    external.Provider,
+			Subject:     external.Subject,
+			UserID:      user.ID,
+			Email:       external.Email,
+			CreatedAt:   now,
+			LastLoginAt: now,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	// Identities linked before the account moved to a directory don't get around it
+	if DirectoryManaged(user) {
+		return nil, ErrDirectoryManaged
+	}
+	
+	if p.config.RequireVerifiedEmail && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	
+	return toAuthUser(user), nil
+}
+
+// links an external identity to a user who is already logged in. Linking an identity
+// the user already has does nothing; identities linked to someone else return ErrIdentityLinked.
+// Directory users can't link identities and get ErrDirectoryManaged.
+func (p *Provider) LinkIdentity(ctx context.Context, userID string, external ExternalIdentity) (*Identity, error) {
+	if external.Provider == "" || external.Subject == "" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	existing, err := p.identityStore.Get(ctx, external.Provider, external.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			return nil, ErrIdentityLinked
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, err
+	}
+	
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if DirectoryManaged(user) {
+		return nil, ErrDirectoryManaged
+	}
+	
+	now := time.Now()
+	identity := &Identity{
+		Provider:    external.Provider,
+		Subject:     external.Subject,
+		UserID:      userID,
+		Email:       external.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	if err := p.identityStore.Create(ctx, identity); err != nil {
+		return nil, err
+	}
+	
+	return identity, nil
+}
+
+// returns the external identities linked to a user, oldest first
+func (p *Provider) ListIdentities(ctx context.Context, userID string) ([]*Identity, error) {
+	return p.identityStore.ListByUser(ctx, userID)
+}
+
+// unlinks one of the user's external identities. Users without a password
+// can't unlink their last identity, as they couldn't log in anymore; set a
+// password first. Passkeys aren't taken into account.
+func (p *Provider) UnlinkIdentity(ctx context.Context, userID string, provider string, subject string) error {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	
+	if user.PasswordHash == "" {
+		identities, err := p.identityStore.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(identities) == 1 && identities[0].Provider == provider && identities[0].Subject == subject {
+			return ErrLastLoginMethod
+		}
+	}
+	
+	return p.identityStore.Delete(ctx, userID, provider, subject)
+}
+
+// remembers that the external login with this state links an identity to the user
+// rather than logging someone in. The state has to be bound to the user's browser.
+func (p *Provider) StartIdentityLink(ctx context.Context, userID string, state string) error {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	return p.oneTimeStore.Create(ctx, &OneTimeToken{
+		TokenHash: hashOpaqueToken(state),
+		UserID:    user.ID,
+		Purpose:   PurposeLinkIdentity,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.config.IdentityLinkExpiration),
+	})
+}
+
+// returns the ID of the user who started the link with this state, once.
+// Returns ErrInvalidOneTimeToken if the external login wasn't started with StartIdentityLink.
+func (p *Provider) ConsumeIdentityLink(ctx context.Context, state string) (string, error) {
+	token, err := p.oneTimeStore.Consume(ctx, hashOpaqueToken(state), PurposeLinkIdentity)
+	if err != nil {
+		return "", err
+	}
+	return token.UserID, nil
+}
+
+// returns the user an identity is linked to and records the login
+func (p *Provider) linkedUser(ctx context.Context, external ExternalIdentity, now time.Time) (*StoredUser, error) {
+	identity, err := p.identityStore.Get(ctx, external.Provider, external.Subject)
+	if err != nil {
+		return nil, err
+	}
+	
+	user, err := p.userStore.GetByID(ctx, identity.UserID)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := p.identityStore.Touch(ctx, external.Provider, external.Subject, now); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// finds the user to link a new identity to, or creates one
+func (p *Provider) userForNewIdentity(ctx context.Context, external ExternalIdentity) (*StoredUser, error) {
+	// Both sides must have verified the address, or whoever controls an unverified one could take over the other account
+	if p.config.LinkVerifiedEmail && external.EmailVerified && external.Email != "" {
+		user, err := p.userStore.GetByEmail(ctx, external.Email)
+		if err == nil && user.EmailVerified && !DirectoryManaged(user) {
+			return user, nil
+		}
+		if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+			return nil, err
+		}
+	}
+	
+	if p.config.DisableRegistration {
+		return nil, ErrRegistrationDisabled
+	}
+	
+	address, err := mail.ParseAddress(external.Email)
+	if err != nil || address.Address != external.Email {
+		return nil, ErrInvalidEmail
+	}
+	
+	// Identity providers only hand out the roles they are trusted with
+	var roles []string
+	for _, role := range external.Roles {
+		if slices.Contains(p.config.IdentityRoles, role) && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		roles = []string{"user"}
+	}
+	user := &StoredUser{
+		Email:         external.Email,
+		EmailVerified: external.EmailVerified,
+		Roles:         roles,
+		Metadata:      map[string]interface{}{"created_by": external.Provider},
+	}
+	
+	// Someone else may have the username already
+	base := identityUsername(external)
+	for attempt := 1; attempt <= 10; attempt++ {
+		user.Username = base
+		if attempt > 1 {
+			user.Username = fmt.Sprintf("%s-%d", base, attempt)
+		}
+	
+		err = p.userStore.Create(ctx, user)
+		if !errors.Is(err, ErrUsernameTaken) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	if !user.EmailVerified {
+		p.trySendVerificationEmail(ctx, user)
+	}
+	return user, nil
+}
+
+// picks the username of an account created for an external identity: the identity
+// provider's preferred username if it's valid, then the email address's local part,
+// and otherwise the provider and subject with invalid characters replaced
+func identityUsername(external ExternalIdentity) string {
+	name, _, _ := strings.Cut(external.Email, "@")
+	for _, candidate := range []string{external.Username, name} {
+		if username, err := normalizeUsername(candidate); err == nil {
+			return username
+		}
+	}
+	
+	username := invalidUsername.ReplaceAllString(external.Provider+"-"+external.Subject, "-")
+	if len(username) > 64 {
+		username = username[:64]
+	}
+	return username
+}
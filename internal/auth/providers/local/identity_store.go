@@ -0,0 +1,43 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrIdentityLinked   = errors.New("identity is already linked to a user")
+)
+
+// Identity links an account at an external identity provider to a local user.
+// The provider's subject identifies the account; unlike the email address it never changes.
+type Identity struct {
+	Provider    string // Name of the external provider, e.g. "google"
+	Subject     string // The user's ID at the provider
+	UserID      string
+	Email       string // Address the provider reported when the identity was linked
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// IdentityStore persists the external identities linked to users
+type IdentityStore interface {
+	// returns ErrIdentityLinked if the identity is already linked to a user
+	Create(ctx context.Context, identity *Identity) error
+	
+	// returns ErrIdentityNotFound if the identity isn't linked to any user
+	Get(ctx context.Context, provider string, subject string) (*Identity, error)
+	
+	// returns the user's identities, oldest first
+	ListByUser(ctx context.Context, userID string) ([]*Identity, error)
+	
+	// records a login with the identity
+	Touch(ctx context.Context, provider string, subject string, loginAt time.Time) error
+	
+	// returns ErrIdentityNotFound if the identity isn't linked to this user
+	Delete(ctx context.Context, userID string, provider string, subject string) error
+	
+	DeleteByUser(ctx context.Context, userID string) error
+}
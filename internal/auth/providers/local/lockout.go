@@ -0,0 +1,226 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+)
+
+var ErrLoginThrottled = errors.New("too many failed login attempts")
+
+// LoginThrottledError is returned while an account or IP address is locked out after failed logins
+type LoginThrottledError struct {
+	RetryAfter time.Duration // Time left until logins are accepted again
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrLoginThrottled
+}
+
+// authenticates like Authenticate and also throttles the client's IP address
+// once it has made Config.MaxFailedLoginsPerIP failed attempts
+func (p *Provider) AuthenticateFrom(ctx context.Context, creds auth.Credentials, client ClientInfo) (*auth.User, error) {
+	return p.throttleIP(ctx, client, func() (*auth.User, error) {
+		return p.Authenticate(ctx, creds)
+	})
+}
+
+// checks a password with another provider, such as a directory, with the same account lockout,
+// delays and IP throttling as local logins. Failed attempts are counted per provider and username,
+// as the provider's usernames aren't local ones.
+func (p *Provider) AuthenticateWith(ctx context.Context, provider auth.Provider, creds auth.Credentials, client ClientInfo) (*auth.User, error) {
+	return p.throttleIP(ctx, client, func() (*auth.User, error) {
+		key := providerAttemptKey(provider.Name(), creds.Username)
+		if p.config.MaxFailedLogins > 0 {
+			if err := p.checkLoginAttempts(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		
+		user, err := provider.Authenticate(ctx, creds)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, p.failLogin(ctx, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		
+		// Second factors are counted under the local username, so this doesn't help guess them
+		if p.config.MaxFailedLogins > 0 {
+			if err := p.attemptStore.Reset(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		return user, nil
+	})
+}
+
+// runs a login attempt, throttling the client's IP address once it has made
+// Config.MaxFailedLoginsPerIP failed attempts
+func (p *Provider) throttleIP(ctx context.Context, client ClientInfo, authenticate func() (*auth.User, error)) (*auth.User, error) {
+	if p.config.MaxFailedLoginsPerIP <= 0 || client.IPAddress == "" {
+		return authenticate()
+	}
+	
+	key := IPAttemptPrefix + client.IPAddress
+	if err := p.checkLoginAttempts(ctx, key); err != nil {
+		return nil, err
+	}
+	
+	user, err := authenticate()
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		return user, err
+	}
+	
+	attempts, recordErr := p.attemptStore.RecordFailure(ctx, key, time.Now(), p.config.LockoutDuration)
+	if recordErr != nil {
+		return nil, recordErr
+	}
+	if attempts.Failures >= p.config.MaxFailedLoginsPerIP {
+		if lockErr := p.attemptStore.Lock(ctx, key, attempts.LastFailure.Add(p.config.LockoutDuration)); lockErr != nil {
+			return nil, lockErr
+		}
+	}
+	
+	return nil, err
+}
+
+// returns the lockout state of a user's account
+func (p *Provider) AccountLockout(ctx context.Context, userID string) (*LoginAttempts, error) {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Directory users' passwords are checked by the directory
+	if createdBy, _ := user.Metadata["created_by"].(string); DirectoryManaged(user) && createdBy != "" {
+		return p.attemptStore.Get(ctx, providerAttemptKey(createdBy, user.Username))
+	}
+	return p.attemptStore.Get(ctx, AccountAttemptPrefix+user.Username)
+}
+
+// unlocks a user's account and forgets their failed logins
+func (p *Provider) ClearAccountLockout(ctx context.Context, userID string) error {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	
+	if createdBy, _ := user.Metadata["created_by"].(string); DirectoryManaged(user) && createdBy != "" {
+		if err := p.attemptStore.Reset(ctx, providerAttemptKey(createdBy, user.Username)); err != nil {
+			return err
+		}
+	}
+	return p.attemptStore.Reset(ctx, AccountAttemptPrefix+user.Username)
+}
+
+// returns the accounts, IP addresses and email addresses that are currently locked out
+func (p *Provider) ListLockouts(ctx context.Context) ([]*LoginAttempts, error) {
+	return p.attemptStore.ListLocked(ctx, time.Now())
+}
+
+// unblocks an IP address and forgets its failed logins and requested emails
+func (p *Provider) ClearIPLockout(ctx context.Context, ip string) error {
+	if err := p.attemptStore.Reset(ctx, IPAttemptPrefix+ip); err != nil {
+		return err
+	}
+	return p.attemptStore.Reset(ctx, EmailIPAttemptPrefix+ip)
+}
+
+// counts a request for a password reset or login link email to an address. Once the address
+// or the client's IP address has asked for too many within Config.EmailRequestWindow, further
+// requests get a LoginThrottledError. Requests for addresses without an account are counted too,
+// so the limits don't reveal which addresses have one.
+func (p *Provider) ThrottleEmailRequest(ctx context.Context, email string, client ClientInfo) error {
+	limits := map[string]int{
+		EmailAttemptPrefix + strings.ToLower(strings.TrimSpace(email)): p.config.MaxEmailsPerAddress,
+	}
+	if client.IPAddress != "" {
+		limits[EmailIPAttemptPrefix+client.IPAddress] = p.config.MaxEmailRequestsPerIP
+	}
+	
+	for key, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+		if err := p.checkLoginAttempts(ctx, key); err != nil {
+			return err
+		}
+	}
+	
+	for key, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+		attempts, err := p.attemptStore.RecordFailure(ctx, key, time.Now(), p.config.EmailRequestWindow)
+		if err != nil {
+			return err
+		}
+		if attempts.Failures >= limit {
+			if err := p.attemptStore.Lock(ctx, key, attempts.LastFailure.Add(p.config.EmailRequestWindow)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// returns a LoginThrottledError if logins for the key are locked
+func (p *Provider) checkLoginAttempts(ctx context.Context, key string) error {
+	attempts, err := p.attemptStore.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	if attempts.Locked(now) {
+		return &LoginThrottledError{RetryAfter: attempts.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// counts a failed login for an account and locks it if needed.
+// Returns auth.ErrInvalidCredentials unless the failure couldn't be recorded.
+func (p *Provider) failLogin(ctx context.Context, key string) error {
+	if p.config.MaxFailedLogins <= 0 {
+		return auth.ErrInvalidCredentials
+	}
+	
+	attempts, err := p.attemptStore.RecordFailure(ctx, key, time.Now(), p.config.LockoutDuration)
+	if err != nil {
+		return err
+	}
+	
+	// Slow down guessing with growing delays, then lock the account
+	var lockFor time.Duration
+	switch {
+	case attempts.Failures >= p.config.MaxFailedLogins:
+		lockFor = p.config.LockoutDuration
+	case attempts.Failures >= 2 && p.config.FailedLoginDelay > 0:
+		lockFor = p.config.LockoutDuration
+		if shift := attempts.Failures - 2; shift < 20 {
+			lockFor = min(p.config.FailedLoginDelay<<shift, lockFor)
+		}
+	}
+	
+	if lockFor > 0 {
+		if err := p.attemptStore.Lock(ctx, key, attempts.LastFailure.Add(lockFor)); err != nil {
+			return err
+		}
+	}
+	
+	return auth.ErrInvalidCredentials
+}
+
+// returns the key of failed logins to a username at another provider. Usernames are
+// compared case-insensitively, like directories usually do.
+func providerAttemptKey(provider string, username string) string {
+	return AccountAttemptPrefix + provider + ":" + strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,50 @@
+package local
+
+import (
+	"context"
+	"time"
+)
+
+// Prefixes of LoginAttempts keys
+const (
+	AccountAttemptPrefix = "account:" // Followed by the username that was tried, after the provider and a colon for other providers
+	IPAttemptPrefix      = "ip:"      // Followed by the client's IP address
+	
+	EmailAttemptPrefix   = "email:"    // Followed by an address password reset or login link emails were asked for
+	EmailIPAttemptPrefix = "email-ip:" // Followed by the IP address of a client that asked for such emails
+)
+
+// LoginAttempts counts the recent failed logins for an account or IP address
+type LoginAttempts struct {
+	Key         string
+	Failures    int // Consecutive failed attempts
+	LastFailure time.Time
+	LockedUntil time.Time // Logins are refused until then
+	ExpiresAt   time.Time // When the record is forgotten and counting starts over
+}
+
+// reports whether logins are refused at the given time
+func (a *LoginAttempts) Locked(at time.Time) bool {
+	return at.Before(a.LockedUntil)
+}
+
+// LoginAttemptStore persists failed login counters
+type LoginAttemptStore interface {
+	// returns the record for the key. Missing and expired records are returned empty.
+	Get(ctx context.Context, key string) (*LoginAttempts, error)
+	
+	// atomically counts a failed attempt and returns the updated record.
+	// The record is kept for at least window after the attempt.
+	RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*LoginAttempts, error)
+	
+	// refuses logins for the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	
+	// forgets the key's failed attempts, e.g. after a successful login
+	Reset(ctx context.Context, key string) error
+	
+	// returns the records that are locked at the given time, the longest lockout first
+	ListLocked(ctx context.Context, at time.Time) ([]*LoginAttempts, error)
+	
+	CleanupExpiredAttempts(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,116 @@
+package local
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryIdentityStore implements IdentityStore with in-memory storage
+type MemoryIdentityStore struct {
+	identities map[string]*Identity // Indexed by provider and subject
+	mu         sync.RWMutex
+}
+
+// NewMemoryIdentityStore creates a new in-memory identity store
+func NewMemoryIdentityStore() *MemoryIdentityStore {
+	return &MemoryIdentityStore{
+		identities: make(map[string]*Identity),
+	}
+}
+
+// identityKey joins provider and subject into a map key. Provider names can't contain NUL.
+func identityKey(provider string, subject string) string {
+	return provider + "\x00" + subject
+}
+
+// Create links a new identity
+func (s *MemoryIdentityStore) Create(ctx context.Context, identity *Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	key := identityKey(identity.Provider, identity.Subject)
+	if _, exists := s.identities[key]; exists {
+		return ErrIdentityLinked
+	}
+	
+	identityCopy := *identity
+	s.identities[key] = &identityCopy
+	return nil
+}
+
+// Get retrieves an identity by provider and subject
+func (s *MemoryIdentityStore) Get(ctx context.Context, provider string, subject string) (*Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	identity, exists := s.identities[identityKey(provider, subject)]
+	if !exists {
+		return nil, ErrIdentityNotFound
+	}
+	
+	identityCopy := *identity
+	return &identityCopy, nil
+}
+
+// ListByUser returns the user's identities, oldest first
+func (s *MemoryIdentityStore) ListByUser(ctx context.Context, userID string) ([]*Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	identities := []*Identity{}
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identityCopy := *identity
+			identities = append(identities, &identityCopy)
+		}
+	}
+	
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].CreatedAt.Before(identities[j].CreatedAt)
+	})
+	return identities, nil
+}
+
+// Touch records a login with the identity
+func (s *MemoryIdentityStore) Touch(ctx context.Context, provider string, subject string, loginAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	identity, exists := s.identities[identityKey(provider, subject)]
+	if !exists {
+		return ErrIdentityNotFound
+	}
+	
+	identity.LastLoginAt = loginAt
+	return nil
+}
+
+// Delete unlinks one of the user's identities
+func (s *MemoryIdentityStore) Delete(ctx context.Context, userID string, provider string, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	key := identityKey(provider, subject)
+	identity, exists := s.identities[key]
+	if !exists || identity.UserID != userID {
+		return ErrIdentityNotFound
+	}
+	
+	delete(s.identities, key)
+	return nil
+}
+
+// DeleteByUser unlinks all of the user's identities
+func (s *MemoryIdentityStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for key, identity := range s.identities {
+		if identity.UserID == userID {
+			delete(s.identities, key)
+		}
+	}
+	return nil
+}
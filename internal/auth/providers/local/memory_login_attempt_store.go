@@ -0,0 +1,121 @@
+package local
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptStore implements LoginAttemptStore with in-memory storage
+type MemoryLoginAttemptStore struct {
+	attempts map[string]*LoginAttempts // Indexed by key
+	mu       sync.Mutex
+}
+
+// NewMemoryLoginAttemptStore creates a new in-memory login attempt store
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: make(map[string]*LoginAttempts),
+	}
+}
+
+// Get returns the record for the key, or an empty one
+func (s *MemoryLoginAttemptStore) Get(ctx context.Context, key string) (*LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	attempts, exists := s.attempts[key]
+	if !exists || time.Now().After(attempts.ExpiresAt) {
+		return &LoginAttempts{Key: key}, nil
+	}
+	
+	attemptsCopy := *attempts
+	return &attemptsCopy, nil
+}
+
+// RecordFailure counts a failed attempt
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	attempts, exists := s.attempts[key]
+	if !exists || at.After(attempts.ExpiresAt) {
+		attempts = &LoginAttempts{Key: key}
+		s.attempts[key] = attempts
+	}
+	
+	attempts.Failures++
+	attempts.LastFailure = at
+	if expiresAt := at.Add(window); expiresAt.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = expiresAt
+	}
+	
+	attemptsCopy := *attempts
+	return &attemptsCopy, nil
+}
+
+// Lock refuses logins for the key until the given time
+func (s *MemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	attempts, exists := s.attempts[key]
+	if !exists {
+		attempts = &LoginAttempts{Key: key}
+		s.attempts[key] = attempts
+	}
+	
+	attempts.LockedUntil = until
+	if until.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = until
+	}
+	return nil
+}
+
+// Reset forgets the key's failed attempts
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.attempts, key)
+	return nil
+}
+
+// ListLocked returns the records that are locked at the given time
+func (s *MemoryLoginAttemptStore) ListLocked(ctx context.Context, at time.Time) ([]*LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	locked := make([]*LoginAttempts, 0)
+	for _, attempts := range s.attempts {
+		if attempts.Locked(at) {
+			attemptsCopy := *attempts
+			locked = append(locked, &attemptsCopy)
+		}
+	}
+	
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].LockedUntil.After(locked[j].LockedUntil)
+	})
+	
+	return locked, nil
+}
+
+// CleanupExpiredAttempts removes expired records
+func (s *MemoryLoginAttemptStore) CleanupExpiredAttempts(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	
+	for key, attempts := range s.attempts {
+		if now.After(attempts.ExpiresAt) {
+			delete(s.attempts, key)
+			count++
+		}
+	}
+	
+	return count, nil
+}
@@ -0,0 +1,77 @@
+package local
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryOneTimeTokenStore implements OneTimeTokenStore with in-memory storage
+type MemoryOneTimeTokenStore struct {
+	tokens map[string]*OneTimeToken // Indexed by token hash
+	mu     sync.Mutex
+}
+
+// NewMemoryOneTimeTokenStore creates a new in-memory one-time token store
+func NewMemoryOneTimeTokenStore() *MemoryOneTimeTokenStore {
+	return &MemoryOneTimeTokenStore{
+		tokens: make(map[string]*OneTimeToken),
+	}
+}
+
+// Create stores a new token
+func (s *MemoryOneTimeTokenStore) Create(ctx context.Context, token *OneTimeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	tokenCopy := *token
+	s.tokens[token.TokenHash] = &tokenCopy
+	return nil
+}
+
+// Consume deletes and returns a token
+func (s *MemoryOneTimeTokenStore) Consume(ctx context.Context, tokenHash string, purpose string) (*OneTimeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	token, exists := s.tokens[tokenHash]
+	if !exists || token.Purpose != purpose {
+		return nil, ErrInvalidOneTimeToken
+	}
+	
+	delete(s.tokens, tokenHash)
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidOneTimeToken
+	}
+	
+	return token, nil
+}
+
+// DeleteByUser removes the user's tokens for a purpose
+func (s *MemoryOneTimeTokenStore) DeleteByUser(ctx context.Context, userID string, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for hash, token := range s.tokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// CleanupExpiredTokens removes expired tokens
+func (s *MemoryOneTimeTokenStore) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	for hash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+			count++
+		}
+	}
+	return count, nil
+}
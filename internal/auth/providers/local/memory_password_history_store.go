@@ -0,0 +1,61 @@
+package local
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPasswordHistoryStore implements PasswordHistoryStore with in-memory storage
+type MemoryPasswordHistoryStore struct {
+	hashes map[string][]string // Previous hashes by user ID, newest first
+	mu     sync.RWMutex
+}
+
+// NewMemoryPasswordHistoryStore creates a new in-memory password history store
+func NewMemoryPasswordHistoryStore() *MemoryPasswordHistoryStore {
+	return &MemoryPasswordHistoryStore{
+		hashes: make(map[string][]string),
+	}
+}
+
+// Add records a previous password hash, keeping only the newest keep entries
+func (s *MemoryPasswordHistoryStore) Add(ctx context.Context, userID string, passwordHash string, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if keep <= 0 {
+		delete(s.hashes, userID)
+		return nil
+	}
+	
+	hashes := append([]string{passwordHash}, s.hashes[userID]...)
+	if len(hashes) > keep {
+		hashes = hashes[:keep]
+	}
+	s.hashes[userID] = hashes
+	return nil
+}
+
+// Recent returns up to limit of the user's previous password hashes, newest first
+func (s *MemoryPasswordHistoryStore) Recent(ctx context.Context, userID string, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	hashes := s.hashes[userID]
+	if limit < len(hashes) {
+		hashes = hashes[:max(limit, 0)]
+	}
+	
+	result := make([]string, len(hashes))
+	copy(result, hashes)
+	return result, nil
+}
+
+// DeleteByUser forgets the user's password history
+func (s *MemoryPasswordHistoryStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.hashes, userID)
+	return nil
+}
@@ -0,0 +1,118 @@
+package local
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRefreshTokenStore implements RefreshTokenStore with in-memory storage
+type MemoryRefreshTokenStore struct {
+	tokens map[string]*RefreshToken // Indexed by token hash
+	mu     sync.RWMutex
+}
+
+// NewMemoryRefreshTokenStore creates a new in-memory refresh token store
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens: make(map[string]*RefreshToken),
+	}
+}
+
+// Create stores a new refresh token
+func (s *MemoryRefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	tokenCopy := *token
+	s.tokens[token.TokenHash] = &tokenCopy
+	return nil
+}
+
+// Get retrieves a refresh token by its hash
+func (s *MemoryRefreshTokenStore) Get(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	token, exists := s.tokens[tokenHash]
+	if !exists {
+		return nil, ErrInvalidRefreshToken
+	}
+	
+	tokenCopy := *token
+	return &tokenCopy, nil
+}
+
+// Rotate marks the old token used and stores its replacement
+func (s *MemoryRefreshTokenStore) Rotate(ctx context.Context, oldTokenHash string, next *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	old, exists := s.tokens[oldTokenHash]
+	if !exists {
+		return ErrInvalidRefreshToken
+	}
+	if old.UsedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	
+	usedAt := time.Now()
+	old.UsedAt = &usedAt
+	
+	nextCopy := *next
+	s.tokens[next.TokenHash] = &nextCopy
+	return nil
+}
+
+// Delete removes a refresh token
+func (s *MemoryRefreshTokenStore) Delete(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.tokens, tokenHash)
+	return nil
+}
+
+// DeleteFamily removes every token in a family
+func (s *MemoryRefreshTokenStore) DeleteFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for tokenHash, token := range s.tokens {
+		if token.FamilyID == familyID {
+			delete(s.tokens, tokenHash)
+		}
+	}
+	return nil
+}
+
+// DeleteByUser removes every token issued to a user
+func (s *MemoryRefreshTokenStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for tokenHash, token := range s.tokens {
+		if token.UserID == userID {
+			delete(s.tokens, tokenHash)
+		}
+	}
+	return nil
+}
+
+// CleanupExpiredTokens removes expired refresh tokens
+func (s *MemoryRefreshTokenStore) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	
+	for tokenHash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, tokenHash)
+			count++
+		}
+	}
+	
+	return count, nil
+}
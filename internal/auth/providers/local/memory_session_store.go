@@ -0,0 +1,122 @@
+package local
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemorySessionStore implements SessionStore with in-memory storage
+type MemorySessionStore struct {
+	sessions map[string]*Session // Indexed by session ID
+	mu       sync.RWMutex
+}
+
+// NewMemorySessionStore creates a new in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Create stores a new session
+func (s *MemorySessionStore) Create(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	sessionCopy := *session
+	s.sessions[session.ID] = &sessionCopy
+	return nil
+}
+
+// Get retrieves a session by ID
+func (s *MemorySessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+// ListByUser returns the user's unexpired sessions, most recently seen first
+func (s *MemorySessionStore) ListByUser(ctx context.Context, userID string) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	now := time.Now()
+	sessions := make([]*Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessionCopy := *session
+			sessions = append(sessions, &sessionCopy)
+		}
+	}
+	
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	
+	return sessions, nil
+}
+
+// Touch records activity on a session
+func (s *MemorySessionStore) Touch(ctx context.Context, id string, tokenID string, seenAt time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	session, exists := s.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	
+	session.TokenID = tokenID
+	session.LastSeenAt = seenAt
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+// Delete removes a session
+func (s *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteByUser removes every session of a user
+func (s *MemorySessionStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// CleanupExpiredSessions removes expired sessions
+func (s *MemorySessionStore) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+			count++
+		}
+	}
+	
+	return count, nil
+}
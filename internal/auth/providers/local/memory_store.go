@@ -2,7 +2,9 @@ package local
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -69,11 +71,11 @@ func (s *MemoryUserStore) Create(ctx context.Context, user *StoredUser) error {
 	
 	// Check if username or email already exists
 	if _, exists := s.usernames[user.Username]; exists {
-		return errors.New("username already exists")
+		return ErrUsernameTaken
 	}
 	
 	if _, exists := s.emails[user.Email]; exists {
-		return errors.New("email already exists")
+		return ErrEmailTaken
 	}
 	
 	// Generate ID if not provided
@@ -108,24 +110,25 @@ func (s *MemoryUserStore) Update(ctx context.Context, user *StoredUser) error {
 		return auth.ErrUserNotFound
 	}
 	
-	// Check for username change
-	if user.Username != existingUser.Username {
-		if _, exists := s.usernames[user.Username]; exists {
-			return errors.New("username already exists")
-		}
-		
-		// Update username mapping
+	// Check for conflicts before touching the indexes
+	usernameChanged := user.Username != existingUser.Username
+	if _, exists := s.usernames[user.Username]; usernameChanged && exists {
+		return ErrUsernameTaken
+	}
+	
+	emailChanged := user.Email != existingUser.Email
+	if _, exists := s.emails[user.Email]; emailChanged && exists {
+		return ErrEmailTaken
+	}
+	
+	// Update username mapping
+	if usernameChanged {
 		delete(s.usernames, existingUser.Username)
 		s.usernames[user.Username] = user.ID
 	}
 	
-	// Check for email change
-	if user.Email != existingUser.Email {
-		if _, exists := s.emails[user.Email]; exists {
-			return errors.New("email already exists")
-		}
-		
-		// Update email mapping
+	// Update email mapping
+	if emailChanged {
 		delete(s.emails, existingUser.Email)
 		s.emails[user.Email] = user.ID
 	}
@@ -157,6 +160,111 @@ func (s *MemoryUserStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// List returns one page of users matching the query, ordered by CreatedAt then ID
+func (s *MemoryUserStore) List(ctx context.Context, query UserQuery) (*UserPage, error) {
+	var afterTime time.Time
+	var afterID string
+	if query.Cursor != "" {
+		var err error
+		afterTime, afterID, err = DecodeUserCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	matches := make([]*StoredUser, 0)
+	for _, user := range s.users {
+		if matchesUserQuery(user, query) {
+			matches = append(matches, user)
+		}
+	}
+	
+	// Order by creation time, then ID to break ties
+	sort.Slice(matches, func(i, j int) bool {
+		cmp := compareUserPosition(matches[i], time.Unix(matches[j].CreatedAt, 0), matches[j].ID)
+		if query.Descending {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+	
+	page := &UserPage{Users: make([]*StoredUser, 0)}
+	limit := query.PageSize()
+	for _, user := range matches {
+		// Skip users up to and including the cursor
+		if query.Cursor != "" {
+			cmp := compareUserPosition(user, afterTime, afterID)
+			if (!query.Descending && cmp <= 0) || (query.Descending && cmp >= 0) {
+				continue
+			}
+		}
+		
+		if len(page.Users) == limit {
+			last := page.Users[limit-1]
+			page.NextCursor = EncodeUserCursor(time.Unix(last.CreatedAt, 0), last.ID)
+			break
+		}
+		page.Users = append(page.Users, cloneUser(user))
+	}
+	
+	return page, nil
+}
+
+// compareUserPosition orders a user against a (creation time, ID) position
+func compareUserPosition(user *StoredUser, createdAt time.Time, id string) int {
+	userTime := time.Unix(user.CreatedAt, 0)
+	switch {
+	case userTime.Before(createdAt):
+		return -1
+	case userTime.After(createdAt):
+		return 1
+	}
+	return strings.Compare(user.ID, id)
+}
+
+// matchesUserQuery reports whether the user passes every filter of the query
+func matchesUserQuery(user *StoredUser, query UserQuery) bool {
+	if query.Search != "" {
+		search := strings.ToLower(query.Search)
+		if !strings.Contains(strings.ToLower(user.Username), search) &&
+			!strings.Contains(strings.ToLower(user.Email), search) {
+			return false
+		}
+	}
+	
+	if query.Role != "" {
+		hasRole := false
+		for _, role := range user.Roles {
+			if role == query.Role {
+				hasRole = true
+				break
+			}
+		}
+		if !hasRole {
+			return false
+		}
+	}
+	
+	if query.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(user.Email), "@"+strings.ToLower(query.EmailDomain)) {
+		return false
+	}
+	
+	if query.MetadataKey != "" {
+		value, exists := user.Metadata[query.MetadataKey]
+		if !exists {
+			return false
+		}
+		if query.MetadataValue != "" && fmt.Sprint(value) != query.MetadataValue {
+			return false
+		}
+	}
+	
+	return true
+}
+
 func cloneUser(user *StoredUser) *StoredUser {
 	if user == nil {
 		return nil
@@ -170,14 +278,22 @@ func cloneUser(user *StoredUser) *StoredUser {
 		metadata[k] = v
 	}
 	
+	recoveryCodes := make([]string, len(user.RecoveryCodes))
+	copy(recoveryCodes, user.RecoveryCodes)
+	
 	return &StoredUser{
-		ID:           user.ID,
-		Username:     user.Username,
-		Email:        user.Email,
-		PasswordHash: user.PasswordHash,
-		Roles:        roles,
-		Metadata:     metadata,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PasswordHash:  user.PasswordHash,
+		Roles:         roles,
+		Metadata:      metadata,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		TOTPSecret:    user.TOTPSecret,
+		TOTPEnabled:   user.TOTPEnabled,
+		TOTPLastStep:  user.TOTPLastStep,
+		RecoveryCodes: recoveryCodes,
 	}
 }
\ No newline at end of file
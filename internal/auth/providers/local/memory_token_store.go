@@ -9,6 +9,7 @@ import (
 // MemoryTokenStore implements token revocation with in-memory storage
 type MemoryTokenStore struct {
 	revokedTokens map[string]time.Time // Maps tokenID to expiry time
+	revokedUsers  map[string]time.Time // Maps userID to revocation watermark
 	mu            sync.RWMutex
 }
 
@@ -16,6 +17,7 @@ type MemoryTokenStore struct {
 func NewMemoryTokenStore() *MemoryTokenStore {
 	return &MemoryTokenStore{
 		revokedTokens: make(map[string]time.Time),
+		revokedUsers:  make(map[string]time.Time),
 	}
 }
 
@@ -68,3 +70,26 @@ func (s *MemoryTokenStore) CleanupExpiredTokens(ctx context.Context) (int64, err
 	
 	return count, nil
 }
+
+
+// RevokeAllForUser records a revocation watermark for the user
+func (s *MemoryTokenStore) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	// Never move the watermark backwards
+	if current, exists := s.revokedUsers[userID]; exists && current.After(before) {
+		return nil
+	}
+	
+	s.revokedUsers[userID] = before
+	return nil
+}
+
+// RevokedBefore returns the user's revocation watermark
+func (s *MemoryTokenStore) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	return s.revokedUsers[userID], nil
+}
@@ -0,0 +1,331 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/pkg/totp"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication has not been set up")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
+)
+
+// Number of recovery codes handed out when two-factor authentication is enabled
+const RecoveryCodeCount = 10
+
+// Second factors built into the provider, as listed in MFAChallenge.Methods
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+)
+
+// TOTPEnrollment is what a user needs to add their account to an authenticator app
+type TOTPEnrollment struct {
+	Secret string // Base32 secret for manual entry
+	URI    string // otpauth:// URI, usually shown as a QR code
+}
+
+// MFAChallenge is the second login step a user has to complete after their password
+type MFAChallenge struct {
+	Token   string   // Single-use token identifying the login in progress
+	Methods []string // Second factors the user can complete it with
+}
+
+// SecondFactor is a second factor implemented outside the provider, such as security keys.
+// Users who have one set up get an MFA challenge, which is completed with CompleteMFAChallengeWith.
+type SecondFactor interface {
+	Name() string // listed in MFAChallenge.Methods
+	
+	Enabled(ctx context.Context, userID string) (bool, error)
+}
+
+// adds a second factor users can set up. Call it while setting up the provider, before it serves logins.
+func (p *Provider) AddSecondFactor(factor SecondFactor) {
+	p.secondFactors = append(p.secondFactors, factor)
+}
+
+// starts setting up TOTP for a user with a new secret.
+// Codes aren't required until the user proves their app works with ConfirmTOTP.
+func (p *Provider) EnrollTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	
+	user.TOTPSecret = secret
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.KeyURI(p.config.MFAIssuer, user.Username, secret),
+	}, nil
+}
+
+// turns on TOTP once the user has entered a code from their app, and returns
+// their recovery codes. Only hashes of the codes are stored, so they can't be shown again.
+func (p *Provider) ConfirmTOTP(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	
+	if !p.acceptTOTPCode(user, code) {
+		return nil, ErrInvalidMFACode
+	}
+	
+	codes, err := generateRecoveryCodes(user)
+	if err != nil {
+		return nil, err
+	}
+	
+	user.TOTPEnabled = true
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	
+	return codes, nil
+}
+
+// turns off TOTP after checking a current code or a recovery code
+func (p *Provider) DisableTOTP(ctx context.Context, userID string, code string) error {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrMFANotEnrolled
+	}
+	
+	if !p.acceptSecondFactor(user, code) {
+		return ErrInvalidMFACode
+	}
+	
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	return p.userStore.Update(ctx, user)
+}
+
+// replaces the user's recovery codes after checking a current code or a recovery code
+func (p *Provider) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnrolled
+	}
+	
+	if !p.acceptSecondFactor(user, code) {
+		return nil, ErrInvalidMFACode
+	}
+	
+	codes, err := generateRecoveryCodes(user)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	
+	return codes, nil
+}
+
+// begins the second login step for a user who entered the right password.
+// Returns nil if the user hasn't turned on two-factor authentication.
+func (p *Provider) StartMFAChallenge(ctx context.Context, user *auth.User) (*MFAChallenge, error) {
+	stored, err := p.userStore.GetByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	
+	methods, err := p.mfaMethods(ctx, stored)
+	if err != nil || len(methods) == 0 {
+		return nil, err
+	}
+	
+	challenge, challengeHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	err = p.oneTimeStore.Create(ctx, &OneTimeToken{
+		TokenHash: challengeHash,
+		UserID:    stored.ID,
+		Purpose:   PurposeMFAChallenge,
+		Email:     stored.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.config.MFAChallengeExpiration),
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return &MFAChallenge{Token: challenge, Methods: methods}, nil
+}
+
+// finishes a login with the challenge from StartMFAChallenge and a TOTP or recovery code.
+// A challenge can only be tried once; after a wrong code the user has to enter their password again.
+// Wrong codes count towards the account lockout like wrong passwords.
+func (p *Provider) CompleteMFAChallenge(ctx context.Context, challenge string, code string) (*auth.User, error) {
+	return p.completeMFAChallenge(ctx, challenge, func(user *StoredUser) error {
+		if !user.TOTPEnabled || !p.acceptSecondFactor(user, code) {
+			return ErrInvalidMFACode
+		}
+		return nil
+	})
+}
+
+// finishes a login with the challenge from StartMFAChallenge and a second factor added with AddSecondFactor.
+// verify checks the user's response and returns ErrInvalidMFACode if it's wrong, which counts as a failed login.
+func (p *Provider) CompleteMFAChallengeWith(ctx context.Context, challenge string, verify func(user *auth.User) error) (*auth.User, error) {
+	return p.completeMFAChallenge(ctx, challenge, func(user *StoredUser) error {
+		return verify(toAuthUser(user))
+	})
+}
+
+// consumes the challenge and checks the second factor with verify, which may update the user
+func (p *Provider) completeMFAChallenge(ctx context.Context, challenge string, verify func(user *StoredUser) error) (*auth.User, error) {
+	stored, err := p.oneTimeStore.Consume(ctx, hashOpaqueToken(challenge), PurposeMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+	
+	user, err := p.userStore.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrInvalidOneTimeToken
+		}
+		return nil, err
+	}
+	
+	key := AccountAttemptPrefix + user.Username
+	if err := verify(user); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return nil, err
+		}
+		if err := p.failLogin(ctx, key); !errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+	
+	// Remember a used TOTP code so it can't be replayed
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	
+	if p.config.MaxFailedLogins > 0 {
+		if err := p.attemptStore.Reset(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	
+	return toAuthUser(user), nil
+}
+
+// lists the second factors the user has set up
+func (p *Provider) mfaMethods(ctx context.Context, user *StoredUser) ([]string, error) {
+	var methods []string
+	if user.TOTPEnabled {
+		methods = append(methods, MFAMethodTOTP, MFAMethodRecoveryCode)
+	}
+	
+	for _, factor := range p.secondFactors {
+		enabled, err := factor.Enabled(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			methods = append(methods, factor.Name())
+		}
+	}
+	
+	return methods, nil
+}
+
+// checks a TOTP code or, failing that, a recovery code. Accepted recovery
+// codes are removed from the user; the caller has to store the user.
+func (p *Provider) acceptSecondFactor(user *StoredUser, code string) bool {
+	if p.acceptTOTPCode(user, code) {
+		return true
+	}
+	
+	hash := hashOpaqueToken(normalizeRecoveryCode(code))
+	for i, recoveryCode := range user.RecoveryCodes {
+		if recoveryCode == hash {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	
+	return false
+}
+
+// checks a TOTP code, allowing one time step of clock drift either way.
+// Codes from the last accepted step or earlier are refused, so each code works once;
+// on success the step is recorded on the user, who the caller has to store.
+func (p *Provider) acceptTOTPCode(user *StoredUser, code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1)
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+	
+	user.TOTPLastStep = step
+	return true
+}
+
+// replaces the user's recovery codes with new ones and returns them.
+// Codes look like "abcde-fghij"; the caller has to store the user.
+func generateRecoveryCodes(user *StoredUser) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		
+		code := strings.ToLower(encoding.EncodeToString(raw))
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashOpaqueToken(code))
+	}
+	
+	user.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// strips formatting from a recovery code as typed by the user
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,41 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+
+// Purposes of one-time tokens. A token can only be consumed for the purpose it was issued for.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeMFAChallenge  = "mfa_challenge"
+)
+
+// OneTimeToken is the server-side record of a single-use token sent to a user,
+// such as an email verification or password reset link. Only a hash of the token is stored.
+type OneTimeToken struct {
+	TokenHash string
+	UserID    string
+	Purpose   string
+	Email     string // Address the token was sent to
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// OneTimeTokenStore persists single-use tokens
+type OneTimeTokenStore interface {
+	Create(ctx context.Context, token *OneTimeToken) error
+	
+	// atomically deletes and returns the token.
+	// Returns ErrInvalidOneTimeToken if it doesn't exist, has expired or was issued for another purpose.
+	Consume(ctx context.Context, tokenHash string, purpose string) (*OneTimeToken, error)
+	
+	// removes the user's outstanding tokens for a purpose
+	DeleteByUser(ctx context.Context, userID string, purpose string) error
+	
+	CleanupExpiredTokens(ctx context.Context) (int64, error)
+}
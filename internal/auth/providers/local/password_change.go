@@ -0,0 +1,144 @@
+package local
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrPasswordReused    = errors.New("password was used recently; choose a different one")
+)
+
+// changes a logged-in user's password after checking their current one, then
+// ends all of their other sessions. currentSessionID is the session the request
+// was made from, which stays logged in; pass "" to end every session.
+func (p *Provider) ChangePassword(ctx context.Context, userID string, currentPassword string, newPassword string, currentSessionID string) error {
+	if err := p.changePassword(ctx, userID, currentPassword, newPassword); err != nil {
+		return err
+	}
+	
+	return p.endSessionsExcept(ctx, userID, currentSessionID, p.endSession)
+}
+
+// checks a logged-in user's password before a sensitive change, like removing their last
+// security key, so a stolen token alone isn't enough. Users without a password get ErrIncorrectPassword.
+// Wrong passwords count towards the account lockout like failed logins, and a locked account
+// gets a LoginThrottledError; the right one resets the count.
+func (p *Provider) VerifyPassword(ctx context.Context, userID string, password string) error {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	
+	key := AccountAttemptPrefix + user.Username
+	if p.config.MaxFailedLogins > 0 {
+		if err := p.checkLoginAttempts(ctx, key); err != nil {
+			return err
+		}
+	}
+	
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		if err := p.failLogin(ctx, key); !errors.Is(err, auth.ErrInvalidCredentials) {
+			return err
+		}
+		return ErrIncorrectPassword
+	}
+	
+	// Only users who are already logged in get here, so this can't help get past a second factor
+	if p.config.MaxFailedLogins > 0 {
+		return p.attemptStore.Reset(ctx, key)
+	}
+	return nil
+}
+
+// reports whether a user has a password, which sensitive changes can then be confirmed with
+func (p *Provider) HasPassword(ctx context.Context, userID string) (bool, error) {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.PasswordHash != "", nil
+}
+
+// checks the current password, the policy and the password history, then stores the new password
+func (p *Provider) changePassword(ctx context.Context, userID string, currentPassword string, newPassword string) error {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+	
+	hash, err := p.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	
+	if err := p.checkPasswordHistory(ctx, user, newPassword); err != nil {
+		return err
+	}
+	
+	previousHash := user.PasswordHash
+	user.PasswordHash = hash
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return err
+	}
+	
+	return p.recordPasswordHistory(ctx, user.ID, previousHash)
+}
+
+// ends each of the user's sessions except keepSessionID using the given function
+func (p *Provider) endSessionsExcept(ctx context.Context, userID string, keepSessionID string, end func(ctx context.Context, sessionID string) error) error {
+	sessions, err := p.sessionStore.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := end(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// rejects a password matching the user's current one or one of their
+// last Config.PasswordHistorySize - 1 previous ones
+func (p *Provider) checkPasswordHistory(ctx context.Context, user *StoredUser, password string) error {
+	if p.config.PasswordHistorySize <= 0 {
+		return nil
+	}
+	
+	previous, err := p.historyStore.Recent(ctx, user.ID, p.config.PasswordHistorySize-1)
+	if err != nil {
+		return err
+	}
+	
+	for _, hash := range append([]string{user.PasswordHash}, previous...) {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return ErrPasswordReused
+		}
+	}
+	
+	return nil
+}
+
+// remembers a password hash the user just stopped using
+func (p *Provider) recordPasswordHistory(ctx context.Context, userID string, passwordHash string) error {
+	if passwordHash == "" {
+		return nil
+	}
+	
+	// The current password counts towards the history size, so keep one less
+	return p.historyStore.Add(ctx, userID, passwordHash, p.config.PasswordHistorySize-1)
+}
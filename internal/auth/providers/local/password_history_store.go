@@ -0,0 +1,17 @@
+package local
+
+import (
+	"context"
+)
+
+// PasswordHistoryStore remembers the hashes of passwords users have stopped using,
+// so they can be kept from switching back to a recent password
+type PasswordHistoryStore interface {
+	// records a password hash the user stopped using, keeping only their newest keep entries
+	Add(ctx context.Context, userID string, passwordHash string, keep int) error
+	
+	// returns up to limit of the user's previous password hashes, newest first
+	Recent(ctx context.Context, userID string, limit int) ([]string, error)
+	
+	DeleteByUser(ctx context.Context, userID string) error
+}
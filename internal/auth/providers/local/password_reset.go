@@ -0,0 +1,128 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+)
+
+// Limit on looking up an address and sending it an email after the request has been answered
+const backgroundEmailTimeout = time.Minute
+
+// emails a password reset link to the user with this email address.
+// The address is looked up and the email sent in the background, and unknown addresses
+// and delivery failures are only logged, so neither the outcome nor the time it takes
+// reveals which addresses have accounts.
+func (p *Provider) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(email)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundEmailTimeout)
+		defer cancel()
+		
+		if err := p.sendPasswordReset(ctx, email); err != nil {
+			log.Printf("Password reset request error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// emails a password reset link to the user with this email address, if there is one
+func (p *Provider) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := p.userStore.GetByEmail(ctx, email)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	if DirectoryManaged(user) {
+		log.Printf("Not sending password reset email to user %s, whose password is managed by a directory", user.ID)
+		return nil
+	}
+	
+	if p.mailer == nil {
+		log.Printf("No mailer configured; not sending password reset email to user %s", user.ID)
+		return nil
+	}
+	
+	token, err := p.issueOneTimeToken(ctx, user, PurposeResetPassword, p.config.PasswordResetExpiration)
+	if err != nil {
+		return err
+	}
+	
+	link := strings.TrimSuffix(p.config.PublicURL, "/") + "/auth/password/reset?token=" + url.QueryEscape(token)
+	err = p.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new password, open this link:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't ask for this, you can ignore this email; your password stays the same.\n",
+			user.Username, link, p.config.PasswordResetExpiration),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
+	
+	return nil
+}
+
+// sets a new password using the token from a password reset email, unlocks the account and ends all of the user's sessions.
+// Tokens are single use and stop working if the user changes their address in the meantime.
+// Users whose account a directory owns can't reset their password here.
+// Since the token proves the user controls the address, it's marked verified too.
+func (p *Provider) ResetPassword(ctx context.Context, token string, password string) (*StoredUser, error) {
+	// Check the new password first, so a rejected password doesn't use up the token
+	hash, err := p.hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	
+	stored, err := p.oneTimeStore.Consume(ctx, hashOpaqueToken(token), PurposeResetPassword)
+	if err != nil {
+		return nil, err
+	}
+	
+	user, err := p.userStore.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrInvalidOneTimeToken
+		}
+		return nil, err
+	}
+	if user.Email != stored.Email || DirectoryManaged(user) {
+		return nil, ErrInvalidOneTimeToken
+	}
+	
+	previousHash := user.PasswordHash
+	user.PasswordHash = hash
+	user.EmailVerified = true
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	
+	if err := p.recordPasswordHistory(ctx, user.ID, previousHash); err != nil {
+		return nil, err
+	}
+	
+	// The user has proven who they are, so let them log in straight away
+	if err := p.attemptStore.Reset(ctx, AccountAttemptPrefix+user.Username); err != nil {
+		return nil, err
+	}
+	
+	// Whoever knew the old password may still be logged in
+	if err := p.refreshStore.DeleteByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := p.sessionStore.DeleteByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	
+	return user, nil
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// IdentityStore implements local.IdentityStore with PostgreSQL
+type IdentityStore struct {
+	db *sqlx.DB
+}
+
+// identityRow represents a row in the user_identities table
+type identityRow struct {
+	Provider    string    `db:"provider"`
+	Subject     string    `db:"subject"`
+	UserID      string    `db:"user_id"`
+	Email       string    `db:"email"`
+	CreatedAt   time.Time `db:"created_at"`
+	LastLoginAt time.Time `db:"last_login_at"`
+}
+
+// NewIdentityStore creates a new PostgreSQL-backed identity store
+func NewIdentityStore(db *sqlx.DB) *IdentityStore {
+	return &IdentityStore{
+		db: db,
+	}
+}
+
+// Create links a new identity
+func (s *IdentityStore) Create(ctx context.Context, identity *local.Identity) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		identity.Provider, identity.Subject, identity.UserID, identity.Email,
+		identity.CreatedAt, identity.LastLoginAt)
+	
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return local.ErrIdentityLinked
+	}
+	return err
+}
+
+// Get retrieves an identity by provider and subject
+func (s *IdentityStore) Get(ctx context.Context, provider string, subject string) (*local.Identity, error) {
+	var row identityRow
+	err := s.db.GetContext(ctx, &row, `
+		SELECT * FROM user_identities WHERE provider = $1 AND subject = $2`,
+		provider, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, local.ErrIdentityNotFound
+		}
+		return nil, err
+	}
+	
+	return row.toIdentity(), nil
+}
+
+// ListByUser returns the user's identities, oldest first
+func (s *IdentityStore) ListByUser(ctx context.Context, userID string) ([]*local.Identity, error) {
+	var rows []identityRow
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT * FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	identities := make([]*local.Identity, 0, len(rows))
+	for i := range rows {
+		identities = append(identities, rows[i].toIdentity())
+	}
+	
+	return identities, nil
+}
+
+// Touch records a login with the identity
+func (s *IdentityStore) Touch(ctx context.Context, provider string, subject string, loginAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE user_identities SET last_login_at = $1
+		WHERE provider = $2 AND subject = $3`,
+		loginAt, provider, subject)
+	if err != nil {
+		return err
+	}
+	
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return local.ErrIdentityNotFound
+	}
+	
+	return nil
+}
+
+// Delete unlinks one of the user's identities
+func (s *IdentityStore) Delete(ctx context.Context, userID string, provider string, subject string) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM user_identities
+		WHERE user_id = $1 AND provider = $2 AND subject = $3`,
+		userID, provider, subject)
+	if err != nil {
+		return err
+	}
+	
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return local.ErrIdentityNotFound
+	}
+	
+	return nil
+}
+
+// DeleteByUser unlinks all of the user's identities
+func (s *IdentityStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_identities WHERE user_id = $1", userID)
+	return err
+}
+
+// toIdentity converts a database row to a local.Identity
+func (r *identityRow) toIdentity() *local.Identity {
+	return &local.Identity{
+		Provider:    r.Provider,
+		Subject:     r.Subject,
+		UserID:      r.UserID,
+		Email:       r.Email,
+		CreatedAt:   r.CreatedAt,
+		LastLoginAt: r.LastLoginAt,
+	}
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/jmoiron/sqlx"
+)
+
+// LoginAttemptStore implements local.LoginAttemptStore with PostgreSQL
+type LoginAttemptStore struct {
+	db *sqlx.DB
+}
+
+// loginAttemptRow represents a row in the login_attempts table
+type loginAttemptRow struct {
+	Key         string    `db:"key"`
+	Failures    int       `db:"failures"`
+	LastFailure time.Time `db:"last_failure"`
+	LockedUntil time.Time `db:"locked_until"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// NewLoginAttemptStore creates a new PostgreSQL-backed login attempt store
+func NewLoginAttemptStore(db *sqlx.DB) *LoginAttemptStore {
+	return &LoginAttemptStore{
+		db: db,
+	}
+}
+
+// Get returns the record for the key, or an empty one
+func (s *LoginAttemptStore) Get(ctx context.Context, key string) (*local.LoginAttempts, error) {
+	var row loginAttemptRow
+	err := s.db.GetContext(ctx, &row, `
+		SELECT * FROM login_attempts
+		WHERE key = $1 AND expires_at >= now()`, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &local.LoginAttempts{Key: key}, nil
+		}
+		return nil, err
+	}
+	
+	return row.toLoginAttempts(), nil
+}
+
+// RecordFailure counts a failed attempt. Expired records start over from one.
+func (s *LoginAttemptStore) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*local.LoginAttempts, error) {
+	var row loginAttemptRow
+	err := s.db.GetContext(ctx, &row, `
+		INSERT INTO login_attempts (key, failures, last_failure, expires_at)
+		VALUES ($1, 1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.expires_at < $2 THEN 1 ELSE login_attempts.failures + 1 END,
+			locked_until = CASE WHEN login_attempts.expires_at < $2 THEN to_timestamp(0) ELSE login_attempts.locked_until END,
+			expires_at = CASE WHEN login_attempts.expires_at < $2 THEN $3 ELSE GREATEST(login_attempts.expires_at, $3) END,
+			last_failure = $2
+		RETURNING *`,
+		key, at, at.Add(window))
+	if err != nil {
+		return nil, err
+	}
+	
+	return row.toLoginAttempts(), nil
+}
+
+// Lock refuses logins for the key until the given time
+func (s *LoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO login_attempts (key, failures, last_failure, locked_until, expires_at)
+		VALUES ($1, 0, to_timestamp(0), $2, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			locked_until = $2,
+			expires_at = GREATEST(login_attempts.expires_at, $2)`,
+		key, until)
+	
+	return err
+}
+
+// Reset forgets the key's failed attempts
+func (s *LoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+// ListLocked returns the records that are locked at the given time
+func (s *LoginAttemptStore) ListLocked(ctx context.Context, at time.Time) ([]*local.LoginAttempts, error) {
+	var rows []loginAttemptRow
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT * FROM login_attempts
+		WHERE locked_until > $1
+		ORDER BY locked_until DESC`, at)
+	if err != nil {
+		return nil, err
+	}
+	
+	locked := make([]*local.LoginAttempts, 0, len(rows))
+	for i := range rows {
+		locked = append(locked, rows[i].toLoginAttempts())
+	}
+	
+	return locked, nil
+}
+
+// CleanupExpiredAttempts removes expired records
+func (s *LoginAttemptStore) CleanupExpiredAttempts(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM login_attempts
+		WHERE expires_at < now()`)
+	
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// toLoginAttempts converts a database row to a local.LoginAttempts
+func (r *loginAttemptRow) toLoginAttempts() *local.LoginAttempts {
+	return &local.LoginAttempts{
+		Key:         r.Key,
+		Failures:    r.Failures,
+		LastFailure: r.LastFailure,
+		LockedUntil: r.LockedUntil,
+		ExpiresAt:   r.ExpiresAt,
+	}
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/jmoiron/sqlx"
+)
+
+// OneTimeTokenStore implements local.OneTimeTokenStore with PostgreSQL
+type OneTimeTokenStore struct {
+	db *sqlx.DB
+}
+
+// oneTimeTokenRow represents a row in the one_time_tokens table
+type oneTimeTokenRow struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	Purpose   string    `db:"purpose"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// NewOneTimeTokenStore creates a new PostgreSQL-backed one-time token store
+func NewOneTimeTokenStore(db *sqlx.DB) *OneTimeTokenStore {
+	return &OneTimeTokenStore{
+		db: db,
+	}
+}
+
+// Create stores a new token
+func (s *OneTimeTokenStore) Create(ctx context.Context, token *local.OneTimeToken) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO one_time_tokens (token_hash, user_id, purpose, email, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.TokenHash, token.UserID, token.Purpose, token.Email, token.CreatedAt, token.ExpiresAt)
+	
+	return err
+}
+
+// Consume deletes and returns a token in a single statement, so it can only be used once
+func (s *OneTimeTokenStore) Consume(ctx context.Context, tokenHash string, purpose string) (*local.OneTimeToken, error) {
+	var row oneTimeTokenRow
+	err := s.db.GetContext(ctx, &row, `
+		DELETE FROM one_time_tokens WHERE token_hash = $1 AND purpose = $2
+		RETURNING token_hash, user_id, purpose, email, created_at, expires_at`,
+		tokenHash, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, local.ErrInvalidOneTimeToken
+		}
+		return nil, err
+	}
+	
+	if time.Now().After(row.ExpiresAt) {
+		return nil, local.ErrInvalidOneTimeToken
+	}
+	
+	return &local.OneTimeToken{
+		TokenHash: row.TokenHash,
+		UserID:    row.UserID,
+		Purpose:   row.Purpose,
+		Email:     row.Email,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}, nil
+}
+
+// DeleteByUser removes the user's tokens for a purpose
+func (s *OneTimeTokenStore) DeleteByUser(ctx context.Context, userID string, purpose string) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2", userID, purpose)
+	return err
+}
+
+// CleanupExpiredTokens removes expired tokens
+func (s *OneTimeTokenStore) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM one_time_tokens WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
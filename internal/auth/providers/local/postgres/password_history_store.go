@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PasswordHistoryStore implements local.PasswordHistoryStore with PostgreSQL
+type PasswordHistoryStore struct {
+	db *sqlx.DB
+}
+
+// NewPasswordHistoryStore creates a new PostgreSQL-backed password history store
+func NewPasswordHistoryStore(db *sqlx.DB) *PasswordHistoryStore {
+	return &PasswordHistoryStore{
+		db: db,
+	}
+}
+
+// Add records a previous password hash, keeping only the newest keep entries
+func (s *PasswordHistoryStore) Add(ctx context.Context, userID string, passwordHash string, keep int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	if keep > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO password_history (user_id, password_hash)
+			VALUES ($1, $2)`,
+			userID, passwordHash)
+		if err != nil {
+			return err
+		}
+	}
+	
+	// Drop everything but the newest entries
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2
+		)`,
+		userID, max(keep, 0))
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// Recent returns up to limit of the user's previous password hashes, newest first
+func (s *PasswordHistoryStore) Recent(ctx context.Context, userID string, limit int) ([]string, error) {
+	hashes := make([]string, 0)
+	err := s.db.SelectContext(ctx, &hashes, `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2`,
+		userID, max(limit, 0))
+	if err != nil {
+		return nil, err
+	}
+	
+	return hashes, nil
+}
+
+// DeleteByUser forgets the user's password history
+func (s *PasswordHistoryStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM password_history WHERE user_id = $1", userID)
+	return err
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/jmoiron/sqlx"
+)
+
+// RefreshTokenStore implements local.RefreshTokenStore with PostgreSQL
+type RefreshTokenStore struct {
+	db *sqlx.DB
+}
+
+// refreshTokenRow represents a row in the refresh_tokens table
+type refreshTokenRow struct {
+	TokenHash string       `db:"token_hash"`
+	UserID    string       `db:"user_id"`
+	FamilyID  string       `db:"family_id"`
+	CreatedAt time.Time    `db:"created_at"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+}
+
+// NewRefreshTokenStore creates a new PostgreSQL-backed refresh token store
+func NewRefreshTokenStore(db *sqlx.DB) *RefreshTokenStore {
+	return &RefreshTokenStore{
+		db: db,
+	}
+}
+
+// Create stores a new refresh token
+func (s *RefreshTokenStore) Create(ctx context.Context, token *local.RefreshToken) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, user_id, family_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.TokenHash, token.UserID, token.FamilyID, token.CreatedAt, token.ExpiresAt)
+	
+	return err
+}
+
+// Get retrieves a refresh token by its hash
+func (s *RefreshTokenStore) Get(ctx context.Context, tokenHash string) (*local.RefreshToken, error) {
+	var row refreshTokenRow
+	err := s.db.GetContext(ctx, &row, `
+		SELECT token_hash, user_id, family_id, created_at, expires_at, used_at
+		FROM refresh_tokens WHERE token_hash = $1`, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, local.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	
+	token := &local.RefreshToken{
+		TokenHash: row.TokenHash,
+		UserID:    row.UserID,
+		FamilyID:  row.FamilyID,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.UsedAt.Valid {
+		token.UsedAt = &row.UsedAt.Time
+	}
+	
+	return token, nil
+}
+
+// Rotate marks the old token used and stores its replacement in one transaction
+func (s *RefreshTokenStore) Rotate(ctx context.Context, oldTokenHash string, next *local.RefreshToken) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	// Only one concurrent rotation can flip used_at, the others see it as reuse
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL`, oldTokenHash)
+	if err != nil {
+		return err
+	}
+	
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		var exists bool
+		err = tx.GetContext(ctx, &exists,
+			"SELECT EXISTS(SELECT 1 FROM refresh_tokens WHERE token_hash = $1)", oldTokenHash)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return local.ErrInvalidRefreshToken
+		}
+		return local.ErrRefreshTokenReused
+	}
+	
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, user_id, family_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		next.TokenHash, next.UserID, next.FamilyID, next.CreatedAt, next.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// Delete removes a refresh token
+func (s *RefreshTokenStore) Delete(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE token_hash = $1", tokenHash)
+	return err
+}
+
+// DeleteFamily removes every token in a family
+func (s *RefreshTokenStore) DeleteFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE family_id = $1", familyID)
+	return err
+}
+
+// DeleteByUser removes every token issued to a user
+func (s *RefreshTokenStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1", userID)
+	return err
+}
+
+// CleanupExpiredTokens removes expired refresh tokens
+func (s *RefreshTokenStore) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM refresh_tokens
+		WHERE expires_at < now()`)
+	
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
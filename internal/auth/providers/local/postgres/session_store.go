@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/jmoiron/sqlx"
+)
+
+// SessionStore implements local.SessionStore with PostgreSQL
+type SessionStore struct {
+	db *sqlx.DB
+}
+
+// sessionRow represents a row in the sessions table
+type sessionRow struct {
+	ID         string    `db:"id"`
+	UserID     string    `db:"user_id"`
+	TokenID    string    `db:"token_id"`
+	IPAddress  string    `db:"ip_address"`
+	UserAgent  string    `db:"user_agent"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+// NewSessionStore creates a new PostgreSQL-backed session store
+func NewSessionStore(db *sqlx.DB) *SessionStore {
+	return &SessionStore{
+		db: db,
+	}
+}
+
+// Create stores a new session
+func (s *SessionStore) Create(ctx context.Context, session *local.Session) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, token_id, ip_address, user_agent, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.UserID, session.TokenID, session.IPAddress, session.UserAgent,
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt)
+	
+	return err
+}
+
+// Get retrieves a session by ID
+func (s *SessionStore) Get(ctx context.Context, id string) (*local.Session, error) {
+	var row sessionRow
+	err := s.db.GetContext(ctx, &row, "SELECT * FROM sessions WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, local.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	
+	return row.toSession(), nil
+}
+
+// ListByUser returns the user's unexpired sessions, most recently seen first
+func (s *SessionStore) ListByUser(ctx context.Context, userID string) ([]*local.Session, error) {
+	var rows []sessionRow
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT * FROM sessions
+		WHERE user_id = $1 AND expires_at > now()
+		ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	sessions := make([]*local.Session, 0, len(rows))
+	for i := range rows {
+		sessions = append(sessions, rows[i].toSession())
+	}
+	
+	return sessions, nil
+}
+
+// Touch records activity on a session
+func (s *SessionStore) Touch(ctx context.Context, id string, tokenID string, seenAt time.Time, expiresAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE sessions SET token_id = $1, last_seen_at = $2, expires_at = $3
+		WHERE id = $4`,
+		tokenID, seenAt, expiresAt, id)
+	if err != nil {
+		return err
+	}
+	
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return local.ErrSessionNotFound
+	}
+	
+	return nil
+}
+
+// Delete removes a session
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", id)
+	return err
+}
+
+// DeleteByUser removes every session of a user
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1", userID)
+	return err
+}
+
+// CleanupExpiredSessions removes expired sessions
+func (s *SessionStore) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM sessions
+		WHERE expires_at < now()`)
+	
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// toSession converts a database row to a local.Session
+func (r *sessionRow) toSession() *local.Session {
+	return &local.Session{
+		ID:         r.ID,
+		UserID:     r.UserID,
+		TokenID:    r.TokenID,
+		IPAddress:  r.IPAddress,
+		UserAgent:  r.UserAgent,
+		CreatedAt:  r.CreatedAt,
+		LastSeenAt: r.LastSeenAt,
+		ExpiresAt:  r.ExpiresAt,
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/NBDor/Go-Auth-Service/internal/auth"
 	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // SQLUserStore implements the UserStore interface with PostgreSQL
@@ -20,12 +23,17 @@ type SQLUserStore struct {
 
 // userRow represents a row in the users table
 type userRow struct {
-	ID           string    `db:"id"`
-	Username     string    `db:"username"`
-	Email        string    `db:"email"`
-	PasswordHash string    `db:"password_hash"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID            string         `db:"id"`
+	Username      string         `db:"username"`
+	Email         string         `db:"email"`
+	EmailVerified bool           `db:"email_verified"`
+	PasswordHash  string         `db:"password_hash"`
+	CreatedAt     time.Time      `db:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at"`
+	TOTPSecret    string         `db:"totp_secret"`
+	TOTPEnabled   bool           `db:"totp_enabled"`
+	TOTPLastStep  int64          `db:"totp_last_step"`
+	RecoveryCodes pq.StringArray `db:"recovery_codes"`
 }
 
 // NewSQLUserStore creates a new PostgreSQL-backed user store
@@ -92,11 +100,13 @@ func (s *SQLUserStore) Create(ctx context.Context, user *local.StoredUser) error
 
 	// Insert user
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO users (id, username, email, password_hash)
-		VALUES ($1, $2, $3, $4)`,
-		user.ID, user.Username, user.Email, user.PasswordHash)
+		INSERT INTO users (id, username, email, email_verified, password_hash,
+			totp_secret, totp_enabled, totp_last_step, recovery_codes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		user.ID, user.Username, user.Email, user.EmailVerified, user.PasswordHash,
+		user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, textArray(user.RecoveryCodes))
 	if err != nil {
-		return err
+		return translateUniqueViolation(err)
 	}
 
 	// Insert roles
@@ -144,11 +154,13 @@ func (s *SQLUserStore) Update(ctx context.Context, user *local.StoredUser) error
 	// Update user
 	result, err := tx.ExecContext(ctx, `
 		UPDATE users 
-		SET username = $1, email = $2, password_hash = $3, updated_at = now()
-		WHERE id = $4`,
-		user.Username, user.Email, user.PasswordHash, user.ID)
+		SET username = $1, email = $2, email_verified = $3, password_hash = $4,
+			totp_secret = $5, totp_enabled = $6, totp_last_step = $7, recovery_codes = $8, updated_at = now()
+		WHERE id = $9`,
+		user.Username, user.Email, user.EmailVerified, user.PasswordHash,
+		user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, textArray(user.RecoveryCodes), user.ID)
 	if err != nil {
-		return err
+		return translateUniqueViolation(err)
 	}
 
 	affected, err := result.RowsAffected()
@@ -219,17 +231,97 @@ func (s *SQLUserStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// List returns one page of users matching the query, ordered by created_at then id
+func (s *SQLUserStore) List(ctx context.Context, query local.UserQuery) (*local.UserPage, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if query.Search != "" {
+		pattern := arg("%" + escapeLike(query.Search) + "%")
+		conditions = append(conditions, "(username ILIKE "+pattern+" OR email ILIKE "+pattern+")")
+	}
+	if query.Role != "" {
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM user_roles r WHERE r.user_id = users.id AND r.role = "+arg(query.Role)+")")
+	}
+	if query.EmailDomain != "" {
+		conditions = append(conditions, "email ILIKE "+arg("%@"+escapeLike(query.EmailDomain)))
+	}
+	if query.MetadataKey != "" {
+		condition := "m.user_id = users.id AND m.key = " + arg(query.MetadataKey)
+		if query.MetadataValue != "" {
+			// Compare the scalar's text form, so "3" matches both 3 and "3"
+			condition += " AND m.value #>> '{}' = " + arg(query.MetadataValue)
+		}
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM user_metadata m WHERE "+condition+")")
+	}
+
+	direction, comparison := "ASC", ">"
+	if query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if query.Cursor != "" {
+		createdAt, id, err := local.DecodeUserCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "(created_at, id) "+comparison+" ("+arg(createdAt)+", "+arg(id)+")")
+	}
+
+	// Fetch one extra row to find out whether there is another page
+	limit := query.PageSize()
+	statement := "SELECT * FROM users WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY created_at " + direction + ", id " + direction +
+		" LIMIT " + arg(limit+1)
+
+	var rows []userRow
+	if err := s.db.SelectContext(ctx, &rows, statement, args...); err != nil {
+		return nil, err
+	}
+
+	page := &local.UserPage{Users: make([]*local.StoredUser, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		page.NextCursor = local.EncodeUserCursor(last.CreatedAt, last.ID)
+	}
+
+	for i := range rows {
+		user, err := s.assembleUser(ctx, &rows[i])
+		if err != nil {
+			return nil, err
+		}
+		page.Users = append(page.Users, user)
+	}
+
+	return page, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // assembleUser creates a complete StoredUser from a database row
 func (s *SQLUserStore) assembleUser(ctx context.Context, row *userRow) (*local.StoredUser, error) {
 	user := &local.StoredUser{
-		ID:           row.ID,
-		Username:     row.Username,
-		Email:        row.Email,
-		PasswordHash: row.PasswordHash,
-		CreatedAt:    row.CreatedAt.Unix(),
-		UpdatedAt:    row.UpdatedAt.Unix(),
-		Roles:        make([]string, 0),
-		Metadata:     make(map[string]interface{}),
+		ID:            row.ID,
+		Username:      row.Username,
+		Email:         row.Email,
+		EmailVerified: row.EmailVerified,
+		PasswordHash:  row.PasswordHash,
+		CreatedAt:     row.CreatedAt.Unix(),
+		UpdatedAt:     row.UpdatedAt.Unix(),
+		Roles:         make([]string, 0),
+		Metadata:      make(map[string]interface{}),
+		TOTPSecret:    row.TOTPSecret,
+		TOTPEnabled:   row.TOTPEnabled,
+		TOTPLastStep:  row.TOTPLastStep,
+		RecoveryCodes: row.RecoveryCodes,
 	}
 
 	// Get roles
@@ -263,3 +355,27 @@ func (s *SQLUserStore) assembleUser(ctx context.Context, row *userRow) (*local.S
 
 	return user, nil
 }
+
+// translateUniqueViolation maps duplicate username and email errors to the store's sentinel errors
+func translateUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+
+	switch pqErr.Constraint {
+	case "users_username_key":
+		return local.ErrUsernameTaken
+	case "users_email_key":
+		return local.ErrEmailTaken
+	}
+	return err
+}
+
+// textArray converts a slice for a NOT NULL array column, since pq stores nil slices as NULL
+func textArray(values []string) pq.StringArray {
+	if values == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(values)
+}
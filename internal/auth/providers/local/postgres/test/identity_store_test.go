@@ -0,0 +1,98 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresIdentityStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	// Identities reference a user
+	ctx := context.Background()
+	suffix := time.Now().Format("20060102150405.000000")
+	userStore := postgres.NewSQLUserStore(db)
+	user := &local.StoredUser{
+		Username:     "identity-" + suffix,
+		Email:        "identity-" + suffix + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	defer userStore.Delete(ctx, user.ID)
+	
+	store := postgres.NewIdentityStore(db)
+	now := time.Now().Truncate(time.Microsecond)
+	
+	// 1. Identities are found by provider and subject
+	google := &local.Identity{
+		Provider:    "google",
+		Subject:     "sub-" + suffix,
+		UserID:      user.ID,
+		Email:       user.Email,
+		CreatedAt:   now.Add(-time.Hour),
+		LastLoginAt: now.Add(-time.Hour),
+	}
+	require.NoError(t, store.Create(ctx, google))
+	
+	found, err := store.Get(ctx, "google", google.Subject)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.UserID)
+	assert.Equal(t, user.Email, found.Email)
+	
+	_, err = store.Get(ctx, "github", google.Subject)
+	assert.ErrorIs(t, err, local.ErrIdentityNotFound)
+	
+	// 2. An identity can only be linked once
+	duplicate := *google
+	assert.ErrorIs(t, store.Create(ctx, &duplicate), local.ErrIdentityLinked)
+	
+	// 3. Listing is oldest first, and logins are recorded
+	github := &local.Identity{
+		Provider:    "github",
+		Subject:     "sub-" + suffix,
+		UserID:      user.ID,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	require.NoError(t, store.Create(ctx, github))
+	require.NoError(t, store.Touch(ctx, "google", google.Subject, now))
+	
+	identities, err := store.ListByUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, identities, 2)
+	assert.Equal(t, "google", identities[0].Provider)
+	assert.True(t, identities[0].LastLoginAt.Equal(now))
+	assert.Equal(t, "github", identities[1].Provider)
+	
+	// 4. Only the user's own identities can be deleted
+	assert.ErrorIs(t, store.Delete(ctx, "someone-else", "google", google.Subject), local.ErrIdentityNotFound)
+	require.NoError(t, store.Delete(ctx, user.ID, "google", google.Subject))
+	assert.ErrorIs(t, store.Delete(ctx, user.ID, "google", google.Subject), local.ErrIdentityNotFound)
+	
+	require.NoError(t, store.DeleteByUser(ctx, user.ID))
+	identities, err = store.ListByUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, identities)
+}
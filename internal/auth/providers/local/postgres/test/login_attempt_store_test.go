@@ -0,0 +1,80 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresLoginAttemptStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	store := postgres.NewLoginAttemptStore(db)
+	ctx := context.Background()
+	now := time.Now()
+	suffix := now.Format("20060102150405.000000")
+	accountKey := "account:lockout-" + suffix
+	ipKey := "ip:lockout-" + suffix
+	defer store.Reset(ctx, accountKey)
+	defer store.Reset(ctx, ipKey)
+	
+	// 1. Failures are counted within the window
+	attempts, err := store.RecordFailure(ctx, accountKey, now.Add(-time.Minute), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	assert.False(t, attempts.Locked(now))
+	
+	attempts, err = store.RecordFailure(ctx, accountKey, now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts.Failures)
+	
+	// 2. Locks show up in the listing
+	require.NoError(t, store.Lock(ctx, accountKey, now.Add(2*time.Hour)))
+	
+	attempts, err = store.Get(ctx, accountKey)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts.Failures)
+	assert.True(t, attempts.Locked(now))
+	
+	locked, err := store.ListLocked(ctx, now)
+	require.NoError(t, err)
+	found := false
+	for _, attempts := range locked {
+		found = found || attempts.Key == accountKey
+	}
+	assert.True(t, found)
+	
+	// 3. Failures after the record expired start over
+	_, err = store.RecordFailure(ctx, ipKey, now.Add(-2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	attempts, err = store.RecordFailure(ctx, ipKey, now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	
+	// 4. Reset forgets the key
+	require.NoError(t, store.Reset(ctx, accountKey))
+	attempts, err = store.Get(ctx, accountKey)
+	require.NoError(t, err)
+	assert.Equal(t, 0, attempts.Failures)
+	assert.False(t, attempts.Locked(now))
+}
@@ -0,0 +1,94 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresOneTimeTokenStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	// Tokens reference a user
+	ctx := context.Background()
+	userStore := postgres.NewSQLUserStore(db)
+	user := &local.StoredUser{
+		Username:     "onetime-" + time.Now().Format("20060102150405.000000"),
+		Email:        "onetime-" + time.Now().Format("20060102150405.000000") + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	defer userStore.Delete(ctx, user.ID)
+	
+	store := postgres.NewOneTimeTokenStore(db)
+	now := time.Now()
+	create := func(hash string, expiresAt time.Time) {
+		err := store.Create(ctx, &local.OneTimeToken{
+			TokenHash: hash + "-" + user.ID,
+			UserID:    user.ID,
+			Purpose:   local.PurposeVerifyEmail,
+			Email:     user.Email,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		})
+		require.NoError(t, err)
+	}
+	
+	// 1. A token can be consumed once, and only for its purpose
+	create("live", now.Add(time.Hour))
+	
+	_, err = store.Consume(ctx, "live-"+user.ID, "other_purpose")
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	token, err := store.Consume(ctx, "live-"+user.ID, local.PurposeVerifyEmail)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, token.UserID)
+	assert.Equal(t, user.Email, token.Email)
+	
+	_, err = store.Consume(ctx, "live-"+user.ID, local.PurposeVerifyEmail)
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// 2. Expired tokens can't be consumed and are cleaned up
+	create("expired", now.Add(-time.Hour))
+	create("cleanup", now.Add(-time.Hour))
+	_, err = store.Consume(ctx, "expired-"+user.ID, local.PurposeVerifyEmail)
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	count, err := store.CleanupExpiredTokens(ctx)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(1))
+	
+	// 3. Deleting the user's tokens invalidates outstanding links
+	create("outstanding", now.Add(time.Hour))
+	assert.NoError(t, store.DeleteByUser(ctx, user.ID, local.PurposeVerifyEmail))
+	_, err = store.Consume(ctx, "outstanding-"+user.ID, local.PurposeVerifyEmail)
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// 4. The verified flag round-trips through the user store
+	user.EmailVerified = true
+	require.NoError(t, userStore.Update(ctx, user))
+	stored, err := userStore.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.EmailVerified)
+}
@@ -0,0 +1,64 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresPasswordHistoryStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	// History entries reference a user
+	ctx := context.Background()
+	userStore := postgres.NewSQLUserStore(db)
+	user := &local.StoredUser{
+		Username:     "history-" + time.Now().Format("20060102150405.000000"),
+		Email:        "history-" + time.Now().Format("20060102150405.000000") + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	defer userStore.Delete(ctx, user.ID)
+	
+	store := postgres.NewPasswordHistoryStore(db)
+	
+	// 1. Only the newest entries are kept, newest first
+	for _, hash := range []string{"hash-1", "hash-2", "hash-3"} {
+		require.NoError(t, store.Add(ctx, user.ID, hash, 2))
+	}
+	
+	hashes, err := store.Recent(ctx, user.ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash-3", "hash-2"}, hashes)
+	
+	hashes, err = store.Recent(ctx, user.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash-3"}, hashes)
+	
+	// 2. Deleting forgets the history
+	require.NoError(t, store.DeleteByUser(ctx, user.ID))
+	hashes, err = store.Recent(ctx, user.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, hashes)
+}
@@ -0,0 +1,98 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresRefreshTokenStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	// Refresh tokens reference a user
+	ctx := context.Background()
+	userStore := postgres.NewSQLUserStore(db)
+	user := &local.StoredUser{
+		Username:     "refresh-" + time.Now().Format("20060102150405.000000"),
+		Email:        "refresh-" + time.Now().Format("20060102150405.000000") + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	defer userStore.Delete(ctx, user.ID)
+	
+	store := postgres.NewRefreshTokenStore(db)
+	now := time.Now()
+	
+	// 1. Store and read back a token
+	err = store.Create(ctx, &local.RefreshToken{
+		TokenHash: "live-" + user.ID,
+		UserID:    user.ID,
+		FamilyID:  "family-" + user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	
+	token, err := store.Get(ctx, "live-"+user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, token.UserID)
+	
+	// 2. Rotation marks the token used and only succeeds once
+	next := &local.RefreshToken{
+		TokenHash: "next-" + user.ID,
+		UserID:    user.ID,
+		FamilyID:  "family-" + user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	assert.NoError(t, store.Rotate(ctx, "live-"+user.ID, next))
+	token, err = store.Get(ctx, "live-"+user.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, token.UsedAt)
+	assert.Equal(t, local.ErrRefreshTokenReused, store.Rotate(ctx, "live-"+user.ID, next))
+	
+	// 3. Deleting the family removes every token in it
+	assert.NoError(t, store.DeleteFamily(ctx, "family-"+user.ID))
+	_, err = store.Get(ctx, "live-"+user.ID)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	_, err = store.Get(ctx, "next-"+user.ID)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	
+	// 4. Expired tokens are cleaned up
+	err = store.Create(ctx, &local.RefreshToken{
+		TokenHash: "expired-" + user.ID,
+		UserID:    user.ID,
+		FamilyID:  "expired-family-" + user.ID,
+		CreatedAt: now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour),
+	})
+	assert.NoError(t, err)
+	
+	count, err := store.CleanupExpiredTokens(ctx)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(1))
+	
+	_, err = store.Get(ctx, "expired-"+user.ID)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+}
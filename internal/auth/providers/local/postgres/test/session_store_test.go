@@ -0,0 +1,117 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresSessionStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	// Sessions reference a user
+	ctx := context.Background()
+	userStore := postgres.NewSQLUserStore(db)
+	user := &local.StoredUser{
+		Username:     "session-" + time.Now().Format("20060102150405.000000"),
+		Email:        "session-" + time.Now().Format("20060102150405.000000") + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	defer userStore.Delete(ctx, user.ID)
+	
+	store := postgres.NewSessionStore(db)
+	now := time.Now()
+	
+	// 1. Store and read back sessions
+	older := &local.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		TokenID:    "token-1",
+		IPAddress:  "10.0.0.1",
+		UserAgent:  "Firefox",
+		CreatedAt:  now.Add(-time.Hour),
+		LastSeenAt: now.Add(-time.Hour),
+		ExpiresAt:  now.Add(time.Hour),
+	}
+	newer := &local.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		TokenID:    "token-2",
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+	expired := &local.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		TokenID:    "token-3",
+		CreatedAt:  now.Add(-2 * time.Hour),
+		LastSeenAt: now.Add(-2 * time.Hour),
+		ExpiresAt:  now.Add(-time.Hour),
+	}
+	for _, session := range []*local.Session{older, newer, expired} {
+		require.NoError(t, store.Create(ctx, session))
+	}
+	
+	session, err := store.Get(ctx, older.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", session.IPAddress)
+	assert.Equal(t, "Firefox", session.UserAgent)
+	
+	// 2. Listing skips expired sessions and returns the most recently seen first
+	sessions, err := store.ListByUser(ctx, user.ID)
+	assert.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+	
+	// 3. Touching a session moves it to the top
+	err = store.Touch(ctx, older.ID, "token-4", now.Add(time.Minute), now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	sessions, err = store.ListByUser(ctx, user.ID)
+	assert.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, older.ID, sessions[0].ID)
+	assert.Equal(t, "token-4", sessions[0].TokenID)
+	
+	err = store.Touch(ctx, "missing", "token", now, now)
+	assert.ErrorIs(t, err, local.ErrSessionNotFound)
+	
+	// 4. Cleanup removes only expired sessions
+	_, err = store.CleanupExpiredSessions(ctx)
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, expired.ID)
+	assert.ErrorIs(t, err, local.ErrSessionNotFound)
+	
+	// 5. Delete a single session, then the rest
+	assert.NoError(t, store.Delete(ctx, newer.ID))
+	_, err = store.Get(ctx, newer.ID)
+	assert.ErrorIs(t, err, local.ErrSessionNotFound)
+	
+	assert.NoError(t, store.DeleteByUser(ctx, user.ID))
+	sessions, err = store.ListByUser(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,148 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresUserStoreList(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	ctx := context.Background()
+	store := postgres.NewSQLUserStore(db)
+	
+	// Scope every query to this run with a unique email domain
+	domain := "list-" + time.Now().Format("20060102150405.000000") + ".example.com"
+	created := make([]string, 0, 5)
+	for i := 1; i <= 5; i++ {
+		roles := []string{"user"}
+		if i%2 == 0 {
+			roles = append(roles, "admin")
+		}
+		user := &local.StoredUser{
+			Username:     fmt.Sprintf("member%d@%s", i, domain),
+			Email:        fmt.Sprintf("member%d@%s", i, domain),
+			PasswordHash: "x",
+			Roles:        roles,
+			Metadata:     map[string]interface{}{"level": i},
+		}
+		require.NoError(t, store.Create(ctx, user))
+		defer store.Delete(ctx, user.ID)
+		created = append(created, user.ID)
+	}
+	
+	ids := func(page *local.UserPage) []string {
+		result := make([]string, 0, len(page.Users))
+		for _, user := range page.Users {
+			result = append(result, user.ID)
+		}
+		return result
+	}
+	
+	// 1. Walk the users two at a time
+	page, err := store.List(ctx, local.UserQuery{EmailDomain: domain, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, created[0:2], ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{EmailDomain: domain, Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, created[2:4], ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{EmailDomain: domain, Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, created[4:], ids(page))
+	assert.Empty(t, page.NextCursor)
+	
+	// 2. Newest first
+	page, err = store.List(ctx, local.UserQuery{EmailDomain: domain, Limit: 1, Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, created[4:], ids(page))
+	
+	// 3. Filters
+	page, err = store.List(ctx, local.UserQuery{EmailDomain: domain, Role: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{created[1], created[3]}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{EmailDomain: domain, MetadataKey: "level", MetadataValue: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{created[2]}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{Search: "MEMBER4@" + domain})
+	require.NoError(t, err)
+	assert.Equal(t, []string{created[3]}, ids(page))
+	
+	_, err = store.List(ctx, local.UserQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, local.ErrInvalidCursor)
+}
+
+func TestPostgresUserStoreTOTP(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	ctx := context.Background()
+	store := postgres.NewSQLUserStore(db)
+	
+	username := "totp-" + time.Now().Format("20060102150405.000000")
+	user := &local.StoredUser{Username: username, Email: username + "@example.com", PasswordHash: "x", Roles: []string{"user"}}
+	require.NoError(t, store.Create(ctx, user))
+	defer store.Delete(ctx, user.ID)
+	
+	// The two-factor fields survive a round trip
+	user.TOTPSecret = "JBSWY3DPEHPK3PXP"
+	user.TOTPEnabled = true
+	user.TOTPLastStep = 57
+	user.RecoveryCodes = []string{"hash-1", "hash-2"}
+	require.NoError(t, store.Update(ctx, user))
+	
+	stored, err := store.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", stored.TOTPSecret)
+	assert.True(t, stored.TOTPEnabled)
+	assert.Equal(t, int64(57), stored.TOTPLastStep)
+	assert.Equal(t, []string{"hash-1", "hash-2"}, stored.RecoveryCodes)
+	
+	// Turning it off clears the recovery codes
+	user.TOTPEnabled = false
+	user.RecoveryCodes = nil
+	require.NoError(t, store.Update(ctx, user))
+	
+	stored, err = store.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.TOTPEnabled)
+	assert.Empty(t, stored.RecoveryCodes)
+}
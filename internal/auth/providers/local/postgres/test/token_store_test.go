@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
 	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
 	"github.com/NBDor/Go-Auth-Service/internal/database"
 	"github.com/stretchr/testify/assert"
@@ -67,3 +68,56 @@ func TestPostgresTokenStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, isRevoked)
 }
+
+func TestPostgresTokenStoreUserWatermark(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	// Watermarks reference a user
+	ctx := context.Background()
+	userStore := postgres.NewSQLUserStore(db)
+	suffix := time.Now().Format("20060102150405.000000")
+	user := &local.StoredUser{
+		Username:     "watermark-" + suffix,
+		Email:        "watermark-" + suffix + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	defer userStore.Delete(ctx, user.ID)
+	
+	tokenStore := postgres.NewTokenStore(db)
+	
+	// 1. No watermark by default
+	revokedBefore, err := tokenStore.RevokedBefore(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.IsZero())
+	
+	// 2. Record a watermark
+	now := time.Now().Truncate(time.Second)
+	err = tokenStore.RevokeAllForUser(ctx, user.ID, now)
+	assert.NoError(t, err)
+	
+	revokedBefore, err = tokenStore.RevokedBefore(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.Equal(now))
+	
+	// 3. The watermark never moves backwards
+	err = tokenStore.RevokeAllForUser(ctx, user.ID, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	
+	revokedBefore, err = tokenStore.RevokedBefore(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.Equal(now))
+}
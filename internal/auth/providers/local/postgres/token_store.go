@@ -68,3 +68,32 @@ func (s *TokenStore) CleanupExpiredTokens(ctx context.Context) (int64, error) {
 	
 	return result.RowsAffected()
 }
+
+
+// records a revocation watermark for the user, never moving it backwards
+func (s *TokenStore) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_revocations (user_id, revoked_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET revoked_before = GREATEST(user_revocations.revoked_before, EXCLUDED.revoked_before)`,
+		userID, before)
+	
+	return err
+}
+
+// returns the user's revocation watermark, or the zero time if there is none
+func (s *TokenStore) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	var revokedBefore time.Time
+	err := s.db.GetContext(ctx, &revokedBefore,
+		"SELECT revoked_before FROM user_revocations WHERE user_id = $1", userID)
+	
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	
+	return revokedBefore, nil
+}
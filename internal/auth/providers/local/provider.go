@@ -2,49 +2,196 @@ package local
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
 	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
 	JWTSecret string
 	
-	TokenExpiration time.Duration
+	SigningKey *jwt.SigningKey // Optional asymmetric key; when set, tokens are signed with it instead of JWTSecret
+	
+	RetiredKeys []*jwt.VerificationKey // Previous signing keys, still accepted until their tokens expire
+	
+	TokenExpiration time.Duration // Lifetime of access tokens
+	
+	RefreshTokenExpiration time.Duration // Lifetime of the opaque refresh tokens exchanged for new access tokens
+	
+	Issuer string // Optional "iss" claim; tokens from other issuers are rejected
+	
+	Audience []string // Optional "aud" claim; tokens must be issued for one of these audiences
+	
+	ClockSkew time.Duration // Leeway allowed when checking exp, nbf and iat
 	
 	PasswordValidator func(password string) error // Optional function to validate password requirements
+	
+	PasswordHistorySize int // Users changing their password can't reuse their last this many passwords, including the current one; 0 disables the check
+	
+	MaxFailedLogins int // Consecutive failed logins after which an account is locked for LockoutDuration; 0 disables account lockout
+	
+	FailedLoginDelay time.Duration // From the second consecutive failure on, the account is locked for this long, doubling with every further failure
+	
+	MaxFailedLoginsPerIP int // Failed logins after which an IP address is blocked for LockoutDuration; 0 disables IP throttling
+	
+	LockoutDuration time.Duration // How long lockouts last, and how long failed logins are remembered
+	
+	MaxEmailsPerAddress int // Password reset and login link emails one address can be sent within EmailRequestWindow; 0 turns the limit off
+	
+	MaxEmailRequestsPerIP int // Such emails one IP address can ask for within EmailRequestWindow, across all addresses; 0 turns the limit off
+	
+	EmailRequestWindow time.Duration // How long requested emails are counted, and how long the limits last once reached
+	
+	MFAIssuer string // Name shown next to the account in authenticator apps
+	
+	MFAChallengeExpiration time.Duration // Time users have to enter their second factor after their password
+	
+	DisableRegistration bool // Turns off self-service sign-up; admins can still create users
+	
+	LoginOnRegister bool // Starts a session for users right after they sign up
+	
+	RequireVerifiedEmail bool // Refuses password logins until the user has confirmed their email address
+	
+	EmailVerificationExpiration time.Duration // Lifetime of email verification links
+	
+	PasswordResetExpiration time.Duration // Lifetime of password reset links
+	
+	PublicURL string // Externally reachable base URL of the service, used to build links in emails
+	
+	LinkVerifiedEmail bool // Links a new external identity to the user with the same email address when both the identity provider and the user have verified it
+	
+	IdentityRoles []string // Roles from identity providers that accounts created for external identities get; other roles are dropped, and accounts left without one get "user"
+	
+	IdentityLinkExpiration time.Duration // Time users have to log in at an identity provider they are linking to their account
 }
 
 func DefaultConfig() Config {
 	return Config{
 		JWTSecret:       "change-me-in-production", // Should be overridden in production
-		TokenExpiration: 24 * time.Hour,
-		PasswordValidator: func(password string) error {
-			if len(password) < 8 {
-				return auth.ErrInvalidCredentials
-			}
-			return nil
-		},
+		TokenExpiration:             15 * time.Minute,
+		RefreshTokenExpiration:      7 * 24 * time.Hour,
+		ClockSkew:                   30 * time.Second,
+		PasswordValidator:           DefaultPasswordPolicy().Validate,
+		PasswordHistorySize:         5,
+		MaxFailedLogins:             5,
+		FailedLoginDelay:            time.Second,
+		MaxFailedLoginsPerIP:        20,
+		LockoutDuration:             15 * time.Minute,
+		MaxEmailsPerAddress:         5,
+		MaxEmailRequestsPerIP:       20,
+		EmailRequestWindow:          time.Hour,
+		MFAIssuer:                   "Go-Auth-Service",
+		MFAChallengeExpiration:      5 * time.Minute,
+		LoginOnRegister:             true,
+		EmailVerificationExpiration: 24 * time.Hour,
+		PasswordResetExpiration:     time.Hour,
+		PublicURL:                   "http://localhost:8080",
+		IdentityLinkExpiration:      10 * time.Minute,
 	}
 }
 
 // implements username/password authentication with JWT tokens
 type Provider struct {
-	config    Config
-	userStore UserStore
-	jwtUtil   *jwt.Util
+	config        Config
+	userStore     UserStore
+	refreshStore  RefreshTokenStore
+	sessionStore  SessionStore
+	oneTimeStore  OneTimeTokenStore
+	historyStore  PasswordHistoryStore
+	attemptStore  LoginAttemptStore
+	identityStore IdentityStore
+	mailer        mail.Mailer
+	jwtUtil       *jwt.Util
+	
+	secondFactors []SecondFactor // Set up with AddSecondFactor
+	accountChecks []AccountCheck // Set up with AddAccountCheck
+}
+
+// Option configures optional provider dependencies
+type Option func(*Provider)
+
+// stores refresh tokens in the given store instead of in memory
+func WithRefreshTokenStore(store RefreshTokenStore) Option {
+	return func(p *Provider) {
+		p.refreshStore = store
+	}
+}
+
+// records sessions in the given store instead of in memory
+func WithSessionStore(store SessionStore) Option {
+	return func(p *Provider) {
+		p.sessionStore = store
+	}
+}
+
+// stores one-time tokens such as email verification and password reset links in the given store instead of in memory
+func WithOneTimeTokenStore(store OneTimeTokenStore) Option {
+	return func(p *Provider) {
+		p.oneTimeStore = store
+	}
+}
+
+// remembers previous password hashes in the given store instead of in memory
+func WithPasswordHistoryStore(store PasswordHistoryStore) Option {
+	return func(p *Provider) {
+		p.historyStore = store
+	}
+}
+
+// counts failed logins in the given store instead of in memory
+func WithLoginAttemptStore(store LoginAttemptStore) Option {
+	return func(p *Provider) {
+		p.attemptStore = store
+	}
+}
+
+// keeps the external identities linked to users in the given store instead of in memory
+func WithIdentityStore(store IdentityStore) Option {
+	return func(p *Provider) {
+		p.identityStore = store
+	}
+}
+
+// sends account emails through the given mailer. Without one, no emails are sent.
+func WithMailer(mailer mail.Mailer) Option {
+	return func(p *Provider) {
+		p.mailer = mailer
+	}
 }
 
 // creates a new local authentication provider
-func NewProvider(config Config, userStore UserStore) *Provider {
-	jwtUtil := jwt.NewUtil(config.JWTSecret, config.TokenExpiration)
-	return &Provider{
-		config:    config,
-		userStore: userStore,
-		jwtUtil:   jwtUtil,
+func NewProvider(config Config, userStore UserStore, options ...Option) *Provider {
+	opts := []jwt.Option{
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.Audience...),
+		jwt.WithLeeway(config.ClockSkew),
+	}
+	
+	jwtUtil := jwt.NewUtil(config.JWTSecret, config.TokenExpiration, opts...)
+	if config.SigningKey != nil {
+		keyRing := jwt.NewKeyRing(config.SigningKey, config.RetiredKeys...)
+		jwtUtil = jwt.NewUtilWithKeyRing(keyRing, config.TokenExpiration, opts...)
+	}
+	p := &Provider{
+		config:        config,
+		userStore:     userStore,
+		refreshStore:  NewMemoryRefreshTokenStore(),
+		sessionStore:  NewMemorySessionStore(),
+		oneTimeStore:  NewMemoryOneTimeTokenStore(),
+		historyStore:  NewMemoryPasswordHistoryStore(),
+		attemptStore:  NewMemoryLoginAttemptStore(),
+		identityStore: NewMemoryIdentityStore(),
+		jwtUtil:       jwtUtil,
+	}
+	for _, option := range options {
+		option(p)
 	}
+	return p
 }
 
 // returns the provider identifier
@@ -52,19 +199,64 @@ func (p *Provider) Name() string {
 	return "local"
 }
 
+// returns the public keys that verify tokens issued by this provider
+func (p *Provider) JWKS() jwt.JWKSet {
+	return p.jwtUtil.JWKS()
+}
+
+// returns the utility that signs and validates the provider's tokens, so other
+// providers can issue tokens that services already accept
+func (p *Provider) TokenUtil() *jwt.Util {
+	return p.jwtUtil
+}
+
 func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
 	if creds.Type != "password" {
 		return nil, auth.ErrInvalidCredentials
 	}
 	
-	user, err := p.userStore.GetByUsername(ctx, creds.Username)
-	if err != nil {
+	// No account has a colon in its username, and counting such logins
+	// would lock out the users of other providers, whose keys contain one
+	if strings.Contains(creds.Username, ":") {
 		return nil, auth.ErrInvalidCredentials
 	}
 	
+	// Unknown usernames are counted too, so lockouts don't reveal which accounts exist
+	key := AccountAttemptPrefix + creds.Username
+	if p.config.MaxFailedLogins > 0 {
+		if err := p.checkLoginAttempts(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Directory users log in through the directory, even if they once got a local password
+	user, err := p.userStore.GetByUsername(ctx, creds.Username)
+	if err != nil || DirectoryManaged(user) {
+		return nil, p.failLogin(ctx, key)
+	}
+	
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password))
 	if err != nil {
-		return nil, auth.ErrInvalidCredentials
+		return nil, p.failLogin(ctx, key)
+	}
+	
+	// With two-factor authentication the counter is reset once the second factor is right too,
+	// so guessing codes can't be combined with the known password to dodge the lockout
+	if p.config.MaxFailedLogins > 0 {
+		methods, err := p.mfaMethods(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if len(methods) == 0 {
+			if err := p.attemptStore.Reset(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	
+	// Checked after the password so the error doesn't reveal which accounts exist
+	if p.config.RequireVerifiedEmail && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
 	}
 	
 	authUser := &auth.User{
@@ -85,7 +277,7 @@ func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User,
 	}
 	
 	// Get user ID from claims
-	userID, ok := claims["sub"].(string)
+	userID, ok := accessTokenSubject(claims)
 	if !ok {
 		return nil, auth.ErrInvalidCredentials
 	}
@@ -105,6 +297,19 @@ func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User,
 	return authUser, nil
 }
 
+// returns the user ID of an access token. Tokens issued for something else, like the
+// ID tokens that tell OAuth clients who logged in, say so in "token_use" and aren't accepted.
+func accessTokenSubject(claims map[string]interface{}) (string, bool) {
+	if use, exists := claims["token_use"]; exists && use != "access" {
+		return "", false
+	}
+	userID, ok := claims["sub"].(string)
+	return userID, ok
+}
+
+// issues a new access token.
+// With an empty token, the token is minted for the user stored in the context under "user".
+// Otherwise token must be a refresh token issued by IssueTokens.
 func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
 	// If token is empty, generate a new token for the user in the context
 	if token == "" {
@@ -121,32 +326,38 @@ func (p *Provider) RefreshToken(ctx context.Context, token string) (string, erro
 			return p.jwtUtil.GenerateToken(claims)
 		}
 		
-		// Use the authenticated user's information for the claims
-		claims := map[string]interface{}{
-			"sub":     ctxUser.ID,
-			"roles":   ctxUser.Roles,
-			"email":   ctxUser.Email,
-			"name":    ctxUser.Username,
-			"provider": "local",
-		}
-		
-		return p.jwtUtil.GenerateToken(claims)
+		token, _, err := p.generateAccessToken(ctxUser, "")
+		return token, err
 	}
 	
-	user, err := p.ValidateToken(ctx, token)
+	// Access tokens can't be used to mint new ones, so a leaked access
+	// token stops working once it expires
+	pair, err := p.RefreshTokens(ctx, token)
 	if err != nil {
 		return "", err
 	}
 	
+	return pair.AccessToken, nil
+}
+
+// creates an access token carrying the user's identity and roles and returns it with its jti.
+// sessionID is the session (refresh token family) the token was issued for, if any.
+func (p *Provider) generateAccessToken(user *auth.User, sessionID string) (string, string, error) {
+	tokenID := uuid.New().String()
 	claims := map[string]interface{}{
-		"sub":    user.ID,
-		"roles":  user.Roles,
-		"email":  user.Email,
-		"name":   user.Username,
+		"jti":     tokenID,
+		"sub":     user.ID,
+		"roles":   user.Roles,
+		"email":   user.Email,
+		"name":    user.Username,
 		"provider": "local",
 	}
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
 	
-	return p.jwtUtil.GenerateToken(claims)
+	token, err := p.jwtUtil.GenerateToken(claims)
+	return token, tokenID, err
 }
 
 // invalidates a token
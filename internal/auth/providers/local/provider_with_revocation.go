@@ -2,6 +2,7 @@ package local
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/NBDor/Go-Auth-Service/internal/auth"
@@ -13,6 +14,12 @@ type TokenRevocationStore interface {
 	IsRevoked(ctx context.Context, tokenID string) (bool, error)
 	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
 	CleanupExpiredTokens(ctx context.Context) (int64, error)
+	
+	// RevokeAllForUser invalidates every token issued to the user before the given time
+	RevokeAllForUser(ctx context.Context, userID string, before time.Time) error
+	
+	// RevokedBefore returns the user's revocation watermark, or the zero time if there is none
+	RevokedBefore(ctx context.Context, userID string) (time.Time, error)
 }
 
 // ProviderWithRevocation extends the local provider with token revocation
@@ -22,8 +29,8 @@ type ProviderWithRevocation struct {
 }
 
 // NewProviderWithRevocation creates a new local provider with token revocation
-func NewProviderWithRevocation(config Config, userStore UserStore, tokenStore TokenRevocationStore) *ProviderWithRevocation {
-	provider := NewProvider(config, userStore)
+func NewProviderWithRevocation(config Config, userStore UserStore, tokenStore TokenRevocationStore, options ...Option) *ProviderWithRevocation {
+	provider := NewProvider(config, userStore, options...)
 	return &ProviderWithRevocation{
 		Provider:   provider,
 		tokenStore: tokenStore,
@@ -63,12 +70,32 @@ func (p *ProviderWithRevocation) ValidateToken(ctx context.Context, token string
 		return nil, jwt.ErrInvalidToken
 	}
 	
+	// Check if the session (refresh token family) the token belongs to was revoked
+	if sessionID, ok := claims["sid"].(string); ok {
+		isRevoked, err = p.tokenStore.IsRevoked(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if isRevoked {
+			return nil, jwt.ErrInvalidToken
+		}
+	}
+	
 	// Get user ID from claims
-	userID, ok := claims["sub"].(string)
+	userID, ok := accessTokenSubject(claims)
 	if !ok {
 		return nil, auth.ErrInvalidCredentials
 	}
 	
+	// Check if the token predates a "logout everywhere" for this user
+	revokedBefore, err := p.tokenStore.RevokedBefore(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if IssuedBefore(claims, revokedBefore) {
+		return nil, jwt.ErrInvalidToken
+	}
+	
 	user, err := p.userStore.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -79,42 +106,140 @@ func (p *ProviderWithRevocation) ValidateToken(ctx context.Context, token string
 		Username: user.Username,
 		Email:    user.Email,
 		Roles:    user.Roles,
+		Metadata: map[string]interface{}{},
+	}
+	
+	// Expose the session so callers can tell which session is the current one
+	if sessionID, ok := claims["sid"].(string); ok {
+		authUser.Metadata["session_id"] = sessionID
 	}
 	
 	return authUser, nil
 }
 
-// RefreshToken generates a new token while invalidating the old one
+// RefreshToken overrides the base implementation so refresh token reuse
+// also revokes the access tokens issued to the compromised session
 func (p *ProviderWithRevocation) RefreshToken(ctx context.Context, token string) (string, error) {
 	if token == "" {
 		// Generate a new token for the user in the context
 		return p.Provider.RefreshToken(ctx, token)
 	}
 	
-	// Validate the old token
-	claims, err := p.jwtUtil.ValidateToken(token)
-	if err != nil && err != jwt.ErrExpiredToken {
+	pair, err := p.RefreshTokens(ctx, token)
+	if err != nil {
 		return "", err
 	}
 	
-	// Revoke the old token
-	tokenID, _ := claims["jti"].(string)
-	if tokenID != "" {
-		// Get expiry time
-		expClaim, ok := claims["exp"].(float64)
-		expiresAt := time.Now().Add(p.config.TokenExpiration)
-		if ok {
-			expiresAt = time.Unix(int64(expClaim), 0)
+	return pair.AccessToken, nil
+}
+
+// RefreshTokens rotates the refresh token. When a rotated token is reused or
+// the user has been disabled in the directory, the base provider revokes the
+// refresh token family and this revokes the access tokens that were issued to it.
+func (p *ProviderWithRevocation) RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, lookupErr := p.refreshStore.Get(ctx, hashOpaqueToken(refreshToken))
+	
+	pair, err := p.Provider.RefreshTokens(ctx, refreshToken)
+	if (errors.Is(err, ErrRefreshTokenReused) || errors.Is(err, ErrAccountDisabled)) && lookupErr == nil {
+		if revokeErr := p.revokeSession(ctx, stored.FamilyID); revokeErr != nil {
+			return nil, revokeErr
 		}
-		
-		err = p.tokenStore.RevokeToken(ctx, tokenID, expiresAt)
-		if err != nil {
-			return "", err
+	}
+	
+	return pair, err
+}
+
+// RevokeRefreshToken ends the session of a refresh token, including its access tokens
+func (p *ProviderWithRevocation) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	stored, err := p.refreshStore.Get(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			return nil
 		}
+		return err
+	}
+	
+	return p.revokeSession(ctx, stored.FamilyID)
+}
+
+// RevokeSession ends one of the user's sessions, e.g. a lost device.
+// Returns ErrSessionNotFound if the session doesn't exist or belongs to someone else.
+func (p *ProviderWithRevocation) RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	session, err := p.sessionStore.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
 	}
 	
-	// Generate a new token
-	return p.Provider.RefreshToken(ctx, token)
+	return p.revokeSession(ctx, sessionID)
+}
+
+// RevokeAllTokens logs the user out everywhere: every access token issued
+// before now is rejected and all of the user's refresh tokens and sessions are deleted
+func (p *ProviderWithRevocation) RevokeAllTokens(ctx context.Context, userID string) error {
+	if err := p.tokenStore.RevokeAllForUser(ctx, userID, time.Now()); err != nil {
+		return err
+	}
+	
+	if err := p.refreshStore.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	
+	return p.sessionStore.DeleteByUser(ctx, userID)
+}
+
+// ResetPassword overrides the base implementation so access tokens issued
+// before the reset are rejected too, not just the user's refresh tokens
+func (p *ProviderWithRevocation) ResetPassword(ctx context.Context, token string, password string) (*StoredUser, error) {
+	user, err := p.Provider.ResetPassword(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := p.tokenStore.RevokeAllForUser(ctx, user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	
+	return user, nil
+}
+
+// UpdateUser overrides the base implementation so that when a password is set,
+// access tokens issued before are rejected too, not just the user's refresh tokens
+func (p *ProviderWithRevocation) UpdateUser(ctx context.Context, id string, update UserUpdate) (*StoredUser, error) {
+	user, err := p.Provider.UpdateUser(ctx, id, update)
+	if err != nil {
+		return nil, err
+	}
+	
+	if update.Password != nil {
+		if err := p.tokenStore.RevokeAllForUser(ctx, user.ID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	
+	return user, nil
+}
+
+// ChangePassword overrides the base implementation so the access tokens of
+// the other sessions are rejected too, not just their refresh tokens
+func (p *ProviderWithRevocation) ChangePassword(ctx context.Context, userID string, currentPassword string, newPassword string, currentSessionID string) error {
+	if err := p.changePassword(ctx, userID, currentPassword, newPassword); err != nil {
+		return err
+	}
+	
+	return p.endSessionsExcept(ctx, userID, currentSessionID, p.revokeSession)
+}
+
+// revokeSession ends a session and invalidates every access token carrying its "sid" claim.
+// Access tokens live at most TokenExpiration, so the revocation entry can expire after that.
+func (p *ProviderWithRevocation) revokeSession(ctx context.Context, sessionID string) error {
+	if err := p.tokenStore.RevokeToken(ctx, sessionID, time.Now().Add(p.config.TokenExpiration)); err != nil {
+		return err
+	}
+	
+	return p.endSession(ctx, sessionID)
 }
 
 // RevokeToken overrides the base implementation to store revoked tokens
@@ -131,6 +256,13 @@ func (p *ProviderWithRevocation) RevokeToken(ctx context.Context, token string)
 		tokenID = token // Use the token as ID if jti not available
 	}
 	
+	// Logging out with a session's access token ends the whole session
+	if sessionID, ok := claims["sid"].(string); ok {
+		if err := p.revokeSession(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+	
 	// Get expiry time
 	expClaim, ok := claims["exp"].(float64)
 	if !ok {
@@ -142,3 +274,20 @@ func (p *ProviderWithRevocation) RevokeToken(ctx context.Context, token string)
 	expiresAt := time.Unix(int64(expClaim), 0)
 	return p.tokenStore.RevokeToken(ctx, tokenID, expiresAt)
 }
+
+// IssuedBefore reports whether a token predates a user's "logout everywhere" watermark.
+// Tokens are compared to the microsecond, so ones issued right after it are accepted.
+// Tokens issued in the same microsecond, or without "iat_us", which only have iat's second
+// precision, are rejected too rather than risk letting an old one through.
+func IssuedBefore(claims map[string]interface{}, revokedBefore time.Time) bool {
+	if revokedBefore.IsZero() {
+		return false
+	}
+	
+	// Microseconds since 1970 fit a float64 exactly
+	if issuedAtMicros, ok := claims["iat_us"].(float64); ok {
+		return int64(issuedAtMicros) <= revokedBefore.UnixMicro()
+	}
+	issuedAt, ok := claims["iat"].(float64)
+	return !ok || int64(issuedAt) <= revokedBefore.Unix()
+}
@@ -0,0 +1,71 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken is the server-side record of an issued refresh token.
+// Only a hash of the token is stored, so a leaked database can't be used to refresh sessions.
+//
+// Every refresh token belongs to a family that starts at login. Each refresh
+// rotates the token: the old one is marked used and a new one is issued in the
+// same family. Presenting a used token means it was stolen (or the client is
+// replaying it), so the whole family is revoked.
+type RefreshToken struct {
+	TokenHash string
+	UserID    string
+	FamilyID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time // Set once the token has been rotated
+}
+
+// RefreshTokenStore persists opaque refresh tokens
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	
+	// returns ErrInvalidRefreshToken if no token with this hash exists
+	Get(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	
+	// atomically marks the old token used and stores its replacement.
+	// Returns ErrRefreshTokenReused if the old token was already used.
+	Rotate(ctx context.Context, oldTokenHash string, next *RefreshToken) error
+	
+	Delete(ctx context.Context, tokenHash string) error
+	
+	// removes every token in a family
+	DeleteFamily(ctx context.Context, familyID string) error
+	
+	// removes every token issued to a user
+	DeleteByUser(ctx context.Context, userID string) error
+	
+	CleanupExpiredTokens(ctx context.Context) (int64, error)
+}
+
+// generateOpaqueToken creates a random opaque token and the hash it is stored under.
+// Used for refresh tokens and one-time tokens.
+func generateOpaqueToken() (token string, tokenHash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashOpaqueToken(token), nil
+}
+
+// hashOpaqueToken derives the storage key for an opaque token
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,49 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session records where a user is logged in.
+// A session starts at login and shares its ID with the refresh token family,
+// which access tokens carry in their "sid" claim.
+type Session struct {
+	ID         string
+	UserID     string
+	TokenID    string // jti of the most recent access token
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time // Last login or token refresh
+	ExpiresAt  time.Time // When the session's refresh token expires
+}
+
+// ClientInfo describes the client a session was started from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// SessionStore persists active sessions
+type SessionStore interface {
+	Create(ctx context.Context, session *Session) error
+	
+	// returns ErrSessionNotFound if the session does not exist
+	Get(ctx context.Context, id string) (*Session, error)
+	
+	// returns the user's unexpired sessions, most recently seen first
+	ListByUser(ctx context.Context, userID string) ([]*Session, error)
+	
+	// records activity on a session after its tokens were refreshed
+	Touch(ctx context.Context, id string, tokenID string, seenAt time.Time, expiresAt time.Time) error
+	
+	Delete(ctx context.Context, id string) error
+	
+	DeleteByUser(ctx context.Context, userID string) error
+	
+	CleanupExpiredSessions(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var verificationLink = regexp.MustCompile(`https?://\S+/auth/verify-email\?token=(\S+)`)
+
+// lastVerificationToken extracts the token from the most recent verification email sent to the address
+func lastVerificationToken(t *testing.T, mailer *mail.MemoryMailer, to string) string {
+	message, ok := mailer.Last(to)
+	require.True(t, ok, "no email sent to %s", to)
+	
+	match := verificationLink.FindStringSubmatch(message.Body)
+	require.NotNil(t, match, "no verification link in %q", message.Body)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func TestEmailVerification(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.RequireVerifiedEmail = true
+	config.PublicURL = "https://auth.example.com"
+	
+	mailer := mail.NewMemoryMailer()
+	provider := local.NewProviderWithRevocation(config, local.NewMemoryUserStore(), newMockTokenStore(),
+		local.WithMailer(mailer))
+	ctx := context.Background()
+	creds := auth.Credentials{Type: "password", Username: "newuser", Password: "password123"}
+	
+	// 1. Registering sends a verification link and doesn't log the user in yet
+	user, pair, err := provider.Register(ctx, "newuser", "new@example.com", "password123", local.ClientInfo{})
+	require.NoError(t, err)
+	assert.Nil(t, pair)
+	token := lastVerificationToken(t, mailer, "new@example.com")
+	
+	_, err = provider.Authenticate(ctx, creds)
+	assert.ErrorIs(t, err, local.ErrEmailNotVerified)
+	
+	// A wrong password still reports invalid credentials
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "newuser", Password: "wrong-password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 2. Resending replaces the link
+	require.NoError(t, provider.ResendVerificationEmail(ctx, "new@example.com"))
+	newToken := lastVerificationToken(t, mailer, "new@example.com")
+	assert.NotEqual(t, token, newToken)
+	
+	_, err = provider.VerifyEmail(ctx, token)
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// 3. Verifying unlocks login; the link only works once
+	verified, err := provider.VerifyEmail(ctx, newToken)
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerified)
+	
+	_, err = provider.Authenticate(ctx, creds)
+	assert.NoError(t, err)
+	
+	_, err = provider.VerifyEmail(ctx, newToken)
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// Resending to verified or unknown addresses does nothing
+	sent := len(mailer.Messages())
+	require.NoError(t, provider.ResendVerificationEmail(ctx, "new@example.com"))
+	require.NoError(t, provider.ResendVerificationEmail(ctx, "unknown@example.com"))
+	assert.Len(t, mailer.Messages(), sent)
+	
+	// 4. Changing the email address requires verifying the new one
+	email := "changed@example.com"
+	updated, err := provider.UpdateUser(ctx, user.ID, local.UserUpdate{Email: &email})
+	require.NoError(t, err)
+	assert.False(t, updated.EmailVerified)
+	
+	_, err = provider.Authenticate(ctx, creds)
+	assert.ErrorIs(t, err, local.ErrEmailNotVerified)
+	
+	changedToken := lastVerificationToken(t, mailer, "changed@example.com")
+	_, err = provider.VerifyEmail(ctx, changedToken)
+	require.NoError(t, err)
+	
+	_, err = provider.Authenticate(ctx, creds)
+	assert.NoError(t, err)
+	
+	// 5. Users created already verified get no email
+	sent = len(mailer.Messages())
+	err = provider.CreateUser(ctx, &local.StoredUser{Username: "imported", Email: "imported@example.com", EmailVerified: true}, "password123")
+	require.NoError(t, err)
+	assert.Len(t, mailer.Messages(), sent)
+}
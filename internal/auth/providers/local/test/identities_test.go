@@ -0,0 +1,237 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginWithIdentity(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	provider, userStore, existing := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. An unknown identity gets a new account without a password
+	alice := local.ExternalIdentity{
+		Provider:      "google",
+		Subject:       "alice-sub",
+		Username:      "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+	}
+	user, err := provider.LoginWithIdentity(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, []string{"user"}, user.Roles)
+	
+	stored, err := userStore.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.EmailVerified)
+	assert.Empty(t, stored.PasswordHash)
+	
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "alice", Password: ""})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 2. Later logins find the same account, even if the email address changed
+	alice.Email = "alice@new.example.com"
+	again, err := provider.LoginWithIdentity(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+	
+	// 3. Taken usernames get a suffix
+	bob := local.ExternalIdentity{Provider: "github", Subject: "42", Username: "alice", Email: "bob@example.com"}
+	user, err = provider.LoginWithIdentity(ctx, bob)
+	require.NoError(t, err)
+	assert.Equal(t, "alice-2", user.Username)
+	
+	// 4. Without email linking, an address that has an account is refused
+	mallory := local.ExternalIdentity{Provider: "github", Subject: "43", Email: existing.Email, EmailVerified: true}
+	_, err = provider.LoginWithIdentity(ctx, mallory)
+	assert.ErrorIs(t, err, local.ErrEmailTaken)
+	
+	_, err = provider.LoginWithIdentity(ctx, local.ExternalIdentity{Provider: "github", Subject: "44"})
+	assert.ErrorIs(t, err, local.ErrInvalidEmail)
+	
+	// 5. No new accounts when registration is disabled
+	config.DisableRegistration = true
+	closed, _, _ := newRefreshTestProvider(t, config)
+	_, err = closed.LoginWithIdentity(ctx, bob)
+	assert.ErrorIs(t, err, local.ErrRegistrationDisabled)
+}
+
+func TestIdentityRolesAndUsernames(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.IdentityRoles = []string{"staff"}
+	provider, _, _ := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Only the roles identity providers are trusted with are kept
+	user, err := provider.LoginWithIdentity(ctx, local.ExternalIdentity{
+		Provider: "corp",
+		Subject:  "1",
+		Username: "carol",
+		Email:    "carol@example.com",
+		Roles:    []string{"admin", "staff", "staff"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staff"}, user.Roles)
+	
+	user, err = provider.LoginWithIdentity(ctx, local.ExternalIdentity{
+		Provider: "corp",
+		Subject:  "2",
+		Username: "dave",
+		Email:    "dave@example.com",
+		Roles:    []string{"admin"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, user.Roles)
+	
+	// 2. Preferred usernames are trimmed, and invalid ones are replaced with the email address's local part
+	user, err = provider.LoginWithIdentity(ctx, local.ExternalIdentity{Provider: "corp", Subject: "3", Username: "  erin ", Email: "erin@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "erin", user.Username)
+	
+	user, err = provider.LoginWithIdentity(ctx, local.ExternalIdentity{Provider: "corp", Subject: "4", Username: "Frank Smith", Email: "frank@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "frank", user.Username)
+	
+	// Without either, the provider and subject are used, made safe
+	user, err = provider.LoginWithIdentity(ctx, local.ExternalIdentity{Provider: "corp", Subject: "auth0|5", Username: "   ", Email: "grace+hopper@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "corp-auth0-5", user.Username)
+}
+
+func TestLinkIdentityByEmail(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.LinkVerifiedEmail = true
+	provider, userStore, existing := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	external := local.ExternalIdentity{Provider: "google", Subject: "refresh-sub", Email: existing.Email, EmailVerified: true}
+	
+	// 1. The local address isn't verified, so whoever registered it doesn't get the identity
+	_, err := provider.LoginWithIdentity(ctx, external)
+	assert.ErrorIs(t, err, local.ErrEmailTaken)
+	
+	// 2. Neither is an address the identity provider doesn't vouch for
+	stored, err := userStore.GetByID(ctx, existing.ID)
+	require.NoError(t, err)
+	stored.EmailVerified = true
+	require.NoError(t, userStore.Update(ctx, stored))
+	
+	unverified := external
+	unverified.EmailVerified = false
+	_, err = provider.LoginWithIdentity(ctx, unverified)
+	assert.ErrorIs(t, err, local.ErrEmailTaken)
+	
+	// 3. With both verified, the identity is linked to the existing user
+	user, err := provider.LoginWithIdentity(ctx, external)
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, user.ID)
+	
+	identities, err := provider.ListIdentities(ctx, existing.ID)
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+	assert.Equal(t, "google", identities[0].Provider)
+	assert.Equal(t, "refresh-sub", identities[0].Subject)
+}
+
+func TestManageIdentities(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	provider, _, existing := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Logged-in users link identities after logging in at the identity provider
+	require.NoError(t, provider.StartIdentityLink(ctx, existing.ID, "link-state"))
+	userID, err := provider.ConsumeIdentityLink(ctx, "link-state")
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, userID)
+	
+	_, err = provider.ConsumeIdentityLink(ctx, "link-state")
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	github := local.ExternalIdentity{Provider: "github", Subject: "7", Email: "someone@example.com"}
+	identity, err := provider.LinkIdentity(ctx, userID, github)
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, identity.UserID)
+	
+	// Linking again does nothing
+	_, err = provider.LinkIdentity(ctx, userID, github)
+	require.NoError(t, err)
+	
+	user, err := provider.LoginWithIdentity(ctx, github)
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, user.ID)
+	
+	// 2. An identity belongs to one user
+	other, err := provider.LoginWithIdentity(ctx, local.ExternalIdentity{Provider: "google", Subject: "8", Email: "other@example.com"})
+	require.NoError(t, err)
+	_, err = provider.LinkIdentity(ctx, other.ID, github)
+	assert.ErrorIs(t, err, local.ErrIdentityLinked)
+	assert.ErrorIs(t, provider.UnlinkIdentity(ctx, other.ID, "github", "7"), local.ErrIdentityNotFound)
+	
+	// 3. Users without a password keep at least one identity
+	assert.ErrorIs(t, provider.UnlinkIdentity(ctx, other.ID, "google", "8"), local.ErrLastLoginMethod)
+	
+	// 4. Users with a password can unlink all identities, which then log in to a new account
+	password := "password123"
+	_, err = provider.UpdateUser(ctx, existing.ID, local.UserUpdate{Password: &password})
+	require.NoError(t, err)
+	require.NoError(t, provider.UnlinkIdentity(ctx, existing.ID, "github", "7"))
+	identities, err := provider.ListIdentities(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Empty(t, identities)
+	
+	user, err = provider.LoginWithIdentity(ctx, github)
+	require.NoError(t, err)
+	assert.NotEqual(t, existing.ID, user.ID)
+	
+	// 5. Deleting a user unlinks their identities
+	require.NoError(t, provider.DeleteUser(ctx, user.ID))
+	identities, err = provider.ListIdentities(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, identities)
+}
+
+func TestDirectoryUsersCantUseIdentities(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.LinkVerifiedEmail = true
+	provider, userStore, existing := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	github := local.ExternalIdentity{Provider: "github", Subject: "7", Email: "octo@example.com"}
+	_, err := provider.LinkIdentity(ctx, existing.ID, github)
+	require.NoError(t, err)
+	
+	// The account moves to the directory
+	stored, err := userStore.GetByID(ctx, existing.ID)
+	require.NoError(t, err)
+	stored.EmailVerified = true
+	stored.Metadata = map[string]interface{}{local.MetadataDirectoryDN: "uid=refreshuser,ou=people,dc=example,dc=com"}
+	require.NoError(t, userStore.Update(ctx, stored))
+	
+	// 1. The identity linked before no longer logs in
+	_, err = provider.LoginWithIdentity(ctx, github)
+	assert.ErrorIs(t, err, local.ErrDirectoryManaged)
+	
+	// 2. No more identities can be linked
+	_, err = provider.LinkIdentity(ctx, existing.ID, local.ExternalIdentity{Provider: "google", Subject: "8", Email: "g@example.com"})
+	assert.ErrorIs(t, err, local.ErrDirectoryManaged)
+	
+	// 3. Nor are identities with the same verified email address linked to it
+	_, err = provider.LoginWithIdentity(ctx, local.ExternalIdentity{Provider: "google", Subject: "9", Email: existing.Email, EmailVerified: true})
+	assert.ErrorIs(t, err, local.ErrEmailTaken)
+	
+	identities, err := provider.ListIdentities(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Len(t, identities, 1)
+}
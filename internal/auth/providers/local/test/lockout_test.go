@@ -0,0 +1,321 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLockoutTestProvider(t *testing.T, config local.Config) (*local.Provider, *local.StoredUser) {
+	config.JWTSecret = "test-secret"
+	provider := local.NewProvider(config, local.NewMemoryUserStore())
+	
+	user := &local.StoredUser{Username: "target", Email: "target@example.com", EmailVerified: true}
+	require.NoError(t, provider.CreateUser(context.Background(), user, "password123"))
+	return provider, user
+}
+
+func TestAccountLockout(t *testing.T) {
+	config := local.DefaultConfig()
+	config.MaxFailedLogins = 3
+	config.FailedLoginDelay = 0
+	config.LockoutDuration = time.Hour
+	provider, user := newLockoutTestProvider(t, config)
+	ctx := context.Background()
+	
+	wrong := auth.Credentials{Type: "password", Username: "target", Password: "wrong-password"}
+	right := auth.Credentials{Type: "password", Username: "target", Password: "password123"}
+	
+	// 1. A successful login resets the counter
+	for i := 0; i < 2; i++ {
+		_, err := provider.Authenticate(ctx, wrong)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	_, err := provider.Authenticate(ctx, right)
+	require.NoError(t, err)
+	
+	attempts, err := provider.AccountLockout(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, attempts.Failures)
+	
+	// 2. Too many failures lock the account, even for the right password
+	for i := 0; i < 3; i++ {
+		_, err = provider.Authenticate(ctx, wrong)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	
+	_, err = provider.Authenticate(ctx, right)
+	var throttled *local.LoginThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 5)
+	
+	attempts, err = provider.AccountLockout(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts.Failures)
+	assert.True(t, attempts.Locked(time.Now()))
+	
+	lockouts, err := provider.ListLockouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, local.AccountAttemptPrefix+"target", lockouts[0].Key)
+	
+	// 3. An admin can unlock the account
+	require.NoError(t, provider.ClearAccountLockout(ctx, user.ID))
+	_, err = provider.Authenticate(ctx, right)
+	assert.NoError(t, err)
+	
+	// Unknown usernames are locked the same way, so lockouts don't reveal which accounts exist
+	unknown := auth.Credentials{Type: "password", Username: "nobody", Password: "password123"}
+	for i := 0; i < 3; i++ {
+		_, err = provider.Authenticate(ctx, unknown)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	_, err = provider.Authenticate(ctx, unknown)
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+}
+
+func TestFailedLoginDelay(t *testing.T) {
+	config := local.DefaultConfig()
+	config.MaxFailedLogins = 10
+	config.FailedLoginDelay = 100 * time.Millisecond
+	provider, _ := newLockoutTestProvider(t, config)
+	ctx := context.Background()
+	
+	wrong := auth.Credentials{Type: "password", Username: "target", Password: "wrong-password"}
+	right := auth.Credentials{Type: "password", Username: "target", Password: "password123"}
+	
+	// The first failure costs nothing
+	_, err := provider.Authenticate(ctx, wrong)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.Authenticate(ctx, wrong)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// The second one delays the next attempt, the third one twice as long
+	var throttled *local.LoginThrottledError
+	_, err = provider.Authenticate(ctx, right)
+	require.ErrorAs(t, err, &throttled)
+	assert.LessOrEqual(t, throttled.RetryAfter, 100*time.Millisecond)
+	
+	time.Sleep(throttled.RetryAfter)
+	_, err = provider.Authenticate(ctx, wrong)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	_, err = provider.Authenticate(ctx, right)
+	require.ErrorAs(t, err, &throttled)
+	assert.Greater(t, throttled.RetryAfter, 100*time.Millisecond)
+	
+	time.Sleep(throttled.RetryAfter)
+	_, err = provider.Authenticate(ctx, right)
+	assert.NoError(t, err)
+}
+
+func TestIPThrottling(t *testing.T) {
+	config := local.DefaultConfig()
+	config.MaxFailedLogins = 0
+	config.MaxFailedLoginsPerIP = 3
+	config.LockoutDuration = time.Hour
+	provider, _ := newLockoutTestProvider(t, config)
+	ctx := context.Background()
+	
+	attacker := local.ClientInfo{IPAddress: "203.0.113.7"}
+	other := local.ClientInfo{IPAddress: "198.51.100.1"}
+	right := auth.Credentials{Type: "password", Username: "target", Password: "password123"}
+	
+	// Guessing across different usernames still blocks the address
+	for _, username := range []string{"alice", "bob", "carol"} {
+		_, err := provider.AuthenticateFrom(ctx, auth.Credentials{Type: "password", Username: username, Password: "guess"}, attacker)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	
+	_, err := provider.AuthenticateFrom(ctx, right, attacker)
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+	
+	// Other addresses aren't affected
+	_, err = provider.AuthenticateFrom(ctx, right, other)
+	assert.NoError(t, err)
+	
+	lockouts, err := provider.ListLockouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, local.IPAttemptPrefix+"203.0.113.7", lockouts[0].Key)
+	
+	require.NoError(t, provider.ClearIPLockout(ctx, "203.0.113.7"))
+	_, err = provider.AuthenticateFrom(ctx, right, attacker)
+	assert.NoError(t, err)
+}
+
+// directory is a password provider other than the local one that knows dana
+type directory struct {
+	*local.Provider
+}
+
+func (d directory) Name() string {
+	return "directory"
+}
+
+func (d directory) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	if creds.Username != "dana" || creds.Password != "directory-secret" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return &auth.User{ID: "dana", Username: "dana"}, nil
+}
+
+func TestThrottleOtherProviders(t *testing.T) {
+	config := local.DefaultConfig()
+	config.MaxFailedLogins = 3
+	config.FailedLoginDelay = 0
+	config.MaxFailedLoginsPerIP = 5
+	config.LockoutDuration = time.Hour
+	provider, _ := newLockoutTestProvider(t, config)
+	other := directory{provider}
+	ctx := context.Background()
+	
+	client := local.ClientInfo{IPAddress: "203.0.113.7"}
+	right := auth.Credentials{Type: "password", Username: "dana", Password: "directory-secret"}
+	
+	// 1. A successful login resets the counter
+	_, err := provider.AuthenticateWith(ctx, other, auth.Credentials{Type: "password", Username: "dana", Password: "guess"}, client)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.AuthenticateWith(ctx, other, right, client)
+	require.NoError(t, err)
+	
+	// 2. Failures are counted per provider and username, whatever its case
+	for _, username := range []string{"dana", "Dana", "DANA"} {
+		_, err = provider.AuthenticateWith(ctx, other, auth.Credentials{Type: "password", Username: username, Password: "guess"}, client)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	_, err = provider.AuthenticateWith(ctx, other, right, local.ClientInfo{IPAddress: "198.51.100.1"})
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+	
+	// The local account with the same username isn't affected
+	_, err = provider.AuthenticateFrom(ctx, auth.Credentials{Type: "password", Username: "target", Password: "password123"}, client)
+	require.NoError(t, err)
+	
+	// 3. The IP address is throttled across providers
+	_, err = provider.AuthenticateWith(ctx, other, auth.Credentials{Type: "password", Username: "erin", Password: "guess"}, client)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.AuthenticateFrom(ctx, auth.Credentials{Type: "password", Username: "target", Password: "password123"}, client)
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+	
+	// 4. Admins see and clear the lockout on the directory user's shadow user
+	shadow := &local.StoredUser{Username: "dana", Email: "dana@example.com", EmailVerified: true}
+	require.NoError(t, provider.CreateUser(ctx, shadow, "password123"))
+	_, err = provider.UpdateUser(ctx, shadow.ID, local.UserUpdate{
+		Metadata: map[string]interface{}{"created_by": "directory", local.MetadataDirectoryDN: "uid=dana,dc=example,dc=com"},
+	})
+	require.NoError(t, err)
+	
+	attempts, err := provider.AccountLockout(ctx, shadow.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts.Failures)
+	
+	require.NoError(t, provider.ClearAccountLockout(ctx, shadow.ID))
+	_, err = provider.AuthenticateWith(ctx, other, right, local.ClientInfo{IPAddress: "198.51.100.1"})
+	assert.NoError(t, err)
+	
+	// 5. Local logins with usernames like the directory's keys don't lock out its users
+	for i := 0; i < 3; i++ {
+		_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "directory:dana", Password: "guess"})
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+	_, err = provider.AuthenticateWith(ctx, other, right, local.ClientInfo{IPAddress: "198.51.100.1"})
+	assert.NoError(t, err)
+}
+
+func TestThrottleEmailRequests(t *testing.T) {
+	config := local.DefaultConfig()
+	config.MaxEmailsPerAddress = 2
+	config.MaxEmailRequestsPerIP = 3
+	config.EmailRequestWindow = time.Hour
+	provider, _ := newLockoutTestProvider(t, config)
+	ctx := context.Background()
+	
+	client := local.ClientInfo{IPAddress: "192.0.2.1"}
+	other := local.ClientInfo{IPAddress: "192.0.2.2"}
+	
+	// 1. Each address can only be sent so many emails, whoever asks and whether or not it has an account
+	require.NoError(t, provider.ThrottleEmailRequest(ctx, "target@example.com", client))
+	require.NoError(t, provider.ThrottleEmailRequest(ctx, " Target@Example.com ", other))
+	
+	err := provider.ThrottleEmailRequest(ctx, "target@example.com", other)
+	var throttled *local.LoginThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 5)
+	
+	// 2. and each IP address can only ask for so many
+	require.NoError(t, provider.ThrottleEmailRequest(ctx, "nobody@example.com", client))
+	require.NoError(t, provider.ThrottleEmailRequest(ctx, "someone@example.com", client))
+	err = provider.ThrottleEmailRequest(ctx, "anyone@example.com", client)
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+	require.NoError(t, provider.ThrottleEmailRequest(ctx, "anyone@example.com", other))
+	
+	// 3. The limits don't affect logins
+	_, err = provider.AuthenticateFrom(ctx, auth.Credentials{Type: "password", Username: "target", Password: "password123"}, client)
+	assert.NoError(t, err)
+	
+	lockouts, err := provider.ListLockouts(ctx)
+	require.NoError(t, err)
+	keys := make([]string, 0, len(lockouts))
+	for _, attempts := range lockouts {
+		keys = append(keys, attempts.Key)
+	}
+	assert.ElementsMatch(t, []string{local.EmailAttemptPrefix + "target@example.com", local.EmailIPAttemptPrefix + "192.0.2.1"}, keys)
+	
+	// 4. Unblocking the IP address lets it ask for emails again
+	require.NoError(t, provider.ClearIPLockout(ctx, "192.0.2.1"))
+	assert.NoError(t, provider.ThrottleEmailRequest(ctx, "anyone@example.com", client))
+}
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	store := local.NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	now := time.Now()
+	
+	// 1. Failures are counted within the window
+	attempts, err := store.RecordFailure(ctx, "account:alice", now.Add(-time.Minute), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	
+	attempts, err = store.RecordFailure(ctx, "account:alice", now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts.Failures)
+	assert.False(t, attempts.Locked(now))
+	
+	// 2. Locks show up in the listing
+	require.NoError(t, store.Lock(ctx, "account:alice", now.Add(2*time.Hour)))
+	
+	attempts, err = store.Get(ctx, "account:alice")
+	require.NoError(t, err)
+	assert.True(t, attempts.Locked(now))
+	assert.False(t, attempts.Locked(now.Add(3*time.Hour)))
+	
+	locked, err := store.ListLocked(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, locked, 1)
+	assert.Equal(t, "account:alice", locked[0].Key)
+	
+	// 3. Failures after the record expired start over
+	_, err = store.RecordFailure(ctx, "ip:203.0.113.7", now.Add(-2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	attempts, err = store.RecordFailure(ctx, "ip:203.0.113.7", now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	
+	// 4. Reset forgets the key, cleanup removes expired records
+	require.NoError(t, store.Reset(ctx, "account:alice"))
+	attempts, err = store.Get(ctx, "account:alice")
+	require.NoError(t, err)
+	assert.Equal(t, 0, attempts.Failures)
+	
+	_, err = store.RecordFailure(ctx, "ip:192.0.2.1", now.Add(-2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	count, err := store.CleanupExpiredAttempts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
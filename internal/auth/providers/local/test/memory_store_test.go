@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUserStoreList(t *testing.T) {
+	store := local.NewMemoryUserStore()
+	ctx := context.Background()
+	
+	// Users created in the same second are ordered by ID
+	for i := 1; i <= 5; i++ {
+		domain := "example.com"
+		roles := []string{"user"}
+		if i%2 == 0 {
+			domain = "corp.example.org"
+			roles = append(roles, "admin")
+		}
+		require.NoError(t, store.Create(ctx, &local.StoredUser{
+			ID:       fmt.Sprintf("user-%02d", i),
+			Username: fmt.Sprintf("member%d", i),
+			Email:    fmt.Sprintf("member%d@%s", i, domain),
+			Roles:    roles,
+			Metadata: map[string]interface{}{"level": float64(i)},
+		}))
+	}
+	
+	ids := func(page *local.UserPage) []string {
+		result := make([]string, 0, len(page.Users))
+		for _, user := range page.Users {
+			result = append(result, user.ID)
+		}
+		return result
+	}
+	
+	// 1. Walk all users two at a time
+	page, err := store.List(ctx, local.UserQuery{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-01", "user-02"}, ids(page))
+	require.NotEmpty(t, page.NextCursor)
+	
+	page, err = store.List(ctx, local.UserQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-03", "user-04"}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-05"}, ids(page))
+	assert.Empty(t, page.NextCursor)
+	
+	// 2. Newest first
+	page, err = store.List(ctx, local.UserQuery{Limit: 3, Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-05", "user-04", "user-03"}, ids(page))
+	page, err = store.List(ctx, local.UserQuery{Limit: 3, Descending: true, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-02", "user-01"}, ids(page))
+	
+	// 3. Filters
+	page, err = store.List(ctx, local.UserQuery{Role: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-02", "user-04"}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{EmailDomain: "EXAMPLE.COM"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-01", "user-03", "user-05"}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{MetadataKey: "level", MetadataValue: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-03"}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{Search: "MEMBER4"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-04"}, ids(page))
+	
+	page, err = store.List(ctx, local.UserQuery{Role: "admin", EmailDomain: "example.com"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Users)
+	
+	_, err = store.List(ctx, local.UserQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, local.ErrInvalidCursor)
+}
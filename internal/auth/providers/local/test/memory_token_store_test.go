@@ -48,3 +48,29 @@ func TestMemoryTokenStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, isRevoked)
 }
+
+func TestMemoryTokenStoreUserWatermark(t *testing.T) {
+	tokenStore := local.NewMemoryTokenStore()
+	ctx := context.Background()
+	
+	// No watermark by default
+	revokedBefore, err := tokenStore.RevokedBefore(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.IsZero())
+	
+	now := time.Now()
+	err = tokenStore.RevokeAllForUser(ctx, "user-1", now)
+	assert.NoError(t, err)
+	
+	revokedBefore, err = tokenStore.RevokedBefore(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.Equal(now))
+	
+	// The watermark never moves backwards
+	err = tokenStore.RevokeAllForUser(ctx, "user-1", now.Add(-time.Hour))
+	assert.NoError(t, err)
+	
+	revokedBefore, err = tokenStore.RevokedBefore(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.Equal(now))
+}
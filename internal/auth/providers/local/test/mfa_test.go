@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// totpCode returns the code for the given number of time steps from now
+func totpCode(t *testing.T, secret string, offset int64) string {
+	code, err := totp.GenerateCode(secret, totp.Step(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.MFAIssuer = "Example"
+	provider := local.NewProvider(config, local.NewMemoryUserStore())
+	ctx := context.Background()
+	
+	user := &local.StoredUser{Username: "alice", Email: "alice@example.com", EmailVerified: true}
+	require.NoError(t, provider.CreateUser(ctx, user, "password123"))
+	
+	// 1. Codes are needed before enrolling is confirmed
+	_, err := provider.ConfirmTOTP(ctx, user.ID, "123456")
+	assert.ErrorIs(t, err, local.ErrMFANotEnrolled)
+	
+	enrollment, err := provider.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+	
+	uri, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+	assert.Equal(t, "Example", uri.Query().Get("issuer"))
+	
+	// Enrolling without confirming doesn't change how the user logs in
+	authUser, err := provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "alice", Password: "password123"})
+	require.NoError(t, err)
+	challenge, err := provider.StartMFAChallenge(ctx, authUser)
+	require.NoError(t, err)
+	assert.Nil(t, challenge)
+	
+	// 2. Confirm with a code from the app
+	_, err = provider.ConfirmTOTP(ctx, user.ID, "000000")
+	assert.ErrorIs(t, err, local.ErrInvalidMFACode)
+	
+	codes, err := provider.ConfirmTOTP(ctx, user.ID, totpCode(t, enrollment.Secret, 0))
+	require.NoError(t, err)
+	assert.Len(t, codes, local.RecoveryCodeCount)
+	
+	stored, err := provider.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.TOTPEnabled)
+	assert.NotContains(t, stored.RecoveryCodes, codes[0], "recovery codes should be stored hashed")
+	
+	_, err = provider.EnrollTOTP(ctx, user.ID)
+	assert.ErrorIs(t, err, local.ErrMFAAlreadyEnabled)
+	
+	// 3. Turning it off takes a code too
+	assert.ErrorIs(t, provider.DisableTOTP(ctx, user.ID, "000000"), local.ErrInvalidMFACode)
+	require.NoError(t, provider.DisableTOTP(ctx, user.ID, codes[0]))
+	
+	stored, err = provider.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.TOTPEnabled)
+	assert.Empty(t, stored.TOTPSecret)
+	assert.Empty(t, stored.RecoveryCodes)
+}
+
+func TestMFALogin(t *testing.T) {
+	config := local.DefaultConfig()
+	config.MaxFailedLogins = 3
+	config.FailedLoginDelay = 0
+	provider, user := newLockoutTestProvider(t, config)
+	ctx := context.Background()
+	
+	enrollment, err := provider.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	codes, err := provider.ConfirmTOTP(ctx, user.ID, totpCode(t, enrollment.Secret, 0))
+	require.NoError(t, err)
+	
+	creds := auth.Credentials{Type: "password", Username: "target", Password: "password123"}
+	startLogin := func() string {
+		authUser, err := provider.Authenticate(ctx, creds)
+		require.NoError(t, err)
+		challenge, err := provider.StartMFAChallenge(ctx, authUser)
+		require.NoError(t, err)
+		require.NotNil(t, challenge)
+		assert.Equal(t, []string{local.MFAMethodTOTP, local.MFAMethodRecoveryCode}, challenge.Methods)
+		return challenge.Token
+	}
+	
+	// 1. A TOTP code completes the login; the challenge and the code only work once
+	challenge := startLogin()
+	_, err = provider.CompleteMFAChallenge(ctx, "bogus", totpCode(t, enrollment.Secret, 1))
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	authUser, err := provider.CompleteMFAChallenge(ctx, challenge, totpCode(t, enrollment.Secret, 1))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authUser.ID)
+	
+	_, err = provider.CompleteMFAChallenge(ctx, challenge, totpCode(t, enrollment.Secret, 1))
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// The code from the confirmation step is older than the one just used
+	_, err = provider.CompleteMFAChallenge(ctx, startLogin(), totpCode(t, enrollment.Secret, 0))
+	assert.ErrorIs(t, err, local.ErrInvalidMFACode)
+	
+	// 2. Recovery codes work once, in any format the user types them
+	authUser, err = provider.CompleteMFAChallenge(ctx, startLogin(), " "+codes[1][:5]+codes[1][6:]+" ")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authUser.ID)
+	
+	_, err = provider.CompleteMFAChallenge(ctx, startLogin(), codes[1])
+	assert.ErrorIs(t, err, local.ErrInvalidMFACode)
+	
+	// 3. Regenerating replaces the old codes
+	newCodes, err := provider.RegenerateRecoveryCodes(ctx, user.ID, codes[2])
+	require.NoError(t, err)
+	assert.Len(t, newCodes, local.RecoveryCodeCount)
+	
+	_, err = provider.CompleteMFAChallenge(ctx, startLogin(), codes[3])
+	assert.ErrorIs(t, err, local.ErrInvalidMFACode)
+	
+	// 4. Wrong codes count towards the lockout, and the right password doesn't reset it
+	_, err = provider.CompleteMFAChallenge(ctx, startLogin(), "000000")
+	assert.ErrorIs(t, err, local.ErrInvalidMFACode)
+	
+	attempts, err := provider.AccountLockout(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts.Failures)
+	assert.True(t, attempts.Locked(time.Now()))
+	
+	_, err = provider.Authenticate(ctx, creds)
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangePassword(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.PasswordHistorySize = 3
+	
+	provider := local.NewProviderWithRevocation(config, local.NewMemoryUserStore(), newMockTokenStore())
+	ctx := context.Background()
+	
+	stored := &local.StoredUser{Username: "changer", Email: "changer@example.com"}
+	require.NoError(t, provider.CreateUser(ctx, stored, "password-1"))
+	user := &auth.User{ID: stored.ID, Username: stored.Username}
+	
+	current, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	other, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	validated, err := provider.ValidateToken(ctx, current.AccessToken)
+	require.NoError(t, err)
+	currentSession := validated.Metadata["session_id"].(string)
+	
+	// 1. The current password must be right and the new one must meet the policy
+	err = provider.ChangePassword(ctx, user.ID, "wrong-password", "password-2", currentSession)
+	assert.ErrorIs(t, err, local.ErrIncorrectPassword)
+	
+	err = provider.ChangePassword(ctx, user.ID, "password-1", "short", currentSession)
+	assert.ErrorIs(t, err, local.ErrInvalidPassword)
+	
+	err = provider.ChangePassword(ctx, user.ID, "password-1", "password-1", currentSession)
+	assert.ErrorIs(t, err, local.ErrPasswordReused)
+	
+	// 2. Changing the password logs out every other session
+	require.NoError(t, provider.ChangePassword(ctx, user.ID, "password-1", "password-2", currentSession))
+	
+	_, err = provider.ValidateToken(ctx, current.AccessToken)
+	assert.NoError(t, err)
+	_, err = provider.ValidateToken(ctx, other.AccessToken)
+	assert.Error(t, err)
+	_, err = provider.RefreshTokens(ctx, other.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	
+	sessions, err := provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, currentSession, sessions[0].ID)
+	
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "changer", Password: "password-2"})
+	assert.NoError(t, err)
+	
+	// 3. The last three passwords, including the current one, can't be reused
+	require.NoError(t, provider.ChangePassword(ctx, user.ID, "password-2", "password-3", currentSession))
+	
+	for _, password := range []string{"password-1", "password-2", "password-3"} {
+		err = provider.ChangePassword(ctx, user.ID, "password-3", password, currentSession)
+		assert.ErrorIs(t, err, local.ErrPasswordReused, password)
+	}
+	
+	// Older passwords drop out of the history
+	require.NoError(t, provider.ChangePassword(ctx, user.ID, "password-3", "password-4", currentSession))
+	assert.NoError(t, provider.ChangePassword(ctx, user.ID, "password-4", "password-1", currentSession))
+	
+	// Passwords set by an admin count towards the history too
+	password := "password-5"
+	_, err = provider.UpdateUser(ctx, user.ID, local.UserUpdate{Password: &password})
+	require.NoError(t, err)
+	err = provider.ChangePassword(ctx, user.ID, "password-5", "password-1", currentSession)
+	assert.ErrorIs(t, err, local.ErrPasswordReused)
+}
+
+func TestChangePasswordWithoutHistory(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.PasswordHistorySize = 0
+	
+	provider := local.NewProvider(config, local.NewMemoryUserStore())
+	ctx := context.Background()
+	
+	stored := &local.StoredUser{Username: "changer", Email: "changer@example.com"}
+	require.NoError(t, provider.CreateUser(ctx, stored, "password-1"))
+	
+	pair, err := provider.IssueTokens(ctx, &auth.User{ID: stored.ID}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	// Reusing passwords is allowed, and without a current session every session ends
+	require.NoError(t, provider.ChangePassword(ctx, stored.ID, "password-1", "password-1", ""))
+	
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+}
+
+func TestVerifyPassword(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	config.MaxFailedLogins = 3
+	config.FailedLoginDelay = 0
+	
+	userStore := local.NewMemoryUserStore()
+	provider := local.NewProvider(config, userStore)
+	ctx := context.Background()
+	
+	stored := &local.StoredUser{Username: "checker", Email: "checker@example.com"}
+	require.NoError(t, provider.CreateUser(ctx, stored, "password-1"))
+	
+	assert.NoError(t, provider.VerifyPassword(ctx, stored.ID, "password-1"))
+	assert.ErrorIs(t, provider.VerifyPassword(ctx, stored.ID, "wrong-password"), local.ErrIncorrectPassword)
+	
+	hasPassword, err := provider.HasPassword(ctx, stored.ID)
+	require.NoError(t, err)
+	assert.True(t, hasPassword)
+	
+	// Users without a password, e.g. ones who log in with another provider, can't confirm with one
+	passwordless := &local.StoredUser{Username: "social", Email: "social@example.com"}
+	require.NoError(t, userStore.Create(ctx, passwordless))
+	assert.ErrorIs(t, provider.VerifyPassword(ctx, passwordless.ID, ""), local.ErrIncorrectPassword)
+	
+	hasPassword, err = provider.HasPassword(ctx, passwordless.ID)
+	require.NoError(t, err)
+	assert.False(t, hasPassword)
+	
+	// Wrong passwords count towards the lockout, which then also stops logins
+	for i := 0; i < 2; i++ {
+		assert.ErrorIs(t, provider.VerifyPassword(ctx, stored.ID, "wrong-password"), local.ErrIncorrectPassword)
+	}
+	assert.ErrorIs(t, provider.VerifyPassword(ctx, stored.ID, "password-1"), local.ErrLoginThrottled)
+	
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "checker", Password: "password-1"})
+	assert.ErrorIs(t, err, local.ErrLoginThrottled)
+}
+
+func TestMemoryPasswordHistoryStore(t *testing.T) {
+	store := local.NewMemoryPasswordHistoryStore()
+	ctx := context.Background()
+	
+	for _, hash := range []string{"hash-1", "hash-2", "hash-3"} {
+		require.NoError(t, store.Add(ctx, "user-1", hash, 2))
+	}
+	require.NoError(t, store.Add(ctx, "user-2", "other-hash", 2))
+	
+	// Only the newest entries are kept, newest first
+	hashes, err := store.Recent(ctx, "user-1", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash-3", "hash-2"}, hashes)
+	
+	hashes, err = store.Recent(ctx, "user-1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hash-3"}, hashes)
+	
+	require.NoError(t, store.DeleteByUser(ctx, "user-1"))
+	hashes, err = store.Recent(ctx, "user-1", 10)
+	require.NoError(t, err)
+	assert.Empty(t, hashes)
+	
+	hashes, err = store.Recent(ctx, "user-2", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other-hash"}, hashes)
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var resetLink = regexp.MustCompile(`https?://\S+/auth/password/reset\?token=(\S+)`)
+
+// waitForEmails waits for the emails sent in the background until there are count of them
+func waitForEmails(t *testing.T, mailer *mail.MemoryMailer, count int) {
+	require.Eventually(t, func() bool { return len(mailer.Messages()) >= count }, time.Second, 5*time.Millisecond,
+		"expected %d emails", count)
+}
+
+// assertNoEmails checks that no more emails are sent in the background
+func assertNoEmails(t *testing.T, mailer *mail.MemoryMailer, count int) {
+	assert.Never(t, func() bool { return len(mailer.Messages()) > count }, 100*time.Millisecond, 5*time.Millisecond,
+		"unexpected email")
+}
+
+// lastResetToken extracts the token from the most recent password reset email sent to the address
+func lastResetToken(t *testing.T, mailer *mail.MemoryMailer, to string) string {
+	message, ok := mailer.Last(to)
+	require.True(t, ok, "no email sent to %s", to)
+	
+	match := resetLink.FindStringSubmatch(message.Body)
+	require.NotNil(t, match, "no reset link in %q", message.Body)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	
+	mailer := mail.NewMemoryMailer()
+	provider := local.NewProviderWithRevocation(config, local.NewMemoryUserStore(), newMockTokenStore(),
+		local.WithMailer(mailer))
+	ctx := context.Background()
+	
+	user := &local.StoredUser{Username: "forgetful", Email: "forgetful@example.com"}
+	require.NoError(t, provider.CreateUser(ctx, user, "old-password"))
+	
+	pair, err := provider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	// 1. Unknown addresses get no email and no error
+	sent := len(mailer.Messages())
+	require.NoError(t, provider.RequestPasswordReset(ctx, "nobody@example.com"))
+	assertNoEmails(t, mailer, sent)
+	
+	// 2. Asking again replaces the link
+	require.NoError(t, provider.RequestPasswordReset(ctx, "forgetful@example.com"))
+	waitForEmails(t, mailer, sent+1)
+	oldToken := lastResetToken(t, mailer, "forgetful@example.com")
+	require.NoError(t, provider.RequestPasswordReset(ctx, "forgetful@example.com"))
+	waitForEmails(t, mailer, sent+2)
+	token := lastResetToken(t, mailer, "forgetful@example.com")
+	
+	_, err = provider.ResetPassword(ctx, oldToken, "new-password")
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// Reset tokens can't verify an email address, and the other way round
+	_, err = provider.VerifyEmail(ctx, token)
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// 3. A password rejected by the policy leaves the token usable
+	_, err = provider.ResetPassword(ctx, token, "short")
+	assert.ErrorIs(t, err, local.ErrInvalidPassword)
+	
+	reset, err := provider.ResetPassword(ctx, token, "new-password")
+	require.NoError(t, err)
+	assert.True(t, reset.EmailVerified)
+	
+	_, err = provider.ResetPassword(ctx, token, "another-password")
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// 4. Only the new password works
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "forgetful", Password: "old-password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "forgetful", Password: "new-password"})
+	assert.NoError(t, err)
+	
+	// 5. Existing sessions were ended
+	_, err = provider.ValidateToken(ctx, pair.AccessToken)
+	assert.Error(t, err)
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	
+	sessions, err := provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestPasswordResetAfterEmailChange(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	
+	mailer := mail.NewMemoryMailer()
+	provider := local.NewProvider(config, local.NewMemoryUserStore(), local.WithMailer(mailer))
+	ctx := context.Background()
+	
+	user := &local.StoredUser{Username: "mover", Email: "old@example.com", EmailVerified: true}
+	require.NoError(t, provider.CreateUser(ctx, user, "old-password"))
+	
+	require.NoError(t, provider.RequestPasswordReset(ctx, "old@example.com"))
+	waitForEmails(t, mailer, 1)
+	token := lastResetToken(t, mailer, "old@example.com")
+	
+	// Links sent to an address the account no longer uses stop working
+	email := "new@example.com"
+	_, err := provider.UpdateUser(ctx, user.ID, local.UserUpdate{Email: &email})
+	require.NoError(t, err)
+	
+	_, err = provider.ResetPassword(ctx, token, "new-password")
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+}
+
+func TestPasswordResetDirectoryUser(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	
+	mailer := mail.NewMemoryMailer()
+	userStore := local.NewMemoryUserStore()
+	provider := local.NewProvider(config, userStore, local.WithMailer(mailer))
+	ctx := context.Background()
+	
+	user := &local.StoredUser{Username: "moved", Email: "moved@example.com"}
+	require.NoError(t, provider.CreateUser(ctx, user, "old-password"))
+	require.NoError(t, provider.RequestPasswordReset(ctx, "moved@example.com"))
+	waitForEmails(t, mailer, 1)
+	token := lastResetToken(t, mailer, "moved@example.com")
+	
+	// Once a directory owns the account, links sent before don't work
+	stored, err := userStore.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	stored.Metadata = map[string]interface{}{local.MetadataDirectoryDN: "uid=moved,ou=people,dc=example,dc=com"}
+	require.NoError(t, userStore.Update(ctx, stored))
+	
+	_, err = provider.ResetPassword(ctx, token, "new-password")
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+	
+	// and no new ones are sent
+	sent := len(mailer.Messages())
+	require.NoError(t, provider.RequestPasswordReset(ctx, "moved@example.com"))
+	assertNoEmails(t, mailer, sent)
+}
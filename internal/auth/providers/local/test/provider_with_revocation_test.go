@@ -15,11 +15,13 @@ import (
 // mockTokenStore is a simple implementation for testing
 type mockTokenStore struct {
 	revokedTokens map[string]time.Time
+	revokedUsers  map[string]time.Time
 }
 
 func newMockTokenStore() *mockTokenStore {
 	return &mockTokenStore{
 		revokedTokens: make(map[string]time.Time),
+		revokedUsers:  make(map[string]time.Time),
 	}
 }
 
@@ -37,6 +39,15 @@ func (m *mockTokenStore) CleanupExpiredTokens(ctx context.Context) (int64, error
 	return 0, nil
 }
 
+func (m *mockTokenStore) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	m.revokedUsers[userID] = before
+	return nil
+}
+
+func (m *mockTokenStore) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	return m.revokedUsers[userID], nil
+}
+
 func TestProviderWithRevocation(t *testing.T) {
 	// Create user store with a test user
 	userStore := local.NewMemoryUserStore()
@@ -97,3 +108,58 @@ func TestProviderWithRevocation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, jwt.ErrInvalidToken, err)
 }
+
+func TestRevokeAllTokens(t *testing.T) {
+	userStore := local.NewMemoryUserStore()
+	ctx := context.Background()
+	
+	for _, username := range []string{"alice", "bob"} {
+		err := userStore.Create(ctx, &local.StoredUser{
+			ID:       username + "-id",
+			Username: username,
+			Email:    username + "@example.com",
+			Roles:    []string{"user"},
+		})
+		assert.NoError(t, err)
+	}
+	
+	config := local.Config{
+		JWTSecret:              "test-secret",
+		TokenExpiration:        1 * time.Hour,
+		RefreshTokenExpiration: 24 * time.Hour,
+	}
+	provider := local.NewProviderWithRevocation(config, userStore, local.NewMemoryTokenStore())
+	
+	alice := &auth.User{ID: "alice-id", Username: "alice"}
+	bob := &auth.User{ID: "bob-id", Username: "bob"}
+	
+	// Alice is logged in on two devices, Bob on one
+	laptop, err := provider.IssueTokens(ctx, alice, local.ClientInfo{})
+	assert.NoError(t, err)
+	phone, err := provider.IssueTokens(ctx, alice, local.ClientInfo{})
+	assert.NoError(t, err)
+	bobSession, err := provider.IssueTokens(ctx, bob, local.ClientInfo{})
+	assert.NoError(t, err)
+	
+	// Log Alice out everywhere
+	err = provider.RevokeAllTokens(ctx, alice.ID)
+	assert.NoError(t, err)
+	
+	for _, pair := range []*local.TokenPair{laptop, phone} {
+		_, err = provider.ValidateToken(ctx, pair.AccessToken)
+		assert.Equal(t, jwt.ErrInvalidToken, err)
+		
+		_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+		assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	}
+	
+	// Bob is unaffected
+	_, err = provider.ValidateToken(ctx, bobSession.AccessToken)
+	assert.NoError(t, err)
+	
+	// Tokens issued after the watermark are valid again, even within the same second
+	fresh, err := provider.IssueTokens(ctx, alice, local.ClientInfo{})
+	assert.NoError(t, err)
+	_, err = provider.ValidateToken(ctx, fresh.AccessToken)
+	assert.NoError(t, err)
+}
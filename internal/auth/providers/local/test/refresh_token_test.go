@@ -0,0 +1,222 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRefreshTestProvider(t *testing.T, config local.Config) (*local.ProviderWithRevocation, *local.MemoryUserStore, *auth.User) {
+	userStore := local.NewMemoryUserStore()
+	storedUser := &local.StoredUser{
+		ID:       "refresh-user-id",
+		Username: "refreshuser",
+		Email:    "refresh@example.com",
+		Roles:    []string{"user"},
+	}
+	require.NoError(t, userStore.Create(context.Background(), storedUser))
+	
+	provider := local.NewProviderWithRevocation(config, userStore, newMockTokenStore(),
+		local.WithRefreshTokenStore(local.NewMemoryRefreshTokenStore()))
+	
+	user := &auth.User{
+		ID:       storedUser.ID,
+		Username: storedUser.Username,
+		Email:    storedUser.Email,
+		Roles:    storedUser.Roles,
+	}
+	return provider, userStore, user
+}
+
+func TestRefreshTokens(t *testing.T) {
+	config := local.Config{
+		JWTSecret:              "test-secret",
+		TokenExpiration:        5 * time.Minute,
+		RefreshTokenExpiration: time.Hour,
+	}
+	provider, userStore, user := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	pair, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.NotEmpty(t, pair.RefreshToken)
+	assert.Equal(t, 5*time.Minute, pair.ExpiresIn)
+	
+	// The refresh token is opaque, not a JWT
+	_, err = provider.ValidateToken(ctx, pair.RefreshToken)
+	assert.Error(t, err)
+	
+	// Exchanging the refresh token yields a working access token and a rotated refresh token
+	refreshed, err := provider.RefreshTokens(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, refreshed.RefreshToken)
+	validated, err := provider.ValidateToken(ctx, refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "refreshuser", validated.Username)
+	
+	// The auth.Provider method accepts refresh tokens, but not access tokens
+	_, err = provider.RefreshToken(ctx, refreshed.AccessToken)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	accessToken, err := provider.RefreshToken(ctx, refreshed.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEmpty(t, accessToken)
+	
+	// Revoked refresh tokens can't be exchanged, and their session's access tokens stop working
+	pair, err = provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	require.NoError(t, provider.RevokeRefreshToken(ctx, pair.RefreshToken))
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	_, err = provider.ValidateToken(ctx, pair.AccessToken)
+	assert.Error(t, err)
+	
+	// Refresh tokens stop working once the user is deleted
+	pair, err = provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	require.NoError(t, userStore.Delete(ctx, user.ID))
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+}
+
+// directoryCheck is an account check that refuses users while disabled is set
+type directoryCheck struct {
+	disabled bool
+	calls    int
+}
+
+func (c *directoryCheck) CheckAccount(ctx context.Context, user *local.StoredUser) error {
+	c.calls++
+	if c.disabled {
+		return local.ErrAccountDisabled
+	}
+	return nil
+}
+
+func TestRefreshChecksDirectoryUsers(t *testing.T) {
+	config := local.Config{
+		JWTSecret:              "test-secret",
+		TokenExpiration:        5 * time.Minute,
+		RefreshTokenExpiration: time.Hour,
+	}
+	provider, userStore, user := newRefreshTestProvider(t, config)
+	check := &directoryCheck{}
+	provider.AddAccountCheck(check)
+	ctx := context.Background()
+	
+	// 1. Local users aren't checked
+	pair, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	pair, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, 0, check.calls)
+	
+	// 2. Directory users are checked on every refresh
+	stored, err := userStore.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	stored.Metadata = map[string]interface{}{local.MetadataDirectoryDN: "uid=refreshuser,ou=people,dc=example,dc=com"}
+	require.NoError(t, userStore.Update(ctx, stored))
+	
+	pair, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, 1, check.calls)
+	
+	// 3. Once disabled in the directory, their session ends
+	check.disabled = true
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	_, err = provider.ValidateToken(ctx, pair.AccessToken)
+	assert.Error(t, err)
+	
+	check.disabled = false
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+}
+
+func TestRefreshTokenReuseDetection(t *testing.T) {
+	config := local.Config{
+		JWTSecret:              "test-secret",
+		TokenExpiration:        5 * time.Minute,
+		RefreshTokenExpiration: time.Hour,
+	}
+	provider, _, user := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// An unrelated session of the same user
+	other, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	original, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	// The legitimate client rotates the token
+	rotated, err := provider.RefreshTokens(ctx, original.RefreshToken)
+	require.NoError(t, err)
+	
+	// An attacker replays the stolen original token
+	_, err = provider.RefreshTokens(ctx, original.RefreshToken)
+	assert.Equal(t, local.ErrRefreshTokenReused, err)
+	
+	// The whole family is revoked, including the legitimate client's tokens
+	_, err = provider.RefreshTokens(ctx, rotated.RefreshToken)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	_, err = provider.ValidateToken(ctx, rotated.AccessToken)
+	assert.Error(t, err)
+	
+	// Other sessions are unaffected
+	_, err = provider.ValidateToken(ctx, other.AccessToken)
+	assert.NoError(t, err)
+	_, err = provider.RefreshTokens(ctx, other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestExpiredRefreshToken(t *testing.T) {
+	config := local.Config{
+		JWTSecret:              "test-secret",
+		TokenExpiration:        5 * time.Minute,
+		RefreshTokenExpiration: -time.Minute, // Already expired when issued
+	}
+	provider, _, user := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	pair, err := provider.IssueTokens(ctx, user, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+}
+
+func TestMemoryRefreshTokenStoreCleanup(t *testing.T) {
+	store := local.NewMemoryRefreshTokenStore()
+	ctx := context.Background()
+	now := time.Now()
+	
+	require.NoError(t, store.Create(ctx, &local.RefreshToken{TokenHash: "live", UserID: "u", FamilyID: "f", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, store.Create(ctx, &local.RefreshToken{TokenHash: "dead", UserID: "u", FamilyID: "f", CreatedAt: now, ExpiresAt: now.Add(-time.Hour)}))
+	
+	count, err := store.CleanupExpiredTokens(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	
+	_, err = store.Get(ctx, "dead")
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+	_, err = store.Get(ctx, "live")
+	assert.NoError(t, err)
+	
+	// Rotation marks the old token used and refuses to rotate it twice
+	next := &local.RefreshToken{TokenHash: "next", UserID: "u", FamilyID: "f", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, store.Rotate(ctx, "live", next))
+	used, err := store.Get(ctx, "live")
+	require.NoError(t, err)
+	assert.NotNil(t, used.UsedAt)
+	assert.Equal(t, local.ErrRefreshTokenReused, store.Rotate(ctx, "live", next))
+	
+	require.NoError(t, store.DeleteFamily(ctx, "f"))
+	_, err = store.Get(ctx, "next")
+	assert.Equal(t, local.ErrInvalidRefreshToken, err)
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	provider, _, existing := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Registering logs the user in by default
+	user, pair, err := provider.Register(ctx, "newuser", "new@example.com", "password123", local.ClientInfo{})
+	require.NoError(t, err)
+	require.NotNil(t, pair)
+	assert.Equal(t, []string{"user"}, user.Roles)
+	
+	validated, err := provider.ValidateToken(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "newuser", Password: "password123"})
+	assert.NoError(t, err)
+	
+	// 2. Invalid input and duplicates are rejected
+	for _, username := range []string{"  ", "two words", `quote"d`, "ldap:jdoe", "<b>", strings.Repeat("x", 65)} {
+		_, _, err = provider.Register(ctx, username, "blank@example.com", "password123", local.ClientInfo{})
+		assert.ErrorIs(t, err, local.ErrInvalidUsername, username)
+	}
+	_, _, err = provider.Register(ctx, "bademail", "not-an-email", "password123", local.ClientInfo{})
+	assert.ErrorIs(t, err, local.ErrInvalidEmail)
+	_, _, err = provider.Register(ctx, "weak", "weak@example.com", "short", local.ClientInfo{})
+	assert.ErrorIs(t, err, local.ErrInvalidPassword)
+	_, _, err = provider.Register(ctx, "long", "long@example.com", strings.Repeat("x", 73), local.ClientInfo{})
+	assert.ErrorIs(t, err, local.ErrInvalidPassword)
+	_, _, err = provider.Register(ctx, existing.Username, "other@example.com", "password123", local.ClientInfo{})
+	assert.ErrorIs(t, err, local.ErrUsernameTaken)
+	_, _, err = provider.Register(ctx, "other", existing.Email, "password123", local.ClientInfo{})
+	assert.ErrorIs(t, err, local.ErrEmailTaken)
+	
+	// 3. Without auto login no tokens are issued
+	config.LoginOnRegister = false
+	provider, _, _ = newRefreshTestProvider(t, config)
+	_, pair, err = provider.Register(ctx, "quiet", "quiet@example.com", "password123", local.ClientInfo{})
+	require.NoError(t, err)
+	assert.Nil(t, pair)
+	
+	// 4. Registration can be turned off
+	config.DisableRegistration = true
+	provider, _, _ = newRefreshTestProvider(t, config)
+	_, _, err = provider.Register(ctx, "closed", "closed@example.com", "password123", local.ClientInfo{})
+	assert.ErrorIs(t, err, local.ErrRegistrationDisabled)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := local.PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+	
+	assert.NoError(t, policy.Validate("Correct-Horse-42"))
+	
+	err := policy.Validate("short")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least 10 characters")
+	assert.Contains(t, err.Error(), "uppercase")
+	assert.Contains(t, err.Error(), "digit")
+	assert.Contains(t, err.Error(), "symbol")
+	assert.NotContains(t, err.Error(), "lowercase")
+	
+	// Length is counted in characters, not bytes
+	assert.Error(t, local.DefaultPasswordPolicy().Validate("ééé"))
+	assert.NoError(t, local.DefaultPasswordPolicy().Validate("éééééééé"))
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	config := local.Config{
+		JWTSecret:              "test-secret",
+		TokenExpiration:        5 * time.Minute,
+		RefreshTokenExpiration: time.Hour,
+	}
+	provider, _, user := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Each login starts a session recording the client
+	laptop, err := provider.IssueTokens(ctx, user, local.ClientInfo{IPAddress: "10.0.0.1", UserAgent: "Firefox"})
+	require.NoError(t, err)
+	phone, err := provider.IssueTokens(ctx, user, local.ClientInfo{IPAddress: "10.0.0.2", UserAgent: "Safari"})
+	require.NoError(t, err)
+	
+	sessions, err := provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	
+	// Access tokens carry the session they belong to
+	validated, err := provider.ValidateToken(ctx, phone.AccessToken)
+	require.NoError(t, err)
+	phoneSession, ok := validated.Metadata["session_id"].(string)
+	require.True(t, ok)
+	
+	byID := map[string]*local.Session{}
+	for _, session := range sessions {
+		byID[session.ID] = session
+	}
+	require.Contains(t, byID, phoneSession)
+	assert.Equal(t, "10.0.0.2", byID[phoneSession].IPAddress)
+	assert.Equal(t, "Safari", byID[phoneSession].UserAgent)
+	
+	// 2. Refreshing keeps the session and bumps its last seen time
+	time.Sleep(10 * time.Millisecond)
+	refreshed, err := provider.RefreshTokens(ctx, phone.RefreshToken)
+	require.NoError(t, err)
+	validated, err = provider.ValidateToken(ctx, refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, phoneSession, validated.Metadata["session_id"])
+	
+	sessions, err = provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, phoneSession, sessions[0].ID)
+	assert.True(t, sessions[0].LastSeenAt.After(sessions[0].CreatedAt))
+	
+	// 3. Other users can't revoke the session
+	err = provider.RevokeSession(ctx, "someone-else", phoneSession)
+	assert.ErrorIs(t, err, local.ErrSessionNotFound)
+	
+	// 4. Revoking the session kills its access and refresh tokens, but not the other session's
+	require.NoError(t, provider.RevokeSession(ctx, user.ID, phoneSession))
+	
+	_, err = provider.ValidateToken(ctx, refreshed.AccessToken)
+	assert.Error(t, err)
+	_, err = provider.RefreshTokens(ctx, refreshed.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	_, err = provider.ValidateToken(ctx, laptop.AccessToken)
+	assert.NoError(t, err)
+	
+	sessions, err = provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "10.0.0.1", sessions[0].IPAddress)
+	
+	err = provider.RevokeSession(ctx, user.ID, phoneSession)
+	assert.ErrorIs(t, err, local.ErrSessionNotFound)
+	
+	// 5. Logging out with an access token ends its session
+	require.NoError(t, provider.RevokeToken(ctx, laptop.AccessToken))
+	_, err = provider.RefreshTokens(ctx, laptop.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	
+	sessions, err = provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserManagement(t *testing.T) {
+	config := local.DefaultConfig()
+	config.JWTSecret = "test-secret"
+	provider, _, existing := newRefreshTestProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Create a user; the password is hashed and a default role assigned
+	user := &local.StoredUser{
+		Username: "newuser",
+		Email:    "new@example.com",
+		Metadata: map[string]interface{}{"team": "billing"},
+	}
+	require.NoError(t, provider.CreateUser(ctx, user, "password123"))
+	assert.NotEmpty(t, user.ID)
+	assert.NotEqual(t, "password123", user.PasswordHash)
+	assert.Equal(t, []string{"user"}, user.Roles)
+	
+	_, err := provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "newuser", Password: "password123"})
+	assert.NoError(t, err)
+	
+	// Weak passwords and duplicate usernames are rejected
+	err = provider.CreateUser(ctx, &local.StoredUser{Username: "weak", Email: "weak@example.com"}, "short")
+	assert.ErrorIs(t, err, local.ErrInvalidPassword)
+	err = provider.CreateUser(ctx, &local.StoredUser{Username: "newuser", Email: "other@example.com"}, "password123")
+	assert.ErrorIs(t, err, local.ErrUsernameTaken)
+	
+	// 2. Update roles, password and metadata; omitted fields are kept
+	pair, err := provider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	roles := []string{"admin", "user"}
+	password := "new-password"
+	updated, err := provider.UpdateUser(ctx, user.ID, local.UserUpdate{
+		Roles:    &roles,
+		Password: &password,
+		Metadata: map[string]interface{}{"team": nil, "level": "senior"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "newuser", updated.Username)
+	assert.Equal(t, roles, updated.Roles)
+	assert.Equal(t, map[string]interface{}{"level": "senior"}, updated.Metadata)
+	
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "newuser", Password: "password123"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "newuser", Password: "new-password"})
+	assert.NoError(t, err)
+	
+	// Setting the password logged the user out everywhere
+	_, err = provider.ValidateToken(ctx, pair.AccessToken)
+	assert.Error(t, err)
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	
+	// Usernames and email addresses are checked like at registration
+	email := existing.Email
+	_, err = provider.UpdateUser(ctx, user.ID, local.UserUpdate{Email: &email})
+	assert.ErrorIs(t, err, local.ErrEmailTaken)
+	
+	for _, invalid := range []string{"", "not-an-address", "New User <new@example.com>"} {
+		_, err = provider.UpdateUser(ctx, user.ID, local.UserUpdate{Email: &invalid})
+		assert.ErrorIs(t, err, local.ErrInvalidEmail, invalid)
+	}
+	blank := "  "
+	_, err = provider.UpdateUser(ctx, user.ID, local.UserUpdate{Username: &blank})
+	assert.ErrorIs(t, err, local.ErrInvalidUsername)
+	
+	username, email := " renamed ", " renamed@example.com "
+	updated, err = provider.UpdateUser(ctx, user.ID, local.UserUpdate{Username: &username, Email: &email})
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", updated.Username)
+	assert.Equal(t, "renamed@example.com", updated.Email)
+	
+	_, err = provider.UpdateUser(ctx, "missing", local.UserUpdate{})
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+	
+	// 3. Deleting a user ends their sessions
+	pair, err = provider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	require.NoError(t, provider.DeleteUser(ctx, user.ID))
+	
+	_, err = provider.GetUser(ctx, user.ID)
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+	_, err = provider.RefreshTokens(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, local.ErrInvalidRefreshToken)
+	sessions, err := provider.ListSessions(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	
+	assert.ErrorIs(t, provider.DeleteUser(ctx, user.ID), auth.ErrUserNotFound)
+}
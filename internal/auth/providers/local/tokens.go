@@ -0,0 +1,207 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/google/uuid"
+)
+
+// TokenPair is a short-lived access token together with the refresh token
+// that can be exchanged for new access tokens
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration // Lifetime of the access token
+}
+
+// starts a new session for the user: issues an access token and a refresh
+// token starting a new token family, and records where the login came from
+func (p *Provider) IssueTokens(ctx context.Context, user *auth.User, client ClientInfo) (*TokenPair, error) {
+	sessionID := uuid.New().String()
+	
+	refreshToken, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	expiresAt := now.Add(p.config.RefreshTokenExpiration)
+	err = p.refreshStore.Create(ctx, &RefreshToken{
+		TokenHash: tokenHash,
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	accessToken, tokenID, err := p.generateAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	err = p.sessionStore.Create(ctx, &Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		TokenID:    tokenID,
+		IPAddress:  client.IPAddress,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    p.config.TokenExpiration,
+	}, nil
+}
+
+// adds a check that directory users must pass to refresh their tokens. Call it while setting up
+// the provider, before it serves logins.
+func (p *Provider) AddAccountCheck(check AccountCheck) {
+	p.accountChecks = append(p.accountChecks, check)
+}
+
+// exchanges a refresh token for a new token pair, rotating the refresh token.
+// Presenting a refresh token that was already rotated revokes its whole family
+// and returns ErrRefreshTokenReused.
+// The user is reloaded so role changes take effect on the next refresh, and directory users
+// are checked with the account checks.
+func (p *Provider) RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, err := p.refreshStore.Get(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	
+	if stored.UsedAt != nil {
+		return nil, p.revokeReusedFamily(ctx, stored)
+	}
+	
+	if time.Now().After(stored.ExpiresAt) {
+		_ = p.refreshStore.Delete(ctx, stored.TokenHash)
+		return nil, ErrInvalidRefreshToken
+	}
+	
+	user, err := p.userStore.GetByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	
+	// Directory users disabled since they logged in lose the session
+	if err := p.checkAccount(ctx, user); err != nil {
+		if errors.Is(err, ErrAccountDisabled) {
+			if err := p.endSession(ctx, stored.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRefreshToken, err)
+		}
+		return nil, err
+	}
+	
+	nextToken, nextHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	expiresAt := now.Add(p.config.RefreshTokenExpiration)
+	err = p.refreshStore.Rotate(ctx, stored.TokenHash, &RefreshToken{
+		TokenHash: nextHash,
+		UserID:    stored.UserID,
+		FamilyID:  stored.FamilyID,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		// Lost a race against another refresh with the same token
+		if errors.Is(err, ErrRefreshTokenReused) {
+			return nil, p.revokeReusedFamily(ctx, stored)
+		}
+		return nil, err
+	}
+	
+	accessToken, tokenID, err := p.generateAccessToken(toAuthUser(user), stored.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Sessions started before session tracking existed have no record to update
+	err = p.sessionStore.Touch(ctx, stored.FamilyID, tokenID, now, expiresAt)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, err
+	}
+	
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: nextToken,
+		ExpiresIn:    p.config.TokenExpiration,
+	}, nil
+}
+
+// invalidates a refresh token and ends its session
+func (p *Provider) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	stored, err := p.refreshStore.Get(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			return nil
+		}
+		return err
+	}
+	
+	return p.endSession(ctx, stored.FamilyID)
+}
+
+// returns the user's active sessions, most recently seen first
+func (p *Provider) ListSessions(ctx context.Context, userID string) ([]*Session, error) {
+	return p.sessionStore.ListByUser(ctx, userID)
+}
+
+// runs the account checks for a directory user
+func (p *Provider) checkAccount(ctx context.Context, user *StoredUser) error {
+	if !DirectoryManaged(user) {
+		return nil
+	}
+	for _, check := range p.accountChecks {
+		if err := check.CheckAccount(ctx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokes the family of a refresh token that was presented after being rotated
+func (p *Provider) revokeReusedFamily(ctx context.Context, stored *RefreshToken) error {
+	if err := p.endSession(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// endSession deletes a session's record and every refresh token in its family
+func (p *Provider) endSession(ctx context.Context, sessionID string) error {
+	if err := p.refreshStore.DeleteFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	return p.sessionStore.Delete(ctx, sessionID)
+}
+
+// converts a stored user to the provider-neutral representation
+func toAuthUser(user *StoredUser) *auth.User {
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Roles:    user.Roles,
+	}
+}
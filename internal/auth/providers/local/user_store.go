@@ -2,17 +2,63 @@ package local
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUsernameTaken   = errors.New("username already exists")
+	ErrEmailTaken      = errors.New("email already exists")
+	ErrInvalidPassword = errors.New("password does not meet requirements")
+	ErrInvalidCursor   = errors.New("invalid pagination cursor")
+	
+	ErrDirectoryManaged = errors.New("the account belongs to a directory and only logs in through it")
+	ErrAccountDisabled  = errors.New("the account has been disabled")
+)
+
+const (
+	DefaultUserPageSize = 50
+	MaxUserPageSize     = 100
 )
 
 type StoredUser struct {
-	ID           string
-	Username     string
-	Email        string
-	PasswordHash string
-	Roles        []string
-	Metadata     map[string]interface{}
-	CreatedAt    int64
-	UpdatedAt    int64
+	ID            string
+	Username      string
+	Email         string
+	EmailVerified bool
+	PasswordHash  string
+	Roles         []string
+	Metadata      map[string]interface{}
+	CreatedAt     int64
+	UpdatedAt     int64
+	
+	// Two-factor authentication
+	TOTPSecret    string   // Base32 secret; set at enrollment, before TOTPEnabled
+	TOTPEnabled   bool     // Logins need a code once enrollment has been confirmed
+	TOTPLastStep  int64    // Time step of the last accepted code, so codes can't be replayed
+	RecoveryCodes []string // Hashes of the unused recovery codes
+}
+
+// MetadataDirectoryDN is the metadata key under which directory providers, like the LDAP provider,
+// record the entry a shadow user stands for
+const MetadataDirectoryDN = "ldap_dn"
+
+// reports whether a directory owns the user's account. Such users only log in through the
+// directory, so disabling them there locks them out; they can't get a local password or log
+// in with an emailed link.
+func DirectoryManaged(user *StoredUser) bool {
+	dn, _ := user.Metadata[MetadataDirectoryDN].(string)
+	return dn != ""
+}
+
+// AccountCheck asks the system that owns directory users' accounts whether they may still log in.
+// Refreshing their tokens runs the checks, so disabling a user in the directory ends their sessions.
+type AccountCheck interface {
+	// returns ErrAccountDisabled if the user may no longer log in, and nil if the check doesn't apply to them
+	CheckAccount(ctx context.Context, user *StoredUser) error
 }
 
 type UserStore interface {
@@ -27,4 +73,64 @@ type UserStore interface {
 	Update(ctx context.Context, user *StoredUser) error
 	
 	Delete(ctx context.Context, id string) error
+	
+	// List returns one page of users matching the query, ordered by CreatedAt
+	List(ctx context.Context, query UserQuery) (*UserPage, error)
+}
+
+// UserQuery filters and paginates a user listing. Empty filters match every user.
+type UserQuery struct {
+	Search        string // Case-insensitive substring of the username or email
+	Role          string // Users that have this role
+	EmailDomain   string // Users whose email is at this domain, e.g. "example.com"
+	MetadataKey   string // Users that have this metadata key...
+	MetadataValue string // ...set to this value, if not empty
+	
+	Descending bool   // Newest users first
+	Cursor     string // NextCursor of the previous page; empty for the first page
+	Limit      int    // Page size, DefaultUserPageSize if zero and at most MaxUserPageSize
+}
+
+// UserPage is one page of a user listing
+type UserPage struct {
+	Users      []*StoredUser
+	NextCursor string // Empty on the last page
+}
+
+// returns the page size to use, applying the default and maximum
+func (q UserQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultUserPageSize
+	}
+	if q.Limit > MaxUserPageSize {
+		return MaxUserPageSize
+	}
+	return q.Limit
+}
+
+// EncodeUserCursor builds an opaque cursor pointing after the given user.
+// Users are ordered by creation time, then ID to break ties.
+func EncodeUserCursor(createdAt time.Time, id string) string {
+	raw := strconv.FormatInt(createdAt.UnixMicro(), 10) + ":" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeUserCursor parses a cursor built by EncodeUserCursor
+func DecodeUserCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	
+	micros, id, found := strings.Cut(string(raw), ":")
+	if !found || id == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	
+	usec, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	
+	return time.UnixMicro(usec), id, nil
 }
\ No newline at end of file
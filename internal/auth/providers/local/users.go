@@ -0,0 +1,259 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrRegistrationDisabled = errors.New("registration is disabled")
+	ErrInvalidUsername      = errors.New("username must be 1 to 64 letters, digits, dots, underscores, hyphens or @ signs")
+	ErrInvalidEmail         = errors.New("email address is invalid")
+)
+
+// UserUpdate describes changes to a user. Nil fields are left unchanged.
+type UserUpdate struct {
+	Username *string
+	Email    *string
+	Password *string
+	Roles    *[]string
+	
+	// Marks the email address verified or not. Changing Email without setting
+	// this resets the flag and sends a new verification link.
+	EmailVerified *bool
+	
+	// Metadata keys to set; a nil value removes the key
+	Metadata map[string]interface{}
+}
+
+// creates a user with the given password, hashing it with bcrypt.
+// Users created without roles get the "user" role. Unless the user is
+// created already verified, a verification link is emailed to them.
+func (p *Provider) CreateUser(ctx context.Context, user *StoredUser, password string) error {
+	hash, err := p.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	
+	user.PasswordHash = hash
+	if len(user.Roles) == 0 {
+		user.Roles = []string{"user"}
+	}
+	if user.Metadata == nil {
+		user.Metadata = map[string]interface{}{}
+	}
+	
+	if err := p.userStore.Create(ctx, user); err != nil {
+		return err
+	}
+	
+	if !user.EmailVerified {
+		p.trySendVerificationEmail(ctx, user)
+	}
+	return nil
+}
+
+// signs up a new user with the default "user" role.
+// When Config.LoginOnRegister is set a session is started and its tokens
+// returned; otherwise, or if the email address must be verified first, the returned TokenPair is nil.
+func (p *Provider) Register(ctx context.Context, username string, email string, password string, client ClientInfo) (*auth.User, *TokenPair, error) {
+	if p.config.DisableRegistration {
+		return nil, nil, ErrRegistrationDisabled
+	}
+	
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	email, err = normalizeEmail(email)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	stored := &StoredUser{
+		Username: username,
+		Email:    email,
+		Metadata: map[string]interface{}{"created_by": "registration"},
+	}
+	if err := p.CreateUser(ctx, stored, password); err != nil {
+		return nil, nil, err
+	}
+	
+	// Users who must verify their email first can't be logged in yet
+	user := toAuthUser(stored)
+	if !p.config.LoginOnRegister || p.config.RequireVerifiedEmail {
+		return user, nil, nil
+	}
+	
+	pair, err := p.IssueTokens(ctx, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	return user, pair, nil
+}
+
+// returns a user by ID
+func (p *Provider) GetUser(ctx context.Context, id string) (*StoredUser, error) {
+	return p.userStore.GetByID(ctx, id)
+}
+
+// returns one page of users matching the query
+func (p *Provider) ListUsers(ctx context.Context, query UserQuery) (*UserPage, error) {
+	return p.userStore.List(ctx, query)
+}
+
+// applies the update to a user and returns the updated user. Usernames and email
+// addresses are checked like at registration. Setting a password ends the user's sessions.
+func (p *Provider) UpdateUser(ctx context.Context, id string, update UserUpdate) (*StoredUser, error) {
+	user, err := p.userStore.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	
+	if update.Username != nil {
+		user.Username, err = normalizeUsername(*update.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+	email := user.Email
+	if update.Email != nil {
+		email, err = normalizeEmail(*update.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+	emailChanged := email != user.Email
+	if emailChanged {
+		user.Email = email
+		user.EmailVerified = false
+	}
+	if update.EmailVerified != nil {
+		user.EmailVerified = *update.EmailVerified
+	}
+	previousHash := ""
+	if update.Password != nil {
+		previousHash = user.PasswordHash
+		user.PasswordHash, err = p.hashPassword(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if update.Roles != nil {
+		user.Roles = *update.Roles
+	}
+	for key, value := range update.Metadata {
+		if value == nil {
+			delete(user.Metadata, key)
+		} else {
+			user.Metadata[key] = value
+		}
+	}
+	
+	if err := p.userStore.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	
+	if err := p.recordPasswordHistory(ctx, user.ID, previousHash); err != nil {
+		return nil, err
+	}
+	
+	// Whoever knew the old password may still be logged in
+	if update.Password != nil {
+		if err := p.refreshStore.DeleteByUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := p.sessionStore.DeleteByUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	
+	if emailChanged && !user.EmailVerified {
+		p.trySendVerificationEmail(ctx, user)
+	}
+	return user, nil
+}
+
+// deletes a user together with their refresh tokens, sessions, password history and linked identities
+func (p *Provider) DeleteUser(ctx context.Context, id string) error {
+	if err := p.userStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	
+	if err := p.refreshStore.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+	
+	if err := p.sessionStore.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+	
+	if err := p.historyStore.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+	
+	return p.identityStore.DeleteByUser(ctx, id)
+}
+
+// sends a verification email without failing the calling operation;
+// the user can ask for a new link if this one never arrives
+func (p *Provider) trySendVerificationEmail(ctx context.Context, user *StoredUser) {
+	if err := p.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+}
+
+// checks the password against the configured policy and hashes it
+func (p *Provider) hashPassword(password string) (string, error) {
+	if p.config.PasswordValidator != nil {
+		if err := p.config.PasswordValidator(password); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidPassword, err)
+		}
+	}
+	
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", fmt.Errorf("%w: password must be at most 72 bytes", ErrInvalidPassword)
+	}
+	if err != nil {
+		return "", err
+	}
+	
+	return string(hash), nil
+}
+
+// Characters allowed in usernames. Colons are left out so usernames can't
+// collide with the lockout keys of other providers' users.
+var (
+	validUsername   = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+	invalidUsername = regexp.MustCompile(`[^A-Za-z0-9._@-]`)
+)
+
+// trims a username and checks that it can be used
+func normalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if !validUsername.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+	return username, nil
+}
+
+// trims an email address and checks that it is a bare address, without a display name
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
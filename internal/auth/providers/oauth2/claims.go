@@ -0,0 +1,71 @@
+package oauth2
+
+import (
+	"strings"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+)
+
+// maps the identity provider's claims to a user
+func (p *Provider) userFromClaims(claims map[string]interface{}) *auth.User {
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	
+	username, _ := claims[p.config.UsernameClaim].(string)
+	if username == "" {
+		username = email
+	}
+	if username == "" {
+		username = subject
+	}
+	
+	roles := stringList(claims[p.config.RolesClaim])
+	if p.config.RolesClaim == "" || len(roles) == 0 {
+		roles = append([]string(nil), p.config.DefaultRoles...)
+	}
+	
+	return &auth.User{
+		ID:       subject,
+		Username: username,
+		Email:    email,
+		Roles:    roles,
+		Metadata: map[string]interface{}{
+			"provider":       p.config.Name,
+			"issuer":         p.config.Issuer,
+			"subject":        subject,
+			"email_verified": emailVerified(claims),
+			"claims":         claims,
+		},
+	}
+}
+
+// reads email_verified, which some identity providers send as a string
+func emailVerified(claims map[string]interface{}) bool {
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		return verified
+	case string:
+		return strings.EqualFold(verified, "true")
+	}
+	return false
+}
+
+// reads a claim holding a string or a list of strings
+func stringList(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return strings.Fields(strings.ReplaceAll(value, ",", " "))
+	case []interface{}:
+		var list []string
+		for _, item := range value {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
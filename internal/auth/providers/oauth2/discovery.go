@@ -0,0 +1,253 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+)
+
+var ErrDiscovery = errors.New("identity provider discovery failed")
+
+// Minimum time between fetches of the identity provider's keys, so tokens with
+// unknown key IDs can't make us hammer its JWKS endpoint
+const keyRefreshInterval = time.Minute
+
+// Metadata is the part of an identity provider's OpenID configuration the provider uses
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// tokenResponse is the token endpoint's answer to the authorization code
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// fetches the identity provider's OpenID configuration, once
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	
+	var metadata Metadata
+	configURL := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, configURL, "", &metadata); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	
+	// The issuer has to match exactly, or ID tokens from it won't validate
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q doesn't match %q", ErrDiscovery, metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: configuration is missing endpoints", ErrDiscovery)
+	}
+	
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// returns a Util that validates ID tokens with the identity provider's keys.
+// With refresh, the keys are fetched again unless that was done very recently.
+func (p *Provider) signingKeys(ctx context.Context, metadata *Metadata, refresh bool) (*jwt.Util, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if p.keys != nil && (!refresh || time.Since(p.keysLoadedAt) < keyRefreshInterval) {
+		return p.keys, nil
+	}
+	
+	var set jwt.JWKSet
+	if err := p.getJSON(ctx, metadata.JWKSURI, "", &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	
+	keys, err := jwt.NewUtilWithJWKS(set,
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithLeeway(p.config.ClockSkew))
+	if err != nil {
+		return nil, fmt.Errorf("%w: no usable signing keys", ErrDiscovery)
+	}
+	
+	p.keys = keys
+	p.keysLoadedAt = time.Now()
+	return keys, nil
+}
+
+// redeems an authorization code, proving with the PKCE verifier that we started the login.
+// An identity provider refusing the code is reported as auth.ErrInvalidCredentials.
+func (p *Provider) exchangeCode(ctx context.Context, code string, verifier string) (*tokenResponse, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	// Confidential clients authenticate with HTTP Basic, public clients just identify themselves
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, "POST", metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		var oauthError struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &oauthError)
+		return nil, fmt.Errorf("%w: token endpoint returned %q %s", auth.ErrInvalidCredentials, oauthError.Error, oauthError.Description)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+	
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("decoding token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no ID token; is the openid scope requested?", auth.ErrInvalidCredentials)
+	}
+	
+	return &tokens, nil
+}
+
+// checks the ID token's signature, issuer, audience, lifetime and nonce, and returns its claims.
+// Invalid tokens are reported as auth.ErrInvalidCredentials.
+func (p *Provider) verifyIDToken(ctx context.Context, idToken string, nonce string) (map[string]interface{}, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	keys, err := p.signingKeys(ctx, metadata, false)
+	if err != nil {
+		return nil, err
+	}
+	
+	claims, err := keys.ValidateToken(idToken)
+	if errors.Is(err, jwt.ErrInvalidToken) {
+		// The identity provider may have rotated its keys
+		if keys, err = p.signingKeys(ctx, metadata, true); err != nil {
+			return nil, err
+		}
+		claims, err = keys.ValidateToken(idToken)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: ID token: %v", auth.ErrInvalidCredentials, err)
+	}
+	
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce mismatch", auth.ErrInvalidCredentials)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", auth.ErrInvalidCredentials)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: ID token has no expiry", auth.ErrInvalidCredentials)
+	}
+	
+	// A token issued to several clients must name us as the party it was issued to
+	if audiences, ok := claims["aud"].([]interface{}); ok && len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, fmt.Errorf("%w: ID token was issued to another client", auth.ErrInvalidCredentials)
+		}
+	}
+	
+	return claims, nil
+}
+
+// adds the claims from the userinfo endpoint, if the identity provider has one.
+// Claims in the ID token take precedence.
+func (p *Provider) mergeUserInfo(ctx context.Context, accessToken string, claims map[string]interface{}) error {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if metadata.UserInfoEndpoint == "" || accessToken == "" {
+		return nil
+	}
+	
+	var userInfo map[string]interface{}
+	if err := p.getJSON(ctx, metadata.UserInfoEndpoint, accessToken, &userInfo); err != nil {
+		return fmt.Errorf("fetching userinfo: %w", err)
+	}
+	
+	// Userinfo responses for another user must be ignored (OpenID Connect Core, section 5.3.2)
+	if userInfo["sub"] != claims["sub"] {
+		return fmt.Errorf("%w: userinfo subject doesn't match the ID token", auth.ErrInvalidCredentials)
+	}
+	
+	for key, value := range userInfo {
+		if _, exists := claims[key]; !exists {
+			claims[key] = value
+		}
+	}
+	return nil
+}
+
+// fetches and decodes a JSON document, with an optional bearer token
+func (p *Provider) getJSON(ctx context.Context, target string, bearer string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,64 @@
+package oauth2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStateStore implements StateStore with in-memory storage
+type MemoryStateStore struct {
+	states map[string]*LoginState // Indexed by state hash
+	mu     sync.Mutex
+}
+
+// NewMemoryStateStore creates a new in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states: make(map[string]*LoginState),
+	}
+}
+
+// Create stores a new login state
+func (s *MemoryStateStore) Create(ctx context.Context, state *LoginState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	stateCopy := *state
+	s.states[state.StateHash] = &stateCopy
+	return nil
+}
+
+// Consume deletes and returns a login state
+func (s *MemoryStateStore) Consume(ctx context.Context, stateHash string, provider string) (*LoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	state, exists := s.states[stateHash]
+	if !exists || state.Provider != provider {
+		return nil, ErrInvalidState
+	}
+	
+	delete(s.states, stateHash)
+	if time.Now().After(state.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+	
+	return state, nil
+}
+
+// CleanupExpiredStates removes expired login states
+func (s *MemoryStateStore) CleanupExpiredStates(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	for hash, state := range s.states {
+		if now.After(state.ExpiresAt) {
+			delete(s.states, hash)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,273 @@
+// Package oidctest runs a minimal OpenID Connect identity provider for tests.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+)
+
+// Server is an identity provider that logs in whichever user was set with SetUser,
+// without asking. It implements discovery, the authorization code flow with PKCE,
+// ID tokens signed with a published ES256 key, and userinfo.
+type Server struct {
+	URL          string // Issuer URL
+	ClientID     string
+	ClientSecret string // Required from the client at the token endpoint unless empty
+	
+	server    *httptest.Server
+	signer    *jwt.Util
+	mu        sync.Mutex
+	claims    map[string]interface{}
+	overrides map[string]interface{}
+	codes     map[string]*authorization
+	tokens    map[string]map[string]interface{} // userinfo claims by access token
+}
+
+// authorization is an issued authorization code
+type authorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]interface{}
+}
+
+// starts an identity provider with a client registered under clientID and clientSecret
+func NewServer(clientID string, clientSecret string) *Server {
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]*authorization),
+		tokens:       make(map[string]map[string]interface{}),
+	}
+	
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /token", s.handleToken)
+	mux.HandleFunc("GET /userinfo", s.handleUserInfo)
+	mux.HandleFunc("GET /jwks", s.handleJWKS)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	key, err := jwt.NewSigningKey(private)
+	if err != nil {
+		panic(err)
+	}
+	s.signer = jwt.NewUtilWithKey(key, 5*time.Minute, jwt.WithIssuer(s.URL), jwt.WithAudience(clientID))
+	
+	return s
+}
+
+// shuts the identity provider down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// sets the claims of the user who logs in next. "sub" is required.
+// Claims other than sub are only sent from the userinfo endpoint, as many identity providers do.
+func (s *Server) SetUser(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	s.claims = claims
+}
+
+// sets claims that are added to, or replace, the claims of the ID tokens issued next,
+// e.g. a wrong "nonce" or "aud" to test that bad tokens are refused
+func (s *Server) SetIDTokenOverrides(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	s.overrides = claims
+}
+
+// follows an authorization URL like a browser whose user logs in right away, and
+// returns the code and state the identity provider sends back to the redirect URI
+func (s *Server) Authorize(authURL string) (code string, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusFound {
+		return "", "", errors.New("authorization failed: " + resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	if oauthError := location.Query().Get("error"); oauthError != "" {
+		return "", "", errors.New("authorization failed: " + oauthError)
+	}
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"userinfo_endpoint":                     s.URL + "/userinfo",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{s.signer.Algorithm()},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.signer.JWKS())
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != s.ClientID || query.Get("redirect_uri") == "" {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+	
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := url.Values{"state": {query.Get("state")}}
+	
+	s.mu.Lock()
+	claims := s.claims
+	s.mu.Unlock()
+	
+	switch {
+	case query.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+	case claims == nil:
+		params.Set("error", "access_denied")
+	default:
+		code := randomString()
+		s.mu.Lock()
+		s.codes[code] = &authorization{
+			redirectURI:   query.Get("redirect_uri"),
+			nonce:         query.Get("nonce"),
+			codeChallenge: query.Get("code_challenge"),
+			claims:        claims,
+		}
+		s.mu.Unlock()
+		params.Set("code", code)
+	}
+	
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.FormValue("client_id")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.FormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	
+	// Codes work once
+	s.mu.Lock()
+	code := s.codes[r.FormValue("code")]
+	delete(s.codes, r.FormValue("code"))
+	overrides := s.overrides
+	s.mu.Unlock()
+	
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if code == nil || code.redirectURI != r.FormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != code.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	
+	idClaims := map[string]interface{}{"sub": code.claims["sub"]}
+	if code.nonce != "" {
+		idClaims["nonce"] = code.nonce
+	}
+	for key, value := range overrides {
+		idClaims[key] = value
+	}
+	idToken, err := s.signer.GenerateToken(idClaims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	
+	accessToken := randomString()
+	s.mu.Lock()
+	s.tokens[accessToken] = code.claims
+	s.mu.Unlock()
+	
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("Authorization")
+	if len(token) > 7 {
+		token = token[7:]
+	}
+	
+	s.mu.Lock()
+	claims, ok := s.tokens[token]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
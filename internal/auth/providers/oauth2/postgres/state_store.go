@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2"
+	"github.com/jmoiron/sqlx"
+)
+
+// StateStore implements oauth2.StateStore with PostgreSQL
+type StateStore struct {
+	db *sqlx.DB
+}
+
+// stateRow represents a row in the oauth_states table
+type stateRow struct {
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	CreatedAt    time.Time `db:"created_at"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// NewStateStore creates a new PostgreSQL-backed login state store
+func NewStateStore(db *sqlx.DB) *StateStore {
+	return &StateStore{
+		db: db,
+	}
+}
+
+// Create stores a new login state
+func (s *StateStore) Create(ctx context.Context, state *oauth2.LoginState) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO oauth_states (state_hash, provider, code_verifier, nonce, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.CreatedAt, state.ExpiresAt)
+	
+	return err
+}
+
+// Consume deletes and returns a login state in a single statement, so it can only be used once
+func (s *StateStore) Consume(ctx context.Context, stateHash string, provider string) (*oauth2.LoginState, error) {
+	var row stateRow
+	err := s.db.GetContext(ctx, &row, `
+		DELETE FROM oauth_states WHERE state_hash = $1 AND provider = $2
+		RETURNING state_hash, provider, code_verifier, nonce, created_at, expires_at`,
+		stateHash, provider)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, oauth2.ErrInvalidState
+		}
+		return nil, err
+	}
+	
+	if time.Now().After(row.ExpiresAt) {
+		return nil, oauth2.ErrInvalidState
+	}
+	
+	return &oauth2.LoginState{
+		StateHash:    row.StateHash,
+		Provider:     row.Provider,
+		CodeVerifier: row.CodeVerifier,
+		Nonce:        row.Nonce,
+		CreatedAt:    row.CreatedAt,
+		ExpiresAt:    row.ExpiresAt,
+	}, nil
+}
+
+// CleanupExpiredStates removes expired login states
+func (s *StateStore) CleanupExpiredStates(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM oauth_states WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
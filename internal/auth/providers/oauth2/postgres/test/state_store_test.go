@@ -0,0 +1,75 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStateStore(t *testing.T) {
+	// Set up the database
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	store := postgres.NewStateStore(db)
+	ctx := context.Background()
+	now := time.Now()
+	suffix := now.Format("20060102150405.000000")
+	
+	// 1. States can be consumed once, by the provider that created them
+	require.NoError(t, store.Create(ctx, &oauth2.LoginState{
+		StateHash:    "state-" + suffix,
+		Provider:     "corp",
+		CodeVerifier: "verifier",
+		Nonce:        "nonce",
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Minute),
+	}))
+	
+	_, err = store.Consume(ctx, "state-"+suffix, "google")
+	assert.ErrorIs(t, err, oauth2.ErrInvalidState)
+	
+	state, err := store.Consume(ctx, "state-"+suffix, "corp")
+	require.NoError(t, err)
+	assert.Equal(t, "verifier", state.CodeVerifier)
+	assert.Equal(t, "nonce", state.Nonce)
+	
+	_, err = store.Consume(ctx, "state-"+suffix, "corp")
+	assert.ErrorIs(t, err, oauth2.ErrInvalidState)
+	
+	// 2. Expired states are refused and cleaned up
+	require.NoError(t, store.Create(ctx, &oauth2.LoginState{
+		StateHash:    "expired-" + suffix,
+		Provider:     "corp",
+		CodeVerifier: "verifier",
+		Nonce:        "nonce",
+		CreatedAt:    now.Add(-time.Hour),
+		ExpiresAt:    now.Add(-time.Minute),
+	}))
+	
+	count, err := store.CleanupExpiredStates(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(1))
+	
+	_, err = store.Consume(ctx, "expired-"+suffix, "corp")
+	assert.ErrorIs(t, err, oauth2.ErrInvalidState)
+}
@@ -0,0 +1,238 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+)
+
+type Config struct {
+	Name string // Provider identifier in the registry and in callback URLs, e.g. "google"
+	
+	Issuer string // Issuer URL; its /.well-known/openid-configuration is used for discovery
+	
+	ClientID string
+	
+	ClientSecret string // Empty for public clients, which rely on PKCE alone
+	
+	RedirectURL string // Callback URL registered with the identity provider
+	
+	Scopes []string // Must include "openid"
+	
+	UsernameClaim string // Claim used as the username, falling back to the email address and then the subject
+	
+	RolesClaim string // Optional claim holding the user's roles or groups, as a string or a list of strings
+	
+	DefaultRoles []string // Roles of users the identity provider doesn't send roles for
+	
+	StateExpiration time.Duration // Time users have to log in at the identity provider
+	
+	ClockSkew time.Duration // Tolerance for clock differences when checking ID token times
+}
+
+// returns the configuration shared by most identity providers; Name, Issuer,
+// ClientID and RedirectURL still need to be set
+func DefaultConfig() Config {
+	return Config{
+		Scopes:          []string{"openid", "email", "profile"},
+		UsernameClaim:   "preferred_username",
+		DefaultRoles:    []string{"user"},
+		StateExpiration: 10 * time.Minute,
+		ClockSkew:       time.Minute,
+	}
+}
+
+// AuthorizationRequest is a login started with BeginLogin
+type AuthorizationRequest struct {
+	URL string // Where to send the browser
+	
+	State string // Returned with the callback; bind it to the browser, e.g. in a cookie, to stop login CSRF
+}
+
+// logs users in with an OpenID Connect identity provider, using the authorization
+// code flow with PKCE. Tokens are issued and checked by the tokens provider.
+type Provider struct {
+	config     Config
+	tokens     auth.Provider
+	stateStore StateStore
+	client     *http.Client
+	
+	mu           sync.Mutex
+	metadata     *Metadata
+	keys         *jwt.Util
+	keysLoadedAt time.Time
+}
+
+// Option configures optional provider dependencies
+type Option func(*Provider)
+
+// stores the state of logins in progress in the given store instead of in memory
+func WithStateStore(store StateStore) Option {
+	return func(p *Provider) {
+		p.stateStore = store
+	}
+}
+
+// sends requests to the identity provider with the given client
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Provider) {
+		p.client = client
+	}
+}
+
+// creates a new OpenID Connect provider. Token operations are passed on to tokens,
+// usually the local provider. The identity provider is contacted on first use.
+func NewProvider(config Config, tokens auth.Provider, options ...Option) *Provider {
+	p := &Provider{
+		config:     config,
+		tokens:     tokens,
+		stateStore: NewMemoryStateStore(),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// returns the provider identifier
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// starts a login: returns the identity provider URL to send the browser to
+func (p *Provider) BeginLogin(ctx context.Context) (*AuthorizationRequest, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	err = p.stateStore.Create(ctx, &LoginState{
+		StateHash:    hashState(state),
+		Provider:     p.config.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(p.config.StateExpiration),
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	
+	authURL := metadata.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query.Encode()
+	} else {
+		authURL += "?" + query.Encode()
+	}
+	
+	return &AuthorizationRequest{URL: authURL, State: state}, nil
+}
+
+// finishes a login started with BeginLogin. creds.Params holds the "code" and "state"
+// the identity provider sent to the callback. The user's ID is their subject at the identity provider;
+// Metadata holds the provider name, issuer, subject, email_verified and the raw claims.
+func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	if creds.Type != "oauth" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	code, _ := creds.Params["code"].(string)
+	state, _ := creds.Params["state"].(string)
+	if code == "" || state == "" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	loginState, err := p.stateStore.Consume(ctx, hashState(state), p.config.Name)
+	if err != nil {
+		if errors.Is(err, ErrInvalidState) {
+			return nil, fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+		}
+		return nil, err
+	}
+	
+	tokens, err := p.exchangeCode(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	
+	claims, err := p.verifyIDToken(ctx, tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The userinfo endpoint often has claims the ID token leaves out
+	if err := p.mergeUserInfo(ctx, tokens.AccessToken, claims); err != nil {
+		return nil, err
+	}
+	
+	return p.userFromClaims(claims), nil
+}
+
+// validates a token with the provider that issued it
+func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
+	return p.tokens.ValidateToken(ctx, token)
+}
+
+// refreshes a token with the provider that issued it
+func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
+	return p.tokens.RefreshToken(ctx, token)
+}
+
+// revokes a token with the provider that issued it
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	return p.tokens.RevokeToken(ctx, token)
+}
+
+// generates a random URL-safe string for state, nonce and PKCE verifier values
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashes a state parameter for storage
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
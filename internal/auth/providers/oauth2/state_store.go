@@ -0,0 +1,32 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidState = errors.New("invalid or expired login state")
+
+// LoginState is the server-side record of a login in progress at an identity provider.
+// Only a hash of the state parameter is stored; the PKCE verifier and nonce are needed
+// as-is to finish the login.
+type LoginState struct {
+	StateHash    string
+	Provider     string // Name of the provider that started the login
+	CodeVerifier string
+	Nonce        string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// StateStore persists the state of logins in progress
+type StateStore interface {
+	Create(ctx context.Context, state *LoginState) error
+	
+	// atomically deletes and returns the state.
+	// Returns ErrInvalidState if it doesn't exist, has expired or was started by another provider.
+	Consume(ctx context.Context, stateHash string, provider string) (*LoginState, error)
+	
+	CleanupExpiredStates(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "https://app.example.com/auth/oauth/corp/callback"
+
+// setupProvider starts a fake identity provider and a provider logging in with it
+func setupProvider(t *testing.T, clientSecret string) (*oidctest.Server, *oauth2.Provider) {
+	idp := oidctest.NewServer("client-1", clientSecret)
+	t.Cleanup(idp.Close)
+	
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	
+	config := oauth2.DefaultConfig()
+	config.Name = "corp"
+	config.Issuer = idp.URL
+	config.ClientID = "client-1"
+	config.ClientSecret = clientSecret
+	config.RedirectURL = redirectURL
+	config.RolesClaim = "groups"
+	provider := oauth2.NewProvider(config, local.NewProvider(localConfig, local.NewMemoryUserStore()))
+	return idp, provider
+}
+
+// login runs the authorization code flow and returns the result of Authenticate
+func login(t *testing.T, idp *oidctest.Server, provider *oauth2.Provider) (*auth.User, error) {
+	request, err := provider.BeginLogin(context.Background())
+	require.NoError(t, err)
+	
+	code, state, err := idp.Authorize(request.URL)
+	require.NoError(t, err)
+	assert.Equal(t, request.State, state)
+	
+	return provider.Authenticate(context.Background(), oauthCredentials(code, state))
+}
+
+func oauthCredentials(code string, state string) auth.Credentials {
+	return auth.Credentials{Type: "oauth", Provider: "corp", Params: map[string]interface{}{"code": code, "state": state}}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp, provider := setupProvider(t, "client-secret")
+	ctx := context.Background()
+	assert.Equal(t, "corp", provider.Name())
+	
+	// 1. The authorization URL asks for a code with PKCE
+	request, err := provider.BeginLogin(ctx)
+	require.NoError(t, err)
+	authURL, err := url.Parse(request.URL)
+	require.NoError(t, err)
+	query := authURL.Query()
+	assert.Equal(t, idp.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "client-1", query.Get("client_id"))
+	assert.Equal(t, redirectURL, query.Get("redirect_uri"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("code_challenge"))
+	assert.NotEmpty(t, query.Get("nonce"))
+	assert.Equal(t, request.State, query.Get("state"))
+	
+	// 2. Claims are mapped to the user, including those only available from userinfo
+	idp.SetUser(map[string]interface{}{
+		"sub":                "user-123",
+		"email":              "alice@corp.example",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"groups":             []string{"staff", "admin"},
+	})
+	user, err := login(t, idp, provider)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", user.ID)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "alice@corp.example", user.Email)
+	assert.Equal(t, []string{"staff", "admin"}, user.Roles)
+	assert.Equal(t, "corp", user.Metadata["provider"])
+	assert.Equal(t, idp.URL, user.Metadata["issuer"])
+	assert.Equal(t, "user-123", user.Metadata["subject"])
+	assert.Equal(t, true, user.Metadata["email_verified"])
+	
+	// 3. Users without a username or roles get their email address and the default roles
+	idp.SetUser(map[string]interface{}{"sub": "user-456", "email": "bob@corp.example", "email_verified": "false"})
+	user, err = login(t, idp, provider)
+	require.NoError(t, err)
+	assert.Equal(t, "bob@corp.example", user.Username)
+	assert.Equal(t, []string{"user"}, user.Roles)
+	assert.Equal(t, false, user.Metadata["email_verified"])
+}
+
+func TestOIDCPublicClient(t *testing.T) {
+	idp, provider := setupProvider(t, "")
+	idp.SetUser(map[string]interface{}{"sub": "user-123"})
+	
+	user, err := login(t, idp, provider)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", user.ID)
+	assert.Equal(t, "user-123", user.Username)
+}
+
+func TestOIDCLoginRejected(t *testing.T) {
+	idp, provider := setupProvider(t, "client-secret")
+	idp.SetUser(map[string]interface{}{"sub": "user-123"})
+	ctx := context.Background()
+	
+	// 1. States and codes work once
+	request, err := provider.BeginLogin(ctx)
+	require.NoError(t, err)
+	code, state, err := idp.Authorize(request.URL)
+	require.NoError(t, err)
+	
+	_, err = provider.Authenticate(ctx, oauthCredentials(code, "forged-state"))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	_, err = provider.Authenticate(ctx, oauthCredentials(code, state))
+	require.NoError(t, err)
+	_, err = provider.Authenticate(ctx, oauthCredentials(code, state))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 2. A code from a login this provider didn't start is refused by the identity provider,
+	// because the PKCE verifier doesn't match
+	first, err := provider.BeginLogin(ctx)
+	require.NoError(t, err)
+	second, err := provider.BeginLogin(ctx)
+	require.NoError(t, err)
+	code, _, err = idp.Authorize(first.URL)
+	require.NoError(t, err)
+	_, err = provider.Authenticate(ctx, oauthCredentials(code, second.State))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 3. ID tokens with the wrong nonce, audience or issuer are refused
+	for _, overrides := range []map[string]interface{}{
+		{"nonce": "replayed"},
+		{"aud": "another-client"},
+		{"aud": []string{"client-1", "another-client"}, "azp": "another-client"},
+		{"iss": "https://evil.example"},
+	} {
+		idp.SetIDTokenOverrides(overrides)
+		_, err = login(t, idp, provider)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, "%v", overrides)
+	}
+	
+	// 4. Other credential types are refused
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "alice", Password: "x"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestOIDCDiscoveryFailure(t *testing.T) {
+	idp, _ := setupProvider(t, "")
+	
+	// The issuer in the configuration has to match the one in the discovery document
+	config := oauth2.DefaultConfig()
+	config.Name = "corp"
+	config.Issuer = idp.URL + "/"
+	config.ClientID = "client-1"
+	provider := oauth2.NewProvider(config, nil)
+	
+	_, err := provider.BeginLogin(context.Background())
+	assert.ErrorIs(t, err, oauth2.ErrDiscovery)
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	store := oauth2.NewMemoryStateStore()
+	ctx := context.Background()
+	now := time.Now()
+	
+	require.NoError(t, store.Create(ctx, &oauth2.LoginState{StateHash: "current", Provider: "corp", ExpiresAt: now.Add(time.Minute)}))
+	require.NoError(t, store.Create(ctx, &oauth2.LoginState{StateHash: "expired", Provider: "corp", ExpiresAt: now.Add(-time.Minute)}))
+	
+	// States belong to the provider that started the login
+	_, err := store.Consume(ctx, "current", "google")
+	assert.ErrorIs(t, err, oauth2.ErrInvalidState)
+	
+	state, err := store.Consume(ctx, "current", "corp")
+	require.NoError(t, err)
+	assert.Equal(t, "corp", state.Provider)
+	
+	_, err = store.Consume(ctx, "current", "corp")
+	assert.ErrorIs(t, err, oauth2.ErrInvalidState)
+	
+	count, err := store.CleanupExpiredStates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,137 @@
+package oauthserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+)
+
+// AuthorizationRequest is a client's request to act for a user (RFC 6749 section 4.1.1),
+// with a PKCE code challenge (RFC 7636)
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string // Space-separated scopes asked for; all of the client's scopes if empty
+	State               string // Passed back to the client unchanged
+	Nonce               string // Passed on to the ID token
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationError is an error reported to the client by sending the user back to its
+// redirect URI (RFC 6749 section 4.1.2.1). Errors about the client or the redirect URI itself
+// are ErrInvalidClient or ErrInvalidRedirectURI instead, since they can't be reported that way.
+type AuthorizationError struct {
+	Code        string // e.g. invalid_request or invalid_scope
+	Description string
+}
+
+func (e *AuthorizationError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// checks an authorization request before the user is asked to log in and returns
+// the client and the scopes it would get
+func (p *Provider) CheckAuthorizationRequest(ctx context.Context, request AuthorizationRequest) (*Client, []string, error) {
+	client, err := p.clientStore.Get(ctx, request.ClientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	if request.RedirectURI == "" || !contains(client.RedirectURIs, request.RedirectURI) {
+		return nil, nil, fmt.Errorf("%w: not registered for this client", ErrInvalidRedirectURI)
+	}
+	
+	if request.ResponseType != "code" {
+		return nil, nil, &AuthorizationError{Code: "unsupported_response_type", Description: "Only response_type=code is supported"}
+	}
+	
+	// PKCE is mandatory, and only with S256, since the plain method doesn't protect a code that leaks
+	if request.CodeChallengeMethod != "S256" || !validPKCEValue(request.CodeChallenge, 43) {
+		return nil, nil, &AuthorizationError{Code: "invalid_request", Description: "A code_challenge with code_challenge_method=S256 is required"}
+	}
+	
+	// Any client users log in to may ask who they are
+	scopes, err := grantedScopes(client, request.Scope, ScopeOpenID, ScopeProfile, ScopeEmail)
+	if err != nil {
+		return nil, nil, &AuthorizationError{Code: "invalid_scope", Description: err.Error()}
+	}
+	
+	return client, scopes, nil
+}
+
+// issues an authorization code for a user who logged in at authTime and agreed to the request.
+// The request is checked again, so it can come straight from the form the user submitted.
+func (p *Provider) IssueAuthorizationCode(ctx context.Context, request AuthorizationRequest, user *auth.User, authTime time.Time) (string, error) {
+	client, scopes, err := p.CheckAuthorizationRequest(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(buf)
+	
+	now := time.Now()
+	err = p.codeStore.Create(ctx, &AuthorizationCode{
+		CodeHash:      hashSecret(code),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: request.CodeChallenge,
+		Nonce:         request.Nonce,
+		AuthTime:      authTime,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(p.config.CodeExpiration),
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	return code, nil
+}
+
+// returns the scopes a client gets for a space-separated request: the ones asked for, if the
+// client may have them all or they're in extra, or else all of the client's scopes if none were asked for
+func grantedScopes(client *Client, scope string, extra ...string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return client.Scopes, nil
+	}
+	
+	for _, s := range requested {
+		if !contains(client.Scopes, s) && !contains(extra, s) {
+			return nil, fmt.Errorf("%w: %q isn't allowed for this client", ErrInvalidScope, s)
+		}
+	}
+	return requested, nil
+}
+
+// reports whether a PKCE code challenge or verifier has the right length and only
+// unreserved characters (RFC 7636 section 4.1). S256 challenges are always 43 characters.
+func validPKCEValue(value string, minLength int) bool {
+	if len(value) < minLength || len(value) > 128 {
+		return false
+	}
+	for _, c := range value {
+		isUnreserved := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~'
+		if !isUnreserved {
+			return false
+		}
+	}
+	return true
+}
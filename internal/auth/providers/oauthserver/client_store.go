@@ -0,0 +1,41 @@
+package oauthserver
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+)
+
+// Client is an application registered to get tokens from the token endpoint, e.g. a
+// microservice or a web app users log in to. The secret is only shown when the client is
+// registered; the store keeps a hash of it.
+type Client struct {
+	ID            string
+	Name          string        // Label chosen by the administrator, e.g. "Billing service"
+	SecretHash    string        // Hex SHA-256 of the client secret
+	Scopes        []string      // Scopes the client may ask for
+	TokenLifetime time.Duration // Lifetime of the client's access tokens; zero for the provider's default
+	RedirectURIs  []string      // Where users may be sent back to with an authorization code; matched exactly
+	Public        bool          // Apps that can't keep a secret, e.g. mobile and single-page apps; they have no secret
+	CreatedAt     time.Time
+}
+
+// ClientStore persists registered clients
+type ClientStore interface {
+	// returns ErrClientExists if a client with the same ID exists
+	Create(ctx context.Context, client *Client) error
+	
+	// returns ErrClientNotFound if there's no such client
+	Get(ctx context.Context, id string) (*Client, error)
+	
+	// returns every client, oldest first
+	List(ctx context.Context) ([]*Client, error)
+	
+	// returns ErrClientNotFound if there's no such client
+	Delete(ctx context.Context, id string) error
+}
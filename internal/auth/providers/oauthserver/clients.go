@@ -0,0 +1,202 @@
+package oauthserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidClient      = fmt.Errorf("%w: unknown client or wrong secret", auth.ErrInvalidCredentials)
+	ErrInvalidName        = errors.New("client name must be 1 to 100 characters")
+	ErrInvalidScope       = errors.New("invalid scope")
+	ErrInvalidLifetime    = errors.New("invalid token lifetime")
+	ErrInvalidRedirectURI = errors.New("invalid redirect URI")
+)
+
+// Registration describes a client to register
+type Registration struct {
+	Name          string
+	Scopes        []string
+	TokenLifetime time.Duration // Zero for the default lifetime
+	RedirectURIs  []string      // Needed for the authorization code grant
+	Public        bool          // Registers a client without a secret, which can only use the authorization code grant
+}
+
+// registers a client and returns its secret, which isn't stored and can't be shown again.
+// Public clients don't get a secret.
+func (p *Provider) CreateClient(ctx context.Context, registration Registration) (string, *Client, error) {
+	name := strings.TrimSpace(registration.Name)
+	if name == "" || len(name) > 100 {
+		return "", nil, ErrInvalidName
+	}
+	
+	scopes, err := p.checkScopes(registration.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	
+	lifetime := registration.TokenLifetime
+	if lifetime < 0 || (p.config.MaxTokenLifetime > 0 && lifetime > p.config.MaxTokenLifetime) {
+		return "", nil, fmt.Errorf("%w: at most %s", ErrInvalidLifetime, p.config.MaxTokenLifetime)
+	}
+	
+	for _, redirectURI := range registration.RedirectURIs {
+		if err := checkRedirectURI(redirectURI); err != nil {
+			return "", nil, err
+		}
+	}
+	if registration.Public && len(registration.RedirectURIs) == 0 {
+		return "", nil, fmt.Errorf("%w: public clients need one", ErrInvalidRedirectURI)
+	}
+	
+	client := &Client{
+		ID:            uuid.New().String(),
+		Name:          name,
+		Scopes:        scopes,
+		TokenLifetime: lifetime,
+		RedirectURIs:  append([]string(nil), registration.RedirectURIs...),
+		Public:        registration.Public,
+		CreatedAt:     time.Now(),
+	}
+	
+	var secret string
+	if !client.Public {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", nil, err
+		}
+		secret = base64.RawURLEncoding.EncodeToString(buf)
+		client.SecretHash = hashSecret(secret)
+	}
+	
+	if err := p.clientStore.Create(ctx, client); err != nil {
+		return "", nil, err
+	}
+	
+	return secret, client, nil
+}
+
+// returns the registered clients, oldest first
+func (p *Provider) ListClients(ctx context.Context) ([]*Client, error) {
+	return p.clientStore.List(ctx)
+}
+
+// deletes a client; its tokens stop working straight away
+func (p *Provider) DeleteClient(ctx context.Context, id string) error {
+	return p.clientStore.Delete(ctx, id)
+}
+
+// checks a client's secret and returns the client. Unknown clients, wrong secrets
+// and public clients, which have no secret, are ErrInvalidClient.
+func (p *Provider) AuthenticateClient(ctx context.Context, clientID string, secret string) (*Client, error) {
+	if clientID == "" || secret == "" {
+		return nil, ErrInvalidClient
+	}
+	
+	client, err := p.clientStore.Get(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	if client.Public || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// identifies the client at the token endpoint: public clients by their ID alone, others by their secret too
+func (p *Provider) identifyClient(ctx context.Context, clientID string, secret string) (*Client, error) {
+	if secret != "" {
+		return p.AuthenticateClient(ctx, clientID, secret)
+	}
+	
+	client, err := p.clientStore.Get(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !client.Public {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// removes duplicate scopes and checks them against the allowed scopes
+func (p *Provider) checkScopes(scopes []string) ([]string, error) {
+	checked := make([]string, 0, len(scopes))
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\r\n\"\\") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if len(p.config.AllowedScopes) > 0 && !contains(p.config.AllowedScopes, scope) {
+			return nil, fmt.Errorf("%w: %q isn't allowed", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			checked = append(checked, scope)
+		}
+	}
+	return checked, nil
+}
+
+// checks that a redirect URI can be registered: an https URL, an http URL on the user's
+// own device for apps running there, or a private-use scheme like com.example.app:/callback
+// for mobile apps (RFC 8252 section 7.1). Fragments aren't allowed.
+func checkRedirectURI(redirectURI string) error {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Scheme == "" || strings.Contains(redirectURI, "#") {
+		return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, redirectURI)
+	}
+	
+	switch parsed.Scheme {
+	case "https":
+		if parsed.Host == "" {
+			return fmt.Errorf("%w: %q has no host", ErrInvalidRedirectURI, redirectURI)
+		}
+	case "http":
+		host := parsed.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("%w: %q must use https", ErrInvalidRedirectURI, redirectURI)
+		}
+	default:
+		if !strings.Contains(parsed.Scheme, ".") {
+			return fmt.Errorf("%w: %q must use https or a reverse domain scheme", ErrInvalidRedirectURI, redirectURI)
+		}
+	}
+	return nil
+}
+
+// reports whether the list holds the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hashes a client secret for storage. Secrets are random enough that a fast hash is safe.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
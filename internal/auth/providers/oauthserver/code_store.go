@@ -0,0 +1,41 @@
+package oauthserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidCode = fmt.Errorf("%w: invalid, expired or used authorization code", ErrInvalidGrant)
+	ErrCodeExists  = errors.New("authorization code already exists")
+)
+
+// AuthorizationCode is a code issued to a client after a user logged in and agreed to
+// its request. The store only keeps a hash of the code.
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        string
+	RedirectURI   string    // The token request must name the same URI
+	Scope         string    // Space-separated scopes granted
+	CodeChallenge string    // PKCE S256 challenge the token request's code verifier must match
+	Nonce         string    // From the authorization request, for the ID token
+	AuthTime      time.Time // When the user entered their password
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+// CodeStore persists authorization codes until they're exchanged for tokens
+type CodeStore interface {
+	// returns ErrCodeExists if a code with the same hash exists
+	Create(ctx context.Context, code *AuthorizationCode) error
+	
+	// deletes and returns a code, so it can only be used once;
+	// returns ErrInvalidCode if there's no such code or it has expired
+	Consume(ctx context.Context, codeHash string) (*AuthorizationCode, error)
+	
+	// removes expired codes
+	CleanupExpiredCodes(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,61 @@
+package oauthserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+)
+
+// Introspection is what the introspection endpoint tells a client about a token (RFC 7662 section 2.2).
+// Inactive tokens have nothing but Active set.
+type Introspection struct {
+	Active    bool
+	Subject   string // The user the token acts for; empty for client tokens
+	Username  string
+	ClientID  string // The client the token was issued to, if any
+	Scope     string // Space-separated scopes
+	ExpiresAt time.Time
+}
+
+// tells a client, e.g. a gateway that can't validate tokens itself, whether a token is active
+// and who it's for. The client authenticates with its secret. Tokens are checked like everywhere
+// else, revocation included, so expired, revoked, malformed and ID tokens are all just inactive.
+func (p *Provider) Introspect(ctx context.Context, clientID string, secret string, token string) (*Introspection, error) {
+	if _, err := p.AuthenticateClient(ctx, clientID, secret); err != nil {
+		return nil, err
+	}
+	
+	claims, err := p.tokenUtil.ValidateToken(token)
+	if err != nil {
+		return &Introspection{}, nil
+	}
+	
+	user, err := p.ValidateToken(ctx, token)
+	if invalidToken(err) {
+		return &Introspection{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	introspection := &Introspection{Active: true}
+	introspection.ClientID, _ = claims["client_id"].(string)
+	introspection.Scope, _ = claims["scope"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		introspection.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	if _, isClientToken := clientTokenClaims(claims); !isClientToken {
+		introspection.Subject = user.ID
+		introspection.Username = user.Username
+	}
+	return introspection, nil
+}
+
+// reports whether a token validation error means the token isn't valid, rather than that it couldn't be checked
+func invalidToken(err error) bool {
+	return errors.Is(err, auth.ErrInvalidCredentials) || errors.Is(err, auth.ErrUserNotFound) ||
+		errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, jwt.ErrExpiredToken)
+}
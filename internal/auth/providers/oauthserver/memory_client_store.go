@@ -0,0 +1,83 @@
+package oauthserver
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryClientStore implements ClientStore with in-memory storage
+type MemoryClientStore struct {
+	clients map[string]*Client
+	mu      sync.RWMutex
+}
+
+// NewMemoryClientStore creates a new in-memory client store
+func NewMemoryClientStore() *MemoryClientStore {
+	return &MemoryClientStore{
+		clients: make(map[string]*Client),
+	}
+}
+
+// Create stores a new client
+func (s *MemoryClientStore) Create(ctx context.Context, client *Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.clients[client.ID]; exists {
+		return ErrClientExists
+	}
+	
+	s.clients[client.ID] = cloneClient(client)
+	return nil
+}
+
+// Get retrieves a client by ID
+func (s *MemoryClientStore) Get(ctx context.Context, id string) (*Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	client, exists := s.clients[id]
+	if !exists {
+		return nil, ErrClientNotFound
+	}
+	
+	return cloneClient(client), nil
+}
+
+// List returns every client, oldest first
+func (s *MemoryClientStore) List(ctx context.Context) ([]*Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	clients := make([]*Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		clients = append(clients, cloneClient(client))
+	}
+	
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+	})
+	return clients, nil
+}
+
+// Delete removes a client
+func (s *MemoryClientStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.clients[id]; !exists {
+		return ErrClientNotFound
+	}
+	
+	delete(s.clients, id)
+	return nil
+}
+
+// cloneClient returns a copy so callers can't modify stored clients
+func cloneClient(client *Client) *Client {
+	clientCopy := *client
+	clientCopy.Scopes = append([]string(nil), client.Scopes...)
+	clientCopy.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+	return &clientCopy
+}
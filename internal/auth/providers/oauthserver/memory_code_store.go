@@ -0,0 +1,67 @@
+package oauthserver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCodeStore implements CodeStore with in-memory storage
+type MemoryCodeStore struct {
+	codes map[string]*AuthorizationCode // Indexed by code hash
+	mu    sync.Mutex
+}
+
+// NewMemoryCodeStore creates a new in-memory authorization code store
+func NewMemoryCodeStore() *MemoryCodeStore {
+	return &MemoryCodeStore{
+		codes: make(map[string]*AuthorizationCode),
+	}
+}
+
+// Create stores a new code
+func (s *MemoryCodeStore) Create(ctx context.Context, code *AuthorizationCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.codes[code.CodeHash]; exists {
+		return ErrCodeExists
+	}
+	
+	codeCopy := *code
+	s.codes[code.CodeHash] = &codeCopy
+	return nil
+}
+
+// Consume deletes and returns a code
+func (s *MemoryCodeStore) Consume(ctx context.Context, codeHash string) (*AuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	code, exists := s.codes[codeHash]
+	if !exists {
+		return nil, ErrInvalidCode
+	}
+	delete(s.codes, codeHash)
+	
+	if time.Now().After(code.ExpiresAt) {
+		return nil, ErrInvalidCode
+	}
+	return code, nil
+}
+
+// CleanupExpiredCodes removes expired codes
+func (s *MemoryCodeStore) CleanupExpiredCodes(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	for hash, code := range s.codes {
+		if now.After(code.ExpiresAt) {
+			delete(s.codes, hash)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,142 @@
+package oauthserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+)
+
+// OpenID Connect scopes (OpenID Connect Core section 5.4). Any client users log in to may ask for them.
+const (
+	ScopeOpenID  = "openid"  // Asks for an ID token
+	ScopeProfile = "profile" // preferred_username
+	ScopeEmail   = "email"   // email and email_verified
+)
+
+// Values of the "token_use" claim, which keeps tokens issued to clients for users
+// from being accepted as the users' own access tokens
+const (
+	tokenUseDelegated = "oauth_access" // Access tokens issued to clients for users
+	tokenUseID        = "id"           // ID tokens
+)
+
+var (
+	ErrInvalidAccessToken = fmt.Errorf("%w: invalid, expired or revoked access token", auth.ErrInvalidCredentials)
+	ErrInsufficientScope  = errors.New("access token wasn't granted the openid scope")
+)
+
+// OpenIDConfiguration is the provider's discovery document (OpenID Connect Discovery section 3)
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// returns the discovery document, with the endpoints under the configured issuer
+func (p *Provider) OpenIDConfiguration() *OpenIDConfiguration {
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	
+	scopes := []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+	for _, scope := range p.config.AllowedScopes {
+		if !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	
+	return &OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{p.tokenUtil.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"email", "email_verified", "preferred_username"},
+	}
+}
+
+// returns the claims about the user an access token was issued for, filtered by the token's
+// scopes (OpenID Connect Core section 5.3). The token must have been granted the openid scope.
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	claims, err := p.tokenUtil.ValidateToken(accessToken)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	
+	if _, err := p.ValidateToken(ctx, accessToken); err != nil {
+		if invalidToken(err) {
+			return nil, ErrInvalidAccessToken
+		}
+		return nil, err
+	}
+	
+	// Only tokens issued to a client for a user can have been granted the openid scope
+	scope, _ := claims["scope"].(string)
+	scopes := strings.Fields(scope)
+	if use, _ := claims["token_use"].(string); use != tokenUseDelegated || !contains(scopes, ScopeOpenID) {
+		return nil, ErrInsufficientScope
+	}
+	
+	userID, _ := claims["sub"].(string)
+	user, err := p.userStore.GetByID(ctx, userID)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	return userClaims(user, scopes), nil
+}
+
+// issues the ID token telling a client which user logged in and when (OpenID Connect Core section 2).
+// It's signed like access tokens, but says it's an ID token so it can't be used as one.
+func (p *Provider) issueIDToken(client *Client, user *local.StoredUser, code *AuthorizationCode, lifetime time.Duration) (string, error) {
+	claims := userClaims(user, strings.Fields(code.Scope))
+	claims["iss"] = strings.TrimSuffix(p.config.Issuer, "/")
+	claims["aud"] = client.ID
+	claims["exp"] = time.Now().Add(lifetime).Unix()
+	claims["auth_time"] = code.AuthTime.Unix()
+	claims["token_use"] = tokenUseID
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+	
+	return p.tokenUtil.GenerateToken(claims)
+}
+
+// returns the standard claims about a user that the scopes allow
+func userClaims(user *local.StoredUser, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": user.ID}
+	if contains(scopes, ScopeProfile) {
+		claims["preferred_username"] = user.Username
+	}
+	if contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	return claims
+}
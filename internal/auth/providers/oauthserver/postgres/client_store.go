@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ClientStore implements oauthserver.ClientStore with PostgreSQL
+type ClientStore struct {
+	db *sqlx.DB
+}
+
+// clientRow represents a row in the oauth_clients table
+type clientRow struct {
+	ID                   string         `db:"id"`
+	Name                 string         `db:"name"`
+	SecretHash           string         `db:"secret_hash"`
+	Scopes               pq.StringArray `db:"scopes"`
+	TokenLifetimeSeconds int64          `db:"token_lifetime_seconds"`
+	CreatedAt            time.Time      `db:"created_at"`
+	RedirectURIs         pq.StringArray `db:"redirect_uris"`
+	Public               bool           `db:"public"`
+}
+
+// NewClientStore creates a new PostgreSQL-backed client store
+func NewClientStore(db *sqlx.DB) *ClientStore {
+	return &ClientStore{
+		db: db,
+	}
+}
+
+// Create stores a new client
+func (s *ClientStore) Create(ctx context.Context, client *oauthserver.Client) error {
+	scopes := pq.StringArray(client.Scopes)
+	if scopes == nil {
+		scopes = pq.StringArray{}
+	}
+	redirectURIs := pq.StringArray(client.RedirectURIs)
+	if redirectURIs == nil {
+		redirectURIs = pq.StringArray{}
+	}
+	
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO oauth_clients (id, name, secret_hash, scopes, token_lifetime_seconds, created_at, redirect_uris, public)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		client.ID, client.Name, client.SecretHash, scopes, int64(client.TokenLifetime.Seconds()), client.CreatedAt,
+		redirectURIs, client.Public)
+	
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return oauthserver.ErrClientExists
+	}
+	return err
+}
+
+// Get retrieves a client by ID
+func (s *ClientStore) Get(ctx context.Context, id string) (*oauthserver.Client, error) {
+	var row clientRow
+	err := s.db.GetContext(ctx, &row, "SELECT * FROM oauth_clients WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, oauthserver.ErrClientNotFound
+		}
+		return nil, err
+	}
+	
+	return row.toClient(), nil
+}
+
+// List returns every client, oldest first
+func (s *ClientStore) List(ctx context.Context) ([]*oauthserver.Client, error) {
+	var rows []clientRow
+	err := s.db.SelectContext(ctx, &rows, "SELECT * FROM oauth_clients ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	
+	clients := make([]*oauthserver.Client, 0, len(rows))
+	for i := range rows {
+		clients = append(clients, rows[i].toClient())
+	}
+	return clients, nil
+}
+
+// Delete removes a client
+func (s *ClientStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return oauthserver.ErrClientNotFound
+	}
+	return nil
+}
+
+// toClient converts a database row to a client
+func (row *clientRow) toClient() *oauthserver.Client {
+	return &oauthserver.Client{
+		ID:            row.ID,
+		Name:          row.Name,
+		SecretHash:    row.SecretHash,
+		Scopes:        row.Scopes,
+		TokenLifetime: time.Duration(row.TokenLifetimeSeconds) * time.Second,
+		RedirectURIs:  row.RedirectURIs,
+		Public:        row.Public,
+		CreatedAt:     row.CreatedAt,
+	}
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CodeStore implements oauthserver.CodeStore with PostgreSQL
+type CodeStore struct {
+	db *sqlx.DB
+}
+
+// codeRow represents a row in the oauth_authorization_codes table
+type codeRow struct {
+	CodeHash      string    `db:"code_hash"`
+	ClientID      string    `db:"client_id"`
+	UserID        string    `db:"user_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scope         string    `db:"scope"`
+	CodeChallenge string    `db:"code_challenge"`
+	Nonce         string    `db:"nonce"`
+	AuthTime      time.Time `db:"auth_time"`
+	CreatedAt     time.Time `db:"created_at"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+// NewCodeStore creates a new PostgreSQL-backed authorization code store
+func NewCodeStore(db *sqlx.DB) *CodeStore {
+	return &CodeStore{
+		db: db,
+	}
+}
+
+// Create stores a new code
+func (s *CodeStore) Create(ctx context.Context, code *oauthserver.AuthorizationCode) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO oauth_authorization_codes
+			(code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, auth_time, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.CodeChallenge,
+		code.Nonce, code.AuthTime, code.CreatedAt, code.ExpiresAt)
+	
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return oauthserver.ErrCodeExists
+	}
+	return err
+}
+
+// Consume deletes and returns a code in a single statement, so it can only be used once
+func (s *CodeStore) Consume(ctx context.Context, codeHash string) (*oauthserver.AuthorizationCode, error) {
+	var row codeRow
+	err := s.db.GetContext(ctx, &row, `
+		DELETE FROM oauth_authorization_codes WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, auth_time, created_at, expires_at`,
+		codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, oauthserver.ErrInvalidCode
+		}
+		return nil, err
+	}
+	
+	if time.Now().After(row.ExpiresAt) {
+		return nil, oauthserver.ErrInvalidCode
+	}
+	
+	return &oauthserver.AuthorizationCode{
+		CodeHash:      row.CodeHash,
+		ClientID:      row.ClientID,
+		UserID:        row.UserID,
+		RedirectURI:   row.RedirectURI,
+		Scope:         row.Scope,
+		CodeChallenge: row.CodeChallenge,
+		Nonce:         row.Nonce,
+		AuthTime:      row.AuthTime,
+		CreatedAt:     row.CreatedAt,
+		ExpiresAt:     row.ExpiresAt,
+	}, nil
+}
+
+// CleanupExpiredCodes removes expired codes
+func (s *CodeStore) CleanupExpiredCodes(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM oauth_authorization_codes WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
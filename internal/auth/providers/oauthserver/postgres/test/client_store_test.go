@@ -0,0 +1,103 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDatabase connects to the test database
+func setupDatabase(t *testing.T) *sqlx.DB {
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	return db
+}
+
+func TestPostgresClientStore(t *testing.T) {
+	db := setupDatabase(t)
+	store := postgres.NewClientStore(db)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	suffix := now.Format("150405.000000")
+	
+	// 1. Store and read back clients, with and without their own token lifetime
+	client := &oauthserver.Client{
+		ID:            "billing-" + suffix,
+		Name:          "Billing service",
+		SecretHash:    "hash-1",
+		Scopes:        []string{"invoices:read", "invoices:write"},
+		TokenLifetime: 30 * time.Minute,
+		CreatedAt:     now,
+	}
+	require.NoError(t, store.Create(ctx, client))
+	t.Cleanup(func() { store.Delete(ctx, client.ID) })
+	assert.ErrorIs(t, store.Create(ctx, client), oauthserver.ErrClientExists)
+	
+	other := &oauthserver.Client{
+		ID:           "reports-" + suffix,
+		Name:         "Reports",
+		RedirectURIs: []string{"https://reports.example.com/callback", "http://localhost:3000/callback"},
+		Public:       true,
+		CreatedAt:    now.Add(time.Second),
+	}
+	require.NoError(t, store.Create(ctx, other))
+	t.Cleanup(func() { store.Delete(ctx, other.ID) })
+	
+	stored, err := store.Get(ctx, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Billing service", stored.Name)
+	assert.Equal(t, "hash-1", stored.SecretHash)
+	assert.Equal(t, []string{"invoices:read", "invoices:write"}, stored.Scopes)
+	assert.Equal(t, 30*time.Minute, stored.TokenLifetime)
+	assert.Empty(t, stored.RedirectURIs)
+	assert.False(t, stored.Public)
+	
+	stored, err = store.Get(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Scopes)
+	assert.Zero(t, stored.TokenLifetime)
+	assert.Equal(t, other.RedirectURIs, stored.RedirectURIs)
+	assert.True(t, stored.Public)
+	assert.Empty(t, stored.SecretHash)
+	
+	_, err = store.Get(ctx, "unknown")
+	assert.ErrorIs(t, err, oauthserver.ErrClientNotFound)
+	
+	// 2. Both are listed, oldest first
+	clients, err := store.List(ctx)
+	require.NoError(t, err)
+	var ids []string
+	for _, c := range clients {
+		if c.ID == client.ID || c.ID == other.ID {
+			ids = append(ids, c.ID)
+		}
+	}
+	assert.Equal(t, []string{client.ID, other.ID}, ids)
+	
+	// 3. Delete a client
+	require.NoError(t, store.Delete(ctx, client.ID))
+	assert.ErrorIs(t, store.Delete(ctx, client.ID), oauthserver.ErrClientNotFound)
+	_, err = store.Get(ctx, client.ID)
+	assert.ErrorIs(t, err, oauthserver.ErrClientNotFound)
+}
@@ -0,0 +1,94 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	localpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresCodeStore(t *testing.T) {
+	db := setupDatabase(t)
+	store := postgres.NewCodeStore(db)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	suffix := now.Format("150405.000000")
+	
+	// Codes belong to a client and a user
+	userStore := localpg.NewSQLUserStore(db)
+	user := &local.StoredUser{
+		Username:     "oauthserver-" + suffix,
+		Email:        "oauthserver-" + suffix + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(ctx, user))
+	t.Cleanup(func() { userStore.Delete(ctx, user.ID) })
+	
+	clientStore := postgres.NewClientStore(db)
+	client := &oauthserver.Client{
+		ID:           "webapp-" + suffix,
+		Name:         "Web app",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Public:       true,
+		CreatedAt:    now,
+	}
+	require.NoError(t, clientStore.Create(ctx, client))
+	t.Cleanup(func() { clientStore.Delete(ctx, client.ID) })
+	
+	// 1. Store a code and use it once
+	code := &oauthserver.AuthorizationCode{
+		CodeHash:      "code-" + suffix,
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   "https://app.example.com/callback",
+		Scope:         "openid profile",
+		CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		Nonce:         "n-0S6_WzA2Mj",
+		AuthTime:      now.Add(-time.Second),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(time.Minute),
+	}
+	require.NoError(t, store.Create(ctx, code))
+	assert.ErrorIs(t, store.Create(ctx, code), oauthserver.ErrCodeExists)
+	
+	consumed, err := store.Consume(ctx, code.CodeHash)
+	require.NoError(t, err)
+	assert.Equal(t, client.ID, consumed.ClientID)
+	assert.Equal(t, user.ID, consumed.UserID)
+	assert.Equal(t, code.RedirectURI, consumed.RedirectURI)
+	assert.Equal(t, code.Scope, consumed.Scope)
+	assert.Equal(t, code.CodeChallenge, consumed.CodeChallenge)
+	assert.Equal(t, code.Nonce, consumed.Nonce)
+	assert.WithinDuration(t, code.AuthTime, consumed.AuthTime, time.Second)
+	
+	_, err = store.Consume(ctx, code.CodeHash)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidCode)
+	
+	// 2. Expired codes can't be used and are cleaned up
+	expired := *code
+	expired.CodeHash = "expired-" + suffix
+	expired.ExpiresAt = now.Add(-time.Second)
+	require.NoError(t, store.Create(ctx, &expired))
+	
+	stale := expired
+	stale.CodeHash = "stale-" + suffix
+	require.NoError(t, store.Create(ctx, &stale))
+	
+	_, err = store.Consume(ctx, expired.CodeHash)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidCode)
+	
+	count, err := store.CleanupExpiredCodes(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(1))
+	
+	_, err = store.Consume(ctx, stale.CodeHash)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidCode)
+}
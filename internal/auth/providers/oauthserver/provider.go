@@ -0,0 +1,248 @@
+package oauthserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+)
+
+type Config struct {
+	Issuer string // Externally reachable base URL of the service, which identifies it in ID tokens
+	
+	AllowedScopes []string // Scopes clients may be registered with; any scope if empty
+	
+	TokenExpiration time.Duration // Lifetime of access tokens of clients without their own
+	
+	MaxTokenLifetime time.Duration // Longest token lifetime a client may be registered with
+	
+	CodeExpiration time.Duration // Time clients have to exchange an authorization code for tokens
+}
+
+// returns the configuration with hour-long tokens
+func DefaultConfig() Config {
+	return Config{
+		Issuer:           "http://localhost:8080",
+		TokenExpiration:  time.Hour,
+		MaxTokenLifetime: 24 * time.Hour,
+		CodeExpiration:   time.Minute,
+	}
+}
+
+// implements an OAuth 2.0 authorization server and OpenID Connect provider for registered
+// clients. Clients get access tokens for themselves with the client credentials grant, and
+// for the users of the local provider, plus ID tokens, with the authorization code grant.
+// Tokens are signed like the local provider's, so services validate them the same way. Client
+// tokens carry client_id and scope claims instead of a user. Tokens issued to a client for a
+// user carry the user too, but say they're delegated in "token_use", so the local provider
+// doesn't take them for the user's own login; the client can only use them where scopes are
+// checked. Other tokens are passed on to the tokens provider.
+type Provider struct {
+	config          Config
+	userStore       local.UserStore
+	tokenUtil       *jwt.Util
+	tokens          auth.Provider
+	clientStore     ClientStore
+	codeStore       CodeStore
+	revocationStore local.TokenRevocationStore
+}
+
+// Option configures optional provider dependencies
+type Option func(*Provider)
+
+// stores registered clients in the given store instead of in memory
+func WithClientStore(store ClientStore) Option {
+	return func(p *Provider) {
+		p.clientStore = store
+	}
+}
+
+// stores authorization codes in the given store instead of in memory
+func WithCodeStore(store CodeStore) Option {
+	return func(p *Provider) {
+		p.codeStore = store
+	}
+}
+
+// records revoked client tokens in the given store instead of in memory. Sharing the
+// local provider's store lets its logout endpoint revoke client tokens too, and makes
+// delegated tokens honour the users' "logout everywhere".
+func WithRevocationStore(store local.TokenRevocationStore) Option {
+	return func(p *Provider) {
+		p.revocationStore = store
+	}
+}
+
+// creates a new authorization server for the users in userStore that signs tokens with tokenUtil, usually
+// the local provider's. Token operations on tokens that weren't issued to a client are passed on to tokens.
+func NewProvider(config Config, userStore local.UserStore, tokenUtil *jwt.Util, tokens auth.Provider, options ...Option) *Provider {
+	p := &Provider{
+		config:          config,
+		userStore:       userStore,
+		tokenUtil:       tokenUtil,
+		tokens:          tokens,
+		clientStore:     NewMemoryClientStore(),
+		codeStore:       NewMemoryCodeStore(),
+		revocationStore: local.NewMemoryTokenStore(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// returns the provider identifier
+func (p *Provider) Name() string {
+	return "oauthserver"
+}
+
+// authenticates a client with its ID, given as creds.Username, and secret, given as creds.Password.
+// The user returned stands for the client; its ID is the client ID.
+func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	if creds.Type != "client_credentials" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	client, err := p.AuthenticateClient(ctx, creds.Username, creds.Password)
+	if err != nil {
+		return nil, err
+	}
+	return clientUser(client, client.Scopes), nil
+}
+
+// validates an access token issued to a client, or passes any other token on to the tokens provider.
+// The user returned for a client token stands for the client; the one returned for a delegated token
+// is the user. Either way the client and the token's scopes are in its metadata, for callers to check.
+func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
+	claims, err := p.tokenUtil.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	
+	clientID, ok := issuedToClient(claims)
+	if !ok {
+		return p.tokens.ValidateToken(ctx, token)
+	}
+	
+	tokenID, _ := claims["jti"].(string)
+	revoked, err := p.revocationStore.IsRevoked(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, jwt.ErrInvalidToken
+	}
+	
+	// Tokens of deleted clients stop working straight away
+	client, err := p.clientStore.Get(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	scope, _ := claims["scope"].(string)
+	if _, isClientToken := clientTokenClaims(claims); isClientToken {
+		return clientUser(client, strings.Fields(scope)), nil
+	}
+	return p.delegatingUser(ctx, claims, client, strings.Fields(scope))
+}
+
+// refreshes a token with the tokens provider. Tokens issued to clients can't be refreshed;
+// clients ask the token endpoint for a new one.
+func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
+	if claims, err := p.tokenUtil.ValidateToken(token); err == nil {
+		if _, ok := issuedToClient(claims); ok {
+			return "", auth.ErrInvalidCredentials
+		}
+	}
+	return p.tokens.RefreshToken(ctx, token)
+}
+
+// revokes a token issued to a client until it expires, or passes any other token on to the tokens provider
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	claims, err := p.tokenUtil.ValidateToken(token)
+	if errors.Is(err, jwt.ErrExpiredToken) {
+		return nil
+	}
+	if err != nil {
+		return p.tokens.RevokeToken(ctx, token)
+	}
+	
+	if _, ok := issuedToClient(claims); !ok {
+		return p.tokens.RevokeToken(ctx, token)
+	}
+	
+	tokenID, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	return p.revocationStore.RevokeToken(ctx, tokenID, time.Unix(int64(exp), 0))
+}
+
+// returns the user a delegated token acts for. Like the user's own tokens, it stops
+// working when the user logs out everywhere or is deleted.
+func (p *Provider) delegatingUser(ctx context.Context, claims map[string]interface{}, client *Client, scopes []string) (*auth.User, error) {
+	userID, _ := claims["sub"].(string)
+	revokedBefore, err := p.revocationStore.RevokedBefore(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if local.IssuedBefore(claims, revokedBefore) {
+		return nil, jwt.ErrInvalidToken
+	}
+	
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Roles:    user.Roles,
+		Metadata: map[string]interface{}{
+			"provider":  "oauthserver",
+			"client_id": client.ID,
+			"scopes":    scopes,
+		},
+	}, nil
+}
+
+// returns the client ID of a token issued to a client, for itself or on behalf of a user
+func issuedToClient(claims map[string]interface{}) (string, bool) {
+	if clientID, ok := clientTokenClaims(claims); ok {
+		return clientID, true
+	}
+	if use, _ := claims["token_use"].(string); use != tokenUseDelegated {
+		return "", false
+	}
+	clientID, ok := claims["client_id"].(string)
+	return clientID, ok && clientID != ""
+}
+
+// returns the client ID of a token issued to a client for itself, rather than on behalf of a user
+func clientTokenClaims(claims map[string]interface{}) (string, bool) {
+	if _, hasSubject := claims["sub"]; hasSubject {
+		return "", false
+	}
+	clientID, ok := claims["client_id"].(string)
+	return clientID, ok && clientID != ""
+}
+
+// returns the user standing for a client
+func clientUser(client *Client, scopes []string) *auth.User {
+	return &auth.User{
+		ID:       client.ID,
+		Username: client.Name,
+		Metadata: map[string]interface{}{
+			"provider":  "oauthserver",
+			"client_id": client.ID,
+			"scopes":    scopes,
+		},
+	}
+}
@@ -0,0 +1,532 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupProvider creates an authorization server signing with a local provider's key,
+// for a local provider with one user
+func setupProvider(t *testing.T, config oauthserver.Config) (*oauthserver.Provider, *local.ProviderWithRevocation, *local.StoredUser) {
+	userStore := local.NewMemoryUserStore()
+	user := &local.StoredUser{Username: "alice", Email: "alice@example.com", Roles: []string{"user"}}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	localProvider := local.NewProviderWithRevocation(localConfig, userStore, local.NewMemoryTokenStore())
+	return oauthserver.NewProvider(config, userStore, localProvider.TokenUtil(), localProvider), localProvider, user
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	provider, localProvider, _ := setupProvider(t, oauthserver.DefaultConfig())
+	ctx := context.Background()
+	assert.Equal(t, "oauthserver", provider.Name())
+	
+	// 1. Register a client; only a hash of its secret is stored
+	secret, client, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:   " Billing service ",
+		Scopes: []string{"invoices:read", "invoices:write", "invoices:read"},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, "Billing service", client.Name)
+	assert.Equal(t, []string{"invoices:read", "invoices:write"}, client.Scopes)
+	assert.NotContains(t, client.SecretHash, secret)
+	
+	// 2. The client gets a token with all of its scopes, or the ones it asks for
+	token, err := provider.ClientCredentialsGrant(ctx, client.ID, secret, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, time.Hour, token.ExpiresIn)
+	assert.Equal(t, "invoices:read invoices:write", token.Scope)
+	
+	claims, err := localProvider.TokenUtil().ValidateToken(token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, client.ID, claims["client_id"])
+	assert.Equal(t, "invoices:read invoices:write", claims["scope"])
+	assert.NotContains(t, claims, "sub")
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), claims["exp"], 5)
+	
+	token, err = provider.ClientCredentialsGrant(ctx, client.ID, secret, "invoices:read")
+	require.NoError(t, err)
+	assert.Equal(t, "invoices:read", token.Scope)
+	
+	// 3. The token validates as the client, but isn't accepted as a user's token
+	validated, err := provider.ValidateToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, client.ID, validated.ID)
+	assert.Equal(t, "Billing service", validated.Username)
+	assert.Equal(t, client.ID, validated.Metadata["client_id"])
+	assert.Equal(t, []string{"invoices:read"}, validated.Metadata["scopes"])
+	
+	_, err = localProvider.ValidateToken(ctx, token.AccessToken)
+	assert.Error(t, err)
+	
+	_, err = provider.RefreshToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 4. Wrong secrets, unknown clients and scopes the client doesn't have are refused
+	_, err = provider.ClientCredentialsGrant(ctx, client.ID, secret+"x", "")
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.ClientCredentialsGrant(ctx, "unknown", secret, "")
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+	_, err = provider.ClientCredentialsGrant(ctx, client.ID, secret, "invoices:read admin")
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidScope)
+	
+	authenticated, err := provider.Authenticate(ctx, auth.Credentials{Type: "client_credentials", Username: client.ID, Password: secret})
+	require.NoError(t, err)
+	assert.Equal(t, client.ID, authenticated.ID)
+	
+	// 5. Revoked tokens and tokens of deleted clients stop working
+	require.NoError(t, provider.RevokeToken(ctx, token.AccessToken))
+	_, err = provider.ValidateToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+	
+	token, err = provider.ClientCredentialsGrant(ctx, client.ID, secret, "")
+	require.NoError(t, err)
+	require.NoError(t, provider.DeleteClient(ctx, client.ID))
+	_, err = provider.ValidateToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.ErrorIs(t, provider.DeleteClient(ctx, client.ID), oauthserver.ErrClientNotFound)
+}
+
+func TestClientRegistration(t *testing.T) {
+	config := oauthserver.DefaultConfig()
+	config.AllowedScopes = []string{"read", "write"}
+	config.MaxTokenLifetime = time.Hour
+	provider, _, _ := setupProvider(t, config)
+	ctx := context.Background()
+	
+	// 1. Names, scopes and lifetimes are checked
+	_, _, err := provider.CreateClient(ctx, oauthserver.Registration{Name: " "})
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidName)
+	_, _, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "Admin", Scopes: []string{"admin"}})
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidScope)
+	_, _, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "Spaces", Scopes: []string{"read write"}})
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidScope)
+	_, _, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "Long-lived", TokenLifetime: 2 * time.Hour})
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidLifetime)
+	
+	// 2. Redirect URIs must be https, on the user's device or a private-use scheme, and public clients need one
+	for _, redirectURI := range []string{"http://app.example.com/callback", "https:///callback", "https://app.example.com/callback#x", "myapp:/callback", "/callback"} {
+		_, _, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "App", RedirectURIs: []string{redirectURI}})
+		assert.ErrorIs(t, err, oauthserver.ErrInvalidRedirectURI, redirectURI)
+	}
+	_, _, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "SPA", Public: true})
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidRedirectURI)
+	
+	secret, client, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:         "SPA",
+		RedirectURIs: []string{"https://app.example.com/callback", "http://127.0.0.1:8080/cb", "com.example.app:/callback"},
+		Public:       true,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, secret)
+	assert.Empty(t, client.SecretHash)
+	assert.Len(t, client.RedirectURIs, 3)
+	
+	// Public clients can't use the client credentials grant
+	_, err = provider.ClientCredentialsGrant(ctx, client.ID, "", "")
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+	require.NoError(t, provider.DeleteClient(ctx, client.ID))
+	
+	// 3. A client's own lifetime applies to its tokens
+	secret, client, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "Reports", Scopes: []string{"read"}, TokenLifetime: 5 * time.Minute})
+	require.NoError(t, err)
+	token, err := provider.ClientCredentialsGrant(ctx, client.ID, secret, "")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, token.ExpiresIn)
+	
+	// 4. Clients are listed oldest first
+	_, _, err = provider.CreateClient(ctx, oauthserver.Registration{Name: "Exports"})
+	require.NoError(t, err)
+	clients, err := provider.ListClients(ctx)
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	assert.Equal(t, "Reports", clients[0].Name)
+	assert.Equal(t, "Exports", clients[1].Name)
+}
+
+func TestUserTokensPassThrough(t *testing.T) {
+	provider, localProvider, user := setupProvider(t, oauthserver.DefaultConfig())
+	ctx := context.Background()
+	
+	// Tokens issued to users are validated and revoked by the local provider
+	pair, err := localProvider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	validated, err := provider.ValidateToken(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	
+	require.NoError(t, provider.RevokeToken(ctx, pair.AccessToken))
+	_, err = localProvider.ValidateToken(ctx, pair.AccessToken)
+	assert.Error(t, err)
+}
+
+// the PKCE code verifier web apps in the tests use, and its S256 challenge
+const codeVerifier = "dBjftJeZ4CVP-mJ92K9BuBnr-FqeBNeXXtAz_xwa9V0"
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	provider, localProvider, user := setupProvider(t, oauthserver.DefaultConfig())
+	ctx := context.Background()
+	
+	_, client, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:         "Web app",
+		Scopes:       []string{"profile", "email"},
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Public:       true,
+	})
+	require.NoError(t, err)
+	
+	request := oauthserver.AuthorizationRequest{
+		ClientID:            client.ID,
+		RedirectURI:         "https://app.example.com/callback",
+		ResponseType:        "code",
+		Scope:               "profile",
+		State:               "af0ifjsldkj",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       codeChallenge(codeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+	
+	// 1. The request is checked before the user logs in
+	checked, scopes, err := provider.CheckAuthorizationRequest(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, client.ID, checked.ID)
+	assert.Equal(t, []string{"profile"}, scopes)
+	
+	// 2. The code is exchanged for a token for the user, with the scopes granted
+	authTime := time.Now()
+	code, err := provider.IssueAuthorizationCode(ctx, request, &auth.User{ID: user.ID}, authTime)
+	require.NoError(t, err)
+	
+	token, err := provider.AuthorizationCodeGrant(ctx, client.ID, "", code, request.RedirectURI, codeVerifier)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, "profile", token.Scope)
+	
+	claims, err := localProvider.TokenUtil().ValidateToken(token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claims["sub"])
+	assert.Equal(t, client.ID, claims["client_id"])
+	assert.Equal(t, "profile", claims["scope"])
+	assert.Equal(t, "oauth_access", claims["token_use"])
+	
+	// The token acts for the user, but only where its client and scopes are checked;
+	// it isn't a login's token
+	validated, err := provider.ValidateToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	assert.Equal(t, "alice", validated.Username)
+	assert.Equal(t, client.ID, validated.Metadata["client_id"])
+	assert.Equal(t, []string{"profile"}, validated.Metadata["scopes"])
+	
+	_, err = localProvider.ValidateToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.RefreshToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 3. Codes work once
+	_, err = provider.AuthorizationCodeGrant(ctx, client.ID, "", code, request.RedirectURI, codeVerifier)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidCode)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidGrant)
+	
+	// 4. The verifier, redirect URI and client must match the request; a failed exchange uses up the code
+	for _, attempt := range []struct {
+		clientID, redirectURI, verifier string
+		err                             error
+	}{
+		{client.ID, request.RedirectURI, codeVerifier[:42] + "x", oauthserver.ErrInvalidGrant},
+		{client.ID, request.RedirectURI, "short", oauthserver.ErrInvalidGrant},
+		{client.ID, "https://app.example.com/other", codeVerifier, oauthserver.ErrInvalidGrant},
+		{"unknown", request.RedirectURI, codeVerifier, oauthserver.ErrInvalidClient},
+	} {
+		code, err := provider.IssueAuthorizationCode(ctx, request, &auth.User{ID: user.ID}, authTime)
+		require.NoError(t, err)
+		_, err = provider.AuthorizationCodeGrant(ctx, attempt.clientID, "", code, attempt.redirectURI, attempt.verifier)
+		assert.ErrorIs(t, err, attempt.err)
+	}
+	
+	_, err = provider.AuthorizationCodeGrant(ctx, client.ID, "", "", request.RedirectURI, codeVerifier)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidRequest)
+	
+	// 5. Confidential clients authenticate with their secret as well
+	secret, confidential, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:         "Server app",
+		RedirectURIs: []string{"https://server.example.com/callback"},
+	})
+	require.NoError(t, err)
+	request.ClientID = confidential.ID
+	request.RedirectURI = "https://server.example.com/callback"
+	request.Scope = ""
+	
+	code, err = provider.IssueAuthorizationCode(ctx, request, &auth.User{ID: user.ID}, authTime)
+	require.NoError(t, err)
+	_, err = provider.AuthorizationCodeGrant(ctx, confidential.ID, "", code, request.RedirectURI, codeVerifier)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+	
+	code, err = provider.IssueAuthorizationCode(ctx, request, &auth.User{ID: user.ID}, authTime)
+	require.NoError(t, err)
+	token, err = provider.AuthorizationCodeGrant(ctx, confidential.ID, secret, code, request.RedirectURI, codeVerifier)
+	require.NoError(t, err)
+	assert.Empty(t, token.Scope)
+	
+	// 6. Tokens issued for users stop working when revoked or when their client is deleted
+	_, err = provider.ValidateToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, provider.RevokeToken(ctx, token.AccessToken))
+	_, err = provider.ValidateToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+	
+	code, err = provider.IssueAuthorizationCode(ctx, request, &auth.User{ID: user.ID}, authTime)
+	require.NoError(t, err)
+	token, err = provider.AuthorizationCodeGrant(ctx, confidential.ID, secret, code, request.RedirectURI, codeVerifier)
+	require.NoError(t, err)
+	require.NoError(t, provider.DeleteClient(ctx, confidential.ID))
+	_, err = provider.ValidateToken(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAuthorizationRequestChecks(t *testing.T) {
+	provider, _, _ := setupProvider(t, oauthserver.DefaultConfig())
+	ctx := context.Background()
+	
+	_, client, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:         "Web app",
+		Scopes:       []string{"profile"},
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Public:       true,
+	})
+	require.NoError(t, err)
+	
+	valid := oauthserver.AuthorizationRequest{
+		ClientID:            client.ID,
+		RedirectURI:         "https://app.example.com/callback",
+		ResponseType:        "code",
+		CodeChallenge:       codeChallenge(codeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+	
+	// Problems with the client or redirect URI can't be reported to the client
+	request := valid
+	request.ClientID = "unknown"
+	_, _, err = provider.CheckAuthorizationRequest(ctx, request)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+	
+	for _, redirectURI := range []string{"", "https://app.example.com/callback/", "https://app.example.com/callback?x=1"} {
+		request = valid
+		request.RedirectURI = redirectURI
+		_, _, err = provider.CheckAuthorizationRequest(ctx, request)
+		assert.ErrorIs(t, err, oauthserver.ErrInvalidRedirectURI, redirectURI)
+	}
+	
+	// Other problems are
+	for _, test := range []struct {
+		change func(*oauthserver.AuthorizationRequest)
+		code   string
+	}{
+		{func(r *oauthserver.AuthorizationRequest) { r.ResponseType = "token" }, "unsupported_response_type"},
+		{func(r *oauthserver.AuthorizationRequest) { r.CodeChallenge = "" }, "invalid_request"},
+		{func(r *oauthserver.AuthorizationRequest) { r.CodeChallengeMethod = "plain" }, "invalid_request"},
+		{func(r *oauthserver.AuthorizationRequest) { r.CodeChallenge = "too-short" }, "invalid_request"},
+		{func(r *oauthserver.AuthorizationRequest) { r.Scope = "profile admin" }, "invalid_scope"},
+	} {
+		request = valid
+		test.change(&request)
+		_, _, err = provider.CheckAuthorizationRequest(ctx, request)
+		
+		var authorizationErr *oauthserver.AuthorizationError
+		require.True(t, errors.As(err, &authorizationErr), test.code)
+		assert.Equal(t, test.code, authorizationErr.Code)
+	}
+	
+	// Without a scope, the client gets all of its scopes
+	_, scopes, err := provider.CheckAuthorizationRequest(ctx, valid)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"profile"}, scopes)
+}
+
+func TestOpenIDConnect(t *testing.T) {
+	config := oauthserver.DefaultConfig()
+	config.Issuer = "https://auth.example.com/"
+	provider, localProvider, user := setupProvider(t, config)
+	ctx := context.Background()
+	
+	_, client, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:         "Web app",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Public:       true,
+	})
+	require.NoError(t, err)
+	
+	// authorize runs a user through the code flow with the given scopes
+	authorize := func(scope string, nonce string, authTime time.Time) *oauthserver.Token {
+		request := oauthserver.AuthorizationRequest{
+			ClientID:            client.ID,
+			RedirectURI:         "https://app.example.com/callback",
+			ResponseType:        "code",
+			Scope:               scope,
+			Nonce:               nonce,
+			CodeChallenge:       codeChallenge(codeVerifier),
+			CodeChallengeMethod: "S256",
+		}
+		code, err := provider.IssueAuthorizationCode(ctx, request, &auth.User{ID: user.ID}, authTime)
+		require.NoError(t, err)
+		token, err := provider.AuthorizationCodeGrant(ctx, client.ID, "", code, request.RedirectURI, codeVerifier)
+		require.NoError(t, err)
+		return token
+	}
+	
+	// 1. The discovery document points at the endpoints under the issuer
+	discovery := provider.OpenIDConfiguration()
+	assert.Equal(t, "https://auth.example.com", discovery.Issuer)
+	assert.Equal(t, "https://auth.example.com/oauth/authorize", discovery.AuthorizationEndpoint)
+	assert.Equal(t, "https://auth.example.com/userinfo", discovery.UserInfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", discovery.JWKSURI)
+	assert.Equal(t, []string{"S256"}, discovery.CodeChallengeMethodsSupported)
+	assert.Equal(t, []string{"HS256"}, discovery.IDTokenSigningAlgValuesSupported)
+	
+	// 2. The openid scope gets an ID token for the client, with the claims the other scopes allow;
+	// OIDC scopes don't have to be registered for the client
+	authTime := time.Now().Add(-time.Minute)
+	token := authorize("openid profile email", "n-0S6_WzA2Mj", authTime)
+	require.NotEmpty(t, token.IDToken)
+	
+	claims, err := localProvider.TokenUtil().ValidateToken(token.IDToken)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", claims["iss"])
+	assert.Equal(t, client.ID, claims["aud"])
+	assert.Equal(t, user.ID, claims["sub"])
+	assert.Equal(t, "alice", claims["preferred_username"])
+	assert.Equal(t, "alice@example.com", claims["email"])
+	assert.Equal(t, false, claims["email_verified"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, float64(authTime.Unix()), claims["auth_time"])
+	
+	// ID tokens can't be used as access tokens
+	_, err = provider.ValidateToken(ctx, token.IDToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = provider.UserInfo(ctx, token.IDToken)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidAccessToken)
+	
+	// 3. Userinfo returns the same claims, read from the user store
+	info, err := provider.UserInfo(ctx, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"sub":                user.ID,
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     false,
+	}, info)
+	
+	// 4. With only the openid scope, the user's ID is all the client learns
+	token = authorize("openid", "", authTime)
+	claims, err = localProvider.TokenUtil().ValidateToken(token.IDToken)
+	require.NoError(t, err)
+	assert.NotContains(t, claims, "email")
+	assert.NotContains(t, claims, "preferred_username")
+	assert.NotContains(t, claims, "nonce")
+	
+	info, err = provider.UserInfo(ctx, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"sub": user.ID}, info)
+	
+	// 5. Without the openid scope there's no ID token, and no user info
+	token = authorize("profile", "", authTime)
+	assert.Empty(t, token.IDToken)
+	_, err = provider.UserInfo(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, oauthserver.ErrInsufficientScope)
+	
+	// 6. Revoked access tokens get no user info
+	token = authorize("openid", "", authTime)
+	require.NoError(t, provider.RevokeToken(ctx, token.AccessToken))
+	_, err = provider.UserInfo(ctx, token.AccessToken)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidAccessToken)
+}
+
+func TestIntrospection(t *testing.T) {
+	provider, localProvider, user := setupProvider(t, oauthserver.DefaultConfig())
+	ctx := context.Background()
+	
+	secret, gateway, err := provider.CreateClient(ctx, oauthserver.Registration{Name: "Gateway", Scopes: []string{"read"}})
+	require.NoError(t, err)
+	
+	// 1. A client token is active, with its client and scopes but no user
+	clientToken, err := provider.ClientCredentialsGrant(ctx, gateway.ID, secret, "")
+	require.NoError(t, err)
+	
+	introspection, err := provider.Introspect(ctx, gateway.ID, secret, clientToken.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, gateway.ID, introspection.ClientID)
+	assert.Equal(t, "read", introspection.Scope)
+	assert.Empty(t, introspection.Subject)
+	assert.Empty(t, introspection.Username)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), introspection.ExpiresAt, time.Minute)
+	
+	// 2. A user's token is active, with the user
+	pair, err := localProvider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	
+	introspection, err = provider.Introspect(ctx, gateway.ID, secret, pair.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, user.ID, introspection.Subject)
+	assert.Equal(t, "alice", introspection.Username)
+	assert.Empty(t, introspection.ClientID)
+	
+	// 3. Revoked, expired and malformed tokens are inactive, and say nothing else
+	require.NoError(t, provider.RevokeToken(ctx, pair.AccessToken))
+	require.NoError(t, provider.RevokeToken(ctx, clientToken.AccessToken))
+	expired, err := localProvider.TokenUtil().GenerateToken(map[string]interface{}{
+		"sub": user.ID,
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	
+	for _, token := range []string{pair.AccessToken, clientToken.AccessToken, expired, "not-a-token"} {
+		introspection, err = provider.Introspect(ctx, gateway.ID, secret, token)
+		require.NoError(t, err)
+		assert.Equal(t, &oauthserver.Introspection{}, introspection)
+	}
+	
+	// 4. Tokens of deleted users are inactive
+	pair, err = localProvider.IssueTokens(ctx, &auth.User{ID: user.ID, Username: user.Username}, local.ClientInfo{})
+	require.NoError(t, err)
+	require.NoError(t, localProvider.DeleteUser(ctx, user.ID))
+	introspection, err = provider.Introspect(ctx, gateway.ID, secret, pair.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, introspection.Active)
+	
+	// 5. Only clients with a secret may ask
+	_, err = provider.Introspect(ctx, gateway.ID, secret+"x", pair.AccessToken)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+	
+	_, public, err := provider.CreateClient(ctx, oauthserver.Registration{
+		Name:         "Web app",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Public:       true,
+	})
+	require.NoError(t, err)
+	_, err = provider.Introspect(ctx, public.ID, "", pair.AccessToken)
+	assert.ErrorIs(t, err, oauthserver.ErrInvalidClient)
+}
@@ -0,0 +1,138 @@
+package oauthserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+)
+
+var (
+	ErrInvalidGrant   = errors.New("invalid grant")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Token is a token endpoint response
+type Token struct {
+	AccessToken string
+	TokenType   string
+	ExpiresIn   time.Duration
+	Scope       string // Space-separated scopes granted
+	IDToken     string // For the authorization code grant with the openid scope
+}
+
+// issues an access token to a client for itself, after checking its secret. scope is
+// the space-separated scopes asked for; all of the client's scopes if empty.
+func (p *Provider) ClientCredentialsGrant(ctx context.Context, clientID string, secret string, scope string) (*Token, error) {
+	client, err := p.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return nil, err
+	}
+	
+	scopes, err := grantedScopes(client, scope)
+	if err != nil {
+		return nil, err
+	}
+	
+	return p.issueToken(client, strings.Join(scopes, " "), nil)
+}
+
+// exchanges an authorization code for an access token for the user who approved the client's request,
+// plus an ID token if the client asked for the openid scope. The client proves it started the request
+// with the PKCE code verifier, and confidential clients authenticate with their secret too. Codes work once.
+func (p *Provider) AuthorizationCodeGrant(ctx context.Context, clientID string, secret string, code string, redirectURI string, codeVerifier string) (*Token, error) {
+	client, err := p.identifyClient(ctx, clientID, secret)
+	if err != nil {
+		return nil, err
+	}
+	
+	if code == "" || redirectURI == "" || codeVerifier == "" {
+		return nil, fmt.Errorf("%w: code, redirect_uri and code_verifier are required", ErrInvalidRequest)
+	}
+	
+	stored, err := p.codeStore.Consume(ctx, hashSecret(code))
+	if err != nil {
+		return nil, err
+	}
+	
+	if stored.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: code was issued to another client", ErrInvalidGrant)
+	}
+	if stored.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("%w: redirect_uri doesn't match the authorization request", ErrInvalidGrant)
+	}
+	if !validPKCEValue(codeVerifier, 43) || subtle.ConstantTimeCompare([]byte(s256(codeVerifier)), []byte(stored.CodeChallenge)) != 1 {
+		return nil, fmt.Errorf("%w: code_verifier doesn't match the code challenge", ErrInvalidGrant)
+	}
+	
+	user, err := p.userStore.GetByID(ctx, stored.UserID)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	token, err := p.issueToken(client, stored.Scope, map[string]interface{}{
+		"sub":   user.ID,
+		"roles": user.Roles,
+		"email": user.Email,
+		"name":  user.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	if contains(strings.Fields(stored.Scope), ScopeOpenID) {
+		if token.IDToken, err = p.issueIDToken(client, user, stored, token.ExpiresIn); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// issues an access token to a client, for itself or, with userClaims, for a user.
+// Tokens for a user are marked as delegated so they're only accepted where scopes are checked.
+func (p *Provider) issueToken(client *Client, scope string, userClaims map[string]interface{}) (*Token, error) {
+	lifetime := client.TokenLifetime
+	if lifetime == 0 {
+		lifetime = p.config.TokenExpiration
+	}
+	
+	claims := map[string]interface{}{
+		"client_id": client.ID,
+		"scope":     scope,
+		"exp":       time.Now().Add(lifetime).Unix(),
+		"provider":  p.Name(),
+	}
+	if userClaims != nil {
+		for key, value := range userClaims {
+			claims[key] = value
+		}
+		claims["token_use"] = tokenUseDelegated
+	}
+	
+	token, err := p.tokenUtil.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   lifetime,
+		Scope:       scope,
+	}, nil
+}
+
+// returns the PKCE S256 code challenge for a code verifier
+func s256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,37 @@
+package webauthn
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidChallenge = errors.New("invalid or expired WebAuthn challenge")
+
+// Ceremonies a challenge can be issued for. A challenge can only be used for the ceremony it was issued for.
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+	CeremonySecondFactor = "second_factor"
+)
+
+// Challenge is the server-side record of a ceremony in progress.
+// Only a hash of the challenge is stored.
+type Challenge struct {
+	ChallengeHash string
+	UserID        string // Empty for passwordless logins that don't name the user up front
+	Ceremony      string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+// ChallengeStore persists the challenges of ceremonies in progress
+type ChallengeStore interface {
+	Create(ctx context.Context, challenge *Challenge) error
+	
+	// atomically deletes and returns the challenge.
+	// Returns ErrInvalidChallenge if it doesn't exist, has expired or was issued for another ceremony.
+	Consume(ctx context.Context, challengeHash string, ceremony string) (*Challenge, error)
+	
+	CleanupExpiredChallenges(ctx context.Context) (int64, error)
+}
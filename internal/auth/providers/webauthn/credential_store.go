@@ -0,0 +1,44 @@
+package webauthn
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrCredentialNotFound = errors.New("credential not found")
+	ErrCredentialExists   = errors.New("credential already registered")
+)
+
+// Credential is a passkey or security key registered to a user
+type Credential struct {
+	ID             string // Base64url credential ID, as sent by browsers
+	UserID         string
+	Name           string // Label chosen by the user, e.g. "YubiKey" or "Laptop"
+	PublicKey      []byte // COSE_Key
+	SignCount      uint32 // Highest signature counter seen, to detect cloned authenticators
+	Transports     []string
+	BackupEligible bool // Synced passkey that can be restored on other devices
+	BackupState    bool
+	CreatedAt      time.Time
+	LastUsedAt     time.Time // Zero until the credential is first used to log in
+}
+
+// CredentialStore persists registered credentials
+type CredentialStore interface {
+	// returns ErrCredentialExists if a credential with the same ID is already registered
+	Create(ctx context.Context, credential *Credential) error
+	
+	// returns ErrCredentialNotFound if the credential does not exist
+	Get(ctx context.Context, id string) (*Credential, error)
+	
+	// returns the user's credentials, oldest first
+	ListByUser(ctx context.Context, userID string) ([]*Credential, error)
+	
+	// records a login with the credential
+	UpdateUsage(ctx context.Context, id string, signCount uint32, backupState bool, usedAt time.Time) error
+	
+	// removes one of the user's credentials; returns ErrCredentialNotFound if the user has no such credential
+	Delete(ctx context.Context, userID string, id string) error
+}
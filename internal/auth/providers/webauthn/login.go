@@ -0,0 +1,159 @@
+package webauthn
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+)
+
+var ErrSignCountRegression = errors.New("signature counter did not increase; the authenticator may have been cloned")
+
+// starts a passwordless login. The options are passed to navigator.credentials.get() in the browser.
+// With a username, the browser is asked for one of that user's credentials; without one, the user
+// picks one of their discoverable passkeys. Unknown usernames are treated like no username.
+func (p *Provider) BeginLogin(ctx context.Context, username string) (*protocol.RequestOptions, error) {
+	var userID string
+	var allowed []*Credential
+	if username != "" {
+		user, err := p.userStore.GetByUsername(ctx, username)
+		if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			userID = user.ID
+			if allowed, err = p.credentialStore.ListByUser(ctx, user.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	
+	challenge, err := p.newChallenge(ctx, CeremonyLogin, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	userVerification := protocol.UserVerificationPreferred
+	if p.config.RequireUserVerification {
+		userVerification = protocol.UserVerificationRequired
+	}
+	
+	return &protocol.RequestOptions{
+		Challenge:        challenge,
+		Timeout:          p.timeout(),
+		RPID:             p.config.RPID,
+		AllowCredentials: descriptors(allowed),
+		UserVerification: userVerification,
+	}, nil
+}
+
+// starts checking a credential as the second factor of a user who entered their password.
+// Returns ErrCredentialNotFound if the user has no credentials.
+func (p *Provider) BeginSecondFactor(ctx context.Context, userID string) (*protocol.RequestOptions, error) {
+	credentials, err := p.credentialStore.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	
+	challenge, err := p.newChallenge(ctx, CeremonySecondFactor, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The password was the first factor, so plain security keys without a PIN are fine
+	return &protocol.RequestOptions{
+		Challenge:        challenge,
+		Timeout:          p.timeout(),
+		RPID:             p.config.RPID,
+		AllowCredentials: descriptors(credentials),
+		UserVerification: protocol.UserVerificationDiscouraged,
+	}, nil
+}
+
+// checks the response to a BeginSecondFactor challenge, for local.Provider.CompleteMFAChallengeWith.
+// Returns local.ErrInvalidMFACode if it doesn't come from one of the user's credentials.
+func (p *Provider) VerifySecondFactor(ctx context.Context, userID string, response []byte) error {
+	_, err := p.finishAssertion(ctx, response, CeremonySecondFactor, userID, false)
+	if isVerificationError(err) {
+		return local.ErrInvalidMFACode
+	}
+	return err
+}
+
+// verifies a response to a login challenge and records the credential's use.
+// If userID is set, the challenge must have been issued to that user.
+func (p *Provider) finishAssertion(ctx context.Context, response []byte, ceremony string, userID string, requireUserVerification bool) (*Credential, error) {
+	assertion, err := protocol.ParseCredentialAssertion(response)
+	if err != nil {
+		return nil, err
+	}
+	
+	challenge, err := assertion.Challenge()
+	if err != nil {
+		return nil, err
+	}
+	
+	stored, err := p.challengeStore.Consume(ctx, hashChallenge(challenge), ceremony)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && stored.UserID != userID {
+		return nil, ErrInvalidChallenge
+	}
+	
+	credential, err := p.credentialStore.Get(ctx, assertion.ID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The credential has to belong to the user the challenge was issued to, and to the user
+	// the authenticator says it belongs to. Discoverable logins only have the latter.
+	userHandle := string(assertion.Response.UserHandle)
+	if stored.UserID != "" && credential.UserID != stored.UserID {
+		return nil, ErrCredentialNotFound
+	}
+	if (stored.UserID == "" || userHandle != "") && userHandle != credential.UserID {
+		return nil, ErrCredentialNotFound
+	}
+	
+	authData, err := p.rp.VerifyAssertion(assertion, challenge, credential.PublicKey, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Authenticators that count signatures must count up, or the key may have been copied.
+	// Synced passkeys always report zero.
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return nil, ErrSignCountRegression
+	}
+	
+	now := time.Now()
+	if err := p.credentialStore.UpdateUsage(ctx, credential.ID, authData.SignCount, authData.BackupState(), now); err != nil {
+		return nil, err
+	}
+	credential.SignCount = authData.SignCount
+	credential.BackupState = authData.BackupState()
+	credential.LastUsedAt = now
+	
+	return credential, nil
+}
+
+// reports whether err means the response was rejected, as opposed to a storage failure
+func isVerificationError(err error) bool {
+	return errors.Is(err, protocol.ErrVerificationFailed) ||
+		errors.Is(err, ErrInvalidChallenge) ||
+		errors.Is(err, ErrCredentialNotFound) ||
+		errors.Is(err, ErrSignCountRegression)
+}
+
+// decodes a stored credential ID
+func decodeCredentialID(id string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(id)
+}
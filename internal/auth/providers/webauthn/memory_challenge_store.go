@@ -0,0 +1,64 @@
+package webauthn
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryChallengeStore implements ChallengeStore with in-memory storage
+type MemoryChallengeStore struct {
+	challenges map[string]*Challenge // Indexed by challenge hash
+	mu         sync.Mutex
+}
+
+// NewMemoryChallengeStore creates a new in-memory challenge store
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{
+		challenges: make(map[string]*Challenge),
+	}
+}
+
+// Create stores a new challenge
+func (s *MemoryChallengeStore) Create(ctx context.Context, challenge *Challenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	challengeCopy := *challenge
+	s.challenges[challenge.ChallengeHash] = &challengeCopy
+	return nil
+}
+
+// Consume deletes and returns a challenge
+func (s *MemoryChallengeStore) Consume(ctx context.Context, challengeHash string, ceremony string) (*Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	challenge, exists := s.challenges[challengeHash]
+	if !exists || challenge.Ceremony != ceremony {
+		return nil, ErrInvalidChallenge
+	}
+	
+	delete(s.challenges, challengeHash)
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidChallenge
+	}
+	
+	return challenge, nil
+}
+
+// CleanupExpiredChallenges removes expired challenges
+func (s *MemoryChallengeStore) CleanupExpiredChallenges(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	var count int64
+	for hash, challenge := range s.challenges {
+		if now.After(challenge.ExpiresAt) {
+			delete(s.challenges, hash)
+			count++
+		}
+	}
+	return count, nil
+}
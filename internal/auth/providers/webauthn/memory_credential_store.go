@@ -0,0 +1,103 @@
+package webauthn
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryCredentialStore implements CredentialStore with in-memory storage
+type MemoryCredentialStore struct {
+	credentials map[string]*Credential // Indexed by credential ID
+	mu          sync.RWMutex
+}
+
+// NewMemoryCredentialStore creates a new in-memory credential store
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		credentials: make(map[string]*Credential),
+	}
+}
+
+// Create stores a new credential
+func (s *MemoryCredentialStore) Create(ctx context.Context, credential *Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.credentials[credential.ID]; exists {
+		return ErrCredentialExists
+	}
+	
+	s.credentials[credential.ID] = cloneCredential(credential)
+	return nil
+}
+
+// Get retrieves a credential by ID
+func (s *MemoryCredentialStore) Get(ctx context.Context, id string) (*Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	credential, exists := s.credentials[id]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+	
+	return cloneCredential(credential), nil
+}
+
+// ListByUser returns the user's credentials, oldest first
+func (s *MemoryCredentialStore) ListByUser(ctx context.Context, userID string) ([]*Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	credentials := make([]*Credential, 0)
+	for _, credential := range s.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, cloneCredential(credential))
+		}
+	}
+	
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+// UpdateUsage records a login with the credential
+func (s *MemoryCredentialStore) UpdateUsage(ctx context.Context, id string, signCount uint32, backupState bool, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	credential, exists := s.credentials[id]
+	if !exists {
+		return ErrCredentialNotFound
+	}
+	
+	credential.SignCount = signCount
+	credential.BackupState = backupState
+	credential.LastUsedAt = usedAt
+	return nil
+}
+
+// Delete removes one of the user's credentials
+func (s *MemoryCredentialStore) Delete(ctx context.Context, userID string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	credential, exists := s.credentials[id]
+	if !exists || credential.UserID != userID {
+		return ErrCredentialNotFound
+	}
+	
+	delete(s.credentials, id)
+	return nil
+}
+
+// cloneCredential returns a copy so callers can't modify stored credentials
+func cloneCredential(credential *Credential) *Credential {
+	credentialCopy := *credential
+	credentialCopy.PublicKey = append([]byte(nil), credential.PublicKey...)
+	credentialCopy.Transports = append([]string(nil), credential.Transports...)
+	return &credentialCopy
+}
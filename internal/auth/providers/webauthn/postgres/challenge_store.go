@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn"
+	"github.com/jmoiron/sqlx"
+)
+
+// ChallengeStore implements webauthn.ChallengeStore with PostgreSQL
+type ChallengeStore struct {
+	db *sqlx.DB
+}
+
+// challengeRow represents a row in the webauthn_challenges table
+type challengeRow struct {
+	ChallengeHash string         `db:"challenge_hash"`
+	UserID        sql.NullString `db:"user_id"`
+	Ceremony      string         `db:"ceremony"`
+	CreatedAt     time.Time      `db:"created_at"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+}
+
+// NewChallengeStore creates a new PostgreSQL-backed challenge store
+func NewChallengeStore(db *sqlx.DB) *ChallengeStore {
+	return &ChallengeStore{
+		db: db,
+	}
+}
+
+// Create stores a new challenge
+func (s *ChallengeStore) Create(ctx context.Context, challenge *webauthn.Challenge) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webauthn_challenges (challenge_hash, user_id, ceremony, created_at, expires_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)`,
+		challenge.ChallengeHash, challenge.UserID, challenge.Ceremony, challenge.CreatedAt, challenge.ExpiresAt)
+	
+	return err
+}
+
+// Consume deletes and returns a challenge in a single statement, so it can only be used once
+func (s *ChallengeStore) Consume(ctx context.Context, challengeHash string, ceremony string) (*webauthn.Challenge, error) {
+	var row challengeRow
+	err := s.db.GetContext(ctx, &row, `
+		DELETE FROM webauthn_challenges WHERE challenge_hash = $1 AND ceremony = $2
+		RETURNING challenge_hash, user_id, ceremony, created_at, expires_at`,
+		challengeHash, ceremony)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webauthn.ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	
+	if time.Now().After(row.ExpiresAt) {
+		return nil, webauthn.ErrInvalidChallenge
+	}
+	
+	return &webauthn.Challenge{
+		ChallengeHash: row.ChallengeHash,
+		UserID:        row.UserID.String,
+		Ceremony:      row.Ceremony,
+		CreatedAt:     row.CreatedAt,
+		ExpiresAt:     row.ExpiresAt,
+	}, nil
+}
+
+// CleanupExpiredChallenges removes expired challenges
+func (s *ChallengeStore) CleanupExpiredChallenges(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM webauthn_challenges WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CredentialStore implements webauthn.CredentialStore with PostgreSQL
+type CredentialStore struct {
+	db *sqlx.DB
+}
+
+// credentialRow represents a row in the webauthn_credentials table
+type credentialRow struct {
+	ID             string         `db:"id"`
+	UserID         string         `db:"user_id"`
+	Name           string         `db:"name"`
+	PublicKey      []byte         `db:"public_key"`
+	SignCount      int64          `db:"sign_count"`
+	Transports     pq.StringArray `db:"transports"`
+	BackupEligible bool           `db:"backup_eligible"`
+	BackupState    bool           `db:"backup_state"`
+	CreatedAt      time.Time      `db:"created_at"`
+	LastUsedAt     sql.NullTime   `db:"last_used_at"`
+}
+
+// NewCredentialStore creates a new PostgreSQL-backed credential store
+func NewCredentialStore(db *sqlx.DB) *CredentialStore {
+	return &CredentialStore{
+		db: db,
+	}
+}
+
+// Create stores a new credential
+func (s *CredentialStore) Create(ctx context.Context, credential *webauthn.Credential) error {
+	transports := pq.StringArray(credential.Transports)
+	if transports == nil {
+		transports = pq.StringArray{}
+	}
+	
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webauthn_credentials (id, user_id, name, public_key, sign_count, transports,
+			backup_eligible, backup_state, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		credential.ID, credential.UserID, credential.Name, credential.PublicKey, int64(credential.SignCount),
+		transports, credential.BackupEligible, credential.BackupState, credential.CreatedAt)
+	
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return webauthn.ErrCredentialExists
+	}
+	return err
+}
+
+// Get retrieves a credential by ID
+func (s *CredentialStore) Get(ctx context.Context, id string) (*webauthn.Credential, error) {
+	var row credentialRow
+	err := s.db.GetContext(ctx, &row, "SELECT * FROM webauthn_credentials WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webauthn.ErrCredentialNotFound
+		}
+		return nil, err
+	}
+	
+	return row.toCredential(), nil
+}
+
+// ListByUser returns the user's credentials, oldest first
+func (s *CredentialStore) ListByUser(ctx context.Context, userID string) ([]*webauthn.Credential, error) {
+	var rows []credentialRow
+	err := s.db.SelectContext(ctx, &rows,
+		"SELECT * FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	credentials := make([]*webauthn.Credential, 0, len(rows))
+	for i := range rows {
+		credentials = append(credentials, rows[i].toCredential())
+	}
+	return credentials, nil
+}
+
+// UpdateUsage records a login with the credential
+func (s *CredentialStore) UpdateUsage(ctx context.Context, id string, signCount uint32, backupState bool, usedAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE webauthn_credentials SET sign_count = $1, backup_state = $2, last_used_at = $3
+		WHERE id = $4`,
+		int64(signCount), backupState, usedAt, id)
+	if err != nil {
+		return err
+	}
+	
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return webauthn.ErrCredentialNotFound
+	}
+	return nil
+}
+
+// Delete removes one of the user's credentials
+func (s *CredentialStore) Delete(ctx context.Context, userID string, id string) error {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return webauthn.ErrCredentialNotFound
+	}
+	return nil
+}
+
+// toCredential converts a database row to a credential
+func (row *credentialRow) toCredential() *webauthn.Credential {
+	credential := &webauthn.Credential{
+		ID:             row.ID,
+		UserID:         row.UserID,
+		Name:           row.Name,
+		PublicKey:      row.PublicKey,
+		SignCount:      uint32(row.SignCount),
+		Transports:     row.Transports,
+		BackupEligible: row.BackupEligible,
+		BackupState:    row.BackupState,
+		CreatedAt:      row.CreatedAt,
+	}
+	if row.LastUsedAt.Valid {
+		credential.LastUsedAt = row.LastUsedAt.Time
+	}
+	return credential
+}
@@ -0,0 +1,148 @@
+//go:build database
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	localpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDatabase connects to the test database and creates a user to own credentials and challenges
+func setupDatabase(t *testing.T) (*sqlx.DB, *local.StoredUser) {
+	dbConfig := database.DefaultConfig()
+	
+	// Override with environment variables if available
+	if envConfig := database.NewConfigFromEnv(); envConfig.Host != "localhost" {
+		dbConfig = envConfig
+	}
+	
+	db, err := database.Connect(dbConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	
+	err = database.Initialize(db)
+	require.NoError(t, err)
+	
+	userStore := localpg.NewSQLUserStore(db)
+	suffix := time.Now().Format("20060102150405.000000")
+	user := &local.StoredUser{
+		Username:     "webauthn-" + suffix,
+		Email:        "webauthn-" + suffix + "@example.com",
+		PasswordHash: "x",
+	}
+	require.NoError(t, userStore.Create(context.Background(), user))
+	t.Cleanup(func() { userStore.Delete(context.Background(), user.ID) })
+	
+	return db, user
+}
+
+func TestPostgresCredentialStore(t *testing.T) {
+	db, user := setupDatabase(t)
+	store := postgres.NewCredentialStore(db)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	
+	// 1. Store and read back a credential
+	credential := &webauthn.Credential{
+		ID:             "cred-" + user.ID,
+		UserID:         user.ID,
+		Name:           "Laptop",
+		PublicKey:      []byte{0xa5, 0x01, 0x02},
+		SignCount:      3,
+		BackupEligible: true,
+		CreatedAt:      now,
+	}
+	require.NoError(t, store.Create(ctx, credential))
+	assert.ErrorIs(t, store.Create(ctx, credential), webauthn.ErrCredentialExists)
+	
+	stored, err := store.Get(ctx, credential.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.Equal(t, credential.PublicKey, stored.PublicKey)
+	assert.Equal(t, uint32(3), stored.SignCount)
+	assert.Empty(t, stored.Transports)
+	assert.True(t, stored.BackupEligible)
+	assert.True(t, stored.LastUsedAt.IsZero())
+	
+	_, err = store.Get(ctx, "unknown")
+	assert.ErrorIs(t, err, webauthn.ErrCredentialNotFound)
+	
+	// 2. Record a login
+	require.NoError(t, store.UpdateUsage(ctx, credential.ID, 4, true, now))
+	
+	credentials, err := store.ListByUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	assert.Equal(t, uint32(4), credentials[0].SignCount)
+	assert.True(t, credentials[0].BackupState)
+	assert.WithinDuration(t, now, credentials[0].LastUsedAt, time.Second)
+	
+	// 3. Only the owner can delete it
+	assert.ErrorIs(t, store.Delete(ctx, "someone-else", credential.ID), webauthn.ErrCredentialNotFound)
+	require.NoError(t, store.Delete(ctx, user.ID, credential.ID))
+	assert.ErrorIs(t, store.Delete(ctx, user.ID, credential.ID), webauthn.ErrCredentialNotFound)
+}
+
+func TestPostgresChallengeStore(t *testing.T) {
+	db, user := setupDatabase(t)
+	store := postgres.NewChallengeStore(db)
+	ctx := context.Background()
+	now := time.Now()
+	suffix := now.Format("20060102150405.000000")
+	
+	// 1. Challenges can be consumed once, for their ceremony
+	require.NoError(t, store.Create(ctx, &webauthn.Challenge{
+		ChallengeHash: "login-" + suffix,
+		Ceremony:      webauthn.CeremonyLogin,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(time.Minute),
+	}))
+	
+	_, err := store.Consume(ctx, "login-"+suffix, webauthn.CeremonyRegistration)
+	assert.ErrorIs(t, err, webauthn.ErrInvalidChallenge)
+	
+	challenge, err := store.Consume(ctx, "login-"+suffix, webauthn.CeremonyLogin)
+	require.NoError(t, err)
+	assert.Empty(t, challenge.UserID)
+	
+	_, err = store.Consume(ctx, "login-"+suffix, webauthn.CeremonyLogin)
+	assert.ErrorIs(t, err, webauthn.ErrInvalidChallenge)
+	
+	// 2. Challenges issued to a user keep the user
+	require.NoError(t, store.Create(ctx, &webauthn.Challenge{
+		ChallengeHash: "registration-" + suffix,
+		UserID:        user.ID,
+		Ceremony:      webauthn.CeremonyRegistration,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(time.Minute),
+	}))
+	
+	challenge, err = store.Consume(ctx, "registration-"+suffix, webauthn.CeremonyRegistration)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, challenge.UserID)
+	
+	// 3. Expired challenges are refused and cleaned up
+	require.NoError(t, store.Create(ctx, &webauthn.Challenge{
+		ChallengeHash: "expired-" + suffix,
+		Ceremony:      webauthn.CeremonyLogin,
+		CreatedAt:     now.Add(-time.Hour),
+		ExpiresAt:     now.Add(-time.Minute),
+	}))
+	
+	count, err := store.CleanupExpiredChallenges(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(1))
+	
+	_, err = store.Consume(ctx, "expired-"+suffix, webauthn.CeremonyLogin)
+	assert.ErrorIs(t, err, webauthn.ErrInvalidChallenge)
+}
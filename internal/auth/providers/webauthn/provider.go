@@ -0,0 +1,184 @@
+package webauthn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+)
+
+type Config struct {
+	RPID string // Domain passkeys are bound to, e.g. example.com; changing it invalidates all registered credentials
+	
+	RPName string // Name authenticators show users when registering
+	
+	Origins []string // Origins of the pages that run the ceremonies, e.g. https://example.com
+	
+	ChallengeExpiration time.Duration // Time users have to answer their authenticator's prompt
+	
+	RequireUserVerification bool // Passwordless logins must be confirmed with a PIN or biometrics on the authenticator
+}
+
+// returns the configuration for a service running on localhost
+func DefaultConfig() Config {
+	return Config{
+		RPID:                    "localhost",
+		RPName:                  "Go-Auth-Service",
+		Origins:                 []string{"http://localhost:8080"},
+		ChallengeExpiration:     5 * time.Minute,
+		RequireUserVerification: true,
+	}
+}
+
+// implements passkey and security key authentication for the users of the local provider.
+// Tokens are issued and checked by the local provider.
+type Provider struct {
+	config          Config
+	rp              *protocol.RelyingParty
+	userStore       local.UserStore
+	tokens          auth.Provider
+	credentialStore CredentialStore
+	challengeStore  ChallengeStore
+}
+
+// Option configures optional provider dependencies
+type Option func(*Provider)
+
+// stores registered credentials in the given store instead of in memory
+func WithCredentialStore(store CredentialStore) Option {
+	return func(p *Provider) {
+		p.credentialStore = store
+	}
+}
+
+// stores the challenges of ceremonies in progress in the given store instead of in memory
+func WithChallengeStore(store ChallengeStore) Option {
+	return func(p *Provider) {
+		p.challengeStore = store
+	}
+}
+
+// creates a new WebAuthn provider for the users in userStore. Token operations are passed on to tokens,
+// usually the local provider, which also issues the tokens after a passkey login.
+func NewProvider(config Config, userStore local.UserStore, tokens auth.Provider, options ...Option) *Provider {
+	p := &Provider{
+		config: config,
+		rp: &protocol.RelyingParty{
+			ID:      config.RPID,
+			Name:    config.RPName,
+			Origins: config.Origins,
+		},
+		userStore:       userStore,
+		tokens:          tokens,
+		credentialStore: NewMemoryCredentialStore(),
+		challengeStore:  NewMemoryChallengeStore(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// returns the provider identifier
+func (p *Provider) Name() string {
+	return "webauthn"
+}
+
+// finishes a passwordless login started with BeginLogin. creds.Params["credential"] holds the
+// JSON the browser produced with navigator.credentials.get(), as a string or []byte.
+func (p *Provider) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.User, error) {
+	if creds.Type != "webauthn" {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	var response []byte
+	switch credential := creds.Params["credential"].(type) {
+	case string:
+		response = []byte(credential)
+	case []byte:
+		response = credential
+	default:
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	credential, err := p.finishAssertion(ctx, response, CeremonyLogin, "", p.config.RequireUserVerification)
+	if err != nil {
+		if isVerificationError(err) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	
+	user, err := p.userStore.GetByID(ctx, credential.UserID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	
+	// Passkeys registered before the account moved to a directory don't get around it
+	if local.DirectoryManaged(user) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Roles:    user.Roles,
+	}, nil
+}
+
+// validates a token with the provider that issued it
+func (p *Provider) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
+	return p.tokens.ValidateToken(ctx, token)
+}
+
+// refreshes a token with the provider that issued it
+func (p *Provider) RefreshToken(ctx context.Context, token string) (string, error) {
+	return p.tokens.RefreshToken(ctx, token)
+}
+
+// revokes a token with the provider that issued it
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	return p.tokens.RevokeToken(ctx, token)
+}
+
+// stores a new challenge for a ceremony and returns it
+func (p *Provider) newChallenge(ctx context.Context, ceremony string, userID string) ([]byte, error) {
+	challenge, err := protocol.GenerateChallenge()
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	err = p.challengeStore.Create(ctx, &Challenge{
+		ChallengeHash: hashChallenge(challenge),
+		UserID:        userID,
+		Ceremony:      ceremony,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(p.config.ChallengeExpiration),
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return challenge, nil
+}
+
+// how long browsers should wait for the user, in milliseconds
+func (p *Provider) timeout() int64 {
+	return p.config.ChallengeExpiration.Milliseconds()
+}
+
+// hashes a challenge for storage
+func hashChallenge(challenge []byte) string {
+	sum := sha256.Sum256(challenge)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,154 @@
+package webauthn
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+)
+
+// Longest credential name accepted; longer names are cut off
+const maxCredentialNameLength = 64
+
+// starts registering a passkey or security key for a user.
+// The options are passed to navigator.credentials.create() in the browser.
+// Directory users only log in through the directory and get local.ErrDirectoryManaged.
+func (p *Provider) BeginRegistration(ctx context.Context, userID string) (*protocol.CreationOptions, error) {
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if local.DirectoryManaged(user) {
+		return nil, local.ErrDirectoryManaged
+	}
+	
+	existing, err := p.credentialStore.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	
+	challenge, err := p.newChallenge(ctx, CeremonyRegistration, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	
+	params := make([]protocol.CredentialParameter, 0, len(protocol.SupportedAlgorithms))
+	for _, alg := range protocol.SupportedAlgorithms {
+		params = append(params, protocol.CredentialParameter{Type: "public-key", Algorithm: alg})
+	}
+	
+	return &protocol.CreationOptions{
+		RP: protocol.RelyingPartyEntity{ID: p.config.RPID, Name: p.config.RPName},
+		User: protocol.UserEntity{
+			ID:          []byte(user.ID),
+			Name:        user.Username,
+			DisplayName: user.Username,
+		},
+		Challenge:        challenge,
+		PubKeyCredParams: params,
+		Timeout:          p.timeout(),
+		// Stops users from registering the same authenticator twice
+		ExcludeCredentials: descriptors(existing),
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: protocol.UserVerificationPreferred,
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// finishes a registration started with BeginRegistration, using the JSON the browser produced
+// with navigator.credentials.create(), and stores the new credential under the given name.
+func (p *Provider) FinishRegistration(ctx context.Context, userID string, name string, response []byte) (*Credential, error) {
+	creation, err := protocol.ParseCredentialCreation(response)
+	if err != nil {
+		return nil, err
+	}
+	
+	challenge, err := creation.Challenge()
+	if err != nil {
+		return nil, err
+	}
+	
+	stored, err := p.challengeStore.Consume(ctx, hashChallenge(challenge), CeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if stored.UserID != userID {
+		return nil, ErrInvalidChallenge
+	}
+	
+	// The account may have moved to a directory since the registration started
+	user, err := p.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if local.DirectoryManaged(user) {
+		return nil, local.ErrDirectoryManaged
+	}
+	
+	verified, err := p.rp.VerifyRegistration(creation, challenge, false)
+	if err != nil {
+		return nil, err
+	}
+	
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if runes := []rune(name); len(runes) > maxCredentialNameLength {
+		name = string(runes[:maxCredentialNameLength])
+	}
+	
+	credential := &Credential{
+		ID:             creation.ID,
+		UserID:         userID,
+		Name:           name,
+		PublicKey:      verified.PublicKey,
+		SignCount:      verified.SignCount,
+		Transports:     verified.Transports,
+		BackupEligible: verified.BackupEligible,
+		BackupState:    verified.BackupState,
+		CreatedAt:      time.Now(),
+	}
+	if err := p.credentialStore.Create(ctx, credential); err != nil {
+		return nil, err
+	}
+	
+	return credential, nil
+}
+
+// returns the user's registered credentials, oldest first
+func (p *Provider) ListCredentials(ctx context.Context, userID string) ([]*Credential, error) {
+	return p.credentialStore.ListByUser(ctx, userID)
+}
+
+// removes one of the user's credentials
+func (p *Provider) DeleteCredential(ctx context.Context, userID string, credentialID string) error {
+	return p.credentialStore.Delete(ctx, userID, credentialID)
+}
+
+// reports whether the user has registered a credential, which makes the local provider
+// ask for it after the password (implements local.SecondFactor)
+func (p *Provider) Enabled(ctx context.Context, userID string) (bool, error) {
+	credentials, err := p.credentialStore.ListByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// describes credentials for excludeCredentials and allowCredentials
+func descriptors(credentials []*Credential) []protocol.CredentialDescriptor {
+	result := make([]protocol.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		id, err := decodeCredentialID(credential.ID)
+		if err != nil {
+			continue
+		}
+		result = append(result, protocol.Descriptor(id, credential.Transports))
+	}
+	return result
+}
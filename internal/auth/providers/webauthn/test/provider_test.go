@@ -0,0 +1,275 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const origin = "https://example.com"
+
+// setupProvider creates a local provider with one user and a WebAuthn provider for example.com
+func setupProvider(t *testing.T) (*local.Provider, *webauthn.Provider, *local.StoredUser) {
+	localConfig := local.DefaultConfig()
+	localConfig.JWTSecret = "test-secret"
+	userStore := local.NewMemoryUserStore()
+	localProvider := local.NewProvider(localConfig, userStore)
+	
+	config := webauthn.DefaultConfig()
+	config.RPID = "example.com"
+	config.Origins = []string{origin}
+	provider := webauthn.NewProvider(config, userStore, localProvider)
+	localProvider.AddSecondFactor(provider)
+	
+	user := &local.StoredUser{Username: "alice", Email: "alice@example.com", EmailVerified: true}
+	require.NoError(t, localProvider.CreateUser(context.Background(), user, "password123"))
+	return localProvider, provider, user
+}
+
+// register registers a credential for the user on the authenticator
+func register(t *testing.T, provider *webauthn.Provider, authenticator *protocol.SoftwareAuthenticator, userID string) *webauthn.Credential {
+	ctx := context.Background()
+	options, err := provider.BeginRegistration(ctx, userID)
+	require.NoError(t, err)
+	
+	created, err := authenticator.Create(options)
+	require.NoError(t, err)
+	response, err := json.Marshal(created)
+	require.NoError(t, err)
+	
+	credential, err := provider.FinishRegistration(ctx, userID, "Laptop", response)
+	require.NoError(t, err)
+	return credential
+}
+
+// sign answers request options with the authenticator
+func sign(t *testing.T, authenticator *protocol.SoftwareAuthenticator, options *protocol.RequestOptions) []byte {
+	assertion, err := authenticator.Get(options)
+	require.NoError(t, err)
+	response, err := json.Marshal(assertion)
+	require.NoError(t, err)
+	return response
+}
+
+func passkeyCredentials(response []byte) auth.Credentials {
+	return auth.Credentials{Type: "webauthn", Params: map[string]interface{}{"credential": response}}
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	_, provider, user := setupProvider(t)
+	authenticator := protocol.NewSoftwareAuthenticator(origin)
+	ctx := context.Background()
+	
+	// 1. Register a passkey
+	credential := register(t, provider, authenticator, user.ID)
+	assert.Equal(t, "Laptop", credential.Name)
+	assert.Equal(t, user.ID, credential.UserID)
+	assert.Equal(t, []string{"internal"}, credential.Transports)
+	
+	enabled, err := provider.Enabled(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	
+	// 2. The same authenticator is excluded from registering again
+	options, err := provider.BeginRegistration(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, options.ExcludeCredentials, 1)
+	_, err = authenticator.Create(options)
+	assert.ErrorIs(t, err, protocol.ErrCredentialExcluded)
+	
+	// 3. Challenges can only be answered once, and only by the user they were issued to
+	created, err := protocol.NewSoftwareAuthenticator(origin).Create(options)
+	require.NoError(t, err)
+	response, err := json.Marshal(created)
+	require.NoError(t, err)
+	
+	_, err = provider.FinishRegistration(ctx, "someone-else", "Phone", response)
+	assert.ErrorIs(t, err, webauthn.ErrInvalidChallenge)
+	_, err = provider.FinishRegistration(ctx, user.ID, "Phone", response)
+	assert.ErrorIs(t, err, webauthn.ErrInvalidChallenge)
+	
+	// 4. Remove the passkey
+	assert.ErrorIs(t, provider.DeleteCredential(ctx, "someone-else", credential.ID), webauthn.ErrCredentialNotFound)
+	require.NoError(t, provider.DeleteCredential(ctx, user.ID, credential.ID))
+	
+	credentials, err := provider.ListCredentials(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, credentials)
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	_, provider, user := setupProvider(t)
+	authenticator := protocol.NewSoftwareAuthenticator(origin)
+	credential := register(t, provider, authenticator, user.ID)
+	ctx := context.Background()
+	
+	// 1. Usernameless login with a discoverable passkey
+	options, err := provider.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, options.AllowCredentials)
+	assert.Equal(t, protocol.UserVerificationRequired, options.UserVerification)
+	
+	response := sign(t, authenticator, options)
+	authUser, err := provider.Authenticate(ctx, passkeyCredentials(response))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authUser.ID)
+	assert.Equal(t, "alice", authUser.Username)
+	
+	// The same response can't be replayed
+	_, err = provider.Authenticate(ctx, passkeyCredentials(response))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 2. Login after entering a username
+	options, err = provider.BeginLogin(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, options.AllowCredentials, 1)
+	
+	authUser, err = provider.Authenticate(ctx, passkeyCredentials(sign(t, authenticator, options)))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authUser.ID)
+	
+	credentials, err := provider.ListCredentials(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	assert.Equal(t, credential.ID, credentials[0].ID)
+	assert.Equal(t, uint32(2), credentials[0].SignCount)
+	assert.False(t, credentials[0].LastUsedAt.IsZero())
+	
+	// 3. Passwordless logins need user verification
+	authenticator.UserVerified = false
+	options, err = provider.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, err = provider.Authenticate(ctx, passkeyCredentials(sign(t, authenticator, options)))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 4. Unknown credentials and other credential types are refused
+	_, otherProvider, otherUser := setupProvider(t)
+	stranger := protocol.NewSoftwareAuthenticator(origin)
+	register(t, otherProvider, stranger, otherUser.ID)
+	
+	options, err = provider.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, err = provider.Authenticate(ctx, passkeyCredentials(sign(t, stranger, options)))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	_, err = provider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "alice", Password: "password123"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestPasskeysOfDirectoryUsers(t *testing.T) {
+	localProvider, provider, user := setupProvider(t)
+	authenticator := protocol.NewSoftwareAuthenticator(origin)
+	register(t, provider, authenticator, user.ID)
+	ctx := context.Background()
+	
+	// A registration is under way when the account moves to a directory, which users log in through only
+	options, err := provider.BeginRegistration(ctx, user.ID)
+	require.NoError(t, err)
+	created, err := protocol.NewSoftwareAuthenticator(origin).Create(options)
+	require.NoError(t, err)
+	response, err := json.Marshal(created)
+	require.NoError(t, err)
+	
+	_, err = localProvider.UpdateUser(ctx, user.ID, local.UserUpdate{
+		Metadata: map[string]interface{}{local.MetadataDirectoryDN: "uid=alice,ou=people,dc=example,dc=com"},
+	})
+	require.NoError(t, err)
+	
+	// 1. Passkeys registered before don't log in
+	loginOptions, err := provider.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, err = provider.Authenticate(ctx, passkeyCredentials(sign(t, authenticator, loginOptions)))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	
+	// 2. No passkeys can be registered, including the one under way
+	_, err = provider.FinishRegistration(ctx, user.ID, "Phone", response)
+	assert.ErrorIs(t, err, local.ErrDirectoryManaged)
+	_, err = provider.BeginRegistration(ctx, user.ID)
+	assert.ErrorIs(t, err, local.ErrDirectoryManaged)
+	
+	credentials, err := provider.ListCredentials(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, credentials, 1)
+}
+
+func TestSignCountRegression(t *testing.T) {
+	_, provider, user := setupProvider(t)
+	authenticator := protocol.NewSoftwareAuthenticator(origin)
+	register(t, provider, authenticator, user.ID)
+	ctx := context.Background()
+	
+	options, err := provider.BeginLogin(ctx, "alice")
+	require.NoError(t, err)
+	first := sign(t, authenticator, options)
+	
+	// A response signed later reaches the server first, e.g. from a cloned authenticator
+	options, err = provider.BeginLogin(ctx, "alice")
+	require.NoError(t, err)
+	_, err = provider.Authenticate(ctx, passkeyCredentials(sign(t, authenticator, options)))
+	require.NoError(t, err)
+	
+	_, err = provider.Authenticate(ctx, passkeyCredentials(first))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestSecurityKeySecondFactor(t *testing.T) {
+	localProvider, provider, user := setupProvider(t)
+	authenticator := protocol.NewSoftwareAuthenticator(origin)
+	ctx := context.Background()
+	
+	authUser, err := localProvider.Authenticate(ctx, auth.Credentials{Type: "password", Username: "alice", Password: "password123"})
+	require.NoError(t, err)
+	
+	// 1. Without a security key there's nothing to ask for
+	_, err = provider.BeginSecondFactor(ctx, user.ID)
+	assert.ErrorIs(t, err, webauthn.ErrCredentialNotFound)
+	
+	challenge, err := localProvider.StartMFAChallenge(ctx, authUser)
+	require.NoError(t, err)
+	assert.Nil(t, challenge)
+	
+	// 2. Once registered, the password login needs the key too
+	register(t, provider, authenticator, user.ID)
+	
+	challenge, err = localProvider.StartMFAChallenge(ctx, authUser)
+	require.NoError(t, err)
+	require.NotNil(t, challenge)
+	assert.Equal(t, []string{"webauthn"}, challenge.Methods)
+	
+	verify := func(response []byte) func(*auth.User) error {
+		return func(user *auth.User) error {
+			return provider.VerifySecondFactor(ctx, user.ID, response)
+		}
+	}
+	
+	// A response to a passwordless login challenge isn't accepted
+	options, err := provider.BeginLogin(ctx, "alice")
+	require.NoError(t, err)
+	_, err = localProvider.CompleteMFAChallengeWith(ctx, challenge.Token, verify(sign(t, authenticator, options)))
+	assert.ErrorIs(t, err, local.ErrInvalidMFACode)
+	
+	// Each attempt needs a new challenge
+	challenge, err = localProvider.StartMFAChallenge(ctx, authUser)
+	require.NoError(t, err)
+	
+	options, err = provider.BeginSecondFactor(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, protocol.UserVerificationDiscouraged, options.UserVerification)
+	
+	// Security keys without a PIN are fine as a second factor
+	authenticator.UserVerified = false
+	loggedIn, err := localProvider.CompleteMFAChallengeWith(ctx, challenge.Token, verify(sign(t, authenticator, options)))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, loggedIn.ID)
+	
+	// The MFA challenge is used up
+	_, err = localProvider.CompleteMFAChallengeWith(ctx, challenge.Token, verify(nil))
+	assert.ErrorIs(t, err, local.ErrInvalidOneTimeToken)
+}
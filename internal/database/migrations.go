@@ -49,9 +49,198 @@ func Migrate(db *sqlx.DB) error {
 		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
 	);
 
-	-- Make sure we have a version record
+	-- Create refresh token table
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+	-- Group refresh tokens into families for rotation and reuse detection
+	ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(64);
+	UPDATE refresh_tokens SET family_id = token_hash WHERE family_id IS NULL;
+	ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+	ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS used_at TIMESTAMP WITH TIME ZONE;
+
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+	-- Create per-user revocation watermark table
+	CREATE TABLE IF NOT EXISTS user_revocations (
+		user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		revoked_before TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	-- Create session table
+	CREATE TABLE IF NOT EXISTS sessions (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_id VARCHAR(255) NOT NULL,
+		ip_address VARCHAR(45) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+	-- Support paginated user listing and filtering by role
+	CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles(role);
+
+	-- Track whether the user's email address has been confirmed
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+	-- Create one-time token table (email verification links, etc.)
+	CREATE TABLE IF NOT EXISTS one_time_tokens (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		purpose VARCHAR(50) NOT NULL,
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_one_time_tokens_user_id ON one_time_tokens(user_id);
+
+	-- Create password history table (hashes of passwords users stopped using)
+	CREATE TABLE IF NOT EXISTS password_history (
+		id BIGSERIAL PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		password_hash VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history(user_id);
+
+	-- Create login attempt table (failed login counters and lockouts per account and IP address)
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		key VARCHAR(320) PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure TIMESTAMP WITH TIME ZONE NOT NULL,
+		locked_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT to_timestamp(0),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_login_attempts_locked_until ON login_attempts(locked_until);
+
+	-- Store TOTP two-factor authentication settings and recovery code hashes with the user
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS recovery_codes TEXT[] NOT NULL DEFAULT '{}';
+
+	-- Create WebAuthn credential table (passkeys and security keys)
+	CREATE TABLE IF NOT EXISTS webauthn_credentials (
+		id TEXT PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(64) NOT NULL,
+		public_key BYTEA NOT NULL,
+		sign_count BIGINT NOT NULL DEFAULT 0,
+		transports TEXT[] NOT NULL DEFAULT '{}',
+		backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+		backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		last_used_at TIMESTAMP WITH TIME ZONE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+	-- Create WebAuthn challenge table (registrations and logins in progress)
+	CREATE TABLE IF NOT EXISTS webauthn_challenges (
+		challenge_hash VARCHAR(64) PRIMARY KEY,
+		user_id VARCHAR(36) REFERENCES users(id) ON DELETE CASCADE,
+		ceremony VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	-- Create OAuth2/OpenID Connect login state table (logins in progress at external identity providers)
+	CREATE TABLE IF NOT EXISTS oauth_states (
+		state_hash VARCHAR(64) PRIMARY KEY,
+		provider VARCHAR(64) NOT NULL,
+		code_verifier VARCHAR(128) NOT NULL,
+		nonce VARCHAR(128) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	-- Create external identity table (accounts at external identity providers linked to users)
+	CREATE TABLE IF NOT EXISTS user_identities (
+		provider VARCHAR(64) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		last_login_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		PRIMARY KEY (provider, subject)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+	-- Create API key table (keys users created for machine clients; only hashes are stored)
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(64) NOT NULL UNIQUE,
+		key_hash VARCHAR(64) NOT NULL,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE,
+		last_used_at TIMESTAMP WITH TIME ZONE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+	-- Create login link table (emailed single-use links; only hashes of the token and browser nonce are stored)
+	CREATE TABLE IF NOT EXISTS magic_links (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		nonce_hash VARCHAR(64) NOT NULL,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		email VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_magic_links_expires_at ON magic_links(expires_at);
+
+	-- Create OAuth client table (applications that get tokens from the token endpoint; only secret hashes are stored)
+	CREATE TABLE IF NOT EXISTS oauth_clients (
+		id VARCHAR(36) PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		secret_hash VARCHAR(64) NOT NULL,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		token_lifetime_seconds BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	);
+
+	-- Add redirect URIs and public clients for the authorization code grant
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS public BOOLEAN NOT NULL DEFAULT FALSE;
+
+	-- Create authorization code table (single-use codes clients exchange for user tokens; only hashes are stored)
+	CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+		code_hash VARCHAR(64) PRIMARY KEY,
+		client_id VARCHAR(36) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+		user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		redirect_uri TEXT NOT NULL,
+		scope TEXT NOT NULL DEFAULT '',
+		code_challenge VARCHAR(128) NOT NULL,
+		nonce TEXT NOT NULL DEFAULT '',
+		auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+
+	-- Make sure we have version records
 	INSERT INTO schema_migrations (version)
-	VALUES (1)
+	VALUES (1), (2), (3), (4), (5), (6), (7), (8), (9), (10), (11), (12), (13), (14), (15), (16), (17)
 	ON CONFLICT (version) DO NOTHING;
 	`
 
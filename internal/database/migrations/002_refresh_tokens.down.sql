@@ -0,0 +1,5 @@
+-- Migration: 002_refresh_tokens (rollback)
+
+DROP TABLE IF EXISTS refresh_tokens;
+
+DELETE FROM schema_migrations WHERE version = 2;
@@ -0,0 +1,7 @@
+-- Migration: 003_refresh_token_families (rollback)
+
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS used_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
+
+DELETE FROM schema_migrations WHERE version = 3;
@@ -0,0 +1,11 @@
+-- Migration: 003_refresh_token_families
+
+-- Group refresh tokens into families for rotation and reuse detection
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(64);
+UPDATE refresh_tokens SET family_id = token_hash WHERE family_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS used_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+INSERT INTO schema_migrations (version) VALUES (3);
@@ -0,0 +1,5 @@
+-- Migration: 004_user_revocations (rollback)
+
+DROP TABLE IF EXISTS user_revocations;
+
+DELETE FROM schema_migrations WHERE version = 4;
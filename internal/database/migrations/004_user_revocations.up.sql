@@ -0,0 +1,9 @@
+-- Migration: 004_user_revocations
+
+-- Create per-user revocation watermark table
+CREATE TABLE IF NOT EXISTS user_revocations (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+INSERT INTO schema_migrations (version) VALUES (4);
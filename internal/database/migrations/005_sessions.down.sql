@@ -0,0 +1,5 @@
+-- Migration: 005_sessions (rollback)
+
+DROP TABLE IF EXISTS sessions;
+
+DELETE FROM schema_migrations WHERE version = 5;
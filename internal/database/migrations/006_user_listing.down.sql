@@ -0,0 +1,6 @@
+-- Migration: 006_user_listing (rollback)
+
+DROP INDEX IF EXISTS idx_user_roles_role;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+
+DELETE FROM schema_migrations WHERE version = 6;
@@ -0,0 +1,7 @@
+-- Migration: 006_user_listing
+
+-- Support paginated user listing and filtering by role
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles(role);
+
+INSERT INTO schema_migrations (version) VALUES (6);
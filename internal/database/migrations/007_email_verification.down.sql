@@ -0,0 +1,7 @@
+-- Migration: 007_email_verification (rollback)
+
+DROP TABLE IF EXISTS one_time_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+
+DELETE FROM schema_migrations WHERE version = 7;
@@ -0,0 +1,5 @@
+-- Migration: 008_password_history (rollback)
+
+DROP TABLE IF EXISTS password_history;
+
+DELETE FROM schema_migrations WHERE version = 8;
@@ -0,0 +1,5 @@
+-- Migration: 009_login_attempts (rollback)
+
+DROP TABLE IF EXISTS login_attempts;
+
+DELETE FROM schema_migrations WHERE version = 9;
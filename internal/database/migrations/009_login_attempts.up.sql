@@ -0,0 +1,14 @@
+-- Migration: 009_login_attempts
+
+-- Create login attempt table (failed login counters and lockouts per account and IP address)
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT to_timestamp(0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_locked_until ON login_attempts(locked_until);
+
+INSERT INTO schema_migrations (version) VALUES (9);
@@ -0,0 +1,8 @@
+-- Migration: 010_mfa (rollback)
+
+ALTER TABLE users DROP COLUMN IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
+
+DELETE FROM schema_migrations WHERE version = 10;
@@ -0,0 +1,6 @@
+-- Migration: 011_webauthn (rollback)
+
+DROP TABLE IF EXISTS webauthn_challenges;
+DROP TABLE IF EXISTS webauthn_credentials;
+
+DELETE FROM schema_migrations WHERE version = 11;
@@ -0,0 +1,5 @@
+-- Migration: 012_oauth_states (rollback)
+
+DROP TABLE IF EXISTS oauth_states;
+
+DELETE FROM schema_migrations WHERE version = 12;
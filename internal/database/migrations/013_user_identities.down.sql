@@ -0,0 +1,5 @@
+-- Migration: 013_user_identities (rollback)
+
+DROP TABLE IF EXISTS user_identities;
+
+DELETE FROM schema_migrations WHERE version = 13;
@@ -0,0 +1,5 @@
+-- Migration: 014_api_keys (rollback)
+
+DROP TABLE IF EXISTS api_keys;
+
+DELETE FROM schema_migrations WHERE version = 14;
@@ -0,0 +1,5 @@
+-- Migration: 015_magic_links (rollback)
+
+DROP TABLE IF EXISTS magic_links;
+
+DELETE FROM schema_migrations WHERE version = 15;
@@ -0,0 +1,15 @@
+-- Migration: 015_magic_links
+
+-- Create login link table (emailed single-use links; only hashes of the token and browser nonce are stored)
+CREATE TABLE IF NOT EXISTS magic_links (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    nonce_hash VARCHAR(64) NOT NULL,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_links_expires_at ON magic_links(expires_at);
+
+INSERT INTO schema_migrations (version) VALUES (15);
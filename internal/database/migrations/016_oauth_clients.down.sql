@@ -0,0 +1,5 @@
+-- Migration: 016_oauth_clients (rollback)
+
+DROP TABLE IF EXISTS oauth_clients;
+
+DELETE FROM schema_migrations WHERE version = 16;
@@ -0,0 +1,13 @@
+-- Migration: 016_oauth_clients
+
+-- Create OAuth client table (applications that get tokens from the token endpoint; only secret hashes are stored)
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    token_lifetime_seconds BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_migrations (version) VALUES (16);
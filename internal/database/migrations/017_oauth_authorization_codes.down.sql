@@ -0,0 +1,8 @@
+-- Migration: 017_oauth_authorization_codes (rollback)
+
+DROP TABLE IF EXISTS oauth_authorization_codes;
+
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS public;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS redirect_uris;
+
+DELETE FROM schema_migrations WHERE version = 17;
@@ -0,0 +1,259 @@
+//go:build !database
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryLogin logs in with the given credentials and returns the access token
+func memoryLogin(t *testing.T, router http.Handler, username, password string) string {
+	form := url.Values{}
+	form.Add("username", username)
+	form.Add("password", password)
+	
+	loginReq := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+	loginReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	loginW := httptest.NewRecorder()
+	
+	router.ServeHTTP(loginW, loginReq)
+	
+	assert.Equal(t, http.StatusOK, loginW.Code)
+	
+	var loginResponse map[string]interface{}
+	err := json.Unmarshal(loginW.Body.Bytes(), &loginResponse)
+	assert.NoError(t, err)
+	
+	token, _ := loginResponse["access_token"].(string)
+	return token
+}
+
+func TestMemoryAdminUsers(t *testing.T) {
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	adminRequest := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. Non-admins are forbidden
+	userToken := memoryLogin(t, router, "testuser", "password123")
+	w := adminRequest("POST", "/admin/users", userToken, `{"username":"x","email":"x@example.com","password":"password123"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	
+	w = adminRequest("GET", "/admin/users/anything", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	// 2. Create a user
+	w = adminRequest("POST", "/admin/users", adminToken,
+		`{"username":"alice","email":"alice@example.com","password":"password123","roles":["user","editor"],"metadata":{"team":"docs"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	
+	var created struct {
+		User map[string]interface{} `json:"user"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &created)
+	assert.NoError(t, err)
+	assert.NotContains(t, created.User, "password_hash")
+	userID, _ := created.User["id"].(string)
+	assert.NotEmpty(t, userID)
+	
+	// The new user can log in
+	memoryLogin(t, router, "alice", "password123")
+	
+	w = adminRequest("POST", "/admin/users", adminToken, `{"username":"alice","email":"other@example.com","password":"password123"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	
+	w = adminRequest("POST", "/admin/users", adminToken, `{"username":"bob","email":"bob@example.com","password":"short"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	// 3. Update roles and metadata
+	w = adminRequest("PATCH", "/admin/users/"+userID, adminToken, `{"roles":["user"],"metadata":{"team":null,"level":"senior"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	w = adminRequest("GET", "/admin/users/"+userID, adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var fetched struct {
+		User struct {
+			Username string                 `json:"username"`
+			Roles    []string               `json:"roles"`
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"user"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &fetched)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", fetched.User.Username)
+	assert.Equal(t, []string{"user"}, fetched.User.Roles)
+	assert.Equal(t, map[string]interface{}{"level": "senior"}, fetched.User.Metadata)
+	
+	w = adminRequest("PATCH", "/admin/users/"+userID, adminToken, `{"username":" "}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = adminRequest("PATCH", "/admin/users/"+userID, adminToken, `{"email":"not-an-address"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	// Setting a password logs the user out everywhere
+	aliceToken := memoryLogin(t, router, "alice", "password123")
+	w = adminRequest("PATCH", "/admin/users/"+userID, adminToken, `{"password":"new-password"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	w = adminRequest("GET", "/auth/me", aliceToken, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	memoryLogin(t, router, "alice", "new-password")
+	
+	// 4. Delete the user
+	w = adminRequest("DELETE", "/admin/users/"+userID, adminToken, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	
+	w = adminRequest("GET", "/admin/users/"+userID, adminToken, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMemoryAdminListUsers(t *testing.T) {
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	list := func(query string) (int, []string, string) {
+		req := httptest.NewRequest("GET", "/admin/users?"+query, nil)
+		req.Header.Add("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		
+		var response struct {
+			Users []struct {
+				Username string `json:"username"`
+			} `json:"users"`
+			NextCursor string `json:"next_cursor"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		
+		usernames := make([]string, 0, len(response.Users))
+		for _, user := range response.Users {
+			usernames = append(usernames, user.Username)
+		}
+		return w.Code, usernames, response.NextCursor
+	}
+	
+	// 1. Page through the seeded users one at a time
+	code, first, cursor := list("limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, first, 1)
+	assert.NotEmpty(t, cursor)
+	
+	code, second, cursor := list("limit=1&cursor=" + url.QueryEscape(cursor))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, second, 1)
+	assert.NotEqual(t, first, second)
+	assert.Empty(t, cursor)
+	
+	// 2. Filter by role
+	code, admins, _ := list("role=admin")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"admin"}, admins)
+	
+	// 3. Bad parameters are rejected
+	code, _, _ = list("limit=zero")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _, _ = list("order=sideways")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _, _ = list("cursor=garbage")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestMemoryLoginLockout(t *testing.T) {
+	os.Setenv("MAX_FAILED_LOGINS", "2")
+	os.Setenv("FAILED_LOGIN_DELAY", "0s")
+	defer os.Unsetenv("MAX_FAILED_LOGINS")
+	defer os.Unsetenv("FAILED_LOGIN_DELAY")
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	login := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Add("username", "testuser")
+		form.Add("password", password)
+		
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	adminRequest := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Add("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. Two wrong passwords lock the account
+	assert.Equal(t, http.StatusUnauthorized, login("wrong-password").Code)
+	assert.Equal(t, http.StatusUnauthorized, login("wrong-password").Code)
+	
+	w := login("password123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	
+	// 2. Admins can see the lockout
+	w = adminRequest("GET", "/admin/lockouts")
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var listed struct {
+		Lockouts []map[string]interface{} `json:"lockouts"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.NoError(t, err)
+	if assert.Len(t, listed.Lockouts, 1) {
+		assert.Equal(t, "account", listed.Lockouts[0]["type"])
+		assert.Equal(t, "testuser", listed.Lockouts[0]["username"])
+	}
+	
+	w = adminRequest("GET", "/admin/users?q=testuser")
+	var users struct {
+		Users []map[string]interface{} `json:"users"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &users)
+	assert.NoError(t, err)
+	if !assert.Len(t, users.Users, 1) {
+		return
+	}
+	userID, _ := users.Users[0]["id"].(string)
+	
+	w = adminRequest("GET", "/admin/users/"+userID+"/lockout")
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var status struct {
+		Lockout map[string]interface{} `json:"lockout"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.Equal(t, true, status.Lockout["locked"])
+	assert.Equal(t, float64(2), status.Lockout["failures"])
+	
+	// 3. Clearing the lockout lets the user log in again
+	assert.Equal(t, http.StatusNoContent, adminRequest("DELETE", "/admin/users/"+userID+"/lockout").Code)
+	assert.Equal(t, http.StatusOK, login("password123").Code)
+	
+	assert.Equal(t, http.StatusNotFound, adminRequest("GET", "/admin/users/unknown-id/lockout").Code)
+	assert.Equal(t, http.StatusNoContent, adminRequest("DELETE", "/admin/lockouts/ip/192.0.2.1").Code)
+}
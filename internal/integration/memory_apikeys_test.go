@@ -0,0 +1,95 @@
+//go:build !database
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryAPIKeys(t *testing.T) {
+	router, _ := server.SetupRouter()
+	token := memoryLogin(t, router, "testuser", "password123")
+	
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+	
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. Create a key; the response is the only time it's shown
+	w := request("POST", "/auth/api-keys", token, "name=Nightly+export&scopes=read,export&expires_in=720h")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	
+	var created struct {
+		Key    string                 `json:"key"`
+		APIKey map[string]interface{} `json:"api_key"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Key, "ak_"))
+	assert.Equal(t, "Nightly export", created.APIKey["name"])
+	assert.Equal(t, []interface{}{"read", "export"}, created.APIKey["scopes"])
+	assert.NotNil(t, created.APIKey["expires_at"])
+	assert.Nil(t, created.APIKey["last_used_at"])
+	assert.True(t, strings.HasPrefix(created.Key, created.APIKey["prefix"].(string)))
+	
+	// 2. The key authenticates like a token from a login
+	w = request("GET", "/auth/me", created.Key, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"testuser"`)
+	
+	w = request("GET", "/auth/me", token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	// 3. Keys can't be used to manage keys
+	w = request("GET", "/auth/api-keys", created.Key, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	// 4. The listing shows the last use but never the key
+	w = request("GET", "/auth/api-keys", token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key)
+	
+	var listed struct {
+		APIKeys []map[string]interface{} `json:"api_keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.APIKeys, 1)
+	assert.NotNil(t, listed.APIKeys[0]["last_used_at"])
+	
+	// 5. Invalid requests are refused
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/auth/api-keys", token, "name=").Code)
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/auth/api-keys", token, "name=Key&expires_in=soon").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("POST", "/auth/api-keys", "", "name=Key").Code)
+	
+	// 6. Other users can't delete the key, and a deleted key stops working
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	id := created.APIKey["id"].(string)
+	assert.Equal(t, http.StatusNotFound, request("DELETE", "/auth/api-keys/"+id, adminToken, "").Code)
+	assert.Equal(t, http.StatusNoContent, request("DELETE", "/auth/api-keys/"+id, token, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/auth/me", created.Key, "").Code)
+	
+	// 7. Logging out everywhere disables the user's keys too
+	w = request("POST", "/auth/api-keys", token, "name=Another")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, http.StatusOK, request("GET", "/auth/me", created.Key, "").Code)
+	
+	assert.Equal(t, http.StatusOK, request("POST", "/auth/logout-all", token, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/auth/me", created.Key, "").Code)
+}
@@ -8,10 +8,14 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/NBDor/Go-Auth-Service/pkg/totp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -75,3 +79,347 @@ func TestMemoryAuthentication(t *testing.T) {
 	
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestMemoryJWKSEndpoint(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	
+	// Tokens are signed with a shared secret here, so no keys are published
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{}, response["keys"])
+}
+
+func TestMemoryRegister(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	register := func(username, email, password string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Add("username", username)
+		form.Add("email", email)
+		form.Add("password", password)
+		
+		req := httptest.NewRequest("POST", "/auth/register", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. Sign up and use the returned token straight away
+	w := register("newcomer", "newcomer@example.com", "password123")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response, "user")
+	
+	token, ok := response["access_token"].(string)
+	assert.True(t, ok)
+	
+	meReq := httptest.NewRequest("GET", "/auth/me", nil)
+	meReq.Header.Add("Authorization", "Bearer "+token)
+	meW := httptest.NewRecorder()
+	
+	router.ServeHTTP(meW, meReq)
+	
+	assert.Equal(t, http.StatusOK, meW.Code)
+	assert.Contains(t, meW.Body.String(), "newcomer")
+	
+	// 2. Duplicates and weak passwords are rejected
+	w = register("testuser", "someone@example.com", "password123")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	
+	w = register("weakling", "weakling@example.com", "short")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	// 3. Registration can be turned off
+	os.Setenv("DISABLE_REGISTRATION", "true")
+	defer os.Unsetenv("DISABLE_REGISTRATION")
+	router, _ = server.SetupRouter()
+	
+	w = register("latecomer", "latecomer@example.com", "password123")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestMemoryEmailVerification(t *testing.T) {
+	mailDir := t.TempDir()
+	os.Setenv("MAIL_DIR", mailDir)
+	os.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+	defer os.Unsetenv("MAIL_DIR")
+	defer os.Unsetenv("REQUIRE_VERIFIED_EMAIL")
+	router, _ := server.SetupRouter()
+	
+	login := func() int {
+		form := url.Values{}
+		form.Add("username", "verifier")
+		form.Add("password", "password123")
+		
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	
+	// 1. Register; no tokens until the address is verified
+	form := url.Values{}
+	form.Add("username", "verifier")
+	form.Add("email", "verifier@example.com")
+	form.Add("password", "password123")
+	
+	req := httptest.NewRequest("POST", "/auth/register", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "access_token")
+	assert.Equal(t, http.StatusForbidden, login())
+	
+	// 2. Follow the link from the email
+	files, err := os.ReadDir(mailDir)
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 1) {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(mailDir, files[0].Name()))
+	assert.NoError(t, err)
+	
+	match := regexp.MustCompile(`/auth/verify-email\?token=(\S+)`).FindStringSubmatch(string(data))
+	if !assert.NotNil(t, match) {
+		return
+	}
+	
+	verifyReq := httptest.NewRequest("GET", match[0], nil)
+	verifyW := httptest.NewRecorder()
+	
+	router.ServeHTTP(verifyW, verifyReq)
+	
+	assert.Equal(t, http.StatusOK, verifyW.Code)
+	assert.Equal(t, http.StatusOK, login())
+	
+	// 3. The link can't be used twice
+	verifyW = httptest.NewRecorder()
+	
+	router.ServeHTTP(verifyW, verifyReq)
+	
+	assert.Equal(t, http.StatusBadRequest, verifyW.Code)
+}
+
+func TestMemoryPasswordReset(t *testing.T) {
+	mailDir := t.TempDir()
+	os.Setenv("MAIL_DIR", mailDir)
+	defer os.Unsetenv("MAIL_DIR")
+	router, _ := server.SetupRouter()
+	
+	post := func(path string, form url.Values) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	
+	// 1. Known and unknown addresses get the same response; only the known one gets an email
+	assert.Equal(t, http.StatusAccepted, post("/auth/password/forgot", url.Values{"email": {"nobody@example.com"}}))
+	assert.Equal(t, http.StatusAccepted, post("/auth/password/forgot", url.Values{"email": {"test@example.com"}}))
+	
+	// The email is sent in the background, after the response
+	emails := func() int {
+		files, _ := os.ReadDir(mailDir)
+		return len(files)
+	}
+	if !assert.Eventually(t, func() bool { return emails() > 0 }, time.Second, 5*time.Millisecond) {
+		return
+	}
+	assert.Never(t, func() bool { return emails() > 1 }, 100*time.Millisecond, 5*time.Millisecond)
+	
+	files, err := os.ReadDir(mailDir)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(mailDir, files[0].Name()))
+	assert.NoError(t, err)
+	
+	match := regexp.MustCompile(`http://localhost:8080(/auth/password/reset\?token=(\S+))`).FindStringSubmatch(string(data))
+	if !assert.NotNil(t, match) {
+		return
+	}
+	token, err := url.QueryUnescape(match[2])
+	assert.NoError(t, err)
+	
+	// 2. The link opens a page with a form for the new password, which carries the token
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", match[1], nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Contains(t, w.Body.String(), `action="/auth/password/reset"`)
+	assert.Contains(t, w.Body.String(), `name="password"`)
+	assert.Contains(t, w.Body.String(), `value="`+token+`"`)
+	
+	// submit posts the page's form like a browser would
+	submit := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}, "password": {password}}
+		req := httptest.NewRequest("POST", "/auth/password/reset", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Accept", "text/html,application/xhtml+xml")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 3. Set a new password; a rejected one shows the form again, and the link only works once
+	w = submit("short")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `name="password"`)
+	assert.Equal(t, http.StatusBadRequest, post("/auth/password/reset", url.Values{"token": {token}, "password": {"short"}}))
+	
+	w = submit("new-password")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Your password has been reset")
+	assert.Equal(t, http.StatusBadRequest, post("/auth/password/reset", url.Values{"token": {token}, "password": {"new-password"}}))
+	
+	// 4. Log in with the new password
+	assert.Equal(t, http.StatusUnauthorized, post("/auth/login", url.Values{"username": {"testuser"}, "password": {"password123"}}))
+	assert.Equal(t, http.StatusOK, post("/auth/login", url.Values{"username": {"testuser"}, "password": {"new-password"}}))
+	
+	// 5. Each address can only be sent so many emails, whether or not it has an account
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusAccepted, post("/auth/password/forgot", url.Values{"email": {"nobody@example.com"}}))
+	}
+	req := httptest.NewRequest("POST", "/auth/password/forgot", strings.NewReader(url.Values{"email": {"nobody@example.com"}}.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestMemoryPasswordChange(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	login := func(password string) (int, string) {
+		form := url.Values{}
+		form.Add("username", "testuser")
+		form.Add("password", password)
+		
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		token, _ := response["access_token"].(string)
+		return w.Code, token
+	}
+	
+	change := func(token string, current string, next string) int {
+		form := url.Values{}
+		form.Add("current_password", current)
+		form.Add("new_password", next)
+		
+		req := httptest.NewRequest("POST", "/auth/password/change", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	
+	me := func(token string) int {
+		req := httptest.NewRequest("GET", "/auth/me", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	
+	// Logged in on two devices
+	_, current := login("password123")
+	_, other := login("password123")
+	
+	assert.Equal(t, http.StatusUnauthorized, change("", "password123", "new-password"))
+	assert.Equal(t, http.StatusForbidden, change(current, "wrong-password", "new-password"))
+	assert.Equal(t, http.StatusBadRequest, change(current, "password123", "short"))
+	assert.Equal(t, http.StatusBadRequest, change(current, "password123", "password123"))
+	assert.Equal(t, http.StatusOK, change(current, "password123", "new-password"))
+	
+	// Only the session that changed the password is still logged in
+	assert.Equal(t, http.StatusOK, me(current))
+	assert.Equal(t, http.StatusUnauthorized, me(other))
+	
+	code, _ := login("new-password")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestMemoryMFALogin(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	post := func(path string, token string, form url.Values) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	credentials := url.Values{"username": {"testuser"}, "password": {"password123"}}
+	
+	// 1. Set up an authenticator app
+	token := memoryLogin(t, router, "testuser", "password123")
+	
+	code, enrollment := post("/auth/mfa/totp/enroll", token, nil)
+	assert.Equal(t, http.StatusOK, code)
+	secret, _ := enrollment["secret"].(string)
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+	
+	code, _ = post("/auth/mfa/totp/confirm", token, url.Values{"code": {"not-a-code"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+	
+	first, err := totp.GenerateCode(secret, totp.Step(time.Now()))
+	assert.NoError(t, err)
+	code, confirmed := post("/auth/mfa/totp/confirm", token, url.Values{"code": {first}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, confirmed["recovery_codes"], 10)
+	
+	// 2. The password alone only gets a challenge
+	code, login := post("/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, login["mfa_required"])
+	assert.Nil(t, login["access_token"])
+	challenge, _ := login["mfa_token"].(string)
+	
+	next, err := totp.GenerateCode(secret, totp.Step(time.Now())+1)
+	assert.NoError(t, err)
+	code, login = post("/auth/login/mfa", "", url.Values{"mfa_token": {challenge}, "code": {next}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, login["access_token"])
+	
+	// 3. Challenges only work once
+	code, _ = post("/auth/login/mfa", "", url.Values{"mfa_token": {challenge}, "code": {next}})
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
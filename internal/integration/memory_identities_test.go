@@ -0,0 +1,152 @@
+//go:build !database
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2/oidctest"
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryExternalIdentities(t *testing.T) {
+	idp := oidctest.NewServer("auth-service", "client-secret")
+	defer idp.Close()
+	
+	t.Setenv("OIDC_PROVIDERS", "fake")
+	t.Setenv("OIDC_FAKE_ISSUER", idp.URL)
+	t.Setenv("OIDC_FAKE_CLIENT_ID", "auth-service")
+	t.Setenv("OIDC_FAKE_CLIENT_SECRET", "client-secret")
+	router, _ := server.SetupRouter()
+	
+	request := func(method, path, token string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+	
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// startLink starts linking an identity, confirming with a password
+	startLink := func(token string, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/auth/identities/fake", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+	
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// stateCookie returns the cookie binding the login to the browser
+	stateCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "oauth_state" {
+				return cookie
+			}
+		}
+		require.Fail(t, "no oauth_state cookie")
+		return nil
+	}
+	// finish logs in at the identity provider and follows its redirect back to the callback
+	finish := func(authURL string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		code, state, err := idp.Authorize(authURL)
+		require.NoError(t, err)
+		query := url.Values{"code": {code}, "state": {state}}
+		return request("GET", "/auth/oauth/fake/callback?"+query.Encode(), "", cookie)
+	}
+	// login runs a whole external login and returns the callback's response
+	login := func() *httptest.ResponseRecorder {
+		w := request("GET", "/auth/oauth/fake/login", "", nil)
+		require.Equal(t, http.StatusFound, w.Code)
+		return finish(w.Header().Get("Location"), stateCookie(w))
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+	
+	// 1. Unknown identity providers don't exist, and neither do local ones
+	assert.Equal(t, http.StatusNotFound, request("GET", "/auth/oauth/other/login", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, request("GET", "/auth/oauth/local/login", "", nil).Code)
+	
+	// 2. A new identity gets an account and tokens
+	idp.SetUser(map[string]interface{}{
+		"sub":                "alice-sub",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+	})
+	w := login()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	body := decode(w)
+	aliceToken, _ := body["access_token"].(string)
+	require.NotEmpty(t, aliceToken)
+	assert.Equal(t, "alice", body["user"].(map[string]interface{})["username"])
+	
+	// The callback only works in the browser that started the login
+	w = request("GET", "/auth/oauth/fake/login", "", nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, http.StatusBadRequest, finish(w.Header().Get("Location"), nil).Code)
+	
+	w = request("GET", "/auth/oauth/fake/callback?error=access_denied&state=x", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	// 3. Addresses that have an account aren't taken over without email linking
+	idp.SetUser(map[string]interface{}{"sub": "test-sub", "email": "test@example.com", "email_verified": true})
+	assert.Equal(t, http.StatusConflict, login().Code)
+	
+	// 4. Logged-in users link the identity themselves
+	testToken := memoryLogin(t, router, "testuser", "password123")
+	assert.Equal(t, http.StatusUnauthorized, request("POST", "/auth/identities/fake", "", nil).Code)
+	
+	// A token alone isn't enough; the user confirms with their password
+	assert.Equal(t, http.StatusForbidden, request("POST", "/auth/identities/fake", testToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, startLink(testToken, "wrong-password").Code)
+	
+	w = startLink(testToken, "password123")
+	require.Equal(t, http.StatusOK, w.Code)
+	authURL, _ := decode(w)["authorization_url"].(string)
+	w = finish(authURL, stateCookie(w))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	identity := decode(w)["identity"].(map[string]interface{})
+	assert.Equal(t, "fake", identity["provider"])
+	assert.Equal(t, "test-sub", identity["subject"])
+	
+	w = request("GET", "/auth/identities", testToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, decode(w)["identities"], 1)
+	
+	// The identity now logs in as testuser
+	w = login()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "testuser", decode(w)["user"].(map[string]interface{})["username"])
+	
+	// 5. Nobody else can link it
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	w = startLink(adminToken, "admin123")
+	require.Equal(t, http.StatusOK, w.Code)
+	authURL, _ = decode(w)["authorization_url"].(string)
+	assert.Equal(t, http.StatusConflict, finish(authURL, stateCookie(w)).Code)
+	
+	// 6. Unlinking
+	assert.Equal(t, http.StatusConflict, request("DELETE", "/auth/identities/fake/alice-sub", aliceToken, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request("DELETE", "/auth/identities/fake/alice-sub", testToken, nil).Code)
+	assert.Equal(t, http.StatusNoContent, request("DELETE", "/auth/identities/fake/test-sub", testToken, nil).Code)
+	
+	w = request("GET", "/auth/identities", testToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, decode(w)["identities"])
+}
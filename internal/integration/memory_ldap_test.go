@@ -0,0 +1,87 @@
+//go:build !database
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/NBDor/Go-Auth-Service/pkg/ldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLDAPLogin(t *testing.T) {
+	directory := ldap.NewTestServer()
+	defer directory.Close()
+	directory.AddEntry("cn=service,dc=example,dc=com", map[string][]string{
+		"userPassword": {"service-secret"},
+	})
+	directory.AddEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{
+		"uid":          {"jdoe"},
+		"mail":         {"jdoe@example.com"},
+		"memberOf":     {"cn=ops,ou=groups,dc=example,dc=com"},
+		"userPassword": {"directory-secret"},
+	})
+	
+	t.Setenv("LDAP_URL", directory.URL)
+	t.Setenv("LDAP_BASE_DN", "ou=people,dc=example,dc=com")
+	t.Setenv("LDAP_BIND_DN", "cn=service,dc=example,dc=com")
+	t.Setenv("LDAP_BIND_PASSWORD", "service-secret")
+	t.Setenv("LDAP_GROUP_ROLES", "ops=operator")
+	router, _ := server.SetupRouter()
+	
+	login := func(provider, username, password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}, "provider": {provider}}
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+	
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. Directory users log in with provider=ldap and get tokens from the local provider
+	w := login("ldap", "jdoe", "directory-secret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	token, _ := response["access_token"].(string)
+	require.NotEmpty(t, token)
+	
+	req := httptest.NewRequest("GET", "/auth/me", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var me struct {
+		User struct {
+			Username string   `json:"username"`
+			Roles    []string `json:"roles"`
+		} `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &me))
+	assert.Equal(t, "jdoe", me.User.Username)
+	assert.Equal(t, []string{"user", "operator"}, me.User.Roles)
+	
+	// 2. The shadow user has no local password
+	assert.Equal(t, http.StatusUnauthorized, login("", "jdoe", "directory-secret").Code)
+	
+	// 3. Wrong directory passwords and local accounts are rejected through the directory
+	assert.Equal(t, http.StatusUnauthorized, login("ldap", "jdoe", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, login("ldap", "testuser", "password123").Code)
+	
+	// 4. Unknown providers are a bad request
+	assert.Equal(t, http.StatusBadRequest, login("nope", "jdoe", "directory-secret").Code)
+	
+	// 5. Failed directory logins slow down and lock the account like local ones, whatever the case of the username
+	assert.Equal(t, http.StatusUnauthorized, login("ldap", "jdoe", "wrong-again").Code)
+	w = login("ldap", "JDoe", "directory-secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,107 @@
+//go:build !database
+
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMagicLink(t *testing.T) {
+	mailDir := t.TempDir()
+	t.Setenv("MAIL_DIR", mailDir)
+	router, _ := server.SetupRouter()
+	
+	requestLink := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/auth/magic-link", strings.NewReader(url.Values{"email": {email}}.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	emails := func() int {
+		files, _ := os.ReadDir(mailDir)
+		return len(files)
+	}
+	
+	// lastLink waits for the count-th email, which is sent in the background, and returns its link
+	lastLink := func(count int) string {
+		require.Eventually(t, func() bool { return emails() >= count }, time.Second, 5*time.Millisecond)
+		files, err := os.ReadDir(mailDir)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(mailDir, files[len(files)-1].Name()))
+		require.NoError(t, err)
+		
+		match := regexp.MustCompile(`/auth/magic-link/callback\?token=\S+`).FindString(string(data))
+		require.NotEmpty(t, match)
+		return match
+	}
+	
+	openLink := func(link string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", link, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. Known and unknown addresses get the same response; only the known one gets an email
+	w := requestLink("nobody@example.com")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	require.Len(t, w.Result().Cookies(), 1)
+	
+	assert.Never(t, func() bool { return emails() > 0 }, 100*time.Millisecond, 5*time.Millisecond)
+	
+	w = requestLink("test@example.com")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "magic_link_nonce", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	link := lastLink(1)
+	
+	// 2. Without the cookie the link is refused but stays usable
+	assert.Equal(t, http.StatusBadRequest, openLink(link, nil).Code)
+	
+	// 3. The browser that asked for it is logged in, once
+	w = openLink(link, cookies)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "access_token")
+	assert.Contains(t, w.Body.String(), "refresh_token")
+	
+	assert.Equal(t, http.StatusUnauthorized, openLink(link, cookies).Code)
+	
+	// 4. Another browser's cookie doesn't work
+	otherCookies := requestLink("nobody@example.com").Result().Cookies()
+	requestLink("test@example.com")
+	assert.Equal(t, http.StatusUnauthorized, openLink(lastLink(2), otherCookies).Code)
+	
+	// 5. Invalid requests are refused
+	assert.Equal(t, http.StatusBadRequest, requestLink("").Code)
+	assert.Equal(t, http.StatusBadRequest, openLink("/auth/magic-link/callback", cookies).Code)
+	
+	// 6. Each address can only be sent so many links
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusAccepted, requestLink("test@example.com").Code)
+	}
+	w = requestLink("test@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Empty(t, w.Result().Cookies())
+}
@@ -0,0 +1,434 @@
+//go:build !database
+
+package integration
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryClientCredentials(t *testing.T) {
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	request := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	tokenRequest := func(form url.Values, clientID, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		if clientID != "" {
+			req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+		}
+		return request(req)
+	}
+	
+	// 1. An admin registers a client; the secret is only shown in this response
+	req := httptest.NewRequest("POST", "/admin/oauth/clients",
+		strings.NewReader(`{"name":"Billing service","scopes":["invoices:read","invoices:write"],"token_lifetime":"10m"}`))
+	req.Header.Add("Authorization", "Bearer "+adminToken)
+	w := request(req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	
+	var registered struct {
+		ClientSecret string                 `json:"client_secret"`
+		Client       map[string]interface{} `json:"client"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	clientID := registered.Client["id"].(string)
+	assert.Equal(t, "10m0s", registered.Client["token_lifetime"])
+	
+	userToken := memoryLogin(t, router, "testuser", "password123")
+	req = httptest.NewRequest("GET", "/admin/oauth/clients", nil)
+	req.Header.Add("Authorization", "Bearer "+userToken)
+	assert.Equal(t, http.StatusForbidden, request(req).Code)
+	
+	// 2. The client gets a token with HTTP Basic authentication
+	w = tokenRequest(url.Values{"grant_type": {"client_credentials"}, "scope": {"invoices:read"}}, clientID, registered.ClientSecret)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	
+	var token struct {
+		AccessToken string  `json:"access_token"`
+		TokenType   string  `json:"token_type"`
+		ExpiresIn   float64 `json:"expires_in"`
+		Scope       string  `json:"scope"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, float64(600), token.ExpiresIn)
+	assert.Equal(t, "invoices:read", token.Scope)
+	
+	// 3. Or with its credentials in the form
+	w = tokenRequest(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {registered.ClientSecret},
+	}, "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"scope":"invoices:read invoices:write"`)
+	
+	// 4. Client tokens don't act as a user
+	req = httptest.NewRequest("GET", "/auth/me", nil)
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	assert.Equal(t, http.StatusUnauthorized, request(req).Code)
+	
+	// 5. Errors follow RFC 6749
+	w = tokenRequest(url.Values{"grant_type": {"client_credentials"}}, clientID, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	
+	w = tokenRequest(url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}}, clientID, registered.ClientSecret)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_scope"`)
+	
+	w = tokenRequest(url.Values{"grant_type": {"password"}}, clientID, registered.ClientSecret)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"unsupported_grant_type"`)
+	
+	w = tokenRequest(url.Values{}, clientID, registered.ClientSecret)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+	
+	// 6. Deleting the client ends its access
+	req = httptest.NewRequest("DELETE", "/admin/oauth/clients/"+clientID, nil)
+	req.Header.Add("Authorization", "Bearer "+adminToken)
+	assert.Equal(t, http.StatusNoContent, request(req).Code)
+	
+	w = tokenRequest(url.Values{"grant_type": {"client_credentials"}}, clientID, registered.ClientSecret)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMemoryAuthorizationCode(t *testing.T) {
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	request := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	postForm := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		return request(req)
+	}
+	
+	// 1. An admin registers a public client, e.g. a single-page app, which gets no secret
+	req := httptest.NewRequest("POST", "/admin/oauth/clients",
+		strings.NewReader(`{"name":"Web app","scopes":["profile"],"redirect_uris":["http://localhost:3000/callback"],"public":true}`))
+	req.Header.Add("Authorization", "Bearer "+adminToken)
+	w := request(req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "client_secret")
+	
+	var registered struct {
+		Client map[string]interface{} `json:"client"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	clientID := registered.Client["id"].(string)
+	assert.Equal(t, true, registered.Client["public"])
+	
+	verifier := "dBjftJeZ4CVP-mJ92K9BuBnr-FqeBNeXXtAz_xwa9V0"
+	sum := sha256.Sum256([]byte(verifier))
+	authorization := url.Values{
+		"client_id":             {clientID},
+		"redirect_uri":          {"http://localhost:3000/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"profile"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	
+	// 2. The user sees a login and consent page naming the client, which can't be framed
+	w = request(httptest.NewRequest("GET", "/oauth/authorize?"+authorization.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Web app")
+	assert.Contains(t, w.Body.String(), `name="password"`)
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	
+	// 3. A wrong password shows the page again
+	login := url.Values{}
+	for key, values := range authorization {
+		login[key] = values
+	}
+	login.Set("username", "testuser")
+	login.Set("password", "wrong")
+	login.Set("action", "allow")
+	w = postForm("/oauth/authorize", login)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid username or password")
+	
+	// 4. Logging in sends the user back to the client with a code and the state
+	login.Set("password", "password123")
+	w = postForm("/oauth/authorize", login)
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:3000", location.Host)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+	
+	// 5. The client exchanges the code and the verifier for a token that acts for the user
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {"http://localhost:3000/callback"},
+		"code_verifier": {verifier},
+	}
+	w = postForm("/oauth/token", exchange)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Scope       string `json:"scope"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, "profile", token.Scope)
+	
+	// The token is only good for its scopes, not as the user's own login
+	req = httptest.NewRequest("GET", "/auth/me", nil)
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	w = request(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	req = httptest.NewRequest("POST", "/auth/api-keys", strings.NewReader(`{"name":"stolen"}`))
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	w = request(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	req = httptest.NewRequest("GET", "/auth/api-keys", nil)
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	w = request(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	// 6. Codes work once
+	w = postForm("/oauth/token", exchange)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+	
+	// 7. Requests without PKCE go back to the client with an error
+	withoutPKCE := url.Values{}
+	for key, values := range authorization {
+		withoutPKCE[key] = values
+	}
+	withoutPKCE.Del("code_challenge")
+	w = request(httptest.NewRequest("GET", "/oauth/authorize?"+withoutPKCE.Encode(), nil))
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	location, err = url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	
+	// 8. Unregistered redirect URIs are never redirected to
+	withoutPKCE.Set("redirect_uri", "https://attacker.example.com/callback")
+	w = request(httptest.NewRequest("GET", "/oauth/authorize?"+withoutPKCE.Encode(), nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	
+	// 9. Users can say no
+	login.Set("action", "deny")
+	w = postForm("/oauth/authorize", login)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	location, err = url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "access_denied", location.Query().Get("error"))
+	assert.Empty(t, location.Query().Get("code"))
+}
+
+func TestMemoryOpenIDConnect(t *testing.T) {
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	request := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	postForm := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		return request(req)
+	}
+	
+	userInfo := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		return request(req)
+	}
+	
+	// 1. Clients find the endpoints in the discovery document
+	w := request(httptest.NewRequest("GET", "/.well-known/openid-configuration", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	
+	var discovery map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+	assert.Equal(t, "http://localhost:8080", discovery["issuer"])
+	assert.Equal(t, "http://localhost:8080/userinfo", discovery["userinfo_endpoint"])
+	assert.Equal(t, "http://localhost:8080/.well-known/jwks.json", discovery["jwks_uri"])
+	assert.Contains(t, discovery["scopes_supported"], "openid")
+	
+	// 2. A client logs the user in with the openid scope
+	req := httptest.NewRequest("POST", "/admin/oauth/clients",
+		strings.NewReader(`{"name":"Web app","redirect_uris":["http://localhost:3000/callback"],"public":true}`))
+	req.Header.Add("Authorization", "Bearer "+adminToken)
+	w = request(req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	
+	var registered struct {
+		Client map[string]interface{} `json:"client"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	clientID := registered.Client["id"].(string)
+	
+	verifier := "dBjftJeZ4CVP-mJ92K9BuBnr-FqeBNeXXtAz_xwa9V0"
+	sum := sha256.Sum256([]byte(verifier))
+	w = postForm("/oauth/authorize", url.Values{
+		"client_id":             {clientID},
+		"redirect_uri":          {"http://localhost:3000/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"openid email"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+		"username":              {"testuser"},
+		"password":              {"password123"},
+		"action":                {"allow"},
+	})
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	
+	// 3. The token response includes an ID token
+	w = postForm("/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {"http://localhost:3000/callback"},
+		"code_verifier": {verifier},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	require.NotEmpty(t, token.IDToken)
+	
+	// 4. Userinfo answers with the claims the scopes allow
+	w = userInfo(token.AccessToken)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claims))
+	assert.Equal(t, "test@example.com", claims["email"])
+	assert.Contains(t, claims, "email_verified")
+	assert.NotContains(t, claims, "preferred_username")
+	assert.NotEmpty(t, claims["sub"])
+	
+	// 5. ID tokens and tokens without the openid scope aren't accepted
+	w = userInfo(token.IDToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+	
+	w = userInfo(memoryLogin(t, router, "testuser", "password123"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "insufficient_scope")
+	
+	assert.Equal(t, http.StatusUnauthorized, userInfo("").Code)
+	
+	req = httptest.NewRequest("GET", "/auth/me", nil)
+	req.Header.Add("Authorization", "Bearer "+token.IDToken)
+	assert.Equal(t, http.StatusUnauthorized, request(req).Code)
+}
+
+func TestMemoryTokenIntrospection(t *testing.T) {
+	router, _ := server.SetupRouter()
+	adminToken := memoryLogin(t, router, "admin", "admin123")
+	
+	request := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	
+	// 1. A gateway registers as a client
+	req := httptest.NewRequest("POST", "/admin/oauth/clients", strings.NewReader(`{"name":"Legacy gateway"}`))
+	req.Header.Add("Authorization", "Bearer "+adminToken)
+	w := request(req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	
+	var registered struct {
+		ClientSecret string                 `json:"client_secret"`
+		Client       map[string]interface{} `json:"client"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	clientID := registered.Client["id"].(string)
+	
+	introspect := func(token, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/oauth/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+		return request(req)
+	}
+	
+	// 2. A user's token is active
+	userToken := memoryLogin(t, router, "testuser", "password123")
+	w = introspect(userToken, registered.ClientSecret)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	
+	var introspection map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &introspection))
+	assert.Equal(t, true, introspection["active"])
+	assert.Equal(t, "testuser", introspection["username"])
+	assert.NotEmpty(t, introspection["sub"])
+	assert.NotNil(t, introspection["exp"])
+	
+	// 3. After logout it isn't, and nothing else is said about it
+	req = httptest.NewRequest("POST", "/auth/logout", nil)
+	req.Header.Add("Authorization", "Bearer "+userToken)
+	require.Equal(t, http.StatusOK, request(req).Code)
+	
+	w = introspect(userToken, registered.ClientSecret)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"active":false}`, w.Body.String())
+	
+	w = introspect("garbage", registered.ClientSecret)
+	assert.JSONEq(t, `{"active":false}`, w.Body.String())
+	
+	// 4. Only registered clients may ask
+	w = introspect(userToken, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+	
+	w = introspect("", registered.ClientSecret)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+	
+	// 5. The discovery document lists the endpoint
+	w = request(httptest.NewRequest("GET", "/.well-known/openid-configuration", nil))
+	assert.Contains(t, w.Body.String(), `"introspection_endpoint":"http://localhost:8080/oauth/introspect"`)
+}
@@ -92,3 +92,212 @@ func TestMemoryProtectedEndpoint(t *testing.T) {
 	
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestMemoryRefreshToken(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	// 1. Login to get an access token and a refresh token
+	form := url.Values{}
+	form.Add("username", "testuser")
+	form.Add("password", "password123")
+	
+	loginReq := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+	loginReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	loginW := httptest.NewRecorder()
+	
+	router.ServeHTTP(loginW, loginReq)
+	
+	assert.Equal(t, http.StatusOK, loginW.Code)
+	
+	var loginResponse map[string]interface{}
+	err := json.Unmarshal(loginW.Body.Bytes(), &loginResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, loginResponse["token"], loginResponse["access_token"])
+	
+	refreshToken, ok := loginResponse["refresh_token"].(string)
+	assert.True(t, ok)
+	assert.NotEmpty(t, refreshToken)
+	
+	// 2. Exchange the refresh token for a new access token
+	form = url.Values{}
+	form.Add("refresh_token", refreshToken)
+	
+	refreshReq := httptest.NewRequest("POST", "/auth/refresh", strings.NewReader(form.Encode()))
+	refreshReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	refreshW := httptest.NewRecorder()
+	
+	router.ServeHTTP(refreshW, refreshReq)
+	
+	assert.Equal(t, http.StatusOK, refreshW.Code)
+	
+	var refreshResponse map[string]interface{}
+	err = json.Unmarshal(refreshW.Body.Bytes(), &refreshResponse)
+	assert.NoError(t, err)
+	
+	accessToken, ok := refreshResponse["access_token"].(string)
+	assert.True(t, ok)
+	
+	// 3. The new access token works on protected endpoints
+	meReq := httptest.NewRequest("GET", "/auth/me", nil)
+	meReq.Header.Add("Authorization", "Bearer "+accessToken)
+	meW := httptest.NewRecorder()
+	
+	router.ServeHTTP(meW, meReq)
+	
+	assert.Equal(t, http.StatusOK, meW.Code)
+	
+	// 4. Logout revokes the refresh token along with the access token
+	logoutReq := httptest.NewRequest("POST", "/auth/logout", strings.NewReader(form.Encode()))
+	logoutReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	logoutReq.Header.Add("Authorization", "Bearer "+accessToken)
+	logoutW := httptest.NewRecorder()
+	
+	router.ServeHTTP(logoutW, logoutReq)
+	
+	assert.Equal(t, http.StatusOK, logoutW.Code)
+	
+	refreshReq = httptest.NewRequest("POST", "/auth/refresh", strings.NewReader(form.Encode()))
+	refreshReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	refreshW = httptest.NewRecorder()
+	
+	router.ServeHTTP(refreshW, refreshReq)
+	
+	assert.Equal(t, http.StatusUnauthorized, refreshW.Code)
+}
+
+func TestMemoryLogoutAll(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	// 1. Login twice, as if from two devices
+	login := func() string {
+		form := url.Values{}
+		form.Add("username", "testuser")
+		form.Add("password", "password123")
+		
+		loginReq := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		loginReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		loginW := httptest.NewRecorder()
+		
+		router.ServeHTTP(loginW, loginReq)
+		
+		assert.Equal(t, http.StatusOK, loginW.Code)
+		
+		var loginResponse map[string]interface{}
+		err := json.Unmarshal(loginW.Body.Bytes(), &loginResponse)
+		assert.NoError(t, err)
+		
+		token, _ := loginResponse["access_token"].(string)
+		return token
+	}
+	laptopToken := login()
+	phoneToken := login()
+	
+	// 2. Logout everywhere from one device
+	logoutReq := httptest.NewRequest("POST", "/auth/logout-all", nil)
+	logoutReq.Header.Add("Authorization", "Bearer "+laptopToken)
+	logoutW := httptest.NewRecorder()
+	
+	router.ServeHTTP(logoutW, logoutReq)
+	
+	assert.Equal(t, http.StatusOK, logoutW.Code)
+	
+	// 3. Both devices are logged out
+	for _, token := range []string{laptopToken, phoneToken} {
+		meReq := httptest.NewRequest("GET", "/auth/me", nil)
+		meReq.Header.Add("Authorization", "Bearer "+token)
+		meW := httptest.NewRecorder()
+		
+		router.ServeHTTP(meW, meReq)
+		
+		assert.Equal(t, http.StatusUnauthorized, meW.Code)
+	}
+}
+
+func TestMemorySessions(t *testing.T) {
+	router, _ := server.SetupRouter()
+	
+	// 1. Login from two devices
+	login := func(userAgent string) string {
+		form := url.Values{}
+		form.Add("username", "testuser")
+		form.Add("password", "password123")
+		
+		loginReq := httptest.NewRequest("POST", "/auth/login", strings.NewReader(form.Encode()))
+		loginReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		loginReq.Header.Add("User-Agent", userAgent)
+		loginW := httptest.NewRecorder()
+		
+		router.ServeHTTP(loginW, loginReq)
+		
+		assert.Equal(t, http.StatusOK, loginW.Code)
+		
+		var loginResponse map[string]interface{}
+		err := json.Unmarshal(loginW.Body.Bytes(), &loginResponse)
+		assert.NoError(t, err)
+		
+		token, _ := loginResponse["access_token"].(string)
+		return token
+	}
+	laptopToken := login("laptop-browser")
+	phoneToken := login("phone-app")
+	
+	// 2. List sessions from the laptop
+	listReq := httptest.NewRequest("GET", "/auth/sessions", nil)
+	listReq.Header.Add("Authorization", "Bearer "+laptopToken)
+	listW := httptest.NewRecorder()
+	
+	router.ServeHTTP(listW, listReq)
+	
+	assert.Equal(t, http.StatusOK, listW.Code)
+	
+	var listResponse struct {
+		Sessions []struct {
+			ID        string `json:"id"`
+			IPAddress string `json:"ip_address"`
+			UserAgent string `json:"user_agent"`
+			Current   bool   `json:"current"`
+		} `json:"sessions"`
+	}
+	err := json.Unmarshal(listW.Body.Bytes(), &listResponse)
+	assert.NoError(t, err)
+	assert.Len(t, listResponse.Sessions, 2)
+	
+	var phoneSession string
+	for _, session := range listResponse.Sessions {
+		assert.NotEmpty(t, session.IPAddress)
+		assert.Equal(t, session.UserAgent == "laptop-browser", session.Current)
+		if session.UserAgent == "phone-app" {
+			phoneSession = session.ID
+		}
+	}
+	assert.NotEmpty(t, phoneSession)
+	
+	// 3. Revoke the phone's session from the laptop
+	deleteReq := httptest.NewRequest("DELETE", "/auth/sessions/"+phoneSession, nil)
+	deleteReq.Header.Add("Authorization", "Bearer "+laptopToken)
+	deleteW := httptest.NewRecorder()
+	
+	router.ServeHTTP(deleteW, deleteReq)
+	
+	assert.Equal(t, http.StatusOK, deleteW.Code)
+	
+	// 4. The phone is logged out, the laptop is not
+	for token, expected := range map[string]int{phoneToken: http.StatusUnauthorized, laptopToken: http.StatusOK} {
+		meReq := httptest.NewRequest("GET", "/auth/me", nil)
+		meReq.Header.Add("Authorization", "Bearer "+token)
+		meW := httptest.NewRecorder()
+		
+		router.ServeHTTP(meW, meReq)
+		
+		assert.Equal(t, expected, meW.Code)
+	}
+	
+	// 5. Unknown sessions are not found
+	deleteReq = httptest.NewRequest("DELETE", "/auth/sessions/"+phoneSession, nil)
+	deleteReq.Header.Add("Authorization", "Bearer "+laptopToken)
+	deleteW = httptest.NewRecorder()
+	
+	router.ServeHTTP(deleteW, deleteReq)
+	
+	assert.Equal(t, http.StatusNotFound, deleteW.Code)
+}
@@ -0,0 +1,146 @@
+//go:build !database
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/server"
+	"github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryWebAuthn(t *testing.T) {
+	router, _ := server.SetupRouter()
+	authenticator := webauthn.NewSoftwareAuthenticator("http://localhost:8080")
+	
+	request := func(method, path, token string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+	
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// publicKey decodes the options in a {"publicKey": ...} object
+	publicKey := func(body []byte, options interface{}) {
+		var wrapper struct {
+			PublicKey json.RawMessage `json:"publicKey"`
+		}
+		require.NoError(t, json.Unmarshal(body, &wrapper))
+		require.NoError(t, json.Unmarshal(wrapper.PublicKey, options))
+	}
+	// sign answers the options with the authenticator and returns the credential form value
+	sign := func(options *webauthn.RequestOptions) string {
+		assertion, err := authenticator.Get(options)
+		require.NoError(t, err)
+		response, err := json.Marshal(assertion)
+		require.NoError(t, err)
+		return string(response)
+	}
+	
+	// 1. Register a passkey
+	token := memoryLogin(t, router, "testuser", "password123")
+	
+	w := request("POST", "/auth/webauthn/register/begin", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	// Users with a password confirm with it, so a stolen token can't add a passkey
+	w = request("POST", "/auth/webauthn/register/begin", token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	
+	var creationOptions webauthn.CreationOptions
+	w = request("POST", "/auth/webauthn/register/begin", token, url.Values{"password": {"password123"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	publicKey(w.Body.Bytes(), &creationOptions)
+	assert.Equal(t, "localhost", creationOptions.RP.ID)
+	
+	created, err := authenticator.Create(&creationOptions)
+	require.NoError(t, err)
+	response, err := json.Marshal(created)
+	require.NoError(t, err)
+	
+	w = request("POST", "/auth/webauthn/register/finish", token, url.Values{"credential": {string(response)}, "name": {"Laptop"}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	
+	// The challenge was used up
+	w = request("POST", "/auth/webauthn/register/finish", token, url.Values{"credential": {string(response)}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	w = request("GET", "/auth/webauthn/credentials", token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Credentials []map[string]interface{} `json:"credentials"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Credentials, 1)
+	assert.Equal(t, "Laptop", list.Credentials[0]["name"])
+	assert.NotContains(t, list.Credentials[0], "public_key")
+	credentialID, _ := list.Credentials[0]["id"].(string)
+	
+	// 2. Log in without a password
+	var requestOptions webauthn.RequestOptions
+	w = request("POST", "/auth/webauthn/login/begin", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	publicKey(w.Body.Bytes(), &requestOptions)
+	
+	w = request("POST", "/auth/webauthn/login/finish", "", url.Values{"credential": {sign(&requestOptions)}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var login map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.NotEmpty(t, login["access_token"])
+	
+	w = request("POST", "/auth/webauthn/login/finish", "", url.Values{"credential": {`{"id":"AAAA"}`}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	
+	// 3. Password logins now need the passkey as a second factor
+	w = request("POST", "/auth/login", "", url.Values{"username": {"testuser"}, "password": {"password123"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.Equal(t, true, login["mfa_required"])
+	assert.Equal(t, []interface{}{"webauthn"}, login["mfa_methods"])
+	challenge, _ := login["mfa_token"].(string)
+	
+	var mfaLogin map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &mfaLogin))
+	publicKey(mfaLogin["webauthn_options"], &requestOptions)
+	w = request("POST", "/auth/login/mfa", "", url.Values{"mfa_token": {challenge}, "credential": {sign(&requestOptions)}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.NotEmpty(t, login["access_token"])
+	
+	// 4. With a passkey registered, adding another takes the password or the passkey,
+	// so a stolen token can't swap in its own
+	w = request("POST", "/auth/webauthn/register/begin", token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("POST", "/auth/webauthn/register/begin", token, url.Values{"password": {"wrong"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("POST", "/auth/webauthn/register/begin", token, url.Values{"password": {"password123"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	// 5. Removing the last passkey turns off two-factor authentication, so it takes the same confirmation
+	w = request("DELETE", "/auth/webauthn/credentials/unknown", token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	
+	w = request("DELETE", "/auth/webauthn/credentials/"+credentialID, token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("DELETE", "/auth/webauthn/credentials/"+credentialID, token, url.Values{"password": {"wrong"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	
+	w = request("POST", "/auth/webauthn/verify/begin", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	publicKey(w.Body.Bytes(), &requestOptions)
+	w = request("DELETE", "/auth/webauthn/credentials/"+credentialID, token, url.Values{"credential": {sign(&requestOptions)}})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	
+	assert.NotEmpty(t, memoryLogin(t, router, "testuser", "password123"))
+}
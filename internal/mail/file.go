@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes each message to a .eml file in a directory instead of
+// sending it. Useful in development, where the files can be opened with any mail client.
+type FileMailer struct {
+	dir     string
+	from    string
+	counter atomic.Uint64
+}
+
+// creates a mailer that writes messages to dir, creating it if needed
+func NewFileMailer(dir string, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+// Send writes the message to a new file
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	now := time.Now()
+	data, err := message.format(m.from, now)
+	if err != nil {
+		return err
+	}
+	
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102T150405.000000000"), m.counter.Add(1))
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
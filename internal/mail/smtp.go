@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig holds the connection settings for an SMTP relay
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Optional; PLAIN auth is used when set
+	Password string
+	From     string
+}
+
+// SMTPMailer sends email through an SMTP relay, using STARTTLS when the server offers it
+type SMTPMailer struct {
+	config SMTPConfig
+}
+
+// creates a new SMTP mailer
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &SMTPMailer{
+		config: config,
+	}
+}
+
+// Send delivers the message to the relay
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	data, err := message.format(m.config.From, time.Now())
+	if err != nil {
+		return err
+	}
+	
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	return smtp.SendMail(addr, auth, m.config.From, []string{message.To}, data)
+}
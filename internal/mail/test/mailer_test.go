@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := mail.NewMemoryMailer()
+	ctx := context.Background()
+	
+	require.NoError(t, mailer.Send(ctx, mail.Message{To: "a@example.com", Subject: "first"}))
+	require.NoError(t, mailer.Send(ctx, mail.Message{To: "b@example.com", Subject: "second"}))
+	require.NoError(t, mailer.Send(ctx, mail.Message{To: "a@example.com", Subject: "third"}))
+	
+	assert.Len(t, mailer.Messages(), 3)
+	
+	last, ok := mailer.Last("a@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "third", last.Subject)
+	
+	_, ok = mailer.Last("nobody@example.com")
+	assert.False(t, ok)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := mail.NewFileMailer(dir, "no-reply@example.com")
+	require.NoError(t, err)
+	ctx := context.Background()
+	
+	err = mailer.Send(ctx, mail.Message{
+		To:      "user@example.com",
+		Subject: "Welcome",
+		Body:    "line one\nline two\n",
+	})
+	require.NoError(t, err)
+	
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+	
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	message := string(data)
+	assert.Contains(t, message, "From: no-reply@example.com\r\n")
+	assert.Contains(t, message, "To: user@example.com\r\n")
+	assert.Contains(t, message, "Subject: Welcome\r\n")
+	assert.Contains(t, message, "\r\n\r\nline one\r\nline two\r\n")
+	
+	// Headers can't be smuggled in through line breaks
+	err = mailer.Send(ctx, mail.Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "x"})
+	assert.ErrorIs(t, err, mail.ErrInvalidHeader)
+}
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+)
+
+// userManager is implemented by providers that let administrators manage users
+type userManager interface {
+	CreateUser(ctx context.Context, user *local.StoredUser, password string) error
+	GetUser(ctx context.Context, id string) (*local.StoredUser, error)
+	ListUsers(ctx context.Context, query local.UserQuery) (*local.UserPage, error)
+	UpdateUser(ctx context.Context, id string, update local.UserUpdate) (*local.StoredUser, error)
+	DeleteUser(ctx context.Context, id string) error
+}
+
+// lockoutManager is implemented by providers that lock out accounts and IP addresses after failed logins
+type lockoutManager interface {
+	AccountLockout(ctx context.Context, userID string) (*local.LoginAttempts, error)
+	ClearAccountLockout(ctx context.Context, userID string) error
+	ListLockouts(ctx context.Context) ([]*local.LoginAttempts, error)
+	ClearIPLockout(ctx context.Context, ip string) error
+}
+
+// registerAdminRoutes adds the admin-only user management endpoints
+func registerAdminRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Create a user
+	mux.HandleFunc("POST /admin/users", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminUserManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Username      string                 `json:"username"`
+			Email         string                 `json:"email"`
+			EmailVerified bool                   `json:"email_verified"` // skips the verification email
+			Password      string                 `json:"password"`
+			Roles         []string               `json:"roles"`
+			Metadata      map[string]interface{} `json:"metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if request.Username == "" || request.Email == "" || request.Password == "" {
+			http.Error(w, "Username, email and password are required", http.StatusBadRequest)
+			return
+		}
+
+		user := &local.StoredUser{
+			Username:      request.Username,
+			Email:         request.Email,
+			EmailVerified: request.EmailVerified,
+			Roles:         request.Roles,
+			Metadata:      request.Metadata,
+		}
+		if err := manager.CreateUser(r.Context(), user, request.Password); err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"user": userResponse(user)})
+	})
+
+	// List and search users, one page at a time
+	mux.HandleFunc("GET /admin/users", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminUserManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		params := r.URL.Query()
+		query := local.UserQuery{
+			Search:        params.Get("q"),
+			Role:          params.Get("role"),
+			EmailDomain:   params.Get("email_domain"),
+			MetadataKey:   params.Get("metadata_key"),
+			MetadataValue: params.Get("metadata_value"),
+			Cursor:        params.Get("cursor"),
+		}
+		if query.MetadataValue != "" && query.MetadataKey == "" {
+			http.Error(w, "metadata_value requires metadata_key", http.StatusBadRequest)
+			return
+		}
+
+		switch params.Get("order") {
+		case "", "asc":
+		case "desc":
+			query.Descending = true
+		default:
+			http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+			return
+		}
+
+		if limit := params.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			query.Limit = n
+		}
+
+		page, err := manager.ListUsers(r.Context(), query)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		users := make([]map[string]interface{}, 0, len(page.Users))
+		for _, user := range page.Users {
+			users = append(users, userResponse(user))
+		}
+
+		response := map[string]interface{}{"users": users}
+		if page.NextCursor != "" {
+			response["next_cursor"] = page.NextCursor
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+
+	// Get a user
+	mux.HandleFunc("GET /admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminUserManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		user, err := manager.GetUser(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"user": userResponse(user)})
+	})
+
+	// Update a user. Only the fields present in the body are changed.
+	mux.HandleFunc("PATCH /admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminUserManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Username      *string                `json:"username"`
+			Email         *string                `json:"email"`
+			EmailVerified *bool                  `json:"email_verified"`
+			Password      *string                `json:"password"`
+			Roles         *[]string              `json:"roles"`
+			Metadata      map[string]interface{} `json:"metadata"` // null values remove keys
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, err := manager.UpdateUser(r.Context(), r.PathValue("id"), local.UserUpdate{
+			Username:      request.Username,
+			Email:         request.Email,
+			EmailVerified: request.EmailVerified,
+			Password:      request.Password,
+			Roles:         request.Roles,
+			Metadata:      request.Metadata,
+		})
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"user": userResponse(user)})
+	})
+
+	// Delete a user
+	mux.HandleFunc("DELETE /admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminUserManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		if err := manager.DeleteUser(r.Context(), r.PathValue("id")); err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// List the accounts and IP addresses that are locked out after failed logins
+	mux.HandleFunc("GET /admin/lockouts", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminLockoutManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		lockouts, err := manager.ListLockouts(r.Context())
+		if err != nil {
+			log.Printf("Lockout listing error: %v", err)
+			http.Error(w, "Error listing lockouts", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]map[string]interface{}, 0, len(lockouts))
+		for _, attempts := range lockouts {
+			entry := lockoutResponse(attempts)
+			if username, found := strings.CutPrefix(attempts.Key, local.AccountAttemptPrefix); found {
+				entry["type"] = "account"
+				entry["username"] = username
+			} else if ip, found := strings.CutPrefix(attempts.Key, local.IPAttemptPrefix); found {
+				entry["type"] = "ip"
+				entry["ip_address"] = ip
+			} else if email, found := strings.CutPrefix(attempts.Key, local.EmailAttemptPrefix); found {
+				entry["type"] = "email"
+				entry["email"] = email
+			} else if ip, found := strings.CutPrefix(attempts.Key, local.EmailIPAttemptPrefix); found {
+				entry["type"] = "email_ip"
+				entry["ip_address"] = ip
+			}
+			response = append(response, entry)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"lockouts": response})
+	})
+
+	// Unblock an IP address
+	mux.HandleFunc("DELETE /admin/lockouts/ip/{address}", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminLockoutManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		if err := manager.ClearIPLockout(r.Context(), r.PathValue("address")); err != nil {
+			log.Printf("Lockout clearing error: %v", err)
+			http.Error(w, "Error clearing lockout", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Show a user's failed logins and whether their account is locked
+	mux.HandleFunc("GET /admin/users/{id}/lockout", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminLockoutManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		attempts, err := manager.AccountLockout(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"lockout": lockoutResponse(attempts)})
+	})
+
+	// Unlock a user's account
+	mux.HandleFunc("DELETE /admin/users/{id}/lockout", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminLockoutManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		if err := manager.ClearAccountLockout(r.Context(), r.PathValue("id")); err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// adminUserManager authenticates the caller, checks they have the admin role
+// and returns the provider's user manager. On failure the error response has
+// already been written.
+func adminUserManager(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (userManager, bool) {
+	provider, ok := adminProvider(w, r, providerRegistry)
+	if !ok {
+		return nil, false
+	}
+
+	manager, ok := provider.(userManager)
+	if !ok {
+		http.Error(w, "User management not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+
+	return manager, true
+}
+
+// adminLockoutManager is like adminUserManager for the provider's lockout manager
+func adminLockoutManager(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (lockoutManager, bool) {
+	provider, ok := adminProvider(w, r, providerRegistry)
+	if !ok {
+		return nil, false
+	}
+
+	manager, ok := provider.(lockoutManager)
+	if !ok {
+		http.Error(w, "Login lockout not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+
+	return manager, true
+}
+
+// adminProvider authenticates the caller, checks they have the admin role and
+// returns the local provider. On failure the error response has already been written.
+func adminProvider(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (auth.Provider, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	provider, exists := providerRegistry.Get("local")
+	if !exists {
+		http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	user, err := provider.ValidateToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if !hasRole(user, "admin") {
+		http.Error(w, "Admin role required", http.StatusForbidden)
+		return nil, false
+	}
+
+	return provider, true
+}
+
+// hasRole reports whether the user has the given role
+func hasRole(user *auth.User, role string) bool {
+	for _, r := range user.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// userResponse builds the JSON representation of a user. The password hash is never included.
+func userResponse(user *local.StoredUser) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"roles":          user.Roles,
+		"metadata":       user.Metadata,
+		"created_at":     user.CreatedAt,
+		"updated_at":     user.UpdatedAt,
+	}
+}
+
+// lockoutResponse builds the JSON representation of failed login attempts
+func lockoutResponse(attempts *local.LoginAttempts) map[string]interface{} {
+	now := time.Now()
+	response := map[string]interface{}{
+		"failures": attempts.Failures,
+		"locked":   attempts.Locked(now),
+	}
+	if attempts.Failures > 0 {
+		response["last_failure"] = attempts.LastFailure
+	}
+	if attempts.Locked(now) {
+		response["locked_until"] = attempts.LockedUntil
+	}
+	return response
+}
+
+// writeUserError maps user management errors to HTTP responses
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, local.ErrUsernameTaken), errors.Is(err, local.ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, local.ErrInvalidPassword), errors.Is(err, local.ErrInvalidCursor),
+		errors.Is(err, local.ErrInvalidUsername), errors.Is(err, local.ErrInvalidEmail):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("User management error: %v", err)
+		http.Error(w, "Error managing user", http.StatusInternalServerError)
+	}
+}
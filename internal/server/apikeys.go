@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+)
+
+// apiKeyManager is implemented by providers that let users create API keys for machine clients
+type apiKeyManager interface {
+	CreateKey(ctx context.Context, userID string, name string, scopes []string, expiresAt time.Time) (string, *apikey.APIKey, error)
+	ListKeys(ctx context.Context, userID string) ([]*apikey.APIKey, error)
+	DeleteKey(ctx context.Context, userID string, id string) error
+}
+
+// registerAPIKeyRoutes adds the endpoints users manage their API keys with. They need a
+// token from a login; API keys can't be used to create more keys.
+func registerAPIKeyRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Create a key. The response is the only time the key is shown.
+	mux.HandleFunc("POST /auth/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedAPIKeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		var expiresAt time.Time
+		if expiresIn := r.FormValue("expires_in"); expiresIn != "" {
+			lifetime, err := time.ParseDuration(expiresIn)
+			if err != nil || lifetime <= 0 {
+				http.Error(w, "Invalid expires_in", http.StatusBadRequest)
+				return
+			}
+			expiresAt = time.Now().Add(lifetime)
+		}
+
+		key, stored, err := manager.CreateKey(r.Context(), user.ID, r.FormValue("name"),
+			strings.Fields(strings.ReplaceAll(r.FormValue("scopes"), ",", " ")), expiresAt)
+		switch {
+		case errors.Is(err, apikey.ErrInvalidName), errors.Is(err, apikey.ErrInvalidScope),
+			errors.Is(err, apikey.ErrInvalidExpiry):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, apikey.ErrTooManyKeys):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, local.ErrDirectoryManaged):
+			http.Error(w, "This account logs in through the directory", http.StatusForbidden)
+			return
+		case err != nil:
+			log.Printf("API key creation error: %v", err)
+			http.Error(w, "Error creating API key", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"key":     key,
+			"api_key": apiKeyResponse(stored),
+		})
+	})
+
+	// List the caller's keys; the keys themselves aren't included
+	mux.HandleFunc("GET /auth/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedAPIKeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		keys, err := manager.ListKeys(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error listing API keys: %v", err)
+			http.Error(w, "Error listing API keys", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]map[string]interface{}, 0, len(keys))
+		for _, key := range keys {
+			response = append(response, apiKeyResponse(key))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"api_keys": response})
+	})
+
+	// Delete a key; clients using it are refused from then on
+	mux.HandleFunc("DELETE /auth/api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedAPIKeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		err := manager.DeleteKey(r.Context(), user.ID, r.PathValue("id"))
+		if errors.Is(err, apikey.ErrKeyNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting API key: %v", err)
+			http.Error(w, "Error deleting API key", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// authenticatedAPIKeyManager authenticates the caller with the local provider and
+// returns the API key manager. On failure the error response has already been written.
+func authenticatedAPIKeyManager(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (apiKeyManager, *auth.User, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	provider, exists := providerRegistry.Get("local")
+	if !exists {
+		http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	apiKeyProvider, exists := providerRegistry.Get("apikey")
+	manager, ok := apiKeyProvider.(apiKeyManager)
+	if !exists || !ok {
+		http.Error(w, "API keys not supported", http.StatusNotImplemented)
+		return nil, nil, false
+	}
+
+	user, err := provider.ValidateToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	return manager, user, true
+}
+
+// tokenValidator returns the provider that validates bearer tokens on endpoints machine clients
+// may call: the API key provider, which accepts both API keys and JWTs, or else the local provider
+func tokenValidator(providerRegistry *auth.ProviderRegistry) (auth.Provider, bool) {
+	if provider, exists := providerRegistry.Get("apikey"); exists {
+		return provider, true
+	}
+	return providerRegistry.Get("local")
+}
+
+// apiKeyResponse converts an API key to its JSON representation; the key hash isn't included
+func apiKeyResponse(key *apikey.APIKey) map[string]interface{} {
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	response := map[string]interface{}{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       scopes,
+		"created_at":   key.CreatedAt.Format(time.RFC3339),
+		"expires_at":   nil,
+		"last_used_at": nil,
+	}
+	if !key.ExpiresAt.IsZero() {
+		response["expires_at"] = key.ExpiresAt.Format(time.RFC3339)
+	}
+	if !key.LastUsedAt.IsZero() {
+		response["last_used_at"] = key.LastUsedAt.Format(time.RFC3339)
+	}
+	return response
+}
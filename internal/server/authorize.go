@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+)
+
+// authorizationServer is implemented by providers that let users give OAuth clients access
+// to their account with the authorization code grant
+type authorizationServer interface {
+	CheckAuthorizationRequest(ctx context.Context, request oauthserver.AuthorizationRequest) (*oauthserver.Client, []string, error)
+	IssueAuthorizationCode(ctx context.Context, request oauthserver.AuthorizationRequest, user *auth.User, authTime time.Time) (string, error)
+}
+
+// authorizePage is what the login and consent page shows
+type authorizePage struct {
+	Request    oauthserver.AuthorizationRequest
+	ClientName string
+	Scopes     []string
+	Username   string
+	MFAToken   string // Set on the second step, for users with two-factor authentication
+	Error      string
+}
+
+// The login and consent page. The request's parameters travel along as hidden fields,
+// and are checked again when the form comes back.
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Authorize {{.ClientName}}</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 3rem auto; padding: 0 1rem; }
+label, input { display: block; width: 100%; box-sizing: border-box; }
+input { margin: 0.25rem 0 1rem; padding: 0.5rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>{{.ClientName}}</h1>
+<p><strong>{{.ClientName}}</strong> wants to access your account.</p>
+{{if .Scopes}}<p>It asks for:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+{{if .MFAToken}}<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label for="code">Authentication code or recovery code</label>
+<input id="code" name="code" autocomplete="one-time-code" required autofocus>
+{{else}}<label for="username">Username</label>
+<input id="username" name="username" value="{{.Username}}" autocomplete="username" required autofocus>
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required>
+{{end}}<button type="submit" name="action" value="allow">Allow</button>
+<button type="submit" name="action" value="deny" formnovalidate>Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// registerAuthorizeRoutes adds the authorization endpoint of the authorization code grant
+// (RFC 6749 section 4.1). Users log in with their password, and their second factor if
+// they have one, to give the client access; the code is exchanged at /oauth/token.
+func registerAuthorizeRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Show the login and consent page for a client's request
+	mux.HandleFunc("GET /oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		server, ok := authorizationServerProvider(w, providerRegistry)
+		if !ok {
+			return
+		}
+
+		request := authorizationRequest(r)
+		client, scopes, err := server.CheckAuthorizationRequest(r.Context(), request)
+		if err != nil {
+			writeAuthorizationError(w, r, request, err)
+			return
+		}
+
+		writeAuthorizePage(w, http.StatusOK, authorizePage{Request: request, ClientName: client.Name, Scopes: scopes})
+	})
+
+	// Log the user in and send them back to the client with a code, or with an error if they said no
+	mux.HandleFunc("POST /oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		server, ok := authorizationServerProvider(w, providerRegistry)
+		if !ok {
+			return
+		}
+
+		request := authorizationRequest(r)
+		client, scopes, err := server.CheckAuthorizationRequest(r.Context(), request)
+		if err != nil {
+			writeAuthorizationError(w, r, request, err)
+			return
+		}
+
+		if r.PostFormValue("action") == "deny" {
+			redirectToClient(w, r, request, url.Values{"error": {"access_denied"}})
+			return
+		}
+
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+
+		page := authorizePage{Request: request, ClientName: client.Name, Scopes: scopes, Username: r.PostFormValue("username")}
+		user, ok := authorizeLogin(w, r, provider, providerRegistry, page)
+		if !ok {
+			return
+		}
+
+		code, err := server.IssueAuthorizationCode(r.Context(), request, user, time.Now())
+		if err != nil {
+			writeAuthorizationError(w, r, request, err)
+			return
+		}
+
+		redirectToClient(w, r, request, url.Values{"code": {code}})
+	})
+}
+
+// authorizeLogin checks the password on the page, or the second factor on its second step, and returns
+// the user. Otherwise the page has been shown again with the problem, or the error response has been written.
+func authorizeLogin(w http.ResponseWriter, r *http.Request, provider auth.Provider, providerRegistry *auth.ProviderRegistry, page authorizePage) (*auth.User, bool) {
+	mfa, hasMFA := provider.(mfaAuthenticator)
+
+	var user *auth.User
+	var err error
+	if challenge := r.PostFormValue("mfa_token"); challenge != "" && hasMFA {
+		user, err = mfa.CompleteMFAChallenge(r.Context(), challenge, r.PostFormValue("code"))
+	} else {
+		creds := auth.Credentials{
+			Type:     "password",
+			Username: r.PostFormValue("username"),
+			Password: r.PostFormValue("password"),
+			Provider: provider.Name(),
+		}
+		if throttled, ok := provider.(clientAuthenticator); ok {
+			user, err = throttled.AuthenticateFrom(r.Context(), creds, clientInfo(r))
+		} else {
+			user, err = provider.Authenticate(r.Context(), creds)
+		}
+	}
+
+	var throttledErr *local.LoginThrottledError
+	switch {
+	case errors.As(err, &throttledErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+		page.Error = "Too many failed login attempts; try again later"
+		writeAuthorizePage(w, http.StatusTooManyRequests, page)
+		return nil, false
+	case errors.Is(err, local.ErrEmailNotVerified):
+		page.Error = "Verify your email address before logging in"
+		writeAuthorizePage(w, http.StatusForbidden, page)
+		return nil, false
+	case errors.Is(err, local.ErrInvalidOneTimeToken), errors.Is(err, local.ErrInvalidMFACode):
+		page.Error = "Invalid code; log in again"
+		writeAuthorizePage(w, http.StatusUnauthorized, page)
+		return nil, false
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrUserNotFound):
+		page.Error = "Invalid username or password"
+		writeAuthorizePage(w, http.StatusUnauthorized, page)
+		return nil, false
+	case err != nil:
+		log.Printf("Authorization login error: %v", err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !hasMFA || r.PostFormValue("mfa_token") != "" {
+		return user, true
+	}
+
+	// Users with two-factor authentication enter a code on a second step
+	challenge, err := mfa.StartMFAChallenge(r.Context(), user)
+	if err != nil {
+		log.Printf("MFA challenge error: %v", err)
+		http.Error(w, "Error starting two-factor authentication", http.StatusInternalServerError)
+		return nil, false
+	}
+	if challenge == nil {
+		return user, true
+	}
+
+	if !slices.Contains(challenge.Methods, local.MFAMethodTOTP) && !slices.Contains(challenge.Methods, local.MFAMethodRecoveryCode) {
+		page.Error = "Your account needs a security key, which this page doesn't support"
+		writeAuthorizePage(w, http.StatusForbidden, page)
+		return nil, false
+	}
+
+	page.MFAToken = challenge.Token
+	writeAuthorizePage(w, http.StatusOK, page)
+	return nil, false
+}
+
+// authorizationServerProvider returns the authorization server. If it isn't available the error response has already been written.
+func authorizationServerProvider(w http.ResponseWriter, providerRegistry *auth.ProviderRegistry) (authorizationServer, bool) {
+	provider, exists := providerRegistry.Get("oauthserver")
+	server, ok := provider.(authorizationServer)
+	if !exists || !ok {
+		http.Error(w, "OAuth clients not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+	return server, true
+}
+
+// authorizationRequest reads an authorization request from the query, or from the page's form
+func authorizationRequest(r *http.Request) oauthserver.AuthorizationRequest {
+	return oauthserver.AuthorizationRequest{
+		ClientID:            r.FormValue("client_id"),
+		RedirectURI:         r.FormValue("redirect_uri"),
+		ResponseType:        r.FormValue("response_type"),
+		Scope:               r.FormValue("scope"),
+		State:               r.FormValue("state"),
+		Nonce:               r.FormValue("nonce"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+	}
+}
+
+// writeAuthorizationError sends the user back to the client with the error, or shows it to
+// them if the client or redirect URI is the problem, since the URI can't be trusted then
+func writeAuthorizationError(w http.ResponseWriter, r *http.Request, request oauthserver.AuthorizationRequest, err error) {
+	var authorizationErr *oauthserver.AuthorizationError
+	switch {
+	case errors.As(err, &authorizationErr):
+		params := url.Values{"error": {authorizationErr.Code}}
+		if authorizationErr.Description != "" {
+			params.Set("error_description", authorizationErr.Description)
+		}
+		redirectToClient(w, r, request, params)
+	case errors.Is(err, oauthserver.ErrInvalidClient):
+		http.Error(w, "Unknown client", http.StatusBadRequest)
+	case errors.Is(err, oauthserver.ErrInvalidRedirectURI):
+		http.Error(w, "Missing redirect_uri or not registered for this client", http.StatusBadRequest)
+	default:
+		log.Printf("Authorization error: %v", err)
+		http.Error(w, "Error authorizing client", http.StatusInternalServerError)
+	}
+}
+
+// redirectToClient sends the user to the request's redirect URI, which must have been checked, with
+// params and the request's state added to its query (RFC 6749 section 4.1.2)
+func redirectToClient(w http.ResponseWriter, r *http.Request, request oauthserver.AuthorizationRequest, params url.Values) {
+	redirectURL, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		http.Error(w, "Invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	query := redirectURL.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	redirectURL.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, redirectURL.String(), http.StatusSeeOther)
+}
+
+// writeAuthorizePage renders the login and consent page. Other sites can't frame it,
+// so they can't trick users into clicking Allow.
+func writeAuthorizePage(w http.ResponseWriter, status int, page authorizePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+
+	if err := authorizeTemplate.Execute(w, page); err != nil {
+		log.Printf("Authorization page error: %v", err)
+	}
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2"
+)
+
+// Cookie binding an external login to the browser that started it, so nobody
+// can finish their own login in someone else's browser
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateMaxAge = 10 * time.Minute
+)
+
+// externalLoginProvider is implemented by providers that log users in at an external identity provider
+type externalLoginProvider interface {
+	auth.Provider
+	BeginLogin(ctx context.Context) (*oauth2.AuthorizationRequest, error)
+}
+
+// identityLinker is implemented by providers that map external identities to their users
+type identityLinker interface {
+	LoginWithIdentity(ctx context.Context, external local.ExternalIdentity) (*auth.User, error)
+	LinkIdentity(ctx context.Context, userID string, external local.ExternalIdentity) (*local.Identity, error)
+	ListIdentities(ctx context.Context, userID string) ([]*local.Identity, error)
+	UnlinkIdentity(ctx context.Context, userID string, provider string, subject string) error
+	StartIdentityLink(ctx context.Context, userID string, state string) error
+	ConsumeIdentityLink(ctx context.Context, state string) (string, error)
+}
+
+// registerIdentityRoutes adds the external login endpoints and the endpoints for managing linked identities
+func registerIdentityRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Send the browser to the identity provider to log in
+	mux.HandleFunc("GET /auth/oauth/{provider}/login", func(w http.ResponseWriter, r *http.Request) {
+		external, ok := externalProvider(w, providerRegistry, r.PathValue("provider"))
+		if !ok {
+			return
+		}
+
+		request, err := external.BeginLogin(r.Context())
+		if err != nil {
+			writeExternalLoginError(w, err)
+			return
+		}
+
+		setOAuthStateCookie(w, r, external.Name(), request.State)
+		http.Redirect(w, r, request.URL, http.StatusFound)
+	})
+
+	// The identity provider sends the browser back here. Logs in the user linked to
+	// the identity, creating an account if needed, or finishes linking the identity
+	// to the user who started it at POST /auth/identities/{provider}.
+	mux.HandleFunc("GET /auth/oauth/{provider}/callback", func(w http.ResponseWriter, r *http.Request) {
+		external, ok := externalProvider(w, providerRegistry, r.PathValue("provider"))
+		if !ok {
+			return
+		}
+
+		if r.FormValue("error") != "" {
+			http.Error(w, "Login was refused or cancelled at the identity provider", http.StatusUnauthorized)
+			return
+		}
+
+		state := r.FormValue("state")
+		cookie, err := r.Cookie(oauthStateCookie)
+		if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			http.Error(w, "Login state mismatch; start the login again", http.StatusBadRequest)
+			return
+		}
+		clearOAuthStateCookie(w, r, external.Name())
+
+		externalUser, err := external.Authenticate(r.Context(), auth.Credentials{
+			Type:     "oauth",
+			Provider: external.Name(),
+			Params: map[string]interface{}{
+				"code":  r.FormValue("code"),
+				"state": state,
+			},
+		})
+		if err != nil {
+			writeExternalLoginError(w, err)
+			return
+		}
+
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+
+		linker, ok := provider.(identityLinker)
+		if !ok {
+			http.Error(w, "External logins not supported", http.StatusNotImplemented)
+			return
+		}
+
+		identity := externalIdentity(external.Name(), externalUser)
+
+		// A logged-in user linking another identity
+		userID, err := linker.ConsumeIdentityLink(r.Context(), state)
+		if err == nil {
+			linked, err := linker.LinkIdentity(r.Context(), userID, identity)
+			if err != nil {
+				writeIdentityError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"identity": identityResponse(linked)})
+			return
+		}
+		if !errors.Is(err, local.ErrInvalidOneTimeToken) {
+			log.Printf("Identity link error: %v", err)
+			http.Error(w, "Error linking identity", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := linker.LoginWithIdentity(r.Context(), identity)
+		switch {
+		case errors.Is(err, local.ErrRegistrationDisabled):
+			http.Error(w, "No account is linked to this identity", http.StatusForbidden)
+			return
+		case errors.Is(err, local.ErrEmailTaken):
+			http.Error(w, "An account with this email address already exists; log in and link the identity to it", http.StatusConflict)
+			return
+		case errors.Is(err, local.ErrInvalidEmail):
+			http.Error(w, "The identity provider didn't share a valid email address", http.StatusBadRequest)
+			return
+		case errors.Is(err, local.ErrEmailNotVerified):
+			http.Error(w, "Email address not verified", http.StatusForbidden)
+			return
+		case errors.Is(err, local.ErrDirectoryManaged):
+			http.Error(w, "This account logs in through the directory", http.StatusForbidden)
+			return
+		case err != nil:
+			log.Printf("External login error: %v", err)
+			http.Error(w, "Error logging in", http.StatusInternalServerError)
+			return
+		}
+
+		// Two-factor authentication applies to external logins as well
+		if mfa, ok := provider.(mfaAuthenticator); ok {
+			challenge, err := mfa.StartMFAChallenge(r.Context(), user)
+			if err != nil {
+				log.Printf("MFA challenge error: %v", err)
+				http.Error(w, "Error starting two-factor authentication", http.StatusInternalServerError)
+				return
+			}
+			if challenge != nil {
+				writeMFAChallenge(w, r, providerRegistry, user, challenge)
+				return
+			}
+		}
+
+		writeLoginResponse(w, r, provider, user)
+	})
+
+	// List the external identities linked to the current user
+	mux.HandleFunc("GET /auth/identities", func(w http.ResponseWriter, r *http.Request) {
+		linker, user, ok := authenticatedIdentityLinker(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		identities, err := linker.ListIdentities(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Identity listing error: %v", err)
+			http.Error(w, "Error listing identities", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]map[string]interface{}, 0, len(identities))
+		for _, identity := range identities {
+			response = append(response, identityResponse(identity))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"identities": response})
+	})
+
+	// Start linking an identity at an external provider to the current user.
+	// Returns the URL to send the browser to; the login finishes at the callback.
+	// The user confirms with their password or a passkey, so a stolen token can't
+	// link someone else's identity that would outlast logging out everywhere.
+	mux.HandleFunc("POST /auth/identities/{provider}", func(w http.ResponseWriter, r *http.Request) {
+		linker, user, ok := authenticatedIdentityLinker(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		if !confirmStepUp(w, r, providerRegistry, user, r.PostFormValue("password"), r.PostFormValue("credential")) {
+			return
+		}
+
+		external, ok := externalProvider(w, providerRegistry, r.PathValue("provider"))
+		if !ok {
+			return
+		}
+
+		request, err := external.BeginLogin(r.Context())
+		if err != nil {
+			writeExternalLoginError(w, err)
+			return
+		}
+
+		if err := linker.StartIdentityLink(r.Context(), user.ID, request.State); err != nil {
+			log.Printf("Identity link error: %v", err)
+			http.Error(w, "Error linking identity", http.StatusInternalServerError)
+			return
+		}
+
+		setOAuthStateCookie(w, r, external.Name(), request.State)
+		writeJSON(w, http.StatusOK, map[string]string{"authorization_url": request.URL})
+	})
+
+	// Unlink one of the current user's identities
+	mux.HandleFunc("DELETE /auth/identities/{provider}/{subject}", func(w http.ResponseWriter, r *http.Request) {
+		linker, user, ok := authenticatedIdentityLinker(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		err := linker.UnlinkIdentity(r.Context(), user.ID, r.PathValue("provider"), r.PathValue("subject"))
+		if err != nil {
+			writeIdentityError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// externalProvider returns the registered external login provider with this name.
+// On failure the error response has already been written.
+func externalProvider(w http.ResponseWriter, providerRegistry *auth.ProviderRegistry, name string) (externalLoginProvider, bool) {
+	provider, exists := providerRegistry.Get(name)
+	external, ok := provider.(externalLoginProvider)
+	if !exists || !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return nil, false
+	}
+	return external, true
+}
+
+// authenticatedIdentityLinker authenticates the caller with the local provider and
+// returns it as an identityLinker. On failure the error response has already been written.
+func authenticatedIdentityLinker(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (identityLinker, *auth.User, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	provider, exists := providerRegistry.Get("local")
+	if !exists {
+		http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	linker, ok := provider.(identityLinker)
+	if !ok {
+		http.Error(w, "External logins not supported", http.StatusNotImplemented)
+		return nil, nil, false
+	}
+
+	user, err := provider.ValidateToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	return linker, user, true
+}
+
+// externalIdentity reads the identity from a user returned by an external provider,
+// which puts its subject and whether the email address is verified in the metadata
+func externalIdentity(provider string, user *auth.User) local.ExternalIdentity {
+	subject, _ := user.Metadata["subject"].(string)
+	if subject == "" {
+		subject = user.ID
+	}
+	verified, _ := user.Metadata["email_verified"].(bool)
+
+	return local.ExternalIdentity{
+		Provider:      provider,
+		Subject:       subject,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: verified,
+		Roles:         user.Roles,
+	}
+}
+
+// setOAuthStateCookie binds the state of an external login to the browser
+func setOAuthStateCookie(w http.ResponseWriter, r *http.Request, provider string, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/auth/oauth/" + provider,
+		MaxAge:   int(oauthStateMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode, // Sent along when the identity provider redirects back
+	})
+}
+
+// clearOAuthStateCookie removes the state cookie once the login has come back
+func clearOAuthStateCookie(w http.ResponseWriter, r *http.Request, provider string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     "/auth/oauth/" + provider,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// writeExternalLoginError maps errors from external login providers to HTTP responses
+func writeExternalLoginError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	case errors.Is(err, oauth2.ErrDiscovery):
+		log.Printf("Identity provider error: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+	default:
+		log.Printf("External login error: %v", err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+	}
+}
+
+// writeIdentityError maps errors from linking and unlinking identities to HTTP responses
+func writeIdentityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, local.ErrIdentityNotFound):
+		http.Error(w, "Identity not found", http.StatusNotFound)
+	case errors.Is(err, local.ErrIdentityLinked):
+		http.Error(w, "Identity is already linked to another account", http.StatusConflict)
+	case errors.Is(err, local.ErrLastLoginMethod):
+		http.Error(w, "Can't unlink the only way to log in; set a password first", http.StatusConflict)
+	case errors.Is(err, local.ErrDirectoryManaged):
+		http.Error(w, "This account logs in through the directory", http.StatusForbidden)
+	default:
+		log.Printf("Identity error: %v", err)
+		http.Error(w, "Error updating identities", http.StatusInternalServerError)
+	}
+}
+
+// identityResponse converts a linked identity to its JSON representation
+func identityResponse(identity *local.Identity) map[string]interface{} {
+	return map[string]interface{}{
+		"provider":      identity.Provider,
+		"subject":       identity.Subject,
+		"email":         identity.Email,
+		"created_at":    identity.CreatedAt.Format(time.RFC3339),
+		"last_login_at": identity.LastLoginAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink"
+)
+
+// Cookie binding a login link to the browser that asked for it, so a link
+// forwarded to or intercepted by someone else doesn't log them in
+const magicLinkCookie = "magic_link_nonce"
+
+// magicLinkSender is implemented by providers that log users in with emailed links
+type magicLinkSender interface {
+	auth.Provider
+	SendLink(ctx context.Context, email string) (string, error)
+	LinkExpiration() time.Duration
+}
+
+// registerMagicLinkRoutes adds the endpoints for logging in with a link sent by email
+func registerMagicLinkRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Email a login link. Only this browser can use it.
+	mux.HandleFunc("POST /auth/magic-link", func(w http.ResponseWriter, r *http.Request) {
+		email := r.FormValue("email")
+		if email == "" {
+			http.Error(w, "Missing email", http.StatusBadRequest)
+			return
+		}
+
+		sender, ok := magicLinkProvider(w, providerRegistry)
+		if !ok {
+			return
+		}
+
+		if !throttleEmailRequest(w, r, providerRegistry, email) {
+			return
+		}
+
+		nonce, err := sender.SendLink(r.Context(), email)
+		if err != nil {
+			log.Printf("Login link request error: %v", err)
+			http.Error(w, "Error sending login link", http.StatusInternalServerError)
+			return
+		}
+
+		// Same response whether or not the address has an account
+		http.SetCookie(w, &http.Cookie{
+			Name:     magicLinkCookie,
+			Value:    nonce,
+			Path:     "/auth/magic-link",
+			MaxAge:   int(sender.LinkExpiration().Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode, // Sent along when the link is opened from a mail client
+		})
+		writeJSON(w, http.StatusAccepted, map[string]string{
+			"message": "If the address belongs to an account, a login link has been sent",
+		})
+	})
+
+	// The link from the email; tokens are issued by the local provider
+	mux.HandleFunc("GET /auth/magic-link/callback", func(w http.ResponseWriter, r *http.Request) {
+		token := r.FormValue("token")
+		if token == "" {
+			http.Error(w, "Missing token", http.StatusBadRequest)
+			return
+		}
+
+		// Leave the link unused, e.g. for links opened by a mail scanner or on another device
+		cookie, err := r.Cookie(magicLinkCookie)
+		if err != nil || cookie.Value == "" {
+			http.Error(w, "Open the link in the browser you asked for it from", http.StatusBadRequest)
+			return
+		}
+
+		sender, ok := magicLinkProvider(w, providerRegistry)
+		if !ok {
+			return
+		}
+
+		tokenProvider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := sender.Authenticate(r.Context(), auth.Credentials{
+			Type:     "emaillink",
+			Provider: sender.Name(),
+			Token:    token,
+			Params:   map[string]interface{}{"nonce": cookie.Value},
+		})
+		switch {
+		case errors.Is(err, emaillink.ErrWrongBrowser):
+			http.Error(w, "Open the link in the browser you asked for it from", http.StatusUnauthorized)
+			return
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			http.Error(w, "Invalid or expired login link", http.StatusUnauthorized)
+			return
+		case err != nil:
+			log.Printf("Login link error: %v", err)
+			http.Error(w, "Error logging in", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     magicLinkCookie,
+			Path:     "/auth/magic-link",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		// The link replaces the password, not the second factor
+		if mfa, ok := tokenProvider.(mfaAuthenticator); ok {
+			challenge, err := mfa.StartMFAChallenge(r.Context(), user)
+			if err != nil {
+				log.Printf("MFA challenge error: %v", err)
+				http.Error(w, "Error starting two-factor authentication", http.StatusInternalServerError)
+				return
+			}
+			if challenge != nil {
+				writeMFAChallenge(w, r, providerRegistry, user, challenge)
+				return
+			}
+		}
+
+		writeLoginResponse(w, r, tokenProvider, user)
+	})
+}
+
+// magicLinkProvider returns the email link provider. If it isn't available the error response has already been written.
+func magicLinkProvider(w http.ResponseWriter, providerRegistry *auth.ProviderRegistry) (magicLinkSender, bool) {
+	provider, exists := providerRegistry.Get("emaillink")
+	sender, ok := provider.(magicLinkSender)
+	if !exists || !ok {
+		http.Error(w, "Login links not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+	return sender, true
+}
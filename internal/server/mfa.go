@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+)
+
+// mfaAuthenticator is implemented by providers that support two-step logins
+type mfaAuthenticator interface {
+	StartMFAChallenge(ctx context.Context, user *auth.User) (*local.MFAChallenge, error)
+	CompleteMFAChallenge(ctx context.Context, challenge string, code string) (*auth.User, error)
+	CompleteMFAChallengeWith(ctx context.Context, challenge string, verify func(user *auth.User) error) (*auth.User, error)
+}
+
+// mfaManager is implemented by providers that let users set up two-factor authentication
+type mfaManager interface {
+	EnrollTOTP(ctx context.Context, userID string) (*local.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID string, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID string, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error)
+}
+
+// registerMFARoutes adds the second login step and the two-factor authentication setup endpoints
+func registerMFARoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Complete a login with the challenge from /auth/login and a TOTP code, a recovery code
+	// or the response of a security key to webauthn_options
+	mux.HandleFunc("POST /auth/login/mfa", func(w http.ResponseWriter, r *http.Request) {
+		challenge := r.FormValue("mfa_token")
+		code := r.FormValue("code")
+		credential := r.FormValue("credential")
+		if challenge == "" || (code == "" && credential == "") {
+			http.Error(w, "Missing mfa_token, code or credential", http.StatusBadRequest)
+			return
+		}
+
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+
+		mfa, ok := provider.(mfaAuthenticator)
+		if !ok {
+			http.Error(w, "Two-factor authentication not supported", http.StatusNotImplemented)
+			return
+		}
+
+		var user *auth.User
+		var err error
+		if credential != "" {
+			verifier, ok := securityKeyProvider(providerRegistry)
+			if !ok {
+				http.Error(w, "Security keys not supported", http.StatusNotImplemented)
+				return
+			}
+			user, err = mfa.CompleteMFAChallengeWith(r.Context(), challenge, func(user *auth.User) error {
+				return verifier.VerifySecondFactor(r.Context(), user.ID, []byte(credential))
+			})
+		} else {
+			user, err = mfa.CompleteMFAChallenge(r.Context(), challenge, code)
+		}
+		var throttledErr *local.LoginThrottledError
+		switch {
+		case errors.As(err, &throttledErr):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+			http.Error(w, "Too many failed login attempts; try again later", http.StatusTooManyRequests)
+			return
+		case errors.Is(err, local.ErrInvalidOneTimeToken):
+			http.Error(w, "Invalid or expired MFA token; log in again", http.StatusUnauthorized)
+			return
+		case errors.Is(err, local.ErrInvalidMFACode):
+			http.Error(w, "Invalid code; log in again", http.StatusUnauthorized)
+			return
+		case err != nil:
+			log.Printf("MFA login error: %v", err)
+			http.Error(w, "Error completing login", http.StatusInternalServerError)
+			return
+		}
+
+		writeLoginResponse(w, r, provider, user)
+	})
+
+	// Start setting up an authenticator app
+	mux.HandleFunc("POST /auth/mfa/totp/enroll", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedMFAManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		enrollment, err := manager.EnrollTOTP(r.Context(), user.ID)
+		if err != nil {
+			writeMFAError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"secret":      enrollment.Secret,
+			"otpauth_uri": enrollment.URI,
+		})
+	})
+
+	// Turn on two-factor authentication with a first code from the app
+	mux.HandleFunc("POST /auth/mfa/totp/confirm", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedMFAManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		codes, err := manager.ConfirmTOTP(r.Context(), user.ID, r.FormValue("code"))
+		if err != nil {
+			writeMFAError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+	})
+
+	// Turn off two-factor authentication
+	mux.HandleFunc("POST /auth/mfa/totp/disable", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedMFAManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		if err := manager.DisableTOTP(r.Context(), user.ID, r.FormValue("code")); err != nil {
+			writeMFAError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+	})
+
+	// Replace the recovery codes, e.g. after using some of them
+	mux.HandleFunc("POST /auth/mfa/recovery-codes", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedMFAManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		codes, err := manager.RegenerateRecoveryCodes(r.Context(), user.ID, r.FormValue("code"))
+		if err != nil {
+			writeMFAError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+	})
+}
+
+// writeMFAChallenge answers a login that needs a second factor. Users with security keys
+// also get the options for navigator.credentials.get(), so they don't need another round trip.
+func writeMFAChallenge(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry, user *auth.User, challenge *local.MFAChallenge) {
+	response := map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    challenge.Token,
+		"mfa_methods":  challenge.Methods,
+	}
+
+	if verifier, ok := securityKeyProvider(providerRegistry); ok && slices.Contains(challenge.Methods, verifier.Name()) {
+		options, err := verifier.BeginSecondFactor(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Security key challenge error: %v", err)
+			http.Error(w, "Error starting two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		response["webauthn_options"] = map[string]interface{}{"publicKey": options}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// authenticatedMFAManager authenticates the caller and returns the provider's
+// MFA manager. On failure the error response has already been written.
+func authenticatedMFAManager(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (mfaManager, *auth.User, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	provider, exists := providerRegistry.Get("local")
+	if !exists {
+		http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	manager, ok := provider.(mfaManager)
+	if !ok {
+		http.Error(w, "Two-factor authentication not supported", http.StatusNotImplemented)
+		return nil, nil, false
+	}
+
+	user, err := provider.ValidateToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	return manager, user, true
+}
+
+// writeMFAError maps two-factor authentication setup errors to HTTP responses
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, local.ErrMFAAlreadyEnabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, local.ErrMFANotEnrolled), errors.Is(err, local.ErrInvalidMFACode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("MFA error: %v", err)
+		http.Error(w, "Error managing two-factor authentication", http.StatusInternalServerError)
+	}
+}
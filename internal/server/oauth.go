@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+)
+
+// tokenEndpoint is implemented by providers that issue tokens to registered OAuth clients
+type tokenEndpoint interface {
+	ClientCredentialsGrant(ctx context.Context, clientID string, secret string, scope string) (*oauthserver.Token, error)
+	AuthorizationCodeGrant(ctx context.Context, clientID string, secret string, code string, redirectURI string, codeVerifier string) (*oauthserver.Token, error)
+}
+
+// tokenIntrospector is implemented by providers that tell registered clients whether tokens are active
+type tokenIntrospector interface {
+	Introspect(ctx context.Context, clientID string, secret string, token string) (*oauthserver.Introspection, error)
+}
+
+// clientManager is implemented by providers that let administrators register OAuth clients
+type clientManager interface {
+	CreateClient(ctx context.Context, registration oauthserver.Registration) (string, *oauthserver.Client, error)
+	ListClients(ctx context.Context) ([]*oauthserver.Client, error)
+	DeleteClient(ctx context.Context, id string) error
+}
+
+// registerOAuthRoutes adds the OAuth 2.0 token and introspection endpoints and the admin-only client registry endpoints
+func registerOAuthRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Issue tokens to clients, for themselves (RFC 6749 section 4.4) or for a user in exchange
+	// for an authorization code from /oauth/authorize (section 4.1.3). Clients authenticate with
+	// HTTP Basic or with client_id and client_secret in the form; public clients only send client_id.
+	mux.HandleFunc("POST /oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		provider, exists := providerRegistry.Get("oauthserver")
+		endpoint, ok := provider.(tokenEndpoint)
+		if !exists || !ok {
+			http.Error(w, "OAuth clients not supported", http.StatusNotImplemented)
+			return
+		}
+
+		clientID, secret, ok := clientCredentials(r)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Use one way of client authentication")
+			return
+		}
+
+		var token *oauthserver.Token
+		var err error
+		switch r.PostFormValue("grant_type") {
+		case "client_credentials":
+			token, err = endpoint.ClientCredentialsGrant(r.Context(), clientID, secret, r.PostFormValue("scope"))
+		case "authorization_code":
+			token, err = endpoint.AuthorizationCodeGrant(r.Context(), clientID, secret, r.PostFormValue("code"),
+				r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+		case "":
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing grant_type")
+			return
+		default:
+			writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+			return
+		}
+
+		switch {
+		case errors.Is(err, oauthserver.ErrInvalidClient):
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Unknown client or wrong secret")
+			return
+		case errors.Is(err, oauthserver.ErrInvalidScope):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		case errors.Is(err, oauthserver.ErrInvalidRequest):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		case errors.Is(err, oauthserver.ErrInvalidGrant):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+			return
+		case err != nil:
+			log.Printf("Token endpoint error: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		response := map[string]interface{}{
+			"access_token": token.AccessToken,
+			"token_type":   token.TokenType,
+			"expires_in":   int64(token.ExpiresIn.Seconds()),
+		}
+		if token.Scope != "" {
+			response["scope"] = token.Scope
+		}
+		if token.IDToken != "" {
+			response["id_token"] = token.IDToken
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+
+	// Tell a client whether a token is active and who it's for (RFC 7662), for gateways that can't
+	// validate tokens themselves. Clients authenticate like at the token endpoint, but need a secret.
+	mux.HandleFunc("POST /oauth/introspect", func(w http.ResponseWriter, r *http.Request) {
+		provider, exists := providerRegistry.Get("oauthserver")
+		introspector, ok := provider.(tokenIntrospector)
+		if !exists || !ok {
+			http.Error(w, "OAuth clients not supported", http.StatusNotImplemented)
+			return
+		}
+
+		clientID, secret, ok := clientCredentials(r)
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Use one way of client authentication")
+			return
+		}
+
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing token")
+			return
+		}
+
+		introspection, err := introspector.Introspect(r.Context(), clientID, secret, token)
+		switch {
+		case errors.Is(err, oauthserver.ErrInvalidClient):
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Unknown client or wrong secret")
+			return
+		case err != nil:
+			log.Printf("Introspection error: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, introspectionResponse(introspection))
+	})
+
+	// Register a client. The response is the only time its secret is shown; public clients don't get one.
+	mux.HandleFunc("POST /admin/oauth/clients", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminClientManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			TokenLifetime string   `json:"token_lifetime"` // e.g. "30m"; the default lifetime if empty
+			RedirectURIs  []string `json:"redirect_uris"`
+			Public        bool     `json:"public"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var lifetime time.Duration
+		if request.TokenLifetime != "" {
+			var err error
+			if lifetime, err = time.ParseDuration(request.TokenLifetime); err != nil || lifetime <= 0 {
+				http.Error(w, "Invalid token_lifetime", http.StatusBadRequest)
+				return
+			}
+		}
+
+		secret, client, err := manager.CreateClient(r.Context(), oauthserver.Registration{
+			Name:          request.Name,
+			Scopes:        request.Scopes,
+			TokenLifetime: lifetime,
+			RedirectURIs:  request.RedirectURIs,
+			Public:        request.Public,
+		})
+		switch {
+		case errors.Is(err, oauthserver.ErrInvalidName), errors.Is(err, oauthserver.ErrInvalidScope),
+			errors.Is(err, oauthserver.ErrInvalidLifetime), errors.Is(err, oauthserver.ErrInvalidRedirectURI):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Client registration error: %v", err)
+			http.Error(w, "Error registering client", http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{"client": clientResponse(client)}
+		if secret != "" {
+			response["client_secret"] = secret
+		}
+		writeJSON(w, http.StatusCreated, response)
+	})
+
+	// List the registered clients; their secrets aren't included
+	mux.HandleFunc("GET /admin/oauth/clients", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminClientManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		clients, err := manager.ListClients(r.Context())
+		if err != nil {
+			log.Printf("Error listing clients: %v", err)
+			http.Error(w, "Error listing clients", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]map[string]interface{}, 0, len(clients))
+		for _, client := range clients {
+			response = append(response, clientResponse(client))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"clients": response})
+	})
+
+	// Delete a client; its tokens stop working straight away
+	mux.HandleFunc("DELETE /admin/oauth/clients/{id}", func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := adminClientManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		err := manager.DeleteClient(r.Context(), r.PathValue("id"))
+		if errors.Is(err, oauthserver.ErrClientNotFound) {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting client: %v", err)
+			http.Error(w, "Error deleting client", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// adminClientManager authenticates the caller, checks they have the admin role and returns
+// the client registry. On failure the error response has already been written.
+func adminClientManager(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (clientManager, bool) {
+	if _, ok := adminProvider(w, r, providerRegistry); !ok {
+		return nil, false
+	}
+
+	provider, exists := providerRegistry.Get("oauthserver")
+	manager, ok := provider.(clientManager)
+	if !exists || !ok {
+		http.Error(w, "OAuth clients not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+
+	return manager, true
+}
+
+// clientCredentials returns the client ID and secret from HTTP Basic authentication, whose
+// parts are form-encoded (RFC 6749 section 2.3.1), or from the form. Using both isn't allowed.
+func clientCredentials(r *http.Request) (string, string, bool) {
+	username, password, basic := r.BasicAuth()
+	formID, formSecret := r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	if !basic {
+		return formID, formSecret, true
+	}
+	if formSecret != "" {
+		return "", "", false
+	}
+
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err := url.QueryUnescape(password)
+	if err != nil {
+		return "", "", false
+	}
+	return clientID, secret, true
+}
+
+// writeOAuthError writes an OAuth 2.0 error response (RFC 6749 section 5.2)
+func writeOAuthError(w http.ResponseWriter, status int, code string, description string) {
+	response := map[string]string{"error": code}
+	if description != "" {
+		response["error_description"] = description
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, response)
+}
+
+// introspectionResponse converts an introspection to its JSON representation (RFC 7662 section 2.2),
+// leaving out what doesn't apply. Inactive tokens only get "active": false.
+func introspectionResponse(introspection *oauthserver.Introspection) map[string]interface{} {
+	response := map[string]interface{}{"active": introspection.Active}
+	if !introspection.Active {
+		return response
+	}
+
+	if introspection.Subject != "" {
+		response["sub"] = introspection.Subject
+	}
+	if introspection.Username != "" {
+		response["username"] = introspection.Username
+	}
+	if introspection.ClientID != "" {
+		response["client_id"] = introspection.ClientID
+	}
+	if introspection.Scope != "" {
+		response["scope"] = introspection.Scope
+	}
+	if !introspection.ExpiresAt.IsZero() {
+		response["exp"] = introspection.ExpiresAt.Unix()
+	}
+	return response
+}
+
+// clientResponse converts a client to its JSON representation; the secret hash isn't included
+func clientResponse(client *oauthserver.Client) map[string]interface{} {
+	scopes := client.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	redirectURIs := client.RedirectURIs
+	if redirectURIs == nil {
+		redirectURIs = []string{}
+	}
+
+	response := map[string]interface{}{
+		"id":             client.ID,
+		"name":           client.Name,
+		"scopes":         scopes,
+		"redirect_uris":  redirectURIs,
+		"public":         client.Public,
+		"token_lifetime": nil,
+		"created_at":     client.CreatedAt.Format(time.RFC3339),
+	}
+	if client.TokenLifetime > 0 {
+		response["token_lifetime"] = client.TokenLifetime.String()
+	}
+	return response
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+)
+
+// openIDProvider is implemented by providers that act as an OpenID Connect provider for OAuth clients
+type openIDProvider interface {
+	OpenIDConfiguration() *oauthserver.OpenIDConfiguration
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+}
+
+// registerOpenIDRoutes adds the OpenID Connect discovery document and userinfo endpoint.
+// ID tokens are issued by /oauth/token and verified with the keys at /.well-known/jwks.json.
+func registerOpenIDRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Tell clients where the endpoints are and what they support
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := openIDProviderFor(w, providerRegistry)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, provider.OpenIDConfiguration())
+	})
+
+	// Return the claims about the user an access token with the openid scope was issued for
+	// (OpenID Connect Core section 5.3). Clients may use GET or POST.
+	userInfo := func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := openIDProviderFor(w, providerRegistry)
+		if !ok {
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := provider.UserInfo(r.Context(), token)
+		switch {
+		case errors.Is(err, oauthserver.ErrInsufficientScope):
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			writeOAuthError(w, http.StatusForbidden, "insufficient_scope", err.Error())
+			return
+		case errors.Is(err, oauthserver.ErrInvalidAccessToken):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "Invalid, expired or revoked access token")
+			return
+		case err != nil:
+			log.Printf("Userinfo error: %v", err)
+			http.Error(w, "Error getting user info", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, claims)
+	}
+	mux.HandleFunc("GET /userinfo", userInfo)
+	mux.HandleFunc("POST /userinfo", userInfo)
+}
+
+// openIDProviderFor returns the OpenID Connect provider. If it isn't available the error response has already been written.
+func openIDProviderFor(w http.ResponseWriter, providerRegistry *auth.ProviderRegistry) (openIDProvider, bool) {
+	provider, exists := providerRegistry.Get("oauthserver")
+	openID, ok := provider.(openIDProvider)
+	if !exists || !ok {
+		http.Error(w, "OpenID Connect not supported", http.StatusNotImplemented)
+		return nil, false
+	}
+	return openID, true
+}
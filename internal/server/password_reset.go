@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+)
+
+// passwordResetPage is what the page behind the password reset link shows
+type passwordResetPage struct {
+	Token string
+	Error string
+	Done  bool
+}
+
+// The page the password reset email links to. It posts the token from the link and the
+// new password to the reset endpoint, which shows the page again with the outcome.
+var passwordResetTemplate = template.Must(template.New("password-reset").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Reset your password</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 3rem auto; padding: 0 1rem; }
+label, input { display: block; width: 100%; box-sizing: border-box; }
+input { margin: 0.25rem 0 1rem; padding: 0.5rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Reset your password</h1>
+{{if .Done}}<p>Your password has been reset. Please log in again.</p>
+{{else}}{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Token}}<form method="post" action="/auth/password/reset">
+<input type="hidden" name="token" value="{{.Token}}">
+<label for="password">New password</label>
+<input id="password" name="password" type="password" autocomplete="new-password" required autofocus>
+<button type="submit">Reset password</button>
+</form>{{end}}{{end}}
+</body>
+</html>
+`))
+
+// registerPasswordResetPage adds the page password reset emails link to
+func registerPasswordResetPage(mux *http.ServeMux) {
+	mux.HandleFunc("GET /auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			writePasswordResetPage(w, http.StatusBadRequest, passwordResetPage{Error: "Invalid or expired password reset link"})
+			return
+		}
+
+		// The token is only checked when the form comes back, so opening the link doesn't use it up
+		writePasswordResetPage(w, http.StatusOK, passwordResetPage{Token: token})
+	})
+}
+
+// wantsHTML reports whether a request came from a browser, e.g. the password reset page's form,
+// rather than from an API client
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// writePasswordResetResult shows the outcome of a reset posted from the page. A rejected
+// password doesn't use up the token, so the form is shown again to try another one.
+func writePasswordResetResult(w http.ResponseWriter, token string, err error) {
+	switch {
+	case errors.Is(err, local.ErrInvalidOneTimeToken):
+		writePasswordResetPage(w, http.StatusBadRequest, passwordResetPage{Error: "Invalid or expired password reset link"})
+	case errors.Is(err, local.ErrInvalidPassword):
+		writePasswordResetPage(w, http.StatusBadRequest, passwordResetPage{Token: token, Error: err.Error()})
+	case err != nil:
+		log.Printf("Password reset error: %v", err)
+		writePasswordResetPage(w, http.StatusInternalServerError, passwordResetPage{Error: "Error resetting password"})
+	default:
+		writePasswordResetPage(w, http.StatusOK, passwordResetPage{Done: true})
+	}
+}
+
+// writePasswordResetPage renders the password reset page. The token is in the page's URL,
+// so it isn't cached or sent along to other sites.
+func writePasswordResetPage(w http.ResponseWriter, status int, page passwordResetPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+
+	if err := passwordResetTemplate.Execute(w, page); err != nil {
+		log.Printf("Password reset page error: %v", err)
+	}
+}
@@ -2,17 +2,36 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey"
+	apikeypg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/apikey/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink"
+	emaillinkpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/emaillink/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/ldap"
 	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
 	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2"
+	oauthpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauth2/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver"
+	oauthserverpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/oauthserver/postgres"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn"
+	webauthnpg "github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn/postgres"
 	"github.com/NBDor/Go-Auth-Service/internal/database"
+	"github.com/NBDor/Go-Auth-Service/internal/mail"
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -61,46 +80,384 @@ func SetupRouter() (http.Handler, *auth.ProviderRegistry) {
 		fmt.Fprintf(w, `{"status":"ok","providers":["%s"]}`, strings.Join(providerNames, `","`))
 	})
 
+	// Publish the public keys used to sign tokens so other services can verify them
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		keySet := jwt.JWKSet{Keys: []jwt.JWK{}}
+		if publisher, ok := provider.(interface{ JWKS() jwt.JWKSet }); ok {
+			keySet = publisher.JWKS()
+		}
+		
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, keySet)
+	})
+
 	// Add a basic authentication endpoint
 	mux.HandleFunc("POST /auth/login", func(w http.ResponseWriter, r *http.Request) {
 		// Parse username and password from request
 		username := r.FormValue("username")
 		password := r.FormValue("password")
 
+		// Passwords are checked by the local provider unless another one, such as ldap, is named
+		providerName := r.FormValue("provider")
+		if providerName == "" {
+			providerName = "local"
+		}
+
 		// Authenticate the user
 		creds := auth.Credentials{
 			Type:     "password",
 			Username: username,
 			Password: password,
-			Provider: "local",
+			Provider: providerName,
 		}
 
-		provider, exists := providerRegistry.Get("local")
+		// The local provider issues the tokens whichever provider checked the password
+		tokenProvider, exists := providerRegistry.Get("local")
 		if !exists {
 			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
 			return
 		}
+		
+		provider, exists := providerRegistry.Get(providerName)
+		if !exists {
+			http.Error(w, "Unknown authentication provider", http.StatusBadRequest)
+			return
+		}
 
-		user, err := provider.Authenticate(r.Context(), creds)
+		// Providers that throttle failed logins per IP address need to know the client.
+		// The local provider throttles the others, such as ldap, per account and IP address too.
+		var user *auth.User
+		var err error
+		if throttled, ok := provider.(clientAuthenticator); ok {
+			user, err = throttled.AuthenticateFrom(r.Context(), creds, clientInfo(r))
+		} else if throttler, ok := tokenProvider.(loginThrottler); ok {
+			user, err = throttler.AuthenticateWith(r.Context(), provider, creds, clientInfo(r))
+		} else {
+			user, err = provider.Authenticate(r.Context(), creds)
+		}
+		var throttledErr *local.LoginThrottledError
+		if errors.As(err, &throttledErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+			http.Error(w, "Too many failed login attempts; try again later", http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, local.ErrEmailNotVerified) {
+			http.Error(w, "Email address not verified", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, ldap.ErrNotProvisioned) || errors.Is(err, ldap.ErrAccountConflict) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, ldap.ErrUnavailable) {
+			log.Printf("Directory login error: %v", err)
+			http.Error(w, "Directory unavailable", http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
 
-		// Generate a JWT token
-		ctx := context.WithValue(r.Context(), "user", user)
-		token, err := provider.RefreshToken(ctx, "")
+		// Users with two-factor authentication get a challenge to complete at /auth/login/mfa instead of tokens
+		if mfa, ok := tokenProvider.(mfaAuthenticator); ok {
+			challenge, err := mfa.StartMFAChallenge(r.Context(), user)
+			if err != nil {
+				log.Printf("MFA challenge error: %v", err)
+				http.Error(w, "Error starting two-factor authentication", http.StatusInternalServerError)
+				return
+			}
+			if challenge != nil {
+				writeMFAChallenge(w, r, providerRegistry, user, challenge)
+				return
+			}
+		}
+		
+		writeLoginResponse(w, r, tokenProvider, user)
+	})
+
+	// Self-service sign-up
+	mux.HandleFunc("POST /auth/register", func(w http.ResponseWriter, r *http.Request) {
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		registrar, ok := provider.(userRegistrar)
+		if !ok {
+			http.Error(w, "Registration not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		user, pair, err := registrar.Register(r.Context(), r.FormValue("username"), r.FormValue("email"),
+			r.FormValue("password"), clientInfo(r))
+		switch {
+		case errors.Is(err, local.ErrRegistrationDisabled):
+			http.Error(w, "Registration is disabled", http.StatusForbidden)
+			return
+		case errors.Is(err, local.ErrInvalidUsername), errors.Is(err, local.ErrInvalidEmail),
+			errors.Is(err, local.ErrInvalidPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, local.ErrUsernameTaken), errors.Is(err, local.ErrEmailTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Printf("Registration error: %v", err)
+			http.Error(w, "Error registering user", http.StatusInternalServerError)
+			return
+		}
+		
+		// Include tokens when the user was logged in right away
+		response := map[string]interface{}{}
+		if pair != nil {
+			response = tokenPairResponse(pair)
+		}
+		response["user"] = map[string]interface{}{
+			"id":       user.ID,
+			"username": user.Username,
+			"email":    user.Email,
+		}
+		writeJSON(w, http.StatusCreated, response)
+	})
+
+	// Confirm an email address with the token from a verification email
+	mux.HandleFunc("GET /auth/verify-email", func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			http.Error(w, "Missing token", http.StatusBadRequest)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		verifier, ok := provider.(emailVerifier)
+		if !ok {
+			http.Error(w, "Email verification not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		_, err := verifier.VerifyEmail(r.Context(), token)
+		if errors.Is(err, local.ErrInvalidOneTimeToken) {
+			http.Error(w, "Invalid or expired verification link", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
-			log.Printf("Token generation error: %v", err)
-			http.Error(w, "Error generating token", http.StatusInternalServerError)
+			log.Printf("Email verification error: %v", err)
+			http.Error(w, "Error verifying email address", http.StatusInternalServerError)
 			return
 		}
+		
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Email address verified"})
+	})
 
-		// Return token in response
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"token":"%s","user":{"id":"%s","username":"%s","email":"%s"}}`, 
-			token, user.ID, user.Username, user.Email)
+	// Send a new verification email
+	mux.HandleFunc("POST /auth/verify-email/resend", func(w http.ResponseWriter, r *http.Request) {
+		email := r.FormValue("email")
+		if email == "" {
+			http.Error(w, "Missing email", http.StatusBadRequest)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		verifier, ok := provider.(emailVerifier)
+		if !ok {
+			http.Error(w, "Email verification not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		if err := verifier.ResendVerificationEmail(r.Context(), email); err != nil {
+			log.Printf("Verification email error: %v", err)
+			http.Error(w, "Error sending verification email", http.StatusInternalServerError)
+			return
+		}
+		
+		// Same response whether or not the address has an account
+		writeJSON(w, http.StatusAccepted, map[string]string{
+			"message": "If the address belongs to an unverified account, a verification email has been sent",
+		})
+	})
+
+	// Email a password reset link
+	mux.HandleFunc("POST /auth/password/forgot", func(w http.ResponseWriter, r *http.Request) {
+		email := r.FormValue("email")
+		if email == "" {
+			http.Error(w, "Missing email", http.StatusBadRequest)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		resetter, ok := provider.(passwordResetter)
+		if !ok {
+			http.Error(w, "Password reset not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		if !throttleEmailRequest(w, r, providerRegistry, email) {
+			return
+		}
+		
+		if err := resetter.RequestPasswordReset(r.Context(), email); err != nil {
+			log.Printf("Password reset request error: %v", err)
+			http.Error(w, "Error requesting password reset", http.StatusInternalServerError)
+			return
+		}
+		
+		// Same response whether or not the address has an account
+		writeJSON(w, http.StatusAccepted, map[string]string{
+			"message": "If the address belongs to an account, a password reset email has been sent",
+		})
+	})
+
+	// Set a new password with the token from a password reset email
+	mux.HandleFunc("POST /auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		token := r.FormValue("token")
+		password := r.FormValue("password")
+		if token == "" || password == "" {
+			http.Error(w, "Missing token or password", http.StatusBadRequest)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		resetter, ok := provider.(passwordResetter)
+		if !ok {
+			http.Error(w, "Password reset not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		_, err := resetter.ResetPassword(r.Context(), token, password)
+		
+		// Browsers posting the reset page's form get the page back instead of JSON
+		if wantsHTML(r) {
+			writePasswordResetResult(w, token, err)
+			return
+		}
+		
+		switch {
+		case errors.Is(err, local.ErrInvalidOneTimeToken):
+			http.Error(w, "Invalid or expired password reset link", http.StatusBadRequest)
+			return
+		case errors.Is(err, local.ErrInvalidPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Password reset error: %v", err)
+			http.Error(w, "Error resetting password", http.StatusInternalServerError)
+			return
+		}
+		
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset; please log in again"})
+	})
+
+	// Change the caller's password. Their other sessions are logged out.
+	mux.HandleFunc("POST /auth/password/change", func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			return
+		}
+		
+		currentPassword := r.FormValue("current_password")
+		newPassword := r.FormValue("new_password")
+		if currentPassword == "" || newPassword == "" {
+			http.Error(w, "Missing current_password or new_password", http.StatusBadRequest)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		changer, ok := provider.(passwordChanger)
+		if !ok {
+			http.Error(w, "Password change not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		user, err := provider.ValidateToken(r.Context(), token)
+		if err != nil {
+			http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+			return
+		}
+		
+		sessionID, _ := user.Metadata["session_id"].(string)
+		err = changer.ChangePassword(r.Context(), user.ID, currentPassword, newPassword, sessionID)
+		switch {
+		case errors.Is(err, local.ErrIncorrectPassword):
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+			return
+		case errors.Is(err, local.ErrInvalidPassword), errors.Is(err, local.ErrPasswordReused):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Password change error: %v", err)
+			http.Error(w, "Error changing password", http.StatusInternalServerError)
+			return
+		}
+		
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Password changed; other sessions have been logged out"})
+	})
+
+	// Exchange a refresh token for a new access token
+	mux.HandleFunc("POST /auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		refreshToken := r.FormValue("refresh_token")
+		if refreshToken == "" {
+			http.Error(w, "Missing refresh_token", http.StatusBadRequest)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		issuer, ok := provider.(tokenPairIssuer)
+		if !ok {
+			http.Error(w, "Refresh tokens not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		pair, err := issuer.RefreshTokens(r.Context(), refreshToken)
+		if err != nil {
+			if errors.Is(err, local.ErrInvalidRefreshToken) || errors.Is(err, local.ErrRefreshTokenReused) {
+				http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+				return
+			}
+			log.Printf("Token refresh error: %v", err)
+			http.Error(w, "Error refreshing token", http.StatusInternalServerError)
+			return
+		}
+		
+		writeJSON(w, http.StatusOK, tokenPairResponse(pair))
 	})
 
 	// Add a protected endpoint that requires authentication
@@ -118,8 +475,8 @@ func SetupRouter() (http.Handler, *auth.ProviderRegistry) {
 			token = auth[7:]
 		}
 		
-		// Get the provider
-		provider, exists := providerRegistry.Get("local")
+		// Get the provider; API keys are accepted as well as tokens from a login
+		provider, exists := tokenValidator(providerRegistry)
 		if !exists {
 			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
 			return
@@ -134,10 +491,14 @@ func SetupRouter() (http.Handler, *auth.ProviderRegistry) {
 		}
 		
 		// Return user info
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"user":{"id":"%s","username":"%s","email":"%s","roles":["%s"]}}`,
-			user.ID, user.Username, user.Email, strings.Join(user.Roles, "\",\""))
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"user": map[string]interface{}{
+				"id":       user.ID,
+				"username": user.Username,
+				"email":    user.Email,
+				"roles":    user.Roles,
+			},
+		})
 	})
 
 	// Add a token revocation endpoint
@@ -170,86 +531,914 @@ func SetupRouter() (http.Handler, *auth.ProviderRegistry) {
 			return
 		}
 		
+		// Revoke the refresh token too, if the client sent it
+		if refreshToken := r.FormValue("refresh_token"); refreshToken != "" {
+			if issuer, ok := provider.(tokenPairIssuer); ok {
+				if err := issuer.RevokeRefreshToken(r.Context(), refreshToken); err != nil {
+					log.Printf("Refresh token revocation error: %v", err)
+					http.Error(w, "Error revoking token", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		
 		// Return success response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, `{"message":"Successfully logged out"}`)
 	})
 
-	return mux, providerRegistry
-}
-
-// useInMemoryStorage sets up the in-memory user store for authentication
-func useInMemoryStorage(registry *auth.ProviderRegistry) {
-	userStore := local.NewMemoryUserStore()
-	tokenStore := local.NewMemoryTokenStore()
-	localProviderConfig := getJWTConfig()
-	localProvider := local.NewProviderWithRevocation(localProviderConfig, userStore, tokenStore)
-	registry.Register(localProvider)
-
-	// Add a sample user for testing
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
-	sampleUser := &local.StoredUser{
-		Username:     "testuser",
-		Email:        "test@example.com",
-		PasswordHash: string(hashedPassword),
-		Roles:        []string{"user"},
-		Metadata:     map[string]interface{}{"created_by": "system"},
-	}
-	ctx := context.Background()
-	_ = userStore.Create(ctx, sampleUser)
-	
-	log.Println("Initialized in-memory user store with test user: testuser")
-}
-
-// usePostgresStorage sets up the PostgreSQL user store for authentication
-func usePostgresStorage(registry *auth.ProviderRegistry, db *sqlx.DB) {
-	userStore := postgres.NewSQLUserStore(db)
-	tokenStore := postgres.NewTokenStore(db)
-	localProviderConfig := getJWTConfig()
-	log.Printf("Using JWT config: secret=%s, expiry=%s", 
-		localProviderConfig.JWTSecret[:3]+"...", localProviderConfig.TokenExpiration)
-	
-	localProvider := local.NewProviderWithRevocation(localProviderConfig, userStore, tokenStore)
-	registry.Register(localProvider)
-
-	// Check if we need to create an admin user
-	ctx := context.Background()
-	_, err := userStore.GetByUsername(ctx, "admin")
-	if err != nil && err.Error() == auth.ErrUserNotFound.Error() {
-		// Create default admin user
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-		adminUser := &local.StoredUser{
-			Username:     "admin",
-			Email:        "admin@example.com",
-			PasswordHash: string(hashedPassword),
-			Roles:        []string{"admin", "user"},
-			Metadata:     map[string]interface{}{"created_by": "system"},
+	// Revoke every token issued to the caller, logging them out on all devices
+	mux.HandleFunc("POST /auth/logout-all", func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			return
 		}
-		err = userStore.Create(ctx, adminUser)
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		revoker, ok := provider.(userTokenRevoker)
+		if !ok {
+			http.Error(w, "Logout everywhere not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		user, err := provider.ValidateToken(r.Context(), token)
 		if err != nil {
-			log.Printf("Failed to create admin user: %v", err)
-		} else {
-			log.Println("Created default admin user: admin")
+			http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+			return
 		}
-	}
-}
-
-// Get the JWT configuration from environment variables
-func getJWTConfig() local.Config {
-	config := local.DefaultConfig()
-	
-	// Get JWT secret from env
-	if secret := os.Getenv("JWT_SECRET"); secret != "" {
-		config.JWTSecret = secret
-	}
-	
-	// Get token expiry from env
-	if expiryStr := os.Getenv("TOKEN_EXPIRY"); expiryStr != "" {
-		if expiry, err := time.ParseDuration(expiryStr); err == nil {
-			config.TokenExpiration = expiry
+		
+		if err := revoker.RevokeAllTokens(r.Context(), user.ID); err != nil {
+			log.Printf("Token revocation error: %v", err)
+			http.Error(w, "Error revoking tokens", http.StatusInternalServerError)
+			return
 		}
-	}
-	
+		
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Successfully logged out of all sessions"})
+	})
+
+	// List the caller's active sessions
+	mux.HandleFunc("GET /auth/sessions", func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		manager, ok := provider.(sessionManager)
+		if !ok {
+			http.Error(w, "Sessions not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		user, err := provider.ValidateToken(r.Context(), token)
+		if err != nil {
+			http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+			return
+		}
+		
+		sessions, err := manager.ListSessions(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Session listing error: %v", err)
+			http.Error(w, "Error listing sessions", http.StatusInternalServerError)
+			return
+		}
+		
+		currentID, _ := user.Metadata["session_id"].(string)
+		response := make([]map[string]interface{}, 0, len(sessions))
+		for _, session := range sessions {
+			response = append(response, map[string]interface{}{
+				"id":           session.ID,
+				"ip_address":   session.IPAddress,
+				"user_agent":   session.UserAgent,
+				"created_at":   session.CreatedAt,
+				"last_seen_at": session.LastSeenAt,
+				"expires_at":   session.ExpiresAt,
+				"current":      session.ID == currentID,
+			})
+		}
+		
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": response})
+	})
+
+	// Revoke one of the caller's sessions, e.g. a lost device
+	mux.HandleFunc("DELETE /auth/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			return
+		}
+		
+		provider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+		
+		manager, ok := provider.(sessionManager)
+		if !ok {
+			http.Error(w, "Sessions not supported", http.StatusNotImplemented)
+			return
+		}
+		
+		user, err := provider.ValidateToken(r.Context(), token)
+		if err != nil {
+			http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+			return
+		}
+		
+		err = manager.RevokeSession(r.Context(), user.ID, r.PathValue("id"))
+		if errors.Is(err, local.ErrSessionNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Session revocation error: %v", err)
+			http.Error(w, "Error revoking session", http.StatusInternalServerError)
+			return
+		}
+		
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+	})
+
+	registerMFARoutes(mux, providerRegistry)
+	registerWebAuthnRoutes(mux, providerRegistry)
+	registerMagicLinkRoutes(mux, providerRegistry)
+	registerPasswordResetPage(mux)
+	registerIdentityRoutes(mux, providerRegistry)
+	registerAPIKeyRoutes(mux, providerRegistry)
+	registerOAuthRoutes(mux, providerRegistry)
+	registerAuthorizeRoutes(mux, providerRegistry)
+	registerOpenIDRoutes(mux, providerRegistry)
+	registerAdminRoutes(mux, providerRegistry)
+
+	return mux, providerRegistry
+}
+
+// clientAuthenticator is implemented by providers that take the client into account when authenticating
+type clientAuthenticator interface {
+	AuthenticateFrom(ctx context.Context, creds auth.Credentials, client local.ClientInfo) (*auth.User, error)
+}
+
+// loginThrottler is implemented by providers that throttle failed logins checked by other providers
+type loginThrottler interface {
+	AuthenticateWith(ctx context.Context, provider auth.Provider, creds auth.Credentials, client local.ClientInfo) (*auth.User, error)
+}
+
+// emailRequestThrottler is implemented by providers that limit how many emails can be asked for
+type emailRequestThrottler interface {
+	ThrottleEmailRequest(ctx context.Context, email string, client local.ClientInfo) error
+}
+
+// throttleEmailRequest counts a request to email an address and answers it with 429 if the
+// address or client has asked for too many. Returns whether the email may be sent.
+func throttleEmailRequest(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry, email string) bool {
+	provider, exists := providerRegistry.Get("local")
+	if !exists {
+		return true
+	}
+	throttler, ok := provider.(emailRequestThrottler)
+	if !ok {
+		return true
+	}
+	
+	err := throttler.ThrottleEmailRequest(r.Context(), email, clientInfo(r))
+	var throttledErr *local.LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+		http.Error(w, "Too many emails requested; try again later", http.StatusTooManyRequests)
+		return false
+	}
+	if err != nil {
+		log.Printf("Email request throttling error: %v", err)
+		http.Error(w, "Error requesting email", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// tokenPairIssuer is implemented by providers that issue refresh tokens alongside access tokens
+type tokenPairIssuer interface {
+	IssueTokens(ctx context.Context, user *auth.User, client local.ClientInfo) (*local.TokenPair, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (*local.TokenPair, error)
+	RevokeRefreshToken(ctx context.Context, refreshToken string) error
+}
+
+// userTokenRevoker is implemented by providers that can revoke all of a user's tokens at once
+type userTokenRevoker interface {
+	RevokeAllTokens(ctx context.Context, userID string) error
+}
+
+// userRegistrar is implemented by providers that support self-service sign-up
+type userRegistrar interface {
+	Register(ctx context.Context, username string, email string, password string, client local.ClientInfo) (*auth.User, *local.TokenPair, error)
+}
+
+// emailVerifier is implemented by providers that confirm email addresses with emailed links
+type emailVerifier interface {
+	VerifyEmail(ctx context.Context, token string) (*local.StoredUser, error)
+	ResendVerificationEmail(ctx context.Context, email string) error
+}
+
+// passwordResetter is implemented by providers that let users reset a forgotten password through an emailed link
+type passwordResetter interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, password string) (*local.StoredUser, error)
+}
+
+// passwordChanger is implemented by providers that let logged-in users change their password
+type passwordChanger interface {
+	ChangePassword(ctx context.Context, userID string, currentPassword string, newPassword string, currentSessionID string) error
+}
+
+// sessionManager is implemented by providers that let users list and revoke their sessions
+type sessionManager interface {
+	ListSessions(ctx context.Context, userID string) ([]*local.Session, error)
+	RevokeSession(ctx context.Context, userID string, sessionID string) error
+}
+
+// writeLoginResponse issues tokens to a user who just logged in. Providers that
+// support refresh tokens get a token pair, others a single access token.
+func writeLoginResponse(w http.ResponseWriter, r *http.Request, provider auth.Provider, user *auth.User) {
+	// Issue an access token plus a refresh token when the provider supports it
+	if issuer, ok := provider.(tokenPairIssuer); ok {
+		pair, err := issuer.IssueTokens(r.Context(), user, clientInfo(r))
+		if err != nil {
+			log.Printf("Token generation error: %v", err)
+			http.Error(w, "Error generating token", http.StatusInternalServerError)
+			return
+		}
+		
+		response := tokenPairResponse(pair)
+		response["user"] = map[string]interface{}{
+			"id":       user.ID,
+			"username": user.Username,
+			"email":    user.Email,
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	
+	// Generate a JWT token
+	ctx := context.WithValue(r.Context(), "user", user)
+	token, err := provider.RefreshToken(ctx, "")
+	if err != nil {
+		log.Printf("Token generation error: %v", err)
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	// Return token in response
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token": token,
+		"user": map[string]interface{}{
+			"id":       user.ID,
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+}
+
+// clientInfo describes the device a request came from, for display in the session list
+func clientInfo(r *http.Request) local.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return local.ClientInfo{
+		IPAddress: ip,
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// bearerToken extracts the token from the Authorization header, with or without the "Bearer " prefix
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.ToUpper(header[0:7]) == "BEARER " {
+		return header[7:]
+	}
+	return header
+}
+
+// tokenPairResponse builds the JSON body returned when tokens are issued.
+// "token" duplicates the access token for clients written before refresh tokens existed.
+func tokenPairResponse(pair *local.TokenPair) map[string]interface{} {
+	return map[string]interface{}{
+		"token":         pair.AccessToken,
+		"access_token":  pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int64(pair.ExpiresIn.Seconds()),
+	}
+}
+
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// useInMemoryStorage sets up the in-memory user store for authentication
+func useInMemoryStorage(registry *auth.ProviderRegistry) {
+	userStore := local.NewMemoryUserStore()
+	tokenStore := local.NewMemoryTokenStore()
+	refreshStore := local.NewMemoryRefreshTokenStore()
+	mailer := getMailer()
+	localProviderConfig := getJWTConfig()
+	localProvider := local.NewProviderWithRevocation(localProviderConfig, userStore, tokenStore,
+		local.WithRefreshTokenStore(refreshStore),
+		local.WithSessionStore(local.NewMemorySessionStore()),
+		local.WithOneTimeTokenStore(local.NewMemoryOneTimeTokenStore()),
+		local.WithPasswordHistoryStore(local.NewMemoryPasswordHistoryStore()),
+		local.WithLoginAttemptStore(local.NewMemoryLoginAttemptStore()),
+		local.WithIdentityStore(local.NewMemoryIdentityStore()),
+		local.WithMailer(mailer))
+	registry.Register(localProvider)
+	
+	// Passkeys and security keys, usable on their own or as a second factor
+	webauthnProvider := webauthn.NewProvider(getWebAuthnConfig(), userStore, localProvider)
+	localProvider.AddSecondFactor(webauthnProvider)
+	registry.Register(webauthnProvider)
+	
+	// Passwordless logins with links sent by email
+	registry.Register(emaillink.NewProvider(getMagicLinkConfig(), userStore, localProvider,
+		emaillink.WithMailer(mailer)))
+	
+	// API keys for machine clients
+	registry.Register(apikey.NewProvider(getAPIKeyConfig(), userStore, localProvider,
+		apikey.WithRevocationStore(tokenStore)))
+	
+	// OAuth clients: other services getting tokens for themselves, and apps acting for users
+	registry.Register(oauthserver.NewProvider(getOAuthServerConfig(), userStore, localProvider.TokenUtil(), localProvider,
+		oauthserver.WithRevocationStore(tokenStore)))
+	
+	// External OpenID Connect identity providers
+	for _, config := range getOIDCConfigs() {
+		registry.Register(oauth2.NewProvider(config, localProvider))
+	}
+	
+	// Staff directory logins. Refreshing tokens checks that the user is still in the directory.
+	if config, ok := getLDAPConfig(); ok {
+		ldapProvider := ldap.NewProvider(config, userStore, localProvider)
+		localProvider.AddAccountCheck(ldapProvider)
+		registry.Register(ldapProvider)
+	}
+
+	// Add a sample user for testing
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	sampleUser := &local.StoredUser{
+		Username:      "testuser",
+		Email:         "test@example.com",
+		EmailVerified: true,
+		PasswordHash:  string(hashedPassword),
+		Roles:         []string{"user"},
+		Metadata:      map[string]interface{}{"created_by": "system"},
+	}
+	ctx := context.Background()
+	_ = userStore.Create(ctx, sampleUser)
+	
+	// Add an admin user for the user management API
+	hashedPassword, _ = bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+	adminUser := &local.StoredUser{
+		Username:      "admin",
+		Email:         "admin@example.com",
+		EmailVerified: true,
+		PasswordHash:  string(hashedPassword),
+		Roles:         []string{"admin", "user"},
+		Metadata:      map[string]interface{}{"created_by": "system"},
+	}
+	_ = userStore.Create(ctx, adminUser)
+	
+	log.Println("Initialized in-memory user store with test users: testuser, admin")
+}
+
+// usePostgresStorage sets up the PostgreSQL user store for authentication
+func usePostgresStorage(registry *auth.ProviderRegistry, db *sqlx.DB) {
+	userStore := postgres.NewSQLUserStore(db)
+	tokenStore := postgres.NewTokenStore(db)
+	localProviderConfig := getJWTConfig()
+	if localProviderConfig.SigningKey != nil {
+		log.Printf("Using JWT config: algorithm=%s, expiry=%s",
+			localProviderConfig.SigningKey.Algorithm(), localProviderConfig.TokenExpiration)
+	} else {
+		log.Printf("Using JWT config: secret=%s, expiry=%s", 
+			localProviderConfig.JWTSecret[:3]+"...", localProviderConfig.TokenExpiration)
+	}
+	
+	mailer := getMailer()
+	localProvider := local.NewProviderWithRevocation(localProviderConfig, userStore, tokenStore,
+		local.WithRefreshTokenStore(postgres.NewRefreshTokenStore(db)),
+		local.WithSessionStore(postgres.NewSessionStore(db)),
+		local.WithOneTimeTokenStore(postgres.NewOneTimeTokenStore(db)),
+		local.WithPasswordHistoryStore(postgres.NewPasswordHistoryStore(db)),
+		local.WithLoginAttemptStore(postgres.NewLoginAttemptStore(db)),
+		local.WithIdentityStore(postgres.NewIdentityStore(db)),
+		local.WithMailer(mailer))
+	registry.Register(localProvider)
+	
+	// Passkeys and security keys, usable on their own or as a second factor
+	webauthnProvider := webauthn.NewProvider(getWebAuthnConfig(), userStore, localProvider,
+		webauthn.WithCredentialStore(webauthnpg.NewCredentialStore(db)),
+		webauthn.WithChallengeStore(webauthnpg.NewChallengeStore(db)))
+	localProvider.AddSecondFactor(webauthnProvider)
+	registry.Register(webauthnProvider)
+	
+	// Passwordless logins with links sent by email
+	registry.Register(emaillink.NewProvider(getMagicLinkConfig(), userStore, localProvider,
+		emaillink.WithMailer(mailer),
+		emaillink.WithLinkStore(emaillinkpg.NewLinkStore(db))))
+	
+	// API keys for machine clients
+	registry.Register(apikey.NewProvider(getAPIKeyConfig(), userStore, localProvider,
+		apikey.WithKeyStore(apikeypg.NewKeyStore(db)),
+		apikey.WithRevocationStore(tokenStore)))
+	
+	// OAuth clients: other services getting tokens for themselves, and apps acting for users
+	registry.Register(oauthserver.NewProvider(getOAuthServerConfig(), userStore, localProvider.TokenUtil(), localProvider,
+		oauthserver.WithClientStore(oauthserverpg.NewClientStore(db)),
+		oauthserver.WithCodeStore(oauthserverpg.NewCodeStore(db)),
+		oauthserver.WithRevocationStore(tokenStore)))
+	
+	// External OpenID Connect identity providers
+	stateStore := oauthpg.NewStateStore(db)
+	for _, config := range getOIDCConfigs() {
+		registry.Register(oauth2.NewProvider(config, localProvider, oauth2.WithStateStore(stateStore)))
+	}
+	
+	// Staff directory logins. Refreshing tokens checks that the user is still in the directory.
+	if config, ok := getLDAPConfig(); ok {
+		ldapProvider := ldap.NewProvider(config, userStore, localProvider)
+		localProvider.AddAccountCheck(ldapProvider)
+		registry.Register(ldapProvider)
+	}
+
+	// Check if we need to create an admin user
+	ctx := context.Background()
+	_, err := userStore.GetByUsername(ctx, "admin")
+	if err != nil && err.Error() == auth.ErrUserNotFound.Error() {
+		// Create default admin user
+		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+		adminUser := &local.StoredUser{
+			Username:      "admin",
+			Email:         "admin@example.com",
+			EmailVerified: true,
+			PasswordHash:  string(hashedPassword),
+			Roles:         []string{"admin", "user"},
+			Metadata:      map[string]interface{}{"created_by": "system"},
+		}
+		err = userStore.Create(ctx, adminUser)
+		if err != nil {
+			log.Printf("Failed to create admin user: %v", err)
+		} else {
+			log.Println("Created default admin user: admin")
+		}
+	}
+}
+
+// Get the JWT configuration from environment variables
+func getJWTConfig() local.Config {
+	config := local.DefaultConfig()
+	
+	// Get JWT secret from env
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		config.JWTSecret = secret
+	}
+	
+	// Load an asymmetric signing key if one is configured
+	if keyFile := os.Getenv("JWT_SIGNING_KEY_FILE"); keyFile != "" {
+		key, err := jwt.LoadSigningKeyFile(keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load JWT signing key from %s: %v", keyFile, err)
+		}
+		config.SigningKey = key
+	}
+	
+	// Keep verifying tokens signed with keys that have been rotated out
+	if keyFiles := os.Getenv("JWT_RETIRED_KEY_FILES"); keyFiles != "" {
+		for _, keyFile := range strings.Split(keyFiles, ",") {
+			key, err := jwt.LoadVerificationKeyFile(strings.TrimSpace(keyFile))
+			if err != nil {
+				log.Fatalf("Failed to load retired JWT key from %s: %v", keyFile, err)
+			}
+			config.RetiredKeys = append(config.RetiredKeys, key)
+		}
+	}
+	
+	// Get token expiry from env
+	if expiryStr := os.Getenv("TOKEN_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.TokenExpiration = expiry
+		}
+	}
+	
+	if expiryStr := os.Getenv("REFRESH_TOKEN_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.RefreshTokenExpiration = expiry
+		}
+	}
+	
+	// Get issuer, audiences and allowed clock skew from env
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		config.Issuer = issuer
+	}
+	
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		config.Audience = nil
+		for _, aud := range strings.Split(audience, ",") {
+			config.Audience = append(config.Audience, strings.TrimSpace(aud))
+		}
+	}
+	
+	if leewayStr := os.Getenv("JWT_LEEWAY"); leewayStr != "" {
+		if leeway, err := time.ParseDuration(leewayStr); err == nil {
+			config.ClockSkew = leeway
+		}
+	}
+	
+	// Brute-force protection settings
+	if maxFailures, err := strconv.Atoi(os.Getenv("MAX_FAILED_LOGINS")); err == nil {
+		config.MaxFailedLogins = maxFailures
+	}
+	
+	if delayStr := os.Getenv("FAILED_LOGIN_DELAY"); delayStr != "" {
+		if delay, err := time.ParseDuration(delayStr); err == nil {
+			config.FailedLoginDelay = delay
+		}
+	}
+	
+	if maxFailures, err := strconv.Atoi(os.Getenv("MAX_FAILED_LOGINS_PER_IP")); err == nil {
+		config.MaxFailedLoginsPerIP = maxFailures
+	}
+	
+	if durationStr := os.Getenv("LOCKOUT_DURATION"); durationStr != "" {
+		if duration, err := time.ParseDuration(durationStr); err == nil {
+			config.LockoutDuration = duration
+		}
+	}
+	
+	// Limits on password reset and login link emails
+	if maxEmails, err := strconv.Atoi(os.Getenv("MAX_EMAILS_PER_ADDRESS")); err == nil {
+		config.MaxEmailsPerAddress = maxEmails
+	}
+	
+	if maxEmails, err := strconv.Atoi(os.Getenv("MAX_EMAIL_REQUESTS_PER_IP")); err == nil {
+		config.MaxEmailRequestsPerIP = maxEmails
+	}
+	
+	if windowStr := os.Getenv("EMAIL_REQUEST_WINDOW"); windowStr != "" {
+		if window, err := time.ParseDuration(windowStr); err == nil {
+			config.EmailRequestWindow = window
+		}
+	}
+	
+	// Registration settings
+	if disabled, err := strconv.ParseBool(os.Getenv("DISABLE_REGISTRATION")); err == nil {
+		config.DisableRegistration = disabled
+	}
+	
+	if autoLogin, err := strconv.ParseBool(os.Getenv("REGISTRATION_AUTO_LOGIN")); err == nil {
+		config.LoginOnRegister = autoLogin
+	}
+	
+	// Password policy applied to registrations, admin-created users and password changes
+	policy := local.DefaultPasswordPolicy()
+	if minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+		policy.MinLength = minLength
+	}
+	policy.RequireUpper, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_UPPER"))
+	policy.RequireLower, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_LOWER"))
+	policy.RequireDigit, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT"))
+	policy.RequireSymbol, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL"))
+	config.PasswordValidator = policy.Validate
+	
+	if history, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY")); err == nil {
+		config.PasswordHistorySize = history
+	}
+	
+	// Email verification settings
+	if required, err := strconv.ParseBool(os.Getenv("REQUIRE_VERIFIED_EMAIL")); err == nil {
+		config.RequireVerifiedEmail = required
+	}
+	
+	if expiryStr := os.Getenv("EMAIL_VERIFICATION_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.EmailVerificationExpiration = expiry
+		}
+	}
+	
+	if expiryStr := os.Getenv("PASSWORD_RESET_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.PasswordResetExpiration = expiry
+		}
+	}
+	
+	// Two-factor authentication settings
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		config.MFAIssuer = issuer
+	}
+	
+	if expiryStr := os.Getenv("MFA_CHALLENGE_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.MFAChallengeExpiration = expiry
+		}
+	}
+	
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		config.PublicURL = publicURL
+	}
+	
+	// External identity settings
+	if link, err := strconv.ParseBool(os.Getenv("LINK_VERIFIED_EMAIL")); err == nil {
+		config.LinkVerifiedEmail = link
+	}
+	
+	if roles := os.Getenv("IDENTITY_ROLES"); roles != "" {
+		for _, role := range strings.Split(roles, ",") {
+			config.IdentityRoles = append(config.IdentityRoles, strings.TrimSpace(role))
+		}
+	}
+	
 	return config
 }
+
+// Get the WebAuthn configuration from environment variables.
+// The relying party ID and origin default to the host and origin of PUBLIC_URL.
+func getWebAuthnConfig() webauthn.Config {
+	config := webauthn.DefaultConfig()
+	
+	if publicURL, err := url.Parse(os.Getenv("PUBLIC_URL")); err == nil && publicURL.Host != "" {
+		config.RPID = publicURL.Hostname()
+		config.Origins = []string{publicURL.Scheme + "://" + publicURL.Host}
+	}
+	
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		config.RPID = rpID
+	}
+	
+	if rpName := os.Getenv("WEBAUTHN_RP_NAME"); rpName != "" {
+		config.RPName = rpName
+	}
+	
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		config.Origins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			config.Origins = append(config.Origins, strings.TrimSpace(origin))
+		}
+	}
+	
+	if timeoutStr := os.Getenv("WEBAUTHN_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.ChallengeExpiration = timeout
+		}
+	}
+	
+	if required, err := strconv.ParseBool(os.Getenv("WEBAUTHN_REQUIRE_USER_VERIFICATION")); err == nil {
+		config.RequireUserVerification = required
+	}
+	
+	return config
+}
+
+// Get the login link configuration from environment variables
+func getMagicLinkConfig() emaillink.Config {
+	config := emaillink.DefaultConfig()
+	
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		config.PublicURL = publicURL
+	}
+	
+	if expiryStr := os.Getenv("MAGIC_LINK_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.LinkExpiration = expiry
+		}
+	}
+	
+	return config
+}
+
+// Get the API key configuration from environment variables
+func getAPIKeyConfig() apikey.Config {
+	config := apikey.DefaultConfig()
+	
+	if prefix := os.Getenv("API_KEY_PREFIX"); prefix != "" {
+		config.KeyPrefix = prefix
+	}
+	
+	if scopes := os.Getenv("API_KEY_SCOPES"); scopes != "" {
+		config.AllowedScopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	}
+	
+	if maxKeys, err := strconv.Atoi(os.Getenv("API_KEY_MAX_PER_USER")); err == nil {
+		config.MaxKeysPerUser = maxKeys
+	}
+	
+	if lifetimeStr := os.Getenv("API_KEY_MAX_LIFETIME"); lifetimeStr != "" {
+		if lifetime, err := time.ParseDuration(lifetimeStr); err == nil {
+			config.MaxLifetime = lifetime
+		}
+	}
+	
+	return config
+}
+
+// Get the OAuth client settings from environment variables
+func getOAuthServerConfig() oauthserver.Config {
+	config := oauthserver.DefaultConfig()
+	
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		config.Issuer = publicURL
+	}
+	
+	if scopes := os.Getenv("OAUTH_CLIENT_SCOPES"); scopes != "" {
+		config.AllowedScopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	}
+	
+	if expiryStr := os.Getenv("OAUTH_TOKEN_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.TokenExpiration = expiry
+		}
+	}
+	
+	if lifetimeStr := os.Getenv("OAUTH_MAX_TOKEN_LIFETIME"); lifetimeStr != "" {
+		if lifetime, err := time.ParseDuration(lifetimeStr); err == nil {
+			config.MaxTokenLifetime = lifetime
+		}
+	}
+	
+	if expiryStr := os.Getenv("OAUTH_CODE_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.CodeExpiration = expiry
+		}
+	}
+	
+	return config
+}
+
+// Get the OpenID Connect identity providers from environment variables.
+// OIDC_PROVIDERS lists their names; each one is configured with OIDC_<NAME>_* variables.
+// The redirect URL defaults to the provider's callback under PUBLIC_URL.
+func getOIDCConfigs() []oauth2.Config {
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = local.DefaultConfig().PublicURL
+	}
+	
+	var configs []oauth2.Config
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "local" || name == "webauthn" || name == "ldap" || name == "apikey" || name == "emaillink" || name == "oauthserver" {
+			log.Fatalf("OIDC provider name %q is reserved", name)
+		}
+		
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := oauth2.DefaultConfig()
+		config.Name = name
+		config.Issuer = os.Getenv(prefix + "ISSUER")
+		config.ClientID = os.Getenv(prefix + "CLIENT_ID")
+		config.ClientSecret = os.Getenv(prefix + "CLIENT_SECRET")
+		if config.Issuer == "" || config.ClientID == "" {
+			log.Fatalf("OIDC provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		
+		config.RedirectURL = strings.TrimSuffix(publicURL, "/") + "/auth/oauth/" + name + "/callback"
+		if redirectURL := os.Getenv(prefix + "REDIRECT_URL"); redirectURL != "" {
+			config.RedirectURL = redirectURL
+		}
+		
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		
+		if claim := os.Getenv(prefix + "USERNAME_CLAIM"); claim != "" {
+			config.UsernameClaim = claim
+		}
+		
+		config.RolesClaim = os.Getenv(prefix + "ROLES_CLAIM")
+		
+		configs = append(configs, config)
+		log.Printf("Using OIDC provider %s: issuer=%s", name, config.Issuer)
+	}
+	return configs
+}
+
+// Get the LDAP directory configuration from environment variables.
+// Returns false, leaving directory logins disabled, when LDAP_URL isn't set.
+func getLDAPConfig() (ldap.Config, bool) {
+	config := ldap.DefaultConfig()
+	config.URL = os.Getenv("LDAP_URL")
+	if config.URL == "" {
+		return config, false
+	}
+	
+	config.BaseDN = os.Getenv("LDAP_BASE_DN")
+	if config.BaseDN == "" {
+		log.Fatal("LDAP_URL is set but LDAP_BASE_DN is not")
+	}
+	
+	config.BindDN = os.Getenv("LDAP_BIND_DN")
+	config.BindPassword = os.Getenv("LDAP_BIND_PASSWORD")
+	config.InsecureSkipVerify, _ = strconv.ParseBool(os.Getenv("LDAP_INSECURE_SKIP_VERIFY"))
+	
+	if filter := os.Getenv("LDAP_USER_FILTER"); filter != "" {
+		config.UserFilter = filter
+	}
+	
+	if attribute := os.Getenv("LDAP_USERNAME_ATTRIBUTE"); attribute != "" {
+		config.UsernameAttribute = attribute
+	}
+	
+	if attribute := os.Getenv("LDAP_EMAIL_ATTRIBUTE"); attribute != "" {
+		config.EmailAttribute = attribute
+	}
+	
+	if attribute, ok := os.LookupEnv("LDAP_GROUP_ATTRIBUTE"); ok {
+		config.GroupAttribute = attribute
+	}
+	
+	config.GroupBaseDN = os.Getenv("LDAP_GROUP_BASE_DN")
+	if filter := os.Getenv("LDAP_GROUP_FILTER"); filter != "" {
+		config.GroupFilter = filter
+	}
+	
+	// Pairs of group and role, e.g. "admins=admin,staff=user"
+	if mappings := os.Getenv("LDAP_GROUP_ROLES"); mappings != "" {
+		config.GroupRoles = make(map[string]string)
+		for _, mapping := range strings.Split(mappings, ",") {
+			group, role, found := strings.Cut(mapping, "=")
+			if !found {
+				log.Fatalf("Invalid LDAP_GROUP_ROLES entry %q; expected group=role", mapping)
+			}
+			config.GroupRoles[strings.ToLower(strings.TrimSpace(group))] = strings.TrimSpace(role)
+		}
+	}
+	
+	if roles := os.Getenv("LDAP_DEFAULT_ROLES"); roles != "" {
+		config.DefaultRoles = nil
+		for _, role := range strings.Split(roles, ",") {
+			config.DefaultRoles = append(config.DefaultRoles, strings.TrimSpace(role))
+		}
+	}
+	
+	if provision, err := strconv.ParseBool(os.Getenv("LDAP_PROVISION_USERS")); err == nil {
+		config.ProvisionUsers = provision
+	}
+	
+	if timeoutStr := os.Getenv("LDAP_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Timeout = timeout
+		}
+	}
+	
+	log.Printf("Using LDAP directory %s: base=%s", config.URL, config.BaseDN)
+	return config, true
+}
+
+// Get the mailer for account emails from environment variables.
+// Returns nil, which disables account emails, when neither SMTP nor a mail directory is configured.
+func getMailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	}
+	
+	// Write emails to files, e.g. during development
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		mailer, err := mail.NewFileMailer(dir, from)
+		if err != nil {
+			log.Fatalf("Failed to create mail directory %s: %v", dir, err)
+		}
+		return mailer
+	}
+	
+	log.Println("No SMTP_HOST or MAIL_DIR configured; account emails will not be sent")
+	return nil
+}
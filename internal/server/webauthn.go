@@ -0,0 +1,379 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/internal/auth"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/local"
+	"github.com/NBDor/Go-Auth-Service/internal/auth/providers/webauthn"
+	protocol "github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+)
+
+// passkeyManager is implemented by providers that let users register passkeys and security keys
+type passkeyManager interface {
+	BeginRegistration(ctx context.Context, userID string) (*protocol.CreationOptions, error)
+	FinishRegistration(ctx context.Context, userID string, name string, response []byte) (*webauthn.Credential, error)
+	ListCredentials(ctx context.Context, userID string) ([]*webauthn.Credential, error)
+	DeleteCredential(ctx context.Context, userID string, credentialID string) error
+}
+
+// passkeyAuthenticator is implemented by providers that support passwordless logins with passkeys
+type passkeyAuthenticator interface {
+	BeginLogin(ctx context.Context, username string) (*protocol.RequestOptions, error)
+}
+
+// securityKeyVerifier is implemented by providers that check security keys as a second factor
+type securityKeyVerifier interface {
+	Name() string
+	BeginSecondFactor(ctx context.Context, userID string) (*protocol.RequestOptions, error)
+	VerifySecondFactor(ctx context.Context, userID string, response []byte) error
+}
+
+// passwordVerifier is implemented by providers that can check a logged-in user's password
+type passwordVerifier interface {
+	VerifyPassword(ctx context.Context, userID string, password string) error
+	HasPassword(ctx context.Context, userID string) (bool, error)
+}
+
+// registerWebAuthnRoutes adds the passkey registration and passwordless login endpoints
+func registerWebAuthnRoutes(mux *http.ServeMux, providerRegistry *auth.ProviderRegistry) {
+	// Get the options for navigator.credentials.create() to register a passkey or security key.
+	// Users who have a password or a key confirm with it, so a stolen token can't add a key
+	// that outlasts logging out everywhere.
+	mux.HandleFunc("POST /auth/webauthn/register/begin", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedPasskeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		credentials, err := manager.ListCredentials(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error listing passkeys: %v", err)
+			http.Error(w, "Error starting passkey registration", http.StatusInternalServerError)
+			return
+		}
+		hasPassword := false
+		if provider, exists := providerRegistry.Get("local"); exists {
+			if checker, ok := provider.(passwordVerifier); ok {
+				if hasPassword, err = checker.HasPassword(r.Context(), user.ID); err != nil {
+					log.Printf("Passkey registration error: %v", err)
+					http.Error(w, "Error starting passkey registration", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		if (hasPassword || len(credentials) > 0) && !confirmStepUp(w, r, providerRegistry, user, r.PostFormValue("password"), r.PostFormValue("credential")) {
+			return
+		}
+
+		options, err := manager.BeginRegistration(r.Context(), user.ID)
+		if errors.Is(err, local.ErrDirectoryManaged) {
+			http.Error(w, "This account logs in through the directory", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Passkey registration error: %v", err)
+			http.Error(w, "Error starting passkey registration", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+	})
+
+	// Store the credential the browser created
+	mux.HandleFunc("POST /auth/webauthn/register/finish", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedPasskeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		credential, err := manager.FinishRegistration(r.Context(), user.ID, r.FormValue("name"), []byte(r.FormValue("credential")))
+		switch {
+		case errors.Is(err, webauthn.ErrCredentialExists):
+			http.Error(w, "Credential already registered", http.StatusConflict)
+			return
+		case errors.Is(err, local.ErrDirectoryManaged):
+			http.Error(w, "This account logs in through the directory", http.StatusForbidden)
+			return
+		case errors.Is(err, protocol.ErrVerificationFailed), errors.Is(err, webauthn.ErrInvalidChallenge):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Passkey registration error: %v", err)
+			http.Error(w, "Error registering passkey", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"credential": credentialResponse(credential)})
+	})
+
+	// List the caller's passkeys and security keys
+	mux.HandleFunc("GET /auth/webauthn/credentials", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedPasskeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		credentials, err := manager.ListCredentials(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error listing passkeys: %v", err)
+			http.Error(w, "Error listing passkeys", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]map[string]interface{}, 0, len(credentials))
+		for _, credential := range credentials {
+			response = append(response, credentialResponse(credential))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"credentials": response})
+	})
+
+	// Get the options for navigator.credentials.get() to confirm a change to the caller's
+	// passkeys and security keys with one of them
+	mux.HandleFunc("POST /auth/webauthn/verify/begin", func(w http.ResponseWriter, r *http.Request) {
+		_, user, ok := authenticatedPasskeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		verifier, ok := securityKeyProvider(providerRegistry)
+		if !ok {
+			http.Error(w, "Security keys not supported", http.StatusNotImplemented)
+			return
+		}
+
+		options, err := verifier.BeginSecondFactor(r.Context(), user.ID)
+		if errors.Is(err, webauthn.ErrCredentialNotFound) {
+			http.Error(w, "No passkeys registered", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Passkey verification error: %v", err)
+			http.Error(w, "Error starting passkey verification", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+	})
+
+	// Remove a passkey or security key, e.g. a lost one. Removing the last one turns off
+	// two-factor authentication with security keys, so like turning off TOTP, which takes
+	// a code, it takes the password or an assertion from the key in the request body.
+	mux.HandleFunc("DELETE /auth/webauthn/credentials/{id}", func(w http.ResponseWriter, r *http.Request) {
+		manager, user, ok := authenticatedPasskeyManager(w, r, providerRegistry)
+		if !ok {
+			return
+		}
+
+		credentials, err := manager.ListCredentials(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error listing passkeys: %v", err)
+			http.Error(w, "Error deleting passkey", http.StatusInternalServerError)
+			return
+		}
+		if len(credentials) == 1 && credentials[0].ID == r.PathValue("id") {
+			form, err := deleteRequestForm(r)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if !confirmStepUp(w, r, providerRegistry, user, form.Get("password"), form.Get("credential")) {
+				return
+			}
+		}
+
+		err = manager.DeleteCredential(r.Context(), user.ID, r.PathValue("id"))
+		if errors.Is(err, webauthn.ErrCredentialNotFound) {
+			http.Error(w, "Credential not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting passkey: %v", err)
+			http.Error(w, "Error deleting passkey", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Get the options for navigator.credentials.get() to log in without a password
+	mux.HandleFunc("POST /auth/webauthn/login/begin", func(w http.ResponseWriter, r *http.Request) {
+		provider, exists := providerRegistry.Get("webauthn")
+		if !exists {
+			http.Error(w, "Passkeys not supported", http.StatusNotImplemented)
+			return
+		}
+
+		authenticator, ok := provider.(passkeyAuthenticator)
+		if !ok {
+			http.Error(w, "Passkeys not supported", http.StatusNotImplemented)
+			return
+		}
+
+		options, err := authenticator.BeginLogin(r.Context(), r.FormValue("username"))
+		if err != nil {
+			log.Printf("Passkey login error: %v", err)
+			http.Error(w, "Error starting passkey login", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+	})
+
+	// Log in with the browser's response; tokens are issued by the local provider
+	mux.HandleFunc("POST /auth/webauthn/login/finish", func(w http.ResponseWriter, r *http.Request) {
+		credential := r.FormValue("credential")
+		if credential == "" {
+			http.Error(w, "Missing credential", http.StatusBadRequest)
+			return
+		}
+
+		provider, exists := providerRegistry.Get("webauthn")
+		if !exists {
+			http.Error(w, "Passkeys not supported", http.StatusNotImplemented)
+			return
+		}
+
+		tokenProvider, exists := providerRegistry.Get("local")
+		if !exists {
+			http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := provider.Authenticate(r.Context(), auth.Credentials{
+			Type:     "webauthn",
+			Provider: "webauthn",
+			Params:   map[string]interface{}{"credential": credential},
+		})
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Passkey login error: %v", err)
+			http.Error(w, "Error logging in", http.StatusInternalServerError)
+			return
+		}
+
+		writeLoginResponse(w, r, tokenProvider, user)
+	})
+}
+
+// authenticatedPasskeyManager authenticates the caller with the local provider and
+// returns the passkey manager. On failure the error response has already been written.
+func authenticatedPasskeyManager(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry) (passkeyManager, *auth.User, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	provider, exists := providerRegistry.Get("local")
+	if !exists {
+		http.Error(w, "Authentication provider not available", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	webauthnProvider, exists := providerRegistry.Get("webauthn")
+	manager, ok := webauthnProvider.(passkeyManager)
+	if !exists || !ok {
+		http.Error(w, "Passkeys not supported", http.StatusNotImplemented)
+		return nil, nil, false
+	}
+
+	user, err := provider.ValidateToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Invalid or revoked token", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	return manager, user, true
+}
+
+// confirmStepUp checks the caller's password, or an assertion from one of their passkeys or
+// security keys to a challenge from /auth/webauthn/verify/begin, before a change to their second
+// factors that a stolen token alone mustn't be enough for. On failure the error response has
+// already been written.
+func confirmStepUp(w http.ResponseWriter, r *http.Request, providerRegistry *auth.ProviderRegistry, user *auth.User, password string, credential string) bool {
+	var err error
+	switch {
+	case credential != "":
+		verifier, ok := securityKeyProvider(providerRegistry)
+		if !ok {
+			http.Error(w, "Security keys not supported", http.StatusNotImplemented)
+			return false
+		}
+		err = verifier.VerifySecondFactor(r.Context(), user.ID, []byte(credential))
+	case password != "":
+		provider, exists := providerRegistry.Get("local")
+		checker, ok := provider.(passwordVerifier)
+		if !exists || !ok {
+			http.Error(w, "Password confirmation not supported", http.StatusNotImplemented)
+			return false
+		}
+		err = checker.VerifyPassword(r.Context(), user.ID, password)
+	default:
+		http.Error(w, "Confirm with your password or a passkey", http.StatusForbidden)
+		return false
+	}
+
+	var throttledErr *local.LoginThrottledError
+	switch {
+	case errors.As(err, &throttledErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+		http.Error(w, "Too many failed login attempts; try again later", http.StatusTooManyRequests)
+		return false
+	case errors.Is(err, local.ErrIncorrectPassword), errors.Is(err, local.ErrInvalidMFACode):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	case err != nil:
+		log.Printf("Step-up verification error: %v", err)
+		http.Error(w, "Error confirming your identity", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// deleteRequestForm parses the form in the body of a DELETE request, which
+// Request.FormValue only reads for POST, PUT and PATCH requests
+func deleteRequestForm(r *http.Request) (url.Values, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(body))
+}
+
+// securityKeyProvider returns the registered provider that checks security keys as a second factor, if any
+func securityKeyProvider(providerRegistry *auth.ProviderRegistry) (securityKeyVerifier, bool) {
+	provider, exists := providerRegistry.Get("webauthn")
+	if !exists {
+		return nil, false
+	}
+	verifier, ok := provider.(securityKeyVerifier)
+	return verifier, ok
+}
+
+// credentialResponse converts a credential to its JSON representation; public keys aren't included
+func credentialResponse(credential *webauthn.Credential) map[string]interface{} {
+	response := map[string]interface{}{
+		"id":              credential.ID,
+		"name":            credential.Name,
+		"transports":      credential.Transports,
+		"backup_eligible": credential.BackupEligible,
+		"created_at":      credential.CreatedAt.Format(time.RFC3339),
+		"last_used_at":    nil,
+	}
+	if !credential.LastUsedAt.IsZero() {
+		response["last_used_at"] = credential.LastUsedAt.Format(time.RFC3339)
+	}
+	return response
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoSigningKey is returned by GenerateToken on a Util that can only validate tokens
+var ErrNoSigningKey = errors.New("no signing key configured")
+
+// PublicKey decodes the RSA, EC or OKP (Ed25519) public key of a JWK
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKey
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := enc.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		public := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(public.X, public.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return public, nil
+
+	case "OKP":
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// NewUtilWithJWKS creates a Util that validates tokens signed by someone else with
+// the keys they publish, e.g. an OpenID provider's ID tokens. Keys are picked by the
+// token's "kid" header, which may be left out when the set holds a single key.
+// Encryption keys and key types that aren't supported are skipped. The Util can't
+// issue tokens: GenerateToken returns ErrNoSigningKey.
+func NewUtilWithJWKS(set JWKSet, opts ...Option) (*Util, error) {
+	u := &Util{
+		keys: make(map[string]*VerificationKey),
+	}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := verificationKeyFromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		u.keys[key.id] = key
+		u.signer = key
+	}
+
+	if len(u.keys) == 0 {
+		return nil, ErrUnsupportedKey
+	}
+	// Without a kid, the only key there is is used
+	if len(u.keys) > 1 {
+		u.signer = nil
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u, nil
+}
+
+// verificationKeyFromJWK wraps a published key under its own kid, or its thumbprint
+// if it has none. The "alg" member, when present, pins the algorithm.
+func verificationKeyFromJWK(jwk JWK) (*VerificationKey, error) {
+	public, err := jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewVerificationKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	if jwk.Kid != "" {
+		key.id = jwk.Kid
+	}
+	if jwk.Alg != "" {
+		method := jwt.GetSigningMethod(jwk.Alg)
+		_, pss := method.(*jwt.SigningMethodRSAPSS)
+		_, rsaKey := public.(*rsa.PublicKey)
+		if method == nil || !(sameFamily(key.method, method) || pss && rsaKey) {
+			return nil, ErrUnsupportedKey
+		}
+		key.method = method
+		key.exact = true
+	}
+	return key, nil
+}
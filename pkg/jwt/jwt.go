@@ -2,6 +2,7 @@ package jwt
 
 import (
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"time"
@@ -10,56 +11,173 @@ import (
 )
 
 var (
-	ErrInvalidToken = errors.New("invalid token")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrExpiredToken    = errors.New("token has expired")
+	ErrInvalidAudience = errors.New("token was issued for a different audience")
+	ErrInvalidIssuer   = errors.New("token was issued by an unexpected issuer")
 )
 
 // JWT token generation and validation
 type Util struct {
-	secret    []byte
+	signer    *VerificationKey            // identifies the signing key and algorithm
+	signKey   interface{}
+	keys      map[string]*VerificationKey // keys accepted for validation, by kid
+	ring      *KeyRing                    // nil when signing with a shared secret
 	expiresIn time.Duration
+	issuer    string
+	audience  []string
+	leeway    time.Duration
 }
 
-func NewUtil(secret string, expiresIn time.Duration) *Util {
-	return &Util{
-		secret:    []byte(secret),
+// Option configures optional token claims and validation rules
+type Option func(*Util)
+
+// sets the "iss" claim on new tokens and rejects tokens from any other issuer
+func WithIssuer(issuer string) Option {
+	return func(u *Util) {
+		u.issuer = issuer
+	}
+}
+
+// sets the "aud" claim on new tokens and rejects tokens that were not
+// issued for at least one of the given audiences
+func WithAudience(audience ...string) Option {
+	return func(u *Util) {
+		u.audience = audience
+	}
+}
+
+// tolerates clock skew between services when checking exp, nbf and iat
+func WithLeeway(leeway time.Duration) Option {
+	return func(u *Util) {
+		u.leeway = leeway
+	}
+}
+
+// creates a Util that signs with HS256 using a shared secret
+func NewUtil(secret string, expiresIn time.Duration, opts ...Option) *Util {
+	key := &VerificationKey{
+		id:     JWK{Kty: "oct", K: base64.RawURLEncoding.EncodeToString([]byte(secret))}.thumbprint(),
+		method: jwt.SigningMethodHS256,
+		key:    []byte(secret),
+	}
+	u := &Util{
+		signer:    key,
+		signKey:   []byte(secret),
+		keys:      map[string]*VerificationKey{key.id: key},
 		expiresIn: expiresIn,
 	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// creates a Util that signs with an asymmetric key, so tokens can be
+// verified by anyone holding the public key
+func NewUtilWithKey(key *SigningKey, expiresIn time.Duration, opts ...Option) *Util {
+	return NewUtilWithKeyRing(NewKeyRing(key), expiresIn, opts...)
+}
+
+// creates a Util that signs with the ring's active key and accepts tokens
+// signed by any key in the ring
+func NewUtilWithKeyRing(ring *KeyRing, expiresIn time.Duration, opts ...Option) *Util {
+	u := &Util{
+		signer:    ring.active.public,
+		signKey:   ring.active.private,
+		keys:      ring.keys,
+		ring:      ring,
+		expiresIn: expiresIn,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// returns the JWT "alg" value used to sign tokens
+func (u *Util) Algorithm() string {
+	if u.signer == nil {
+		return ""
+	}
+	return u.signer.method.Alg()
+}
+
+// returns the public keys that verify tokens issued by this Util.
+// The set is empty when tokens are signed with a shared secret.
+func (u *Util) JWKS() JWKSet {
+	if u.ring == nil {
+		return JWKSet{Keys: []JWK{}}
+	}
+	return u.ring.JWKS()
 }
 
 // creates a new JWT token with the provided claims
 func (u *Util) GenerateToken(claims map[string]interface{}) (string, error) {
+	if u.signKey == nil {
+		return "", ErrNoSigningKey
+	}
+	
 	now := time.Now()
 	
 	// Create a random token ID
 	tokenID := generateTokenID()
 	
 	// Create the token with standard claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"jti": tokenID,                         // JWT ID
-		"iat": now.Unix(),                      // Issued at
-		"exp": now.Add(u.expiresIn).Unix(),     // Expiration time
-		"nbf": now.Unix(),                      // Not valid before
+	token := jwt.NewWithClaims(u.signer.method, jwt.MapClaims{
+		"jti":    tokenID,                         // JWT ID
+		"iat":    now.Unix(),                      // Issued at
+		"iat_us": now.UnixMicro(),                 // Issued at, in microseconds, for comparing with revocation times
+		"exp":    now.Add(u.expiresIn).Unix(),     // Expiration time
+		"nbf":    now.Unix(),                      // Not valid before
 	})
 	
+	// Add issuer and audience so other services can tell who the token is for
+	if u.issuer != "" {
+		token.Claims.(jwt.MapClaims)["iss"] = u.issuer
+	}
+	if len(u.audience) == 1 {
+		token.Claims.(jwt.MapClaims)["aud"] = u.audience[0]
+	} else if len(u.audience) > 1 {
+		token.Claims.(jwt.MapClaims)["aud"] = u.audience
+	}
+	
 	// Add custom claims
 	for key, value := range claims {
 		token.Claims.(jwt.MapClaims)[key] = value
 	}
 	
+	// Identify the key so validators can pick it out of a key ring
+	token.Header["kid"] = u.signer.id
+	
 	// Sign and return the token
-	return token.SignedString(u.secret)
+	return token.SignedString(u.signKey)
 }
 
 // checks if a token is valid and returns its claims
 func (u *Util) ValidateToken(tokenString string) (map[string]interface{}, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Tokens issued before kid headers were introduced use the active key
+		key := u.signer
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, ok = u.keys[kid]; !ok {
+				return nil, ErrInvalidToken
+			}
+		}
+		if key == nil {
 			return nil, ErrInvalidToken
 		}
-		return u.secret, nil
-	})
+		
+		// Only accept the algorithm family of the key, so an RSA public key
+		// can never be used as an HMAC secret. Keys published with an "alg" only accept that one.
+		if key.exact && token.Method.Alg() != key.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		if !key.exact && !sameFamily(key.method, token.Method) {
+			return nil, ErrInvalidToken
+		}
+		return key.key, nil
+	}, jwt.WithLeeway(u.leeway), jwt.WithIssuedAt())
 	
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -72,12 +190,41 @@ func (u *Util) ValidateToken(tokenString string) (map[string]interface{}, error)
 		return nil, ErrInvalidToken
 	}
 	
-	// Extract and return the claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		return claims, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	
+	// Check issuer and audience here rather than through parser options,
+	// so missing claims get the same distinct errors as mismatched ones
+	if issuer, _ := claims.GetIssuer(); u.issuer != "" && issuer != u.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if !u.audienceAccepted(claims) {
+		return nil, ErrInvalidAudience
+	}
+	
+	return claims, nil
+}
+
+// reports whether the token's "aud" claim contains one of our audiences
+func (u *Util) audienceAccepted(claims jwt.MapClaims) bool {
+	if len(u.audience) == 0 {
+		return true
 	}
 	
-	return nil, ErrInvalidToken
+	tokenAudience, err := claims.GetAudience()
+	if err != nil {
+		return false
+	}
+	for _, aud := range tokenAudience {
+		for _, expected := range u.audience {
+			if aud == expected {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // generateTokenID creates a random token ID
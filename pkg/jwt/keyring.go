@@ -0,0 +1,256 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// VerificationKey is a key that can verify, but not sign, tokens.
+// Its ID is the RFC 7638 thumbprint of the key and is used as the "kid" header.
+type VerificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    interface{}
+	exact  bool // only tokens signed with exactly method are accepted, not just the same family
+}
+
+// NewVerificationKey wraps an RSA, ECDSA or Ed25519 public key
+func NewVerificationKey(public crypto.PublicKey) (*VerificationKey, error) {
+	method, err := signingMethodForPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := publicJWK(public)
+	if err != nil {
+		return nil, err
+	}
+	return &VerificationKey{
+		id:     jwk.thumbprint(),
+		method: method,
+		key:    public,
+	}, nil
+}
+
+// ID returns the key identifier stamped into the "kid" header
+func (k *VerificationKey) ID() string {
+	return k.id
+}
+
+// Algorithm returns the JWT "alg" value this key verifies
+func (k *VerificationKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+// ParseVerificationKeyPEM parses a PEM encoded public key.
+// A private key is also accepted, in which case only its public half is kept.
+func ParseVerificationKeyPEM(data []byte) (*VerificationKey, error) {
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "PUBLIC KEY" {
+			public, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", block.Type, err)
+			}
+			return NewVerificationKey(public)
+		}
+	}
+
+	key, err := ParseSigningKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return key.VerificationKey(), nil
+}
+
+// LoadVerificationKeyFile reads and parses a PEM encoded public or private key from disk
+func LoadVerificationKeyFile(path string) (*VerificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVerificationKeyPEM(data)
+}
+
+// KeyRing holds one active signing key plus retired keys that are still
+// accepted for verification, so keys can be rotated without invalidating
+// tokens that were signed before the rotation.
+type KeyRing struct {
+	active *SigningKey
+	keys   map[string]*VerificationKey
+	order  []string
+}
+
+// NewKeyRing creates a key ring that signs with active and verifies with
+// active and every retired key
+func NewKeyRing(active *SigningKey, retired ...*VerificationKey) *KeyRing {
+	ring := &KeyRing{
+		active: active,
+		keys:   make(map[string]*VerificationKey),
+	}
+
+	ring.add(active.VerificationKey())
+
+	// Sort retired keys so the published key set is stable
+	sorted := make([]*VerificationKey, len(retired))
+	copy(sorted, retired)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].id < sorted[j].id
+	})
+	for _, key := range sorted {
+		ring.add(key)
+	}
+
+	return ring
+}
+
+func (r *KeyRing) add(key *VerificationKey) {
+	if _, exists := r.keys[key.id]; exists {
+		return
+	}
+	r.keys[key.id] = key
+	r.order = append(r.order, key.id)
+}
+
+// Active returns the key new tokens are signed with
+func (r *KeyRing) Active() *SigningKey {
+	return r.active
+}
+
+// JWKS returns the public keys of the ring, active key first
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(r.order))}
+	for _, id := range r.order {
+		key := r.keys[id]
+		jwk, err := publicJWK(key.key)
+		if err != nil {
+			continue
+		}
+		jwk.Kid = key.id
+		jwk.Use = "sig"
+		jwk.Alg = key.method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// JWK is a public key in RFC 7517 JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"-"` // symmetric key material, never published
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint from the required members only
+func (k JWK) thumbprint() string {
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	case "oct":
+		members = struct {
+			K   string `json:"k"`
+			Kty string `json:"kty"`
+		}{k.K, k.Kty}
+	}
+
+	// Marshalling these fixed structs cannot fail
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// publicJWK converts a public key to its JWK representation
+func publicJWK(public crypto.PublicKey) (JWK, error) {
+	enc := base64.RawURLEncoding
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   enc.EncodeToString(k.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   enc.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			Y:   enc.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(k),
+		}, nil
+	}
+	return JWK{}, ErrUnsupportedKey
+}
+
+// signingMethodForPublicKey picks the JWT algorithm matching a public key
+func signingMethodForPublicKey(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, ErrUnsupportedKey
+}
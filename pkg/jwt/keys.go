@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported signing key type")
+	ErrInvalidKeyPEM  = errors.New("no private key found in PEM data")
+)
+
+// SigningKey pairs an asymmetric private key with the JWT algorithm it signs with
+type SigningKey struct {
+	private crypto.Signer
+	public  *VerificationKey
+}
+
+// NewSigningKey wraps an RSA, ECDSA or Ed25519 private key.
+// The algorithm is derived from the key: RS256 for RSA, ES256/ES384/ES512
+// depending on the curve for ECDSA, and EdDSA for Ed25519.
+func NewSigningKey(private crypto.Signer) (*SigningKey, error) {
+	switch private.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	public, err := NewVerificationKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		private: private,
+		public:  public,
+	}, nil
+}
+
+// ID returns the key identifier stamped into the "kid" header
+func (k *SigningKey) ID() string {
+	return k.public.id
+}
+
+// Algorithm returns the JWT "alg" value used with this key
+func (k *SigningKey) Algorithm() string {
+	return k.public.method.Alg()
+}
+
+// Public returns the public half of the key, used for verification
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// VerificationKey returns the public half of the key as a VerificationKey,
+// e.g. to keep it in a KeyRing after the key has been retired
+func (k *SigningKey) VerificationKey() *VerificationKey {
+	return k.public
+}
+
+// ParseSigningKeyPEM parses a PEM encoded private key.
+// PKCS#1 ("RSA PRIVATE KEY"), SEC 1 ("EC PRIVATE KEY") and PKCS#8 ("PRIVATE KEY") blocks are supported.
+func ParseSigningKeyPEM(data []byte) (*SigningKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, ErrInvalidKeyPEM
+		}
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			// Skip unrelated blocks such as "EC PARAMETERS"
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", block.Type, err)
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, ErrUnsupportedKey
+		}
+		return NewSigningKey(signer)
+	}
+}
+
+// LoadSigningKeyFile reads and parses a PEM encoded private key from disk
+func LoadSigningKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKeyPEM(data)
+}
+
+// sameFamily reports whether two signing methods belong to the same algorithm family
+func sameFamily(a, b jwt.SigningMethod) bool {
+	switch a.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok := b.(*jwt.SigningMethodHMAC)
+		return ok
+	case *jwt.SigningMethodRSA:
+		_, ok := b.(*jwt.SigningMethodRSA)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := b.(*jwt.SigningMethodECDSA)
+		return ok
+	case *jwt.SigningMethodEd25519:
+		_, ok := b.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/pkg/jwt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateKeys(t *testing.T) map[string]crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return map[string]crypto.Signer{
+		"RS256": rsaKey,
+		"ES256": ecKey,
+		"EdDSA": edKey,
+	}
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	for alg, private := range generateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			key, err := jwt.NewSigningKey(private)
+			require.NoError(t, err)
+			assert.Equal(t, alg, key.Algorithm())
+
+			util := jwt.NewUtilWithKey(key, time.Hour)
+			token, err := util.GenerateToken(map[string]interface{}{"sub": "user-1"})
+			require.NoError(t, err)
+
+			claims, err := util.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims["sub"])
+
+			// A token signed with a shared secret must not validate
+			hmacToken, err := jwt.NewUtil("secret", time.Hour).GenerateToken(map[string]interface{}{"sub": "user-1"})
+			require.NoError(t, err)
+			_, err = util.ValidateToken(hmacToken)
+			assert.Equal(t, jwt.ErrInvalidToken, err)
+		})
+	}
+}
+
+func TestPublicKeyAsHMACSecretRejected(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := jwt.NewSigningKey(private)
+	require.NoError(t, err)
+	util := jwt.NewUtilWithKey(key, time.Hour)
+
+	// Classic algorithm confusion: sign HS256 with the PEM encoded public key
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	forged, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{
+		"sub": "attacker",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(publicPEM)
+	require.NoError(t, err)
+
+	_, err = util.ValidateToken(forged)
+	assert.Equal(t, jwt.ErrInvalidToken, err)
+}
+
+func TestParseSigningKeyPEM(t *testing.T) {
+	for alg, private := range generateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(private)
+			require.NoError(t, err)
+			data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+			key, err := jwt.ParseSigningKeyPEM(data)
+			require.NoError(t, err)
+			assert.Equal(t, alg, key.Algorithm())
+		})
+	}
+
+	// Legacy PKCS#1 and SEC 1 encodings
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := jwt.ParseSigningKeyPEM(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", key.Algorithm())
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	key, err = jwt.ParseSigningKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, "ES384", key.Algorithm())
+
+	_, err = jwt.ParseSigningKeyPEM([]byte("not a key"))
+	assert.ErrorIs(t, err, jwt.ErrInvalidKeyPEM)
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := generateKeys(t)
+	oldKey, err := jwt.NewSigningKey(keys["RS256"])
+	require.NoError(t, err)
+	newKey, err := jwt.NewSigningKey(keys["EdDSA"])
+	require.NoError(t, err)
+
+	oldUtil := jwt.NewUtilWithKey(oldKey, time.Hour)
+	oldToken, err := oldUtil.GenerateToken(map[string]interface{}{"sub": "user-1"})
+	require.NoError(t, err)
+
+	// Tokens carry the kid of the key that signed them
+	parsed, _, err := gojwt.NewParser().ParseUnverified(oldToken, gojwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.ID(), parsed.Header["kid"])
+
+	// After rotation, tokens from the retired key still validate
+	rotated := jwt.NewUtilWithKeyRing(jwt.NewKeyRing(newKey, oldKey.VerificationKey()), time.Hour)
+	claims, err := rotated.ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims["sub"])
+
+	newToken, err := rotated.GenerateToken(map[string]interface{}{"sub": "user-2"})
+	require.NoError(t, err)
+	_, err = rotated.ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	// Once the old key is dropped from the ring its tokens are rejected
+	_, err = jwt.NewUtilWithKey(newKey, time.Hour).ValidateToken(oldToken)
+	assert.Equal(t, jwt.ErrInvalidToken, err)
+
+	// The JWKS publishes the active key first, then the retired ones
+	keySet := rotated.JWKS()
+	require.Len(t, keySet.Keys, 2)
+	assert.Equal(t, newKey.ID(), keySet.Keys[0].Kid)
+	assert.Equal(t, "OKP", keySet.Keys[0].Kty)
+	assert.Equal(t, "EdDSA", keySet.Keys[0].Alg)
+	assert.Equal(t, oldKey.ID(), keySet.Keys[1].Kid)
+	assert.Equal(t, "RSA", keySet.Keys[1].Kty)
+	assert.NotEmpty(t, keySet.Keys[1].N)
+}
+
+func TestRFC7638Thumbprint(t *testing.T) {
+	// Example key from RFC 7638, section 3.1
+	modulus, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+
+	key, err := jwt.NewVerificationKey(&rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: 65537})
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", key.ID())
+}
+
+func TestIssuerAndAudience(t *testing.T) {
+	issuer := jwt.NewUtil("secret", time.Hour, jwt.WithIssuer("https://auth.example.com"), jwt.WithAudience("billing", "orders"))
+	token, err := issuer.GenerateToken(map[string]interface{}{"sub": "user-1"})
+	require.NoError(t, err)
+
+	claims, err := issuer.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", claims["iss"])
+
+	// Each service only needs its own audience to be present
+	billing := jwt.NewUtil("secret", time.Hour, jwt.WithIssuer("https://auth.example.com"), jwt.WithAudience("billing"))
+	_, err = billing.ValidateToken(token)
+	assert.NoError(t, err)
+
+	reports := jwt.NewUtil("secret", time.Hour, jwt.WithIssuer("https://auth.example.com"), jwt.WithAudience("reports"))
+	_, err = reports.ValidateToken(token)
+	assert.Equal(t, jwt.ErrInvalidAudience, err)
+
+	other := jwt.NewUtil("secret", time.Hour, jwt.WithIssuer("https://other.example.com"))
+	_, err = other.ValidateToken(token)
+	assert.Equal(t, jwt.ErrInvalidIssuer, err)
+
+	// A token without an audience is rejected by services that expect one
+	plain, err := jwt.NewUtil("secret", time.Hour).GenerateToken(map[string]interface{}{"sub": "user-1"})
+	require.NoError(t, err)
+	_, err = billing.ValidateToken(plain)
+	assert.Equal(t, jwt.ErrInvalidIssuer, err)
+	_, err = jwt.NewUtil("secret", time.Hour, jwt.WithAudience("billing")).ValidateToken(plain)
+	assert.Equal(t, jwt.ErrInvalidAudience, err)
+}
+
+func TestClockSkewLeeway(t *testing.T) {
+	now := time.Now()
+	sign := func(claims gojwt.MapClaims) string {
+		token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return token
+	}
+
+	strict := jwt.NewUtil("secret", time.Hour)
+	lenient := jwt.NewUtil("secret", time.Hour, jwt.WithLeeway(time.Minute))
+
+	justExpired := sign(gojwt.MapClaims{"sub": "user-1", "exp": now.Add(-10 * time.Second).Unix()})
+	_, err := strict.ValidateToken(justExpired)
+	assert.Equal(t, jwt.ErrExpiredToken, err)
+	_, err = lenient.ValidateToken(justExpired)
+	assert.NoError(t, err)
+
+	// Issued by a server whose clock runs slightly ahead
+	fromTheFuture := sign(gojwt.MapClaims{
+		"sub": "user-1",
+		"iat": now.Add(10 * time.Second).Unix(),
+		"nbf": now.Add(10 * time.Second).Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	})
+	_, err = strict.ValidateToken(fromTheFuture)
+	assert.Equal(t, jwt.ErrInvalidToken, err)
+	_, err = lenient.ValidateToken(fromTheFuture)
+	assert.NoError(t, err)
+
+	longExpired := sign(gojwt.MapClaims{"sub": "user-1", "exp": now.Add(-10 * time.Minute).Unix()})
+	_, err = lenient.ValidateToken(longExpired)
+	assert.Equal(t, jwt.ErrExpiredToken, err)
+}
+
+func TestValidateWithPublishedKeys(t *testing.T) {
+	keys := generateKeys(t)
+	signers := map[string]*jwt.Util{}
+	var set jwt.JWKSet
+	for alg, private := range keys {
+		key, err := jwt.NewSigningKey(private)
+		require.NoError(t, err)
+		signers[alg] = jwt.NewUtilWithKey(key, time.Hour, jwt.WithIssuer("https://idp.example.com"), jwt.WithAudience("client-1"))
+		set.Keys = append(set.Keys, signers[alg].JWKS().Keys...)
+	}
+
+	// Keys come over the wire as JSON
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	var published jwt.JWKSet
+	require.NoError(t, json.Unmarshal(data, &published))
+
+	verifier, err := jwt.NewUtilWithJWKS(published, jwt.WithIssuer("https://idp.example.com"), jwt.WithAudience("client-1"))
+	require.NoError(t, err)
+
+	for alg, signer := range signers {
+		token, err := signer.GenerateToken(map[string]interface{}{"sub": "user-1"})
+		require.NoError(t, err)
+		claims, err := verifier.ValidateToken(token)
+		require.NoError(t, err, alg)
+		assert.Equal(t, "user-1", claims["sub"])
+	}
+
+	// It can't issue tokens, and doesn't accept tokens signed with other keys
+	_, err = verifier.GenerateToken(map[string]interface{}{"sub": "user-1"})
+	assert.Equal(t, jwt.ErrNoSigningKey, err)
+
+	hmacToken, err := jwt.NewUtil("secret", time.Hour).GenerateToken(map[string]interface{}{"sub": "user-1"})
+	require.NoError(t, err)
+	_, err = verifier.ValidateToken(hmacToken)
+	assert.Equal(t, jwt.ErrInvalidToken, err)
+
+	// A key published with an "alg" only accepts that algorithm
+	rsaKey := keys["RS256"].(*rsa.PrivateKey)
+	pinned := signers["RS256"].JWKS()
+	pinned.Keys[0].Kid = ""
+	pinned.Keys[0].Alg = "PS256"
+	verifier, err = jwt.NewUtilWithJWKS(pinned)
+	require.NoError(t, err)
+
+	rs256, err := gojwt.NewWithClaims(gojwt.SigningMethodRS256, gojwt.MapClaims{"sub": "user-1"}).SignedString(rsaKey)
+	require.NoError(t, err)
+	_, err = verifier.ValidateToken(rs256)
+	assert.Equal(t, jwt.ErrInvalidToken, err)
+
+	ps256, err := gojwt.NewWithClaims(gojwt.SigningMethodPS256, gojwt.MapClaims{"sub": "user-1"}).SignedString(rsaKey)
+	require.NoError(t, err)
+	_, err = verifier.ValidateToken(ps256)
+	assert.NoError(t, err)
+
+	// Sets without usable signing keys are refused
+	_, err = jwt.NewUtilWithJWKS(jwt.JWKSet{Keys: []jwt.JWK{{Kty: "RSA", Use: "enc", N: "AQAB", E: "AQAB"}}})
+	assert.Equal(t, jwt.ErrUnsupportedKey, err)
+}
@@ -0,0 +1,187 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER identifier octets used by LDAPv3 (RFC 4511, section 5.1)
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	appBindRequest           = 0x60
+	appBindResponse          = 0x61
+	appUnbindRequest         = 0x42
+	appSearchRequest         = 0x63
+	appSearchResultEntry     = 0x64
+	appSearchResultDone      = 0x65
+	appSearchResultReference = 0x73
+
+	ctxSimpleAuth = 0x80 // [0] simple password in a BindRequest
+)
+
+// Largest message accepted from the other side; directory entries are small
+const maxMessageSize = 1 << 20
+
+// element is a decoded BER TLV. Constructed elements keep their contents
+// encoded; children parses them.
+type element struct {
+	tag   byte
+	value []byte
+}
+
+// encodes a TLV with the given identifier and contents
+func encode(tag byte, contents ...[]byte) []byte {
+	length := 0
+	for _, c := range contents {
+		length += len(c)
+	}
+
+	out := []byte{tag}
+	switch {
+	case length < 0x80:
+		out = append(out, byte(length))
+	case length <= 0xff:
+		out = append(out, 0x81, byte(length))
+	case length <= 0xffff:
+		out = append(out, 0x82, byte(length>>8), byte(length))
+	default:
+		out = append(out, 0x84, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	}
+	for _, c := range contents {
+		out = append(out, c...)
+	}
+	return out
+}
+
+// encodes a string as an OCTET STRING or a primitive context-specific value
+func encodeString(tag byte, s string) []byte {
+	return encode(tag, []byte(s))
+}
+
+// encodes an INTEGER or ENUMERATED in the fewest two's complement octets
+func encodeInt(tag byte, v int64) []byte {
+	var contents []byte
+	for {
+		contents = append([]byte{byte(v)}, contents...)
+		if (v < 0x80 && v >= -0x80) || len(contents) == 8 {
+			break
+		}
+		v >>= 8
+	}
+	return encode(tag, contents)
+}
+
+// encodes a BOOLEAN
+func encodeBool(v bool) []byte {
+	if v {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0x00})
+}
+
+// reads one element from a stream
+func readElement(r *bufio.Reader) (element, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return element{}, err
+	}
+	if tag&0x1f == 0x1f {
+		return element{}, errors.New("ldap: multi-byte BER tags are not supported")
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return element{}, unexpectedEOF(err)
+	}
+
+	length := int(first)
+	if first&0x80 != 0 {
+		octets := int(first & 0x7f)
+		if octets == 0 || octets > 4 {
+			return element{}, fmt.Errorf("ldap: unsupported BER length encoding 0x%02x", first)
+		}
+		length = 0
+		for i := 0; i < octets; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return element{}, unexpectedEOF(err)
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxMessageSize {
+		return element{}, fmt.Errorf("ldap: message of %d bytes is too large", length)
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return element{}, unexpectedEOF(err)
+	}
+	return element{tag: tag, value: value}, nil
+}
+
+// parses the contents of a constructed element into its children
+func (e element) children() ([]element, error) {
+	var children []element
+	data := e.value
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errors.New("ldap: truncated BER element")
+		}
+		tag, first := data[0], data[1]
+		data = data[2:]
+
+		length := int(first)
+		if first&0x80 != 0 {
+			octets := int(first & 0x7f)
+			if octets == 0 || octets > 4 || len(data) < octets {
+				return nil, errors.New("ldap: invalid BER length")
+			}
+			length = 0
+			for _, b := range data[:octets] {
+				length = length<<8 | int(b)
+			}
+			data = data[octets:]
+		}
+		if length > len(data) {
+			return nil, errors.New("ldap: truncated BER element")
+		}
+
+		children = append(children, element{tag: tag, value: data[:length]})
+		data = data[length:]
+	}
+	return children, nil
+}
+
+// decodes an INTEGER or ENUMERATED
+func (e element) int() (int64, error) {
+	if len(e.value) == 0 || len(e.value) > 8 {
+		return 0, errors.New("ldap: invalid BER integer")
+	}
+	v := int64(int8(e.value[0]))
+	for _, b := range e.value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// returns the contents of a primitive element as a string
+func (e element) string() string {
+	return string(e.value)
+}
+
+// the stream ending inside an element is an error, not a clean EOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,343 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choices (RFC 4511, section 4.5.1.7)
+const (
+	filterAnd            = 0xa0
+	filterOr             = 0xa1
+	filterNot            = 0xa2
+	filterEqualityMatch  = 0xa3
+	filterSubstrings     = 0xa4
+	filterGreaterOrEqual = 0xa5
+	filterLessOrEqual    = 0xa6
+	filterPresent        = 0x87
+	filterApproxMatch    = 0xa8
+
+	substringInitial = 0x80
+	substringAny     = 0x81
+	substringFinal   = 0x82
+)
+
+// filter is a parsed search filter
+type filter struct {
+	op        byte
+	children  []*filter // and, or and not
+	attribute string
+	value     string   // equality, ordering and approximate matches
+	initial   string   // substrings
+	any       []string // substrings
+	final     string   // substrings
+}
+
+// EscapeFilter escapes a value for use in a search filter (RFC 4515), so user
+// input like "*" or ")(uid=*" matches literally instead of changing the filter
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parses the string representation of a search filter (RFC 4515).
+// Extensible matches aren't supported.
+func parseFilter(s string) (*filter, error) {
+	f, rest, err := parseFilterItem(strings.TrimSpace(s), 0)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: unexpected %q after filter", rest)
+	}
+	return f, nil
+}
+
+// parses one parenthesized filter and returns the input that follows it
+func parseFilterItem(s string, depth int) (*filter, string, error) {
+	if depth > 32 {
+		return nil, "", fmt.Errorf("ldap: filter is nested too deeply")
+	}
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("ldap: filter must start with '(': %q", s)
+	}
+	s = s[1:]
+
+	if s != "" && (s[0] == '&' || s[0] == '|' || s[0] == '!') {
+		operator := s[0]
+		f := &filter{op: map[byte]byte{'&': filterAnd, '|': filterOr, '!': filterNot}[operator]}
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			child, rest, err := parseFilterItem(s, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			f.children = append(f.children, child)
+			s = rest
+		}
+		if !strings.HasPrefix(s, ")") {
+			return nil, "", fmt.Errorf("ldap: unterminated filter")
+		}
+		if len(f.children) == 0 || (operator == '!' && len(f.children) != 1) {
+			return nil, "", fmt.Errorf("ldap: wrong number of filters after '%c'", operator)
+		}
+		return f, s[1:], nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("ldap: unterminated filter")
+	}
+	f, err := parseComparison(s[:end])
+	if err != nil {
+		return nil, "", err
+	}
+	return f, s[end+1:], nil
+}
+
+// parses an attribute comparison such as "uid=alice", "cn=a*b" or "mail=*"
+func parseComparison(s string) (*filter, error) {
+	eq := strings.IndexByte(s, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", s)
+	}
+
+	attribute, value := s[:eq], s[eq+1:]
+	f := &filter{op: filterEqualityMatch}
+	switch attribute[len(attribute)-1] {
+	case '>':
+		f.op, attribute = filterGreaterOrEqual, attribute[:len(attribute)-1]
+	case '<':
+		f.op, attribute = filterLessOrEqual, attribute[:len(attribute)-1]
+	case '~':
+		f.op, attribute = filterApproxMatch, attribute[:len(attribute)-1]
+	case ':':
+		return nil, fmt.Errorf("ldap: extensible match filters are not supported")
+	}
+	if attribute == "" || strings.ContainsAny(attribute, "()=*\\ ") {
+		return nil, fmt.Errorf("ldap: invalid attribute in filter item %q", s)
+	}
+	f.attribute = attribute
+
+	if f.op == filterEqualityMatch && value == "*" {
+		f.op = filterPresent
+		return f, nil
+	}
+
+	parts := strings.Split(value, "*")
+	if len(parts) > 1 && f.op != filterEqualityMatch {
+		return nil, fmt.Errorf("ldap: wildcards are only allowed in equality filters: %q", s)
+	}
+	for i, part := range parts {
+		unescaped, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = unescaped
+	}
+
+	if len(parts) == 1 {
+		f.value = parts[0]
+		return f, nil
+	}
+
+	f.op = filterSubstrings
+	f.initial = parts[0]
+	f.final = parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		if part != "" {
+			f.any = append(f.any, part)
+		}
+	}
+	return f, nil
+}
+
+// decodes \XX escapes in a filter value
+func unescapeFilterValue(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		decoded, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// encodes the filter for a SearchRequest
+func (f *filter) encode() []byte {
+	switch f.op {
+	case filterAnd, filterOr, filterNot:
+		var children [][]byte
+		for _, child := range f.children {
+			children = append(children, child.encode())
+		}
+		return encode(f.op, children...)
+	case filterPresent:
+		return encodeString(filterPresent, f.attribute)
+	case filterSubstrings:
+		var substrings [][]byte
+		if f.initial != "" {
+			substrings = append(substrings, encodeString(substringInitial, f.initial))
+		}
+		for _, part := range f.any {
+			substrings = append(substrings, encodeString(substringAny, part))
+		}
+		if f.final != "" {
+			substrings = append(substrings, encodeString(substringFinal, f.final))
+		}
+		return encode(filterSubstrings,
+			encodeString(tagOctetString, f.attribute),
+			encode(tagSequence, substrings...))
+	default:
+		return encode(f.op,
+			encodeString(tagOctetString, f.attribute),
+			encodeString(tagOctetString, f.value))
+	}
+}
+
+// decodes a filter from a SearchRequest
+func decodeFilter(e element, depth int) (*filter, error) {
+	if depth > 32 {
+		return nil, fmt.Errorf("ldap: filter is nested too deeply")
+	}
+
+	f := &filter{op: e.tag}
+	switch e.tag {
+	case filterAnd, filterOr, filterNot:
+		children, err := e.children()
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			decoded, err := decodeFilter(child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			f.children = append(f.children, decoded)
+		}
+		if e.tag == filterNot && len(f.children) != 1 {
+			return nil, fmt.Errorf("ldap: invalid not filter")
+		}
+		return f, nil
+	case filterPresent:
+		f.attribute = e.string()
+		return f, nil
+	case filterSubstrings:
+		children, err := e.children()
+		if err != nil || len(children) != 2 {
+			return nil, fmt.Errorf("ldap: invalid substrings filter")
+		}
+		f.attribute = children[0].string()
+		substrings, err := children[1].children()
+		if err != nil {
+			return nil, err
+		}
+		for _, substring := range substrings {
+			switch substring.tag {
+			case substringInitial:
+				f.initial = substring.string()
+			case substringAny:
+				f.any = append(f.any, substring.string())
+			case substringFinal:
+				f.final = substring.string()
+			}
+		}
+		return f, nil
+	case filterEqualityMatch, filterGreaterOrEqual, filterLessOrEqual, filterApproxMatch:
+		children, err := e.children()
+		if err != nil || len(children) != 2 {
+			return nil, fmt.Errorf("ldap: invalid filter item")
+		}
+		f.attribute = children[0].string()
+		f.value = children[1].string()
+		return f, nil
+	}
+	return nil, fmt.Errorf("ldap: unsupported filter choice 0x%02x", e.tag)
+}
+
+// reports whether an entry matches the filter. Attribute names and values
+// are compared case-insensitively, like most directory attributes.
+func (f *filter) matches(entry *Entry) bool {
+	switch f.op {
+	case filterAnd:
+		for _, child := range f.children {
+			if !child.matches(entry) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range f.children {
+			if child.matches(entry) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !f.children[0].matches(entry)
+	case filterPresent:
+		return len(entry.Values(f.attribute)) > 0
+	}
+
+	for _, value := range entry.Values(f.attribute) {
+		value = strings.ToLower(value)
+		switch f.op {
+		case filterEqualityMatch, filterApproxMatch:
+			if value == strings.ToLower(f.value) {
+				return true
+			}
+		case filterGreaterOrEqual:
+			if value >= strings.ToLower(f.value) {
+				return true
+			}
+		case filterLessOrEqual:
+			if value <= strings.ToLower(f.value) {
+				return true
+			}
+		case filterSubstrings:
+			if matchesSubstrings(value, strings.ToLower(f.initial), f.any, strings.ToLower(f.final)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matches a lowercased value against the parts of a substrings filter
+func matchesSubstrings(value string, initial string, any []string, final string) bool {
+	if !strings.HasPrefix(value, initial) {
+		return false
+	}
+	value = value[len(initial):]
+	for _, part := range any {
+		i := strings.Index(value, strings.ToLower(part))
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, final)
+}
@@ -0,0 +1,333 @@
+// Package ldap implements the parts of LDAPv3 (RFC 4511) needed to authenticate
+// users against a directory: simple binds and searches. It also has an in-process
+// directory server for tests.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result codes (RFC 4511, appendix A)
+const (
+	ResultSuccess            = 0
+	ResultProtocolError      = 2
+	ResultSizeLimitExceeded  = 4
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+	ResultInsufficientAccess = 50
+	ResultUnwillingToPerform = 53
+)
+
+const (
+	protocolVersion   = 3
+	derefAliasesNever = 0
+	dialTimeout       = 10 * time.Second
+
+	// Searches returning more entries than this are cut short; logins need one or two
+	maxSearchEntries = 1000
+)
+
+// ErrInvalidCredentials is returned by Bind when the DN or password is wrong
+var ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+
+// ResultError is an operation the server answered with a result code other than success
+type ResultError struct {
+	Code    int
+	Message string // Diagnostic message from the server
+}
+
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// Scope of a search
+type Scope int
+
+const (
+	ScopeBaseObject   Scope = 0 // Only the base entry
+	ScopeSingleLevel  Scope = 1 // Direct children of the base entry
+	ScopeWholeSubtree Scope = 2 // The base entry and everything below it
+)
+
+// SearchRequest describes a search
+type SearchRequest struct {
+	BaseDN     string
+	Scope      Scope
+	Filter     string   // RFC 4515 filter, e.g. "(uid=alice)"; escape values with EscapeFilter
+	Attributes []string // Attributes to return; all user attributes if empty
+	SizeLimit  int      // Maximum number of entries; 0 for the server's limit
+}
+
+// Entry is a directory entry returned by a search
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// returns the values of an attribute, matching its name case-insensitively
+func (e *Entry) Values(attribute string) []string {
+	if values, ok := e.Attributes[attribute]; ok {
+		return values
+	}
+	for name, values := range e.Attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+// returns the first value of an attribute, or "" if it has none
+func (e *Entry) Value(attribute string) string {
+	values := e.Values(attribute)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Conn is a connection to a directory server. Operations run one at a time.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+	nextID int64
+}
+
+// connects to an ldap:// or ldaps:// URL. tlsConfig is used for ldaps:// and may be nil.
+// The context bounds the time spent connecting.
+func DialURL(ctx context.Context, rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL %q: %w", rawURL, err)
+	}
+
+	host := u.Host
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = u.Hostname()
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// authenticates the connection with a simple bind. Returns ErrInvalidCredentials
+// if the server rejects the DN or password. An empty password is refused without
+// asking the server, since servers treat it as an unauthenticated bind that always succeeds.
+func (c *Conn) Bind(ctx context.Context, dn string, password string) error {
+	if password == "" && dn != "" {
+		return ErrInvalidCredentials
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, err := c.send(ctx, encode(appBindRequest,
+		encodeInt(tagInteger, protocolVersion),
+		encodeString(tagOctetString, dn),
+		encodeString(ctxSimpleAuth, password)))
+	if err != nil {
+		return err
+	}
+
+	response, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if response.tag != appBindResponse {
+		return fmt.Errorf("ldap: unexpected response 0x%02x to bind", response.tag)
+	}
+
+	err = resultError(response)
+	var result *ResultError
+	if errors.As(err, &result) && result.Code == ResultInvalidCredentials {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// runs a search and returns the entries found. If the server stops early, for example
+// because SizeLimit was reached, the entries received so far are returned with the error.
+func (c *Conn) Search(ctx context.Context, request *SearchRequest) ([]*Entry, error) {
+	f, err := parseFilter(request.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes [][]byte
+	for _, attribute := range request.Attributes {
+		attributes = append(attributes, encodeString(tagOctetString, attribute))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, err := c.send(ctx, encode(appSearchRequest,
+		encodeString(tagOctetString, request.BaseDN),
+		encodeInt(tagEnumerated, int64(request.Scope)),
+		encodeInt(tagEnumerated, derefAliasesNever),
+		encodeInt(tagInteger, int64(request.SizeLimit)),
+		encodeInt(tagInteger, 0), // No time limit beyond the context's deadline
+		encodeBool(false),
+		f.encode(),
+		encode(tagSequence, attributes...)))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		response, err := c.receive(id)
+		if err != nil {
+			return entries, err
+		}
+
+		switch response.tag {
+		case appSearchResultEntry:
+			entry, err := decodeEntry(response)
+			if err != nil {
+				return entries, err
+			}
+			if len(entries) >= maxSearchEntries {
+				return entries, fmt.Errorf("ldap: more than %d search results", maxSearchEntries)
+			}
+			entries = append(entries, entry)
+		case appSearchResultReference:
+			// Referrals to other servers aren't followed
+		case appSearchResultDone:
+			return entries, resultError(response)
+		default:
+			return entries, fmt.Errorf("ldap: unexpected response 0x%02x to search", response.tag)
+		}
+	}
+}
+
+// ends the session and closes the connection
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.nextID++
+	c.conn.Write(encode(tagSequence, encodeInt(tagInteger, c.nextID), encode(appUnbindRequest)))
+	return c.conn.Close()
+}
+
+// sends a request as a new message and returns its message ID
+func (c *Conn) send(ctx context.Context, request []byte) (int64, error) {
+	deadline, _ := ctx.Deadline() // Zero, meaning none, without a deadline
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	c.nextID++
+	message := encode(tagSequence, encodeInt(tagInteger, c.nextID), request)
+	if _, err := c.conn.Write(message); err != nil {
+		return 0, err
+	}
+	return c.nextID, nil
+}
+
+// reads the next response, which must belong to the message with this ID
+func (c *Conn) receive(id int64) (element, error) {
+	message, err := readElement(c.reader)
+	if err != nil {
+		return element{}, err
+	}
+	if message.tag != tagSequence {
+		return element{}, errors.New("ldap: malformed message")
+	}
+
+	parts, err := message.children()
+	if err != nil || len(parts) < 2 {
+		return element{}, errors.New("ldap: malformed message")
+	}
+	messageID, err := parts[0].int()
+	if err != nil {
+		return element{}, err
+	}
+	if messageID != id {
+		// Message ID 0 is an unsolicited notification, usually that the server is disconnecting
+		return element{}, fmt.Errorf("ldap: unexpected message %d while waiting for %d", messageID, id)
+	}
+	return parts[1], nil
+}
+
+// converts the LDAPResult in a response to an error, nil on success
+func resultError(response element) error {
+	parts, err := response.children()
+	if err != nil || len(parts) < 3 {
+		return errors.New("ldap: malformed result")
+	}
+	code, err := parts[0].int()
+	if err != nil {
+		return err
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &ResultError{Code: int(code), Message: parts[2].string()}
+}
+
+// decodes a SearchResultEntry
+func decodeEntry(response element) (*Entry, error) {
+	parts, err := response.children()
+	if err != nil || len(parts) != 2 {
+		return nil, errors.New("ldap: malformed search result")
+	}
+
+	attributes, err := parts[1].children()
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{DN: parts[0].string(), Attributes: make(map[string][]string)}
+	for _, attribute := range attributes {
+		pair, err := attribute.children()
+		if err != nil || len(pair) != 2 {
+			return nil, errors.New("ldap: malformed search result attribute")
+		}
+		values, err := pair[1].children()
+		if err != nil {
+			return nil, err
+		}
+		name := pair[0].string()
+		for _, value := range values {
+			entry.Attributes[name] = append(entry.Attributes[name], value.string())
+		}
+	}
+	return entry, nil
+}
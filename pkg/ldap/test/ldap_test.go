@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/pkg/ldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDirectory(t *testing.T) *ldap.TestServer {
+	server := ldap.NewTestServer()
+	t.Cleanup(server.Close)
+	
+	server.AddEntry("cn=service,dc=example,dc=com", map[string][]string{
+		"objectClass":  {"person"},
+		"userPassword": {"service-secret"},
+	})
+	server.AddEntry("uid=alice,ou=people,dc=example,dc=com", map[string][]string{
+		"objectClass":  {"person", "inetOrgPerson"},
+		"uid":          {"alice"},
+		"cn":           {"Alice Liddell"},
+		"mail":         {"alice@example.com"},
+		"userPassword": {"alice-secret"},
+	})
+	server.AddEntry("uid=bob,ou=people,dc=example,dc=com", map[string][]string{
+		"objectClass": {"person"},
+		"uid":         {"bob"},
+		"cn":          {"Bob (Contractor)"},
+	})
+	server.AddEntry("uid=carol,ou=people,ou=archive,dc=example,dc=com", map[string][]string{
+		"objectClass": {"person"},
+		"uid":         {"carol"},
+	})
+	return server
+}
+
+func dial(t *testing.T, server *ldap.TestServer) *ldap.Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	conn, err := ldap.DialURL(ctx, server.URL, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBind(t *testing.T) {
+	server := newDirectory(t)
+	conn := dial(t, server)
+	ctx := context.Background()
+	
+	require.NoError(t, conn.Bind(ctx, "uid=alice,ou=people,dc=example,dc=com", "alice-secret"))
+	assert.Equal(t, 1, server.Binds())
+	
+	// DNs compare case-insensitively
+	require.NoError(t, conn.Bind(ctx, "UID=Alice, OU=People, DC=example, DC=com", "alice-secret"))
+	
+	assert.ErrorIs(t, conn.Bind(ctx, "uid=alice,ou=people,dc=example,dc=com", "wrong"), ldap.ErrInvalidCredentials)
+	assert.ErrorIs(t, conn.Bind(ctx, "uid=nobody,dc=example,dc=com", "alice-secret"), ldap.ErrInvalidCredentials)
+	
+	// An empty password would be an unauthenticated bind, which servers accept
+	assert.ErrorIs(t, conn.Bind(ctx, "uid=alice,ou=people,dc=example,dc=com", ""), ldap.ErrInvalidCredentials)
+	assert.Equal(t, 2, server.Binds())
+}
+
+func TestSearch(t *testing.T) {
+	server := newDirectory(t)
+	conn := dial(t, server)
+	ctx := context.Background()
+	
+	search := func(base string, scope ldap.Scope, filter string) []string {
+		entries, err := conn.Search(ctx, &ldap.SearchRequest{BaseDN: base, Scope: scope, Filter: filter})
+		require.NoError(t, err)
+		var dns []string
+		for _, entry := range entries {
+			dns = append(dns, entry.DN)
+		}
+		return dns
+	}
+	
+	// 1. Searching needs a bind
+	_, err := conn.Search(ctx, &ldap.SearchRequest{BaseDN: "dc=example,dc=com", Filter: "(uid=alice)"})
+	var result *ldap.ResultError
+	require.True(t, errors.As(err, &result))
+	assert.Equal(t, ldap.ResultInsufficientAccess, result.Code)
+	
+	require.NoError(t, conn.Bind(ctx, "cn=service,dc=example,dc=com", "service-secret"))
+	
+	// 2. Attributes come back without passwords
+	entries, err := conn.Search(ctx, &ldap.SearchRequest{
+		BaseDN: "dc=example,dc=com",
+		Scope:  ldap.ScopeWholeSubtree,
+		Filter: "(&(objectClass=person)(uid=alice))",
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", entries[0].DN)
+	assert.Equal(t, "alice@example.com", entries[0].Value("MAIL"))
+	assert.Equal(t, []string{"person", "inetOrgPerson"}, entries[0].Values("objectclass"))
+	assert.Empty(t, entries[0].Values("userPassword"))
+	
+	entries, err = conn.Search(ctx, &ldap.SearchRequest{
+		BaseDN:     "dc=example,dc=com",
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     "(uid=alice)",
+		Attributes: []string{"mail"},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Len(t, entries[0].Attributes, 1)
+	
+	// 3. Filters and scopes
+	base := "dc=example,dc=com"
+	people := "ou=people,dc=example,dc=com"
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(uid=*)"), 3)
+	assert.Len(t, search(people, ldap.ScopeSingleLevel, "(uid=*)"), 2)
+	assert.Empty(t, search(people, ldap.ScopeBaseObject, "(uid=*)"))
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(|(uid=alice)(uid=carol))"), 2)
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(&(uid=*)(!(mail=*)))"), 2)
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(cn=alice*)"), 1)
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(cn=*Lid*ell)"), 1)
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(uid>=bob)"), 2)
+	
+	// 4. Escaped values match literally
+	assert.Equal(t, `Bob \28Contractor\29`, ldap.EscapeFilter("Bob (Contractor)"))
+	assert.Len(t, search(base, ldap.ScopeWholeSubtree, "(cn="+ldap.EscapeFilter("Bob (Contractor)")+")"), 1)
+	assert.Empty(t, search(base, ldap.ScopeWholeSubtree, "(uid="+ldap.EscapeFilter("*")+")"))
+	assert.Empty(t, search(base, ldap.ScopeWholeSubtree, "(uid="+ldap.EscapeFilter("alice)(uid=*")+")"))
+	
+	// 5. Size limits return the entries sent so far
+	entries, err = conn.Search(ctx, &ldap.SearchRequest{BaseDN: base, Scope: ldap.ScopeWholeSubtree, Filter: "(uid=*)", SizeLimit: 2})
+	require.True(t, errors.As(err, &result))
+	assert.Equal(t, ldap.ResultSizeLimitExceeded, result.Code)
+	assert.Len(t, entries, 2)
+	
+	// 6. Invalid filters are refused before anything is sent
+	for _, filter := range []string{"", "uid=alice", "(uid=alice", "(&)", "(!(a=b)(c=d))", "(uid:dn:=alice)", `(uid=\zz)`} {
+		_, err := conn.Search(ctx, &ldap.SearchRequest{BaseDN: base, Filter: filter})
+		assert.Error(t, err, filter)
+	}
+}
@@ -0,0 +1,266 @@
+package ldap
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TestServer is an in-process directory server for tests. Simple binds are checked
+// against the userPassword attribute of its entries, and searches support every
+// filter this package can send. Other operations end the connection.
+type TestServer struct {
+	URL string // ldap:// URL to connect to
+
+	AnonymousSearch bool // Whether searches are allowed without binding first
+
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []*Entry
+	binds    int
+}
+
+// starts a directory server on a random local port
+func NewTestServer() *TestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &TestServer{
+		URL:      "ldap://" + listener.Addr().String(),
+		listener: listener,
+	}
+	go s.accept()
+	return s
+}
+
+// adds an entry. Give it a userPassword attribute to let it bind.
+func (s *TestServer) AddEntry(dn string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, &Entry{DN: dn, Attributes: attributes})
+}
+
+// removes an entry, as when a user is deleted from the directory
+func (s *TestServer) RemoveEntry(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range s.entries {
+		if entry.DN == dn {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// returns the number of successful authenticated binds so far
+func (s *TestServer) Binds() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.binds
+}
+
+// stops accepting connections
+func (s *TestServer) Close() {
+	s.listener.Close()
+}
+
+func (s *TestServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+// answers the requests on one connection until the client unbinds or sends something unsupported
+func (s *TestServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	bound := false
+	for {
+		message, err := readElement(reader)
+		if err != nil || message.tag != tagSequence {
+			return
+		}
+		parts, err := message.children()
+		if err != nil || len(parts) < 2 {
+			return
+		}
+		id, err := parts[0].int()
+		if err != nil {
+			return
+		}
+
+		switch parts[1].tag {
+		case appBindRequest:
+			bound = s.bind(conn, id, parts[1])
+		case appSearchRequest:
+			s.search(conn, id, parts[1], bound)
+		default:
+			return
+		}
+	}
+}
+
+// answers a bind request and reports whether the connection is now authenticated
+func (s *TestServer) bind(conn net.Conn, id int64, request element) bool {
+	fields, err := request.children()
+	if err != nil || len(fields) != 3 || fields[2].tag != ctxSimpleAuth {
+		writeResult(conn, id, appBindResponse, ResultProtocolError, "only simple binds are supported")
+		return false
+	}
+	dn, password := fields[1].string(), fields[2].string()
+
+	// Anonymous bind
+	if dn == "" && password == "" {
+		writeResult(conn, id, appBindResponse, ResultSuccess, "")
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if normalizeDN(entry.DN) != normalizeDN(dn) {
+			continue
+		}
+		stored := entry.Value("userPassword")
+		if password != "" && stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1 {
+			s.binds++
+			writeResult(conn, id, appBindResponse, ResultSuccess, "")
+			return true
+		}
+	}
+	writeResult(conn, id, appBindResponse, ResultInvalidCredentials, "")
+	return false
+}
+
+// answers a search request with the matching entries
+func (s *TestServer) search(conn net.Conn, id int64, request element, bound bool) {
+	fields, err := request.children()
+	if err != nil || len(fields) != 8 {
+		writeResult(conn, id, appSearchResultDone, ResultProtocolError, "malformed search request")
+		return
+	}
+	if !bound && !s.AnonymousSearch {
+		writeResult(conn, id, appSearchResultDone, ResultInsufficientAccess, "bind first")
+		return
+	}
+
+	base := normalizeDN(fields[0].string())
+	scope, _ := fields[1].int()
+	sizeLimit, _ := fields[3].int()
+	f, err := decodeFilter(fields[6], 0)
+	if err != nil {
+		writeResult(conn, id, appSearchResultDone, ResultProtocolError, err.Error())
+		return
+	}
+	requested, err := fields[7].children()
+	if err != nil {
+		writeResult(conn, id, appSearchResultDone, ResultProtocolError, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	var matches []*Entry
+	for _, entry := range s.entries {
+		if inScope(normalizeDN(entry.DN), base, Scope(scope)) && f.matches(entry) {
+			matches = append(matches, entry)
+		}
+	}
+	s.mu.Unlock()
+
+	for i, entry := range matches {
+		if sizeLimit > 0 && int64(i) == sizeLimit {
+			writeResult(conn, id, appSearchResultDone, ResultSizeLimitExceeded, "")
+			return
+		}
+		conn.Write(encode(tagSequence, encodeInt(tagInteger, id), encodeEntry(entry, requested)))
+	}
+	writeResult(conn, id, appSearchResultDone, ResultSuccess, "")
+}
+
+// encodes a SearchResultEntry with the requested attributes. Passwords are never returned.
+func encodeEntry(entry *Entry, requested []element) []byte {
+	names := make([]string, 0, len(entry.Attributes))
+	for name := range entry.Attributes {
+		if strings.EqualFold(name, "userPassword") {
+			continue
+		}
+		if len(requested) > 0 && !requestedAttribute(name, requested) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var attributes [][]byte
+	for _, name := range names {
+		var values [][]byte
+		for _, value := range entry.Attributes[name] {
+			values = append(values, encodeString(tagOctetString, value))
+		}
+		attributes = append(attributes, encode(tagSequence,
+			encodeString(tagOctetString, name),
+			encode(tagSet, values...)))
+	}
+
+	return encode(appSearchResultEntry,
+		encodeString(tagOctetString, entry.DN),
+		encode(tagSequence, attributes...))
+}
+
+// reports whether an attribute was asked for, by name or with "*"
+func requestedAttribute(name string, requested []element) bool {
+	for _, attribute := range requested {
+		if attribute.string() == "*" || strings.EqualFold(attribute.string(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// writes a response that is just an LDAPResult
+func writeResult(conn net.Conn, id int64, tag byte, code int, message string) {
+	conn.Write(encode(tagSequence,
+		encodeInt(tagInteger, id),
+		encode(tag,
+			encodeInt(tagEnumerated, int64(code)),
+			encodeString(tagOctetString, ""),
+			encodeString(tagOctetString, message))))
+}
+
+// reports whether an entry is within the scope of a search; both DNs must be normalized
+func inScope(dn string, base string, scope Scope) bool {
+	switch scope {
+	case ScopeBaseObject:
+		return dn == base
+	case ScopeSingleLevel:
+		_, parent, found := strings.Cut(dn, ",")
+		return found && parent == base
+	default:
+		return dn == base || base == "" || strings.HasSuffix(dn, ","+base)
+	}
+}
+
+// lowercases a DN and removes the spaces around its separators, which is
+// enough to compare the DNs tests use
+func normalizeDN(dn string) string {
+	rdns := strings.Split(strings.ToLower(dn), ",")
+	for i, rdn := range rdns {
+		attribute, value, _ := strings.Cut(rdn, "=")
+		rdns[i] = strings.TrimSpace(attribute) + "=" + strings.TrimSpace(value)
+	}
+	return strings.Join(rdns, ",")
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NBDor/Go-Auth-Service/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA1 test vectors from RFC 6238 appendix B, truncated to six digits
+func TestGenerateCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	
+	for unix, expected := range vectors {
+		code, err := totp.GenerateCode(secret, totp.Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	
+	now := time.Now()
+	code, err := totp.GenerateCode(secret, totp.Step(now))
+	require.NoError(t, err)
+	
+	step, ok := totp.Validate(secret, code, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+	
+	// Codes from the neighbouring steps are accepted, older ones aren't
+	_, ok = totp.Validate(secret, code, now.Add(totp.Period), 1)
+	assert.True(t, ok)
+	_, ok = totp.Validate(secret, code, now.Add(3*totp.Period), 1)
+	assert.False(t, ok)
+	
+	_, ok = totp.Validate(secret, "12345", now, 1)
+	assert.False(t, ok)
+	_, ok = totp.Validate("not base32!", "123456", now, 1)
+	assert.False(t, ok)
+}
+
+func TestKeyURI(t *testing.T) {
+	uri := totp.KeyURI("Example Co", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Example%20Co:alice@example.com?"))
+	
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	assert.Equal(t, "Example Co", parsed.Query().Get("issuer"))
+	assert.Equal(t, "6", parsed.Query().Get("digits"))
+}
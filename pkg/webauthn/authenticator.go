@@ -0,0 +1,182 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrCredentialExcluded = errors.New("authenticator already holds an excluded credential")
+	ErrNoCredential       = errors.New("authenticator holds no matching credential")
+)
+
+// SoftwareAuthenticator is a passkey authenticator that keeps ES256 keys in memory.
+// It plays the part of the browser and the authenticator in tests, producing the
+// same JSON a web page would post after navigator.credentials.create() and get().
+type SoftwareAuthenticator struct {
+	Origin       string // Origin the "browser" reports in client data
+	UserVerified bool   // Whether it claims to have verified the user, e.g. with a PIN
+	BackedUp     bool   // Whether its credentials are synced passkeys
+
+	credentials []*softwareCredential
+	mu          sync.Mutex
+}
+
+type softwareCredential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// creates an authenticator that verifies its user, for pages served from origin
+func NewSoftwareAuthenticator(origin string) *SoftwareAuthenticator {
+	return &SoftwareAuthenticator{
+		Origin:       origin,
+		UserVerified: true,
+	}
+}
+
+// creates a credential for the registration options, like navigator.credentials.create()
+func (a *SoftwareAuthenticator) Create(options *CreationOptions) (*CredentialCreation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(options.RP.ID, excluded.ID) != nil {
+			return nil, ErrCredentialExcluded
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	credential := &softwareCredential{
+		id:         id,
+		rpID:       options.RP.ID,
+		userHandle: append([]byte(nil), options.User.ID...),
+		key:        key,
+	}
+	a.credentials = append(a.credentials, credential)
+
+	// Attested credential data: AAGUID (all zeros for software), credential ID length, ID and public key
+	attested := make([]byte, 16, 18+len(id))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(attested, id...)
+	attested = append(attested, encodeES256PublicKey(&key.PublicKey)...)
+
+	authData := a.authenticatorData(credential, flagAttestedCredential)
+	authData = append(authData, attested...)
+
+	attestationObject := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+
+	return &CredentialCreation{
+		ID:    base64URL.EncodeToString(id),
+		RawID: id,
+		Type:  "public-key",
+		Response: AuthenticatorAttestationResponse{
+			ClientDataJSON:    a.clientData(clientDataCreate, options.Challenge),
+			AttestationObject: attestationObject,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// signs the login challenge with a matching credential, like navigator.credentials.get().
+// Without allowCredentials it picks the newest credential for the relying party, like a discoverable passkey.
+func (a *SoftwareAuthenticator) Get(options *RequestOptions) (*CredentialAssertion, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var credential *softwareCredential
+	if len(options.AllowCredentials) == 0 {
+		for _, candidate := range a.credentials {
+			if candidate.rpID == options.RPID {
+				credential = candidate
+			}
+		}
+	}
+	for _, allowed := range options.AllowCredentials {
+		if found := a.find(options.RPID, allowed.ID); found != nil {
+			credential = found
+			break
+		}
+	}
+	if credential == nil {
+		return nil, ErrNoCredential
+	}
+
+	credential.signCount++
+	authData := a.authenticatorData(credential, 0)
+	clientDataJSON := a.clientData(clientDataGet, options.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, credential.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &CredentialAssertion{
+		ID:    base64URL.EncodeToString(credential.id),
+		RawID: credential.id,
+		Type:  "public-key",
+		Response: AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        credential.userHandle,
+		},
+	}, nil
+}
+
+func (a *SoftwareAuthenticator) find(rpID string, id []byte) *softwareCredential {
+	for _, credential := range a.credentials {
+		if credential.rpID == rpID && bytes.Equal(credential.id, id) {
+			return credential
+		}
+	}
+	return nil
+}
+
+// builds authenticator data without attested credential data
+func (a *SoftwareAuthenticator) authenticatorData(credential *softwareCredential, flags byte) []byte {
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	if a.BackedUp {
+		flags |= flagBackupEligible | flagBackupState
+	}
+
+	rpIDHash := sha256.Sum256([]byte(credential.rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, credential.signCount)
+}
+
+func (a *SoftwareAuthenticator) clientData(ceremony string, challenge []byte) []byte {
+	clientDataJSON, _ := json.Marshal(CollectedClientData{
+		Type:      ceremony,
+		Challenge: base64URL.EncodeToString(challenge),
+		Origin:    a.Origin,
+	})
+	return clientDataJSON
+}
@@ -0,0 +1,210 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CBOR major types (RFC 8949)
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+)
+
+// Deepest nesting accepted when decoding; WebAuthn structures are only a few levels deep
+const cborMaxDepth = 16
+
+// decodes the first CBOR item in data and returns it with the bytes that follow it.
+// Only the subset WebAuthn uses is supported: integers (as int64), byte and text strings,
+// arrays, maps (keyed by int64 or string), booleans and null. Indefinite lengths and floats are rejected.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: CBOR nested too deeply", ErrVerificationFailed)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: truncated CBOR", ErrVerificationFailed)
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		}
+		return nil, nil, fmt.Errorf("%w: unsupported CBOR simple value %d", ErrVerificationFailed, info)
+	}
+
+	// Every other major type starts with an unsigned argument
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, fmt.Errorf("%w: truncated CBOR", ErrVerificationFailed)
+		}
+		for _, b := range data[:size] {
+			arg = arg<<8 | uint64(b)
+		}
+		data = data[size:]
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported CBOR length encoding", ErrVerificationFailed)
+	}
+
+	switch major {
+	case cborUnsigned:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: CBOR integer out of range", ErrVerificationFailed)
+		}
+		return int64(arg), data, nil
+
+	case cborNegative:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: CBOR integer out of range", ErrVerificationFailed)
+		}
+		return -1 - int64(arg), data, nil
+
+	case cborBytes, cborText:
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: truncated CBOR", ErrVerificationFailed)
+		}
+		value := data[:arg]
+		if major == cborText {
+			return string(value), data[arg:], nil
+		}
+		return append([]byte(nil), value...), data[arg:], nil
+
+	case cborArray:
+		// Every item takes at least one byte, which bounds the allocation
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: truncated CBOR", ErrVerificationFailed)
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			var err error
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+
+	case cborMap:
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: truncated CBOR", ErrVerificationFailed)
+		}
+		entries := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			var err error
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported CBOR map key", ErrVerificationFailed)
+			}
+			if _, exists := entries[key]; exists {
+				return nil, nil, fmt.Errorf("%w: duplicate CBOR map key", ErrVerificationFailed)
+			}
+
+			value, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			entries[key] = value
+		}
+		return entries, data, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: unsupported CBOR type %d", ErrVerificationFailed, major)
+}
+
+// encodes values of the types decodeCBOR returns. Map keys are written in
+// CTAP2 canonical order: shorter encodings first, then bytewise.
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return []byte{cborSimple<<5 | 22}
+	case bool:
+		if v {
+			return []byte{cborSimple<<5 | 21}
+		}
+		return []byte{cborSimple<<5 | 20}
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHeader(cborNegative, uint64(-1-v))
+		}
+		return cborHeader(cborUnsigned, uint64(v))
+	case []byte:
+		return append(cborHeader(cborBytes, uint64(len(v))), v...)
+	case string:
+		return append(cborHeader(cborText, uint64(len(v))), v...)
+	case []interface{}:
+		out := cborHeader(cborArray, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		type entry struct{ key, value []byte }
+		entries := make([]entry, 0, len(v))
+		for key, item := range v {
+			entries = append(entries, entry{encodeCBOR(key), encodeCBOR(item)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if len(entries[i].key) != len(entries[j].key) {
+				return len(entries[i].key) < len(entries[j].key)
+			}
+			return string(entries[i].key) < string(entries[j].key)
+		})
+
+		out := cborHeader(cborMap, uint64(len(v)))
+		for _, e := range entries {
+			out = append(out, e.key...)
+			out = append(out, e.value...)
+		}
+		return out
+	}
+	panic(fmt.Sprintf("webauthn: cannot encode %T as CBOR", value))
+}
+
+// writes the initial byte and argument of a CBOR item
+func cborHeader(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= math.MaxUint8:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
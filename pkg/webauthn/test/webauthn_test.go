@@ -0,0 +1,147 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/NBDor/Go-Auth-Service/pkg/webauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rp = &webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}}
+
+func creationOptions(t *testing.T) *webauthn.CreationOptions {
+	challenge, err := webauthn.GenerateChallenge()
+	require.NoError(t, err)
+
+	return &webauthn.CreationOptions{
+		RP:        webauthn.RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:      webauthn.UserEntity{ID: []byte("user-1"), Name: "alice", DisplayName: "alice"},
+		Challenge: challenge,
+	}
+}
+
+func requestOptions(t *testing.T) *webauthn.RequestOptions {
+	challenge, err := webauthn.GenerateChallenge()
+	require.NoError(t, err)
+
+	return &webauthn.RequestOptions{Challenge: challenge, RPID: rp.ID}
+}
+
+// register creates a credential through JSON, the way it travels from a browser
+func register(t *testing.T, authenticator *webauthn.SoftwareAuthenticator, options *webauthn.CreationOptions) *webauthn.CredentialCreation {
+	created, err := authenticator.Create(options)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(created)
+	require.NoError(t, err)
+	parsed, err := webauthn.ParseCredentialCreation(data)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	authenticator := webauthn.NewSoftwareAuthenticator("https://example.com")
+
+	// 1. Register a credential
+	options := creationOptions(t)
+	creation := register(t, authenticator, options)
+
+	challenge, err := creation.Challenge()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(options.Challenge), challenge)
+
+	credential, err := rp.VerifyRegistration(creation, options.Challenge, true)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(creation.RawID), credential.ID)
+	assert.Equal(t, "none", credential.AttestationFormat)
+	assert.Equal(t, uint32(0), credential.SignCount)
+
+	key, err := webauthn.ParsePublicKey(credential.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, webauthn.AlgES256, key.Algorithm)
+
+	// 2. Log in with it
+	request := requestOptions(t)
+	request.AllowCredentials = []webauthn.CredentialDescriptor{webauthn.Descriptor(credential.ID, nil)}
+	assertion, err := authenticator.Get(request)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(assertion)
+	require.NoError(t, err)
+	assertion, err = webauthn.ParseCredentialAssertion(data)
+	require.NoError(t, err)
+
+	authData, err := rp.VerifyAssertion(assertion, request.Challenge, credential.PublicKey, true)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), authData.SignCount)
+	assert.True(t, authData.UserVerified())
+	assert.Equal(t, []byte("user-1"), []byte(assertion.Response.UserHandle))
+
+	// 3. A tampered signature or a different challenge is refused
+	_, err = rp.VerifyAssertion(assertion, options.Challenge, credential.PublicKey, true)
+	assert.ErrorIs(t, err, webauthn.ErrChallengeMismatch)
+
+	assertion.Response.Signature[len(assertion.Response.Signature)-1] ^= 1
+	_, err = rp.VerifyAssertion(assertion, request.Challenge, credential.PublicKey, true)
+	assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+}
+
+func TestVerificationFailures(t *testing.T) {
+	// Another origin, e.g. a phishing site
+	phisher := webauthn.NewSoftwareAuthenticator("https://example.com.evil.test")
+	options := creationOptions(t)
+	_, err := rp.VerifyRegistration(register(t, phisher, options), options.Challenge, false)
+	assert.ErrorIs(t, err, webauthn.ErrOriginMismatch)
+
+	// Credentials scoped to another relying party
+	authenticator := webauthn.NewSoftwareAuthenticator("https://example.com")
+	options = creationOptions(t)
+	options.RP.ID = "other.example"
+	_, err = rp.VerifyRegistration(register(t, authenticator, options), options.Challenge, false)
+	assert.ErrorIs(t, err, webauthn.ErrRPIDMismatch)
+
+	// User verification required but not performed
+	authenticator.UserVerified = false
+	options = creationOptions(t)
+	creation := register(t, authenticator, options)
+	_, err = rp.VerifyRegistration(creation, options.Challenge, true)
+	assert.ErrorIs(t, err, webauthn.ErrUserNotVerified)
+
+	credential, err := rp.VerifyRegistration(creation, options.Challenge, false)
+	require.NoError(t, err)
+
+	// A registration response can't be replayed as a login
+	replayed := &webauthn.CredentialAssertion{
+		ID:    creation.ID,
+		RawID: creation.RawID,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    creation.Response.ClientDataJSON,
+			AuthenticatorData: creation.Response.AttestationObject,
+		},
+	}
+	_, err = rp.VerifyAssertion(replayed, options.Challenge, credential.PublicKey, false)
+	assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+
+	// Malformed input
+	_, err = webauthn.ParseCredentialCreation([]byte(`{"id":"AAAA","rawId":"AQID","type":"public-key"}`))
+	assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+
+	_, err = webauthn.ParseAuthenticatorData([]byte{1, 2, 3})
+	assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+
+	_, err = webauthn.ParsePublicKey([]byte{0xa1, 0x01})
+	assert.ErrorIs(t, err, webauthn.ErrVerificationFailed)
+}
+
+func TestExcludeCredentials(t *testing.T) {
+	authenticator := webauthn.NewSoftwareAuthenticator("https://example.com")
+	first := register(t, authenticator, creationOptions(t))
+
+	options := creationOptions(t)
+	options.ExcludeCredentials = []webauthn.CredentialDescriptor{webauthn.Descriptor(first.RawID, nil)}
+	_, err := authenticator.Create(options)
+	assert.ErrorIs(t, err, webauthn.ErrCredentialExcluded)
+}
@@ -0,0 +1,451 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrVerificationFailed is wrapped by every error about a malformed or untrustworthy response
+var ErrVerificationFailed = errors.New("WebAuthn verification failed")
+
+var (
+	ErrChallengeMismatch    = fmt.Errorf("%w: challenge mismatch", ErrVerificationFailed)
+	ErrOriginMismatch       = fmt.Errorf("%w: origin not allowed", ErrVerificationFailed)
+	ErrRPIDMismatch         = fmt.Errorf("%w: relying party ID mismatch", ErrVerificationFailed)
+	ErrUserNotPresent       = fmt.Errorf("%w: user not present", ErrVerificationFailed)
+	ErrUserNotVerified      = fmt.Errorf("%w: user not verified", ErrVerificationFailed)
+	ErrUnsupportedAlgorithm = fmt.Errorf("%w: unsupported algorithm", ErrVerificationFailed)
+	ErrInvalidSignature     = fmt.Errorf("%w: invalid signature", ErrVerificationFailed)
+)
+
+// Values of userVerification in ceremony options
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// Types of client data, one per ceremony
+const (
+	clientDataCreate = "webauthn.create"
+	clientDataGet    = "webauthn.get"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagBackupEligible     = 0x08
+	flagBackupState        = 0x10
+	flagAttestedCredential = 0x40
+	flagExtensionData      = 0x80
+)
+
+var base64URL = base64.RawURLEncoding.Strict()
+
+// Bytes is binary data encoded as unpadded base64url in JSON, as in the WebAuthn JSON serialization
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64URL.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	// Some clients pad their base64url
+	decoded, err := base64URL.DecodeString(string(bytes.TrimRight([]byte(s), "=")))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty is the service passkeys are registered with
+type RelyingParty struct {
+	ID      string   // Domain credentials are scoped to, e.g. example.com
+	Name    string   // Shown to users by their authenticator
+	Origins []string // Web origins allowed to run ceremonies, e.g. https://example.com
+}
+
+// RelyingPartyEntity identifies the relying party in registration options
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity identifies the account a credential is created for
+type UserEntity struct {
+	ID          Bytes  `json:"id"` // User handle, returned by discoverable credentials when logging in
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter offers a signature algorithm for new credentials
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states what kind of authenticator a registration asks for
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are the options for navigator.credentials.create(), in their JSON form
+type CreationOptions struct {
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              Bytes                  `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"` // Milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options for navigator.credentials.get(), in their JSON form
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"` // Milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AuthenticatorAttestationResponse is the authenticator's part of a new credential
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    Bytes    `json:"clientDataJSON"`
+	AttestationObject Bytes    `json:"attestationObject"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// CredentialCreation is the result of navigator.credentials.create(), as serialized by PublicKeyCredential.toJSON()
+type CredentialCreation struct {
+	ID       string                           `json:"id"`
+	RawID    Bytes                            `json:"rawId"`
+	Type     string                           `json:"type"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+// AuthenticatorAssertionResponse is the authenticator's part of a login
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON"`
+	AuthenticatorData Bytes `json:"authenticatorData"`
+	Signature         Bytes `json:"signature"`
+	UserHandle        Bytes `json:"userHandle,omitempty"`
+}
+
+// CredentialAssertion is the result of navigator.credentials.get(), as serialized by PublicKeyCredential.toJSON()
+type CredentialAssertion struct {
+	ID       string                         `json:"id"`
+	RawID    Bytes                          `json:"rawId"`
+	Type     string                         `json:"type"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+// CollectedClientData is what the browser signs over along with the authenticator data
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// AuthenticatorData is the authenticator's signed statement about a ceremony
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Only set during registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key
+}
+
+func (d *AuthenticatorData) UserPresent() bool    { return d.Flags&flagUserPresent != 0 }
+func (d *AuthenticatorData) UserVerified() bool   { return d.Flags&flagUserVerified != 0 }
+func (d *AuthenticatorData) BackupEligible() bool { return d.Flags&flagBackupEligible != 0 }
+func (d *AuthenticatorData) BackupState() bool    { return d.Flags&flagBackupState != 0 }
+
+// Credential is a newly registered credential, ready to be stored
+type Credential struct {
+	ID                []byte
+	PublicKey         []byte // COSE_Key
+	SignCount         uint32
+	Transports        []string
+	BackupEligible    bool // Synced passkey that can be restored on other devices
+	BackupState       bool
+	AttestationFormat string
+}
+
+// generates a random challenge for a ceremony
+func GenerateChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// describes an existing credential for excludeCredentials and allowCredentials
+func Descriptor(credentialID []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: "public-key", ID: credentialID, Transports: transports}
+}
+
+// decodes the JSON a browser sends after navigator.credentials.create()
+func ParseCredentialCreation(data []byte) (*CredentialCreation, error) {
+	var creation CredentialCreation
+	if err := json.Unmarshal(data, &creation); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	if err := checkCredentialID(creation.Type, creation.ID, creation.RawID); err != nil {
+		return nil, err
+	}
+	return &creation, nil
+}
+
+// decodes the JSON a browser sends after navigator.credentials.get()
+func ParseCredentialAssertion(data []byte) (*CredentialAssertion, error) {
+	var assertion CredentialAssertion
+	if err := json.Unmarshal(data, &assertion); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	if err := checkCredentialID(assertion.Type, assertion.ID, assertion.RawID); err != nil {
+		return nil, err
+	}
+	return &assertion, nil
+}
+
+func checkCredentialID(credentialType string, id string, rawID []byte) error {
+	if credentialType != "public-key" {
+		return fmt.Errorf("%w: unexpected credential type %q", ErrVerificationFailed, credentialType)
+	}
+	if len(rawID) == 0 || len(rawID) > 1023 || id != base64URL.EncodeToString(rawID) {
+		return fmt.Errorf("%w: invalid credential ID", ErrVerificationFailed)
+	}
+	return nil
+}
+
+// returns the challenge the browser signed, so the server can look up the ceremony it belongs to.
+// The challenge still has to be checked by VerifyRegistration.
+func (c *CredentialCreation) Challenge() ([]byte, error) {
+	return clientDataChallenge(c.Response.ClientDataJSON)
+}
+
+// returns the challenge the browser signed, so the server can look up the ceremony it belongs to.
+// The challenge still has to be checked by VerifyAssertion.
+func (a *CredentialAssertion) Challenge() ([]byte, error) {
+	return clientDataChallenge(a.Response.ClientDataJSON)
+}
+
+func clientDataChallenge(clientDataJSON []byte) ([]byte, error) {
+	var clientData CollectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return nil, fmt.Errorf("%w: invalid client data", ErrVerificationFailed)
+	}
+
+	challenge, err := base64URL.DecodeString(clientData.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, fmt.Errorf("%w: invalid challenge", ErrVerificationFailed)
+	}
+	return challenge, nil
+}
+
+// checks a new credential against the challenge issued for its registration (WebAuthn Level 2, section 7.1).
+// Attestation statements aren't verified, so nothing is known about the authenticator's make or model;
+// only "none" attestation is checked for well-formedness.
+func (rp *RelyingParty) VerifyRegistration(creation *CredentialCreation, challenge []byte, requireUserVerification bool) (*Credential, error) {
+	if err := rp.verifyClientData(creation.Response.ClientDataJSON, clientDataCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, rest, err := decodeCBOR(creation.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: invalid attestation object", ErrVerificationFailed)
+	}
+
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := attestation["authData"].([]byte)
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, fmt.Errorf("%w: invalid attestation object", ErrVerificationFailed)
+	}
+	if format == "none" && len(statement) != 0 {
+		return nil, fmt.Errorf("%w: unexpected attestation statement", ErrVerificationFailed)
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrVerificationFailed)
+	}
+	if !bytes.Equal(authData.CredentialID, creation.RawID) {
+		return nil, fmt.Errorf("%w: credential ID mismatch", ErrVerificationFailed)
+	}
+	if _, err := ParsePublicKey(authData.PublicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:                authData.CredentialID,
+		PublicKey:         authData.PublicKey,
+		SignCount:         authData.SignCount,
+		Transports:        creation.Response.Transports,
+		BackupEligible:    authData.BackupEligible(),
+		BackupState:       authData.BackupState(),
+		AttestationFormat: format,
+	}, nil
+}
+
+// checks a login against the challenge issued for it and the public key of the credential
+// it claims to come from (WebAuthn Level 2, section 7.2). The caller has to make sure the
+// credential belongs to the user logging in and compare the signature counter with the stored one.
+func (rp *RelyingParty) VerifyAssertion(assertion *CredentialAssertion, challenge []byte, publicKey []byte, requireUserVerification bool) (*AuthenticatorData, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyClientData(assertion.Response.ClientDataJSON, clientDataGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := ParseAuthenticatorData(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(assertion.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), assertion.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.Verify(signed, assertion.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	return authData, nil
+}
+
+// checks the ceremony type, challenge and origin the browser recorded
+func (rp *RelyingParty) verifyClientData(clientDataJSON []byte, expectedType string, challenge []byte) error {
+	var clientData CollectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return fmt.Errorf("%w: invalid client data", ErrVerificationFailed)
+	}
+	if clientData.Type != expectedType {
+		return fmt.Errorf("%w: unexpected client data type %q", ErrVerificationFailed, clientData.Type)
+	}
+
+	signedChallenge, err := base64URL.DecodeString(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(signedChallenge, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	// Cross-origin iframes aren't supported
+	if clientData.CrossOrigin {
+		return ErrOriginMismatch
+	}
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+// checks the parts of the authenticator data that don't depend on the ceremony
+func (rp *RelyingParty) verifyAuthenticatorData(authData *AuthenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return ErrRPIDMismatch
+	}
+	if !authData.UserPresent() {
+		return ErrUserNotPresent
+	}
+	if requireUserVerification && !authData.UserVerified() {
+		return ErrUserNotVerified
+	}
+	if authData.BackupState() && !authData.BackupEligible() {
+		return fmt.Errorf("%w: invalid backup flags", ErrVerificationFailed)
+	}
+	return nil
+}
+
+// decodes authenticator data (WebAuthn Level 2, section 6.1)
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrVerificationFailed)
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&flagAttestedCredential != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: truncated attested credential data", ErrVerificationFailed)
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential ID", ErrVerificationFailed)
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The public key is followed by extensions, if any, so its length is only known after decoding it
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.PublicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if authData.Flags&flagExtensionData != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing data after authenticator data", ErrVerificationFailed)
+	}
+	return authData, nil
+}